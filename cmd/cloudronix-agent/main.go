@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -11,6 +12,9 @@ import (
 	"github.com/cloudronix/agent/internal/enroll"
 )
 
+// exitRestart is the exit code used when the agent exits to be restarted
+const exitRestart = 3
+
 var (
 	version = "0.1.0"
 	cfgFile string
@@ -34,22 +38,39 @@ and reports system metrics to the central dashboard.`,
 	rootCmd.AddCommand(enrollCmd())
 	rootCmd.AddCommand(runCmd())
 	rootCmd.AddCommand(statusCmd())
+	rootCmd.AddCommand(doctorCmd())
 	rootCmd.AddCommand(installCmd())
 	rootCmd.AddCommand(uninstallCmd())
+	rootCmd.AddCommand(unenrollCmd())
+	rootCmd.AddCommand(renewCmd())
+	rootCmd.AddCommand(configCmd())
+	rootCmd.AddCommand(runOnceCmd())
+	rootCmd.AddCommand(validateLibraryCmd())
+	rootCmd.AddCommand(runPlaybookCmd())
+	rootCmd.AddCommand(maintenanceRebootCmd())
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
+		if errors.Is(err, agent.ErrRestartRequested) {
+			// Distinct exit code; the service manager restarts the agent
+			os.Exit(exitRestart)
+		}
 		os.Exit(1)
 	}
 }
 
 func enrollCmd() *cobra.Command {
+	var pins []string
+
 	cmd := &cobra.Command{
 		Use:   "enroll <token>",
 		Short: "Enroll this device with Cloudronix",
 		Long: `Enroll this device using a one-time enrollment token.
 
-Generate the token from the Cloudronix web dashboard under Devices > Add Device.`,
+Generate the token from the Cloudronix web dashboard under Devices > Add Device.
+
+Use --pin to only send the token to a server whose certificate chain
+contains the pinned public key; the pins are kept for later connections.`,
 		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			token := args[0]
@@ -59,10 +80,13 @@ Generate the token from the Cloudronix web dashboard under Devices > Add Device.
 				return fmt.Errorf("failed to load config: %w", err)
 			}
 
+			cfg.PinnedSPKI = append(cfg.PinnedSPKI, pins...)
 			return enroll.Enroll(cfg, token)
 		},
 	}
 
+	cmd.Flags().StringSliceVar(&pins, "pin", nil, "server public key pin (sha256/<base64 SPKI hash>), may be repeated")
+
 	return cmd
 }
 
@@ -85,6 +109,8 @@ func runCmd() *cobra.Command {
 }
 
 func statusCmd() *cobra.Command {
+	var asJSON bool
+
 	cmd := &cobra.Command{
 		Use:   "status",
 		Short: "Show agent status",
@@ -94,13 +120,61 @@ func statusCmd() *cobra.Command {
 				return fmt.Errorf("failed to load config: %w", err)
 			}
 
-			return agent.Status(cfg)
+			return agent.Status(cfg, asJSON)
 		},
 	}
 
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the status as a JSON object")
+
 	return cmd
 }
 
+func renewCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "renew",
+		Short: "Renew the device certificate now",
+		Long: `Generate a new device key and have the server issue a certificate for it,
+authenticated with the current certificate. The current key and certificate
+are only replaced once the server has accepted the new ones.
+
+The running agent renews automatically when the certificate is within
+cert_renew_days (default 30) of expiry.`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load(cfgFile)
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			return agent.RenewCertificate(cfg)
+		},
+	}
+}
+
+func doctorCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "doctor",
+		Aliases: []string{"diagnose"},
+		Short:   "Diagnose enrollment and connectivity problems",
+		Long: `Check that the config directory is writable, the credentials parse and the
+device certificate is not expired, the server public key is valid, both server
+URLs resolve and accept connections, and the local clock agrees with the
+server. Exits non-zero if any check fails.`,
+		// Failed checks are not usage errors, and main prints the summary
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load(cfgFile)
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			return agent.Doctor(cfg)
+		},
+	}
+}
+
 func installCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "install",
@@ -142,3 +216,199 @@ This will stop the service, remove it from the system, and delete the configurat
 
 	return cmd
 }
+
+func unenrollCmd() *cobra.Command {
+	var opts agent.UnenrollOptions
+
+	cmd := &cobra.Command{
+		Use:   "unenroll",
+		Short: "Remove device credentials but keep the service installed",
+		Long: `Disconnect this device from its Cloudronix server.
+
+This removes the device certificate, keys and device ID, but leaves the
+installed service and binary in place so the device can be enrolled again,
+optionally against a different server.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load(cfgFile)
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			return agent.Unenroll(cfg, opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.ServerURL, "server-url", "", "server URL to enroll against next")
+	cmd.Flags().StringVar(&opts.AgentURL, "agent-url", "", "agent API URL to use after re-enrollment")
+	cmd.Flags().BoolVar(&opts.SkipNotify, "no-notify", false, "don't notify the server that the device is leaving")
+
+	return cmd
+}
+
+func maintenanceRebootCmd() *cobra.Command {
+	var name, policy string
+	var spread int
+
+	cmd := &cobra.Command{
+		Use:   "maintenance-reboot",
+		Short: "Reboot for a maintenance window (run by the installed timers)",
+		Long: `Reboot the device for a maintenance window set up by the maintenance_window
+playbook action. With --policy if_pending (the default) the device only
+reboots when the system reports a pending reboot.`,
+		Hidden: true,
+		Args:   cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return agent.MaintenanceReboot(name, policy, spread)
+		},
+	}
+
+	cmd.Flags().StringVar(&name, "name", "", "maintenance window name, shown in the reboot message")
+	cmd.Flags().StringVar(&policy, "policy", "if_pending", "if_pending or always")
+	cmd.Flags().IntVar(&spread, "spread", 0, "reboot at a random time within this many minutes")
+
+	return cmd
+}
+
+func runPlaybookCmd() *cobra.Command {
+	var opts agent.RunPlaybookOptions
+
+	cmd := &cobra.Command{
+		Use:   "run-playbook <file>",
+		Short: "Run a signed playbook from a file on this device",
+		Long: `Run a signed playbook payload (JSON or YAML, in the form the server sends to
+agents) on this device without going through the job queue, and print the
+execution report as JSON.
+
+The playbook is verified against the server public key pinned at enrollment,
+exactly as for jobs: unsigned, tampered or unapproved files are rejected.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load(cfgFile)
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			return agent.RunPlaybook(cfg, args[0], opts)
+		},
+	}
+
+	cmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "simulate the playbook without making changes")
+	cmd.Flags().StringSliceVar(&opts.Tags, "tags", nil, "only run tasks with these tags")
+	cmd.Flags().StringSliceVar(&opts.SkipTags, "skip-tags", nil, "skip tasks with these tags")
+
+	return cmd
+}
+
+func validateLibraryCmd() *cobra.Command {
+	var keyPath string
+
+	cmd := &cobra.Command{
+		Use:   "validate-library <dir>",
+		Short: "Check a directory of signed playbooks offline",
+		Long: `Check every signed playbook payload (*.json) in a directory the way the
+agent does before running it: hash, signature and approval status, parsing,
+and platform compatibility. Prints which playbooks would run on which
+platforms. Nothing is executed.
+
+Uses the server public key pinned at enrollment unless --public-key is given.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load(cfgFile)
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			return agent.ValidateLibrary(cfg, args[0], keyPath)
+		},
+	}
+
+	cmd.Flags().StringVar(&keyPath, "public-key", "", "raw Ed25519 server public key file (default: the enrolled server key)")
+
+	return cmd
+}
+
+func configCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Change agent settings",
+	}
+
+	setCmd := &cobra.Command{
+		Use:   "set <key> <value>",
+		Short: "Set a config value",
+		Long: `Set a value in config.json by its key, e.g. 'default_shell bash'.
+
+Labels attached to reports and metrics are set with 'labels.<name>', e.g.
+'config set labels.environment production'. An empty value removes the label.
+
+Restart the agent for changes to take effect.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load(cfgFile)
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			if err := cfg.Set(args[0], args[1]); err != nil {
+				return err
+			}
+			if err := cfg.Save(); err != nil {
+				return err
+			}
+
+			fmt.Printf("Set %s\n", args[0])
+			return nil
+		},
+	}
+
+	cmd.AddCommand(setCmd)
+	return cmd
+}
+
+func runOnceCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "run-once",
+		Short: "Manage the markers of run_once commands",
+		Long: `Command tasks with run_once run only once per device: the agent records a
+marker after they first succeed and skips them afterwards. Resetting a marker
+makes its command run again, e.g. when testing a bootstrap playbook.`,
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List commands that already ran",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load(cfgFile)
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			return agent.ListRunOnce(cfg)
+		},
+	}
+
+	var all bool
+	resetCmd := &cobra.Command{
+		Use:   "reset <name>...",
+		Short: "Let run_once commands run again",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if all == (len(args) > 0) {
+				return fmt.Errorf("give marker names or --all")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load(cfgFile)
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			return agent.ResetRunOnce(cfg, args, all)
+		},
+	}
+	resetCmd.Flags().BoolVar(&all, "all", false, "remove every marker")
+
+	cmd.AddCommand(listCmd, resetCmd)
+	return cmd
+}
@@ -2,15 +2,19 @@ package agent
 
 import (
 	"context"
-	"crypto/ed25519"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
+	"runtime"
+	"sort"
 	"syscall"
 	"time"
 
 	"github.com/cloudronix/agent/internal/client"
 	"github.com/cloudronix/agent/internal/config"
+	"github.com/cloudronix/agent/internal/enroll"
 	"github.com/cloudronix/agent/pkg/playbook"
 	"github.com/cloudronix/agent/pkg/sysinfo"
 )
@@ -27,6 +31,18 @@ func Run(cfg *config.Config) error {
 	if !cfg.IsEnrolled() {
 		return fmt.Errorf("device is not enrolled\nRun 'cloudronix-agent enroll <token>' first")
 	}
+	if cfg.CertRevoked {
+		return fmt.Errorf("%w\nRun 'cloudronix-agent enroll <token>' to enroll again", ErrCertificateRevoked)
+	}
+
+	// Write output to a size-rotated log file if configured
+	if cfg.LogFile != "" {
+		restore, err := redirectOutput(cfg)
+		if err != nil {
+			return err
+		}
+		defer restore()
+	}
 
 	// Check if running as Windows Service
 	if IsWindowsService() {
@@ -44,6 +60,11 @@ func runAgent(cfg *config.Config, stopCh <-chan struct{}) error {
 	fmt.Printf("Device ID: %s\n", cfg.DeviceID)
 	fmt.Printf("Agent URL: %s\n", cfg.AgentURL)
 
+	// Credentials may be mid-swap if a renewal was interrupted
+	if err := enroll.RecoverRenewal(cfg); err != nil {
+		return err
+	}
+
 	// Create API client
 	apiClient, err := client.NewClient(cfg)
 	if err != nil {
@@ -59,9 +80,22 @@ func runAgent(cfg *config.Config, stopCh <-chan struct{}) error {
 
 	fmt.Printf("Connected! Device name: %s\n", serverConfig.DeviceName)
 
-	// Update intervals from server
-	heartbeatInterval := time.Duration(serverConfig.HeartbeatIntervalSeconds) * time.Second
-	reportInterval := time.Duration(serverConfig.ReportIntervalSeconds) * time.Second
+	// Refuse to start with a revoked certificate
+	if cfg.CertStatusInterval > 0 {
+		if err := checkCertStatus(context.Background(), cfg, apiClient); errors.Is(err, ErrCertificateRevoked) {
+			return err
+		} else if err != nil {
+			fmt.Printf("Warning: certificate status check failed: %v\n", err)
+		}
+	}
+
+	sysinfo.Configure(collectorOptions(cfg, serverConfig))
+
+	// Server-provided intervals override the local config
+	intervals := effectiveIntervals(cfg, serverConfig)
+	heartbeatInterval := intervals.Heartbeat.Effective()
+	reportInterval := intervals.Report.Effective()
+	fmt.Printf("Intervals: heartbeat %v, report %v\n", intervals.Heartbeat, intervals.Report)
 
 	// Create context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
@@ -77,26 +111,59 @@ func runAgent(cfg *config.Config, stopCh <-chan struct{}) error {
 		cancel()
 	}()
 
+	// Handle Windows Service stop signal
+	if stopCh != nil {
+		go func() {
+			<-stopCh
+			fmt.Println("Service stop signal received")
+			cancel()
+		}()
+	}
+
+	// Spread the first contact of agents that start together
+	jitterPct := intervals.JitterPercent
+	if delay := startupJitter(heartbeatInterval, jitterPct); delay > 0 {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+	}
+
 	// Send initial report
 	fmt.Println("Sending initial system report...")
-	info := sysinfo.Collect()
+	info := sysinfo.Collect(ctx)
 	info.AgentVersion = agentVersion
+	if ctx.Err() != nil {
+		return nil // shut down during collection
+	}
+	// Tracks the security posture sent to the server, to report changes early
+	security := &securityMonitor{}
+
 	if err := apiClient.SendReport(info); err != nil {
 		fmt.Printf("Warning: failed to send initial report: %v\n", err)
+	} else {
+		security.reportSent(info)
 	}
 
-	// Initialize job runner if server public key is available
+	// Signalled when a control playbook requests an agent restart
+	restartCh := make(chan struct{}, 1)
+
+	// Initialize job runner if server public key is available.
+	// jobsDisabled explains why jobs can't run when there is no runner.
 	var jobRunner *JobRunner
+	var jobsDisabled string
 	if cfg.HasServerPublicKey() {
-		pubKeyBytes, err := cfg.LoadServerPublicKey()
+		serverKeys, err := cfg.LoadServerPublicKeys()
 		if err != nil {
 			fmt.Printf("Warning: failed to load server public key: %v\n", err)
 			fmt.Println("Playbook execution disabled - jobs will not be processed")
-		} else if len(pubKeyBytes) == ed25519.PublicKeySize {
+			jobsDisabled = fmt.Sprintf("failed to load server public key: %v", err)
+		} else {
 			jobRunner, err = NewJobRunner(JobRunnerConfig{
-				Config:          cfg,
-				APIClient:       apiClient,
-				ServerPublicKey: ed25519.PublicKey(pubKeyBytes),
+				Config:           cfg,
+				APIClient:        apiClient,
+				ServerPublicKeys: serverKeys,
 				OnJobStart: func(job *client.PendingJob) {
 					fmt.Printf("[JOB] Starting job %s: %s\n", job.JobID, job.PlaybookName)
 				},
@@ -106,44 +173,108 @@ func runAgent(cfg *config.Config, stopCh <-chan struct{}) error {
 				OnJobError: func(job *client.PendingJob, err error) {
 					fmt.Printf("[JOB] Job %s failed: %v\n", job.JobID, err)
 				},
+				OnRestartRequested: func() {
+					select {
+					case restartCh <- struct{}{}:
+					default:
+					}
+				},
 			})
 			if err != nil {
 				fmt.Printf("Warning: failed to create job runner: %v\n", err)
 				fmt.Println("Playbook execution disabled")
+				jobsDisabled = fmt.Sprintf("failed to create job runner: %v", err)
 			} else {
-				fmt.Println("Playbook execution enabled")
+				fmt.Printf("Playbook execution enabled (%d trusted signing key(s))\n", len(serverKeys))
 			}
-		} else {
-			fmt.Printf("Warning: invalid server public key size (%d bytes, expected %d)\n",
-				len(pubKeyBytes), ed25519.PublicKeySize)
 		}
 	} else {
 		fmt.Println("Note: No server public key found - playbook execution disabled")
 		fmt.Println("Re-enroll to enable playbook execution")
+		jobsDisabled = "no server public key found"
 	}
 
-	// Connect to WebSocket for real-time job notifications
+	// Tell the server which playbooks this agent can run
+	sendCapabilities(apiClient, jobRunner)
+
+	// Connect to WebSocket for real-time job notifications. While it is
+	// down, wsDone is nil and wsReconnect fires the next attempt.
 	wsClient := client.NewWebSocketClient(cfg)
+	defer wsClient.Close()
+	fmt.Printf("Connecting to WebSocket: %s\n", wsClient.URL())
+	var wsDone <-chan struct{}
+	var wsBackoff reconnectBackoff
+	wsReconnect := time.NewTimer(0)
+	wsReconnect.Stop()
+	defer wsReconnect.Stop()
 	if err := wsClient.Connect(ctx); err != nil {
 		fmt.Printf("Warning: WebSocket connection failed: %v\n", err)
 		fmt.Println("Falling back to polling mode")
+		wsReconnect.Reset(wsBackoff.next())
 	} else {
-		defer wsClient.Close()
+		wsDone = wsClient.Done()
 	}
 
-	// Start heartbeat, report, and metrics loops
-	heartbeatTicker := time.NewTicker(heartbeatInterval)
-	reportTicker := time.NewTicker(reportInterval)
+	// Start heartbeat, report, and metrics loops. Each period is jittered.
+	heartbeatTicker := newJitterTicker(heartbeatInterval, jitterPct)
+	reportTicker := newJitterTicker(reportInterval, jitterPct)
 	// Metrics collected every 5 seconds for real-time monitoring
-	metricsTicker := time.NewTicker(5 * time.Second)
-	// Fallback polling (in case WebSocket is down)
-	jobPollTicker := time.NewTicker(30 * time.Second)
+	metricsTicker := newJitterTicker(5*time.Second, jitterPct)
+	// Signed diagnostic commands share the playbook trust anchor
+	var commander *remoteCommander
+	commanderErr := "no server public key to verify commands"
+	if jobRunner != nil {
+		var err error
+		if commander, err = newRemoteCommander(cfg, jobRunner.ServerPublicKeys, wsClient); err != nil {
+			fmt.Printf("Warning: remote commands unavailable: %v\n", err)
+			commanderErr = err.Error()
+		}
+	}
+
+	// Fallback polling, relaxed while WebSocket notifications work
+	jobPollTicker := newJitterTicker(jobPollIntervalWSDown, jitterPct)
+	if wsDone != nil {
+		jobPollTicker.setPeriod(jobPollIntervalWSUp)
+	}
 	defer heartbeatTicker.Stop()
 	defer reportTicker.Stop()
 	defer metricsTicker.Stop()
 	defer jobPollTicker.Stop()
+	// Security posture change detection
+	securityTicker := newJitterTicker(securityCheckInterval(cfg, reportInterval), jitterPct)
+	defer securityTicker.Stop()
+
+	// Periodic revocation check (disabled unless configured)
+	var certStatusC <-chan time.Time
+	var certStatusTicker *jitterTicker
+	if cfg.CertStatusInterval > 0 {
+		certStatusTicker = newJitterTicker(time.Duration(cfg.CertStatusInterval)*time.Second, jitterPct)
+		defer certStatusTicker.Stop()
+		certStatusC = certStatusTicker.C
+	}
+
+	// Certificate renewal before expiry, checked now and then periodically
+	var certRenewC <-chan time.Time
+	var certRenewTicker *jitterTicker
+	if cfg.CertRenewDays >= 0 {
+		if err := renewIfDue(ctx, cfg, apiClient); err != nil {
+			fmt.Printf("Warning: certificate renewal failed: %v\n", err)
+		}
+		certRenewTicker = newJitterTicker(certRenewCheckInterval, jitterPct)
+		defer certRenewTicker.Stop()
+		certRenewC = certRenewTicker.C
+	}
+
+	// Backs off the server loops while the server is unreachable
+	breaker := newCircuitBreaker()
+
+	// Warns when the device clock drifts from the server's
+	clockSkew := &clockSkewMonitor{cfg: cfg}
 
-	fmt.Printf("Agent running (heartbeat: %v, report: %v, metrics: 5s)\n", heartbeatInterval, reportInterval)
+	// Volumes currently at critical usage, to log each crossing once
+	criticalDisks := make(map[string]bool)
+
+	fmt.Printf("Agent running (heartbeat: %v, report: %v, metrics: 5s, jitter: %d%%)\n", heartbeatInterval, reportInterval, jitterPct)
 	fmt.Println("Press Ctrl+C to stop")
 
 	// Initial job check
@@ -155,28 +286,40 @@ func runAgent(cfg *config.Config, stopCh <-chan struct{}) error {
 		}()
 	}
 
-	// Handle Windows Service stop signal
-	if stopCh != nil {
-		go func() {
-			<-stopCh
-			fmt.Println("Service stop signal received")
-			cancel()
-		}()
-	}
-
 	for {
 		select {
 		case <-ctx.Done():
 			fmt.Println("Agent stopped")
 			return nil
 
-		case <-wsClient.Done():
-			// WebSocket disconnected, try to reconnect
-			fmt.Println("WebSocket disconnected, reconnecting...")
-			time.Sleep(2 * time.Second)
-			if err := wsClient.Connect(ctx); err != nil {
-				fmt.Printf("Reconnect failed: %v\n", err)
+		case <-restartCh:
+			fmt.Println("Restart requested by control playbook, exiting for restart")
+			return ErrRestartRequested
+
+		case <-wsDone:
+			wsDone = nil
+			jobPollTicker.setPeriod(jobPollIntervalWSDown)
+			delay := wsBackoff.next()
+			fmt.Printf("WebSocket disconnected, reconnecting in %v\n", delay.Round(time.Second))
+			wsReconnect.Reset(delay)
+
+		case <-wsReconnect.C:
+			err := wsClient.Connect(ctx)
+			if err == nil {
+				wsDone = wsClient.Done()
+				wsBackoff.reset()
+				jobPollTicker.setPeriod(jobPollIntervalWSUp)
+				break
+			}
+			if ctx.Err() != nil {
+				break
 			}
+			logFailure := wsBackoff.failure()
+			delay := wsBackoff.next()
+			if logFailure {
+				fmt.Printf("WebSocket reconnect failed (attempt %d, next in %v): %v\n", wsBackoff.failures, delay.Round(time.Second), err)
+			}
+			wsReconnect.Reset(delay)
 
 		case notification := <-wsClient.JobChannel():
 			// Real-time job notification - execute immediately!
@@ -187,47 +330,257 @@ func runAgent(cfg *config.Config, stopCh <-chan struct{}) error {
 						fmt.Printf("Job execution failed: %v\n", err)
 					}
 				}()
+			} else if jobsDisabled != "" {
+				go rejectJob(apiClient, client.PendingJob{JobID: notification.JobID, PlaybookName: notification.PlaybookName}, jobsDisabled)
+			}
+
+		case command := <-wsClient.CommandChannel():
+			if commander == nil {
+				go wsClient.Send(client.CommandResult{Type: "command_result", CommandID: command.CommandID, Status: "rejected", Error: commanderErr})
+				break
 			}
+			go commander.handle(ctx, command)
 
 		case <-heartbeatTicker.C:
-			if _, err := apiClient.SendHeartbeat(); err != nil {
-				fmt.Printf("Heartbeat failed: %v\n", err)
+			heartbeatTicker.next()
+			if !breaker.Allow() {
+				break
+			}
+			heartbeat, err := apiClient.SendHeartbeat()
+			breaker.Record("Heartbeat", err)
+			if err == nil && heartbeat.ServerKeys != nil && jobRunner != nil {
+				applyServerKeyUpdate(cfg, jobRunner, heartbeat.ServerKeys)
+			}
+			clockSkew.check()
+			if err == nil && apiClient.PendingCount() > 0 {
+				sent, err := apiClient.FlushPending()
+				if sent > 0 {
+					fmt.Printf("Sent %d buffered report(s)/metrics\n", sent)
+				}
+				breaker.Record("Buffered send", err)
 			}
 
 		case <-reportTicker.C:
-			info := sysinfo.Collect()
+			reportTicker.next()
+			if !breaker.Allow() {
+				break
+			}
+			info := sysinfo.Collect(ctx)
 			info.AgentVersion = agentVersion
-			if err := apiClient.SendReport(info); err != nil {
-				fmt.Printf("Report failed: %v\n", err)
+			if ctx.Err() != nil {
+				break // shutting down - don't send a partial report
+			}
+			security.prepareReport(info)
+			err := apiClient.SendReport(info)
+			breaker.Record("Report", err)
+			if err == nil {
+				security.reportSent(info)
+			}
+
+		case <-securityTicker.C:
+			securityTicker.next()
+			if !breaker.Allow() {
+				break
+			}
+			if sent, err := security.check(ctx, apiClient); sent {
+				breaker.Record("Security event", err)
 			}
 
 		case <-metricsTicker.C:
-			metrics := sysinfo.CollectMetrics()
+			metricsTicker.next()
+			if !breaker.Allow() {
+				break
+			}
+			metrics := sysinfo.CollectMetrics(ctx)
+			if ctx.Err() != nil {
+				break
+			}
+			logCriticalDisks(metrics, criticalDisks)
 			tempStr := "N/A"
 			if metrics.Temperature != nil {
 				tempStr = fmt.Sprintf("%.1f°C", *metrics.Temperature)
 			}
 			fmt.Printf("[Metrics] CPU: %.1f%%, RAM: %.1f%%, Temp: %s, Processes: %d\n",
 				metrics.CPU.UsagePercent, metrics.Memory.UsagePercent, tempStr, len(metrics.TopProcesses))
-			if err := apiClient.SendMetrics(metrics); err != nil {
-				fmt.Printf("[Metrics] Send failed: %v\n", err)
-			} else {
+			err := apiClient.SendMetrics(metrics)
+			breaker.Record("[Metrics] Send", err)
+			if err == nil {
 				fmt.Println("[Metrics] Sent successfully")
 			}
 
+		case <-certStatusC:
+			certStatusTicker.next()
+			if !breaker.Allow() {
+				break
+			}
+			err := checkCertStatus(ctx, cfg, apiClient)
+			if errors.Is(err, ErrCertificateRevoked) {
+				// Stop all operations; running jobs are cancelled with ctx
+				fmt.Println("Stopping agent - re-enrollment required")
+				return err
+			}
+			breaker.Record("Certificate status", err)
+
+		case <-certRenewC:
+			certRenewTicker.next()
+			if !breaker.Allow() {
+				break
+			}
+			if err := renewIfDue(ctx, cfg, apiClient); err != nil {
+				fmt.Printf("Warning: certificate renewal failed: %v\n", err)
+			}
+
 		case <-jobPollTicker.C:
+			jobPollTicker.next()
 			// Fallback polling in case WebSocket missed something
 			if jobRunner != nil {
 				if err := jobRunner.RunOnce(ctx); err != nil {
 					// Silently ignore poll errors
 				}
+			} else if jobsDisabled != "" {
+				rejectPendingJobs(apiClient, jobsDisabled)
 			}
 		}
 	}
 }
 
-// Status displays the current agent status
-func Status(cfg *config.Config) error {
+// rejectJob reports a job as rejected because this agent cannot run
+// playbooks without a trusted server public key, so operators see why the
+// job didn't run and that the device needs re-enrollment
+func rejectJob(apiClient *client.Client, job client.PendingJob, reason string) {
+	now := time.Now()
+	report := &playbook.ExecutionReport{
+		PlaybookName:  job.PlaybookName,
+		PlaybookID:    job.PlaybookID,
+		IsTestRun:     job.IsTestRun,
+		Status:        "rejected",
+		StartTime:     now,
+		EndTime:       now,
+		TotalDuration: "0s",
+		TaskResults:   []playbook.TaskResult{},
+		ErrorCode:     playbook.ErrorCodeMissingTrustAnchor,
+		ErrorMessage:  fmt.Sprintf("playbook execution disabled: %s - re-enroll the device to restore the server trust anchor", reason),
+	}
+
+	fmt.Printf("[JOB] Rejecting job %s: %s\n", job.JobID, report.ErrorMessage)
+	if err := apiClient.SubmitExecutionReport(job.JobID, report); err != nil {
+		fmt.Printf("[JOB] Failed to report rejected job %s: %v\n", job.JobID, err)
+	}
+}
+
+// rejectPendingJobs rejects every pending job while playbook execution is disabled
+func rejectPendingJobs(apiClient *client.Client, reason string) {
+	jobs, err := apiClient.GetPendingJobs(0)
+	if err != nil {
+		return
+	}
+	for _, job := range jobs {
+		rejectJob(apiClient, job, reason)
+	}
+}
+
+// sendCapabilities reports supported actions and features to the server.
+// Without a job runner no actions are reported, since playbooks cannot run.
+func sendCapabilities(apiClient *client.Client, jobRunner *JobRunner) {
+	report := &client.CapabilitiesReport{
+		AgentVersion: agentVersion,
+		Architecture: runtime.GOARCH,
+		Capabilities: playbook.Capabilities{
+			SchemaVersion:  playbook.SchemaVersion,
+			SchemaVersions: playbook.SupportedSchemaVersions(),
+			Platform:       playbook.NewParser().GetPlatform(),
+			Actions:        []string{},
+			Features:       []string{},
+		},
+	}
+
+	if jobRunner != nil {
+		caps, err := jobRunner.Capabilities()
+		if err != nil {
+			fmt.Printf("Warning: failed to determine capabilities: %v\n", err)
+			return
+		}
+		report.Capabilities = *caps
+	}
+
+	if err := apiClient.SendCapabilities(report); err != nil {
+		fmt.Printf("Warning: failed to send capabilities: %v\n", err)
+	}
+}
+
+// collectorOptions maps agent configuration to system info collector options.
+// Server-provided values take precedence over the local config.
+func collectorOptions(cfg *config.Config, serverConfig *client.AgentConfig) sysinfo.Options {
+	opts := sysinfo.Options{
+		ForceFullSecurityScoring: cfg.ForceFullSecurityScoring,
+		DiskWarningPercent:       cfg.DiskWarningPercent,
+		DiskCriticalPercent:      cfg.DiskCriticalPercent,
+		WatchedFiles:             cfg.WatchedFiles,
+		ProcessDenylist:          cfg.ProcessDenylist,
+	}
+	if serverConfig != nil {
+		if serverConfig.DiskWarningPercent > 0 {
+			opts.DiskWarningPercent = serverConfig.DiskWarningPercent
+		}
+		if serverConfig.DiskCriticalPercent > 0 {
+			opts.DiskCriticalPercent = serverConfig.DiskCriticalPercent
+		}
+		if len(serverConfig.WatchedFiles) > 0 {
+			opts.WatchedFiles = serverConfig.WatchedFiles
+		}
+	}
+	return opts
+}
+
+// logCriticalDisks prints a warning when a volume newly crosses the critical
+// threshold. alerted tracks volumes already reported.
+func logCriticalDisks(metrics *sysinfo.Metrics, alerted map[string]bool) {
+	for _, d := range metrics.Disks {
+		critical := d.AlertLevel == sysinfo.AlertLevelCritical
+		if critical && !alerted[d.Path] {
+			fmt.Printf("WARNING: disk %s is %.1f%% full (critical)\n", d.Path, d.UsagePercent)
+		}
+		alerted[d.Path] = critical
+	}
+}
+
+// statusReport is the output of 'status --json'
+type statusReport struct {
+	Enrolled    bool              `json:"enrolled"`
+	CertRevoked bool              `json:"cert_revoked"`
+	DeviceID    string            `json:"device_id,omitempty"`
+	ServerURL   string            `json:"server_url"`
+	AgentURL    string            `json:"agent_url"`
+	AuthMode    string            `json:"auth_mode"`
+	ConfigDir   string            `json:"config_dir"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Credentials statusCredentials `json:"credentials"`
+	Connection  *statusConnection `json:"connection,omitempty"` // nil when not enrolled
+	Intervals   Intervals         `json:"intervals"`
+}
+
+// statusCredentials reports which credential files are present on disk
+type statusCredentials struct {
+	Certificate bool `json:"certificate"`
+	PrivateKey  bool `json:"private_key"`
+	CACert      bool `json:"ca_cert"`
+}
+
+// statusConnection is the result of the live connection test
+type statusConnection struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+
+	// Server clock minus local clock; positive when the local clock is behind
+	ClockOffsetSeconds *float64 `json:"clock_offset_seconds,omitempty"`
+}
+
+// Status displays the current agent status, as JSON if asJSON is set
+func Status(cfg *config.Config, asJSON bool) error {
+	if asJSON {
+		return statusJSON(cfg)
+	}
+
 	fmt.Println("Cloudronix Agent Status")
 	fmt.Println("========================")
 
@@ -238,12 +591,34 @@ func Status(cfg *config.Config) error {
 		return nil
 	}
 
+	if cfg.CertRevoked {
+		fmt.Println("Status: CERTIFICATE REVOKED")
+		fmt.Println()
+		fmt.Println("Run 'cloudronix-agent enroll <token>' to enroll this device again")
+		return nil
+	}
+
 	fmt.Println("Status: ENROLLED")
 	fmt.Printf("Device ID: %s\n", cfg.DeviceID)
 	fmt.Printf("Server URL: %s\n", cfg.ServerURL)
 	fmt.Printf("Agent URL: %s\n", cfg.AgentURL)
+	fmt.Printf("Auth Mode: %s\n", authMode(cfg))
 	fmt.Printf("Config Dir: %s\n", cfg.ConfigDir)
 
+	if len(cfg.Labels) > 0 {
+		names := make([]string, 0, len(cfg.Labels))
+		for name := range cfg.Labels {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		fmt.Println()
+		fmt.Println("Labels:")
+		for _, name := range names {
+			fmt.Printf("  %s: %s\n", name, cfg.Labels[name])
+		}
+	}
+
 	paths := cfg.Paths()
 	fmt.Println()
 	fmt.Println("Credentials:")
@@ -276,11 +651,83 @@ func Status(cfg *config.Config) error {
 		return nil
 	}
 
-	if _, err := apiClient.GetConfig(); err != nil {
+	serverConfig, err := apiClient.GetConfig()
+	if err != nil {
 		fmt.Printf("Connection: FAILED (%v)\n", err)
 	} else {
 		fmt.Println("Connection: OK")
 	}
+	if offset, ok := client.ClockOffset(); ok {
+		fmt.Printf("Clock: %s\n", client.DescribeClockOffset(offset))
+	}
+
+	intervals := effectiveIntervals(cfg, serverConfig)
+	fmt.Println()
+	fmt.Println("Intervals:")
+	fmt.Printf("  Heartbeat: %v\n", intervals.Heartbeat)
+	fmt.Printf("  Report: %v\n", intervals.Report)
+	fmt.Printf("  Jitter: %d%%\n", intervals.JitterPercent)
+
+	return nil
+}
+
+// authMode returns the configured authentication mode for display
+func authMode(cfg *config.Config) string {
+	if cfg.AuthMode == "" {
+		return config.AuthModeHeaders
+	}
+	return cfg.AuthMode
+}
+
+// statusJSON prints the agent status as a JSON object
+func statusJSON(cfg *config.Config) error {
+	paths := cfg.Paths()
+	report := statusReport{
+		Enrolled:    cfg.IsEnrolled() && !cfg.CertRevoked,
+		CertRevoked: cfg.CertRevoked,
+		DeviceID:    cfg.DeviceID,
+		ServerURL:   cfg.ServerURL,
+		AgentURL:    cfg.AgentURL,
+		AuthMode:    authMode(cfg),
+		ConfigDir:   cfg.ConfigDir,
+		Labels:      cfg.Labels,
+		Credentials: statusCredentials{
+			Certificate: fileExists(paths.Certificate),
+			PrivateKey:  fileExists(paths.PrivateKey),
+			CACert:      fileExists(paths.CACert),
+		},
+	}
 
+	// Server intervals are only known when the server is reachable
+	var serverConfig *client.AgentConfig
+	if report.Enrolled {
+		report.Connection = &statusConnection{}
+		apiClient, err := client.NewClient(cfg)
+		if err == nil {
+			serverConfig, err = apiClient.GetConfig()
+		}
+		if err != nil {
+			report.Connection.Error = err.Error()
+		} else {
+			report.Connection.OK = true
+		}
+		if offset, ok := client.ClockOffset(); ok {
+			seconds := offset.Seconds()
+			report.Connection.ClockOffsetSeconds = &seconds
+		}
+	}
+	report.Intervals = effectiveIntervals(cfg, serverConfig)
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
 	return nil
 }
+
+// fileExists reports whether path can be stat'ed
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,95 @@
+package agent
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cloudronix/agent/internal/client"
+)
+
+const (
+	// Consecutive connectivity failures before the breaker opens
+	breakerThreshold = 5
+
+	// While open, the server is probed at most once per cooldown
+	breakerCooldown = 5 * time.Minute
+)
+
+// circuitBreaker quiets the heartbeat/report/metrics loops while the server
+// is unreachable. After breakerThreshold consecutive connectivity failures it
+// opens: loops skip their work except for one probe per cooldown, and
+// failures are logged once per probe instead of once per tick. The first
+// successful request closes it again.
+//
+// Only connectivity errors count towards tripping the breaker - an auth
+// rejection is a real problem that should stay visible.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	failures  int
+	open      bool
+	openedAt  time.Time
+	lastProbe time.Time
+}
+
+// newCircuitBreaker creates a closed circuit breaker
+func newCircuitBreaker() *circuitBreaker {
+	return &circuitBreaker{}
+}
+
+// Allow reports whether a loop should contact the server now
+func (b *circuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.open {
+		return true
+	}
+
+	if time.Since(b.lastProbe) >= breakerCooldown {
+		b.lastProbe = time.Now()
+		return true
+	}
+	return false
+}
+
+// Record updates the breaker with the outcome of a request and logs the
+// failure if appropriate. op names the request for log output.
+func (b *circuitBreaker) Record(op string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		if b.open {
+			fmt.Printf("Server connection restored after %v, resuming normal schedule\n",
+				time.Since(b.openedAt).Round(time.Second))
+		}
+		b.failures = 0
+		b.open = false
+		return
+	}
+
+	if !client.IsConnectivityError(err) {
+		fmt.Printf("%s failed: %v\n", op, err)
+		return
+	}
+
+	b.failures++
+
+	if b.open {
+		fmt.Printf("%s probe failed, server still unreachable (%d consecutive failures): %v\n", op, b.failures, err)
+		return
+	}
+
+	if b.failures >= breakerThreshold {
+		b.open = true
+		b.openedAt = time.Now()
+		b.lastProbe = b.openedAt
+		fmt.Printf("%s failed: %v\n", op, err)
+		fmt.Printf("Server unreachable after %d consecutive failures, backing off (probing every %v)\n",
+			b.failures, breakerCooldown)
+		return
+	}
+
+	fmt.Printf("%s failed: %v\n", op, err)
+}
@@ -0,0 +1,36 @@
+package agent
+
+import (
+	"fmt"
+
+	"github.com/cloudronix/agent/internal/client"
+	"github.com/cloudronix/agent/internal/config"
+)
+
+// clockSkewMonitor warns when the offset from the server clock, measured on
+// heartbeats, crosses client.ClockSkewWarning, and again when it recovers
+type clockSkewMonitor struct {
+	cfg    *config.Config
+	warned bool
+}
+
+// check compares the last measured offset with the threshold
+func (m *clockSkewMonitor) check() {
+	offset, ok := client.ClockOffset()
+	if !ok {
+		return
+	}
+
+	skewed := offset > client.ClockSkewWarning || offset < -client.ClockSkewWarning
+	switch {
+	case skewed && !m.warned:
+		if m.cfg.CompensateClockSkew {
+			fmt.Printf("Warning: %s; compensating in request signatures\n", client.DescribeClockOffset(offset))
+		} else {
+			fmt.Printf("WARNING: %s; the server may reject signed requests. Fix the system clock or set compensate_clock_skew\n", client.DescribeClockOffset(offset))
+		}
+	case !skewed && m.warned:
+		fmt.Printf("Clock skew resolved: %s\n", client.DescribeClockOffset(offset))
+	}
+	m.warned = skewed
+}
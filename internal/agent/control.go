@@ -0,0 +1,238 @@
+package agent
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cloudronix/agent/internal/config"
+	"github.com/cloudronix/agent/internal/enroll"
+	"github.com/cloudronix/agent/pkg/playbook"
+)
+
+// ErrRestartRequested is returned by the agent loop when a control playbook
+// asked the agent to restart. The process exits so the service manager
+// (systemd, launchd, Windows SCM) starts it again.
+var ErrRestartRequested = errors.New("agent restart requested")
+
+// Maximum time allowed to download an agent update
+const updateDownloadTimeout = 10 * time.Minute
+
+// controlHandler implements the internal agent_control action.
+//
+// SECURITY: This handler is never registered through RegisterHandler. The
+// executor only routes to it for approved playbooks with scope "control",
+// so every lifecycle operation passes the full signature verification chain.
+type controlHandler struct {
+	cfg    *config.Config
+	runner *JobRunner
+}
+
+// newControlHandler creates the agent_control handler for a job runner
+func newControlHandler(cfg *config.Config, runner *JobRunner) *controlHandler {
+	return &controlHandler{cfg: cfg, runner: runner}
+}
+
+// Supports returns all platforms
+func (h *controlHandler) Supports() []string {
+	return []string{"all"}
+}
+
+// Validate checks if the params are valid
+func (h *controlHandler) Validate(params map[string]interface{}) error {
+	switch op, _ := params["operation"].(string); op {
+	case "restart":
+	case "update":
+		if _, ok := params["url"].(string); !ok {
+			return fmt.Errorf("update operation requires 'url' parameter")
+		}
+		if _, ok := params["sha256"].(string); !ok {
+			return fmt.Errorf("update operation requires 'sha256' parameter")
+		}
+	case "reenroll":
+		if _, ok := params["token"].(string); !ok {
+			return fmt.Errorf("reenroll operation requires 'token' parameter")
+		}
+	default:
+		return fmt.Errorf("unknown operation '%s' (expected update, restart or reenroll)", op)
+	}
+	return nil
+}
+
+// Execute performs the requested lifecycle operation
+func (h *controlHandler) Execute(ctx context.Context, params map[string]interface{}, vars *playbook.Variables) (*playbook.TaskResult, error) {
+	result := &playbook.TaskResult{
+		StartTime: time.Now(),
+		Status:    playbook.TaskStatusRunning,
+	}
+
+	if err := h.Validate(params); err != nil {
+		return nil, err
+	}
+
+	var err error
+	switch params["operation"].(string) {
+	case "restart":
+		result.Message = "Agent restart scheduled after the job report is submitted"
+	case "update":
+		result.Message, err = h.update(ctx, params["url"].(string), params["sha256"].(string))
+	case "reenroll":
+		result.Message, err = h.reenroll(params["token"].(string))
+	}
+
+	result.EndTime = time.Now()
+	result.Duration = result.EndTime.Sub(result.StartTime).String()
+
+	if err != nil {
+		result.Status = playbook.TaskStatusFailed
+		return result, err
+	}
+
+	// Every operation takes effect on restart
+	h.runner.requestRestart()
+
+	result.Status = playbook.TaskStatusCompleted
+	result.Changed = true
+	return result, nil
+}
+
+// update downloads a new agent binary, verifies its SHA256 against the
+// signed playbook, and swaps it in place of the running executable
+func (h *controlHandler) update(ctx context.Context, url, expectedHash string) (string, error) {
+	if !strings.HasPrefix(url, "https://") {
+		return "", fmt.Errorf("update url must use https")
+	}
+	expectedHash = strings.ToLower(strings.TrimSpace(expectedHash))
+	if len(expectedHash) != sha256.Size*2 {
+		return "", fmt.Errorf("sha256 must be a hex-encoded SHA256 digest")
+	}
+
+	exePath, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to get executable path: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(exePath); err == nil {
+		exePath = resolved
+	}
+
+	// Download next to the executable so the final rename stays on one filesystem
+	newPath := exePath + ".new"
+	if err := downloadVerified(ctx, url, expectedHash, newPath); err != nil {
+		os.Remove(newPath)
+		return "", err
+	}
+
+	// Keep the previous binary as .old so a failed update can be rolled back by hand
+	oldPath := exePath + ".old"
+	os.Remove(oldPath)
+	if err := os.Rename(exePath, oldPath); err != nil {
+		os.Remove(newPath)
+		return "", fmt.Errorf("failed to move current executable aside: %w", err)
+	}
+	if err := os.Rename(newPath, exePath); err != nil {
+		os.Rename(oldPath, exePath)
+		os.Remove(newPath)
+		return "", fmt.Errorf("failed to install new executable: %w", err)
+	}
+
+	return fmt.Sprintf("Agent binary updated (sha256 %s), restart scheduled", expectedHash), nil
+}
+
+// downloadVerified downloads url to path and checks the SHA256 digest
+func downloadVerified(ctx context.Context, url, expectedHash, path string) error {
+	ctx, cancel := context.WithTimeout(ctx, updateDownloadTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download update: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download update: %s", resp.Status)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create update file: %w", err)
+	}
+
+	hasher := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, hasher), resp.Body); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to download update: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write update file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write update file: %w", err)
+	}
+
+	// SECURITY: the expected hash comes from the signed playbook
+	if actual := hex.EncodeToString(hasher.Sum(nil)); actual != expectedHash {
+		return fmt.Errorf("update hash mismatch: expected %s, got %s", expectedHash, actual)
+	}
+
+	return nil
+}
+
+// reenroll enrolls the device again with a new token. Enrollment runs in a
+// staging directory so the current credentials stay intact on failure.
+func (h *controlHandler) reenroll(token string) (string, error) {
+	stagingDir, err := os.MkdirTemp(h.cfg.ConfigDir, "reenroll-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(stagingDir)
+
+	staged := *h.cfg
+	staged.ConfigDir = stagingDir
+	staged.DeviceID = ""
+
+	if err := enroll.Enroll(&staged, token); err != nil {
+		return "", fmt.Errorf("re-enrollment failed: %w", err)
+	}
+
+	// Move the new credentials into place
+	from := staged.Paths()
+	to := h.cfg.Paths()
+	moves := [][2]string{
+		{from.PrivateKey, to.PrivateKey},
+		{from.Certificate, to.Certificate},
+		{from.CACert, to.CACert},
+		{from.ServerPublicKey, to.ServerPublicKey},
+	}
+	for _, m := range moves {
+		if _, err := os.Stat(m[0]); os.IsNotExist(err) {
+			continue
+		}
+		if err := os.Rename(m[0], m[1]); err != nil {
+			return "", fmt.Errorf("failed to install new credentials: %w", err)
+		}
+	}
+
+	h.cfg.DeviceID = staged.DeviceID
+	h.cfg.AgentURL = staged.AgentURL
+	h.cfg.CertRevoked = false
+	if err := h.cfg.Save(); err != nil {
+		return "", fmt.Errorf("failed to save config: %w", err)
+	}
+
+	return fmt.Sprintf("Device re-enrolled as %s, restart scheduled", staged.DeviceID), nil
+}
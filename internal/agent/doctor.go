@@ -0,0 +1,279 @@
+package agent
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cloudronix/agent/internal/auth"
+	"github.com/cloudronix/agent/internal/client"
+	"github.com/cloudronix/agent/internal/config"
+	"github.com/cloudronix/agent/pkg/playbook"
+)
+
+// Doctor thresholds
+const (
+	doctorCertExpiryWarning = 30 * 24 * time.Hour // warn when the certificate expires sooner
+	doctorMaxClockSkew      = 5 * time.Minute     // signed requests and commands carry timestamps
+	doctorNetworkTimeout    = 10 * time.Second
+)
+
+// Doctor check outcomes
+const (
+	checkPass = "PASS"
+	checkWarn = "WARN"
+	checkFail = "FAIL"
+	checkSkip = "SKIP"
+)
+
+// doctorCheck is one line of the doctor checklist
+type doctorCheck struct {
+	Name   string
+	Status string
+	Detail string
+}
+
+// Doctor checks the agent's configuration, credentials, connectivity and
+// clock, prints a checklist, and returns an error if any check failed
+func Doctor(cfg *config.Config) error {
+	fmt.Println("Cloudronix Agent Doctor")
+	fmt.Println("========================")
+
+	var checks []doctorCheck
+	add := func(check doctorCheck) {
+		fmt.Printf("[%s] %s: %s\n", check.Status, check.Name, check.Detail)
+		checks = append(checks, check)
+	}
+
+	paths := cfg.Paths()
+	add(checkConfigDirWritable(cfg.ConfigDir))
+
+	enrolled := cfg.IsEnrolled()
+	if !enrolled {
+		add(doctorCheck{"Enrollment", checkFail, "device is not enrolled (run 'cloudronix-agent enroll <token>')"})
+	} else if cfg.CertRevoked {
+		add(doctorCheck{"Enrollment", checkFail, "device certificate was revoked (enroll the device again)"})
+	} else {
+		add(doctorCheck{"Enrollment", checkPass, "device " + cfg.DeviceID})
+	}
+
+	add(checkCertificate(paths.Certificate, time.Now()))
+	add(checkPrivateKey(cfg))
+	add(checkCACertificate(paths.CACert))
+	add(checkServerPublicKey(cfg))
+
+	add(checkEndpoint("Server URL", cfg.ServerURL))
+	add(checkEndpoint("Agent URL", cfg.AgentURL))
+
+	if enrolled && !cfg.CertRevoked {
+		add(checkClockSkew(cfg))
+	} else {
+		add(doctorCheck{"Clock", checkSkip, "needs an enrolled device"})
+	}
+
+	failed, warnings := 0, 0
+	for _, check := range checks {
+		switch check.Status {
+		case checkFail:
+			failed++
+		case checkWarn:
+			warnings++
+		}
+	}
+
+	fmt.Println()
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(checks))
+	}
+	if warnings > 0 {
+		fmt.Printf("All checks passed, %d with warnings\n", warnings)
+		return nil
+	}
+	fmt.Println("All checks passed")
+	return nil
+}
+
+// checkConfigDirWritable verifies files can be created in the config directory
+func checkConfigDirWritable(dir string) doctorCheck {
+	name := "Config directory"
+	f, err := os.CreateTemp(dir, ".doctor-*")
+	if err != nil {
+		return doctorCheck{name, checkFail, fmt.Sprintf("%s is not writable: %v", dir, err)}
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return doctorCheck{name, checkPass, dir + " is writable"}
+}
+
+// checkCertificate verifies the device certificate parses and is within its
+// validity period
+func checkCertificate(path string, now time.Time) doctorCheck {
+	name := "Certificate"
+	certs, err := readPEMCertificates(path)
+	if err != nil {
+		return doctorCheck{name, checkFail, err.Error()}
+	}
+	cert := certs[0]
+
+	switch {
+	case now.Before(cert.NotBefore):
+		return doctorCheck{name, checkFail, fmt.Sprintf("not valid until %s (check the clock)", cert.NotBefore.Format(time.RFC3339))}
+	case now.After(cert.NotAfter):
+		return doctorCheck{name, checkFail, fmt.Sprintf("expired on %s", cert.NotAfter.Format(time.RFC3339))}
+	case cert.NotAfter.Sub(now) < doctorCertExpiryWarning:
+		days := int(cert.NotAfter.Sub(now).Hours() / 24)
+		return doctorCheck{name, checkWarn, fmt.Sprintf("expires in %d days (%s)", days, cert.NotAfter.Format(time.RFC3339))}
+	}
+	return doctorCheck{name, checkPass, fmt.Sprintf("valid until %s", cert.NotAfter.Format(time.RFC3339))}
+}
+
+// checkPrivateKey verifies the private key parses and belongs to the certificate
+func checkPrivateKey(cfg *config.Config) doctorCheck {
+	name := "Private key"
+	creds, err := auth.LoadCredentials(cfg)
+	if err != nil {
+		return doctorCheck{name, checkFail, err.Error()}
+	}
+
+	cert, err := x509.ParseCertificate(creds.CertificateDER)
+	if err != nil {
+		return doctorCheck{name, checkFail, err.Error()}
+	}
+	if !creds.PrivateKey.PublicKey.Equal(cert.PublicKey) {
+		return doctorCheck{name, checkFail, "does not match the device certificate"}
+	}
+	return doctorCheck{name, checkPass, "matches the device certificate"}
+}
+
+// checkCACertificate verifies the CA certificate parses
+func checkCACertificate(path string) doctorCheck {
+	name := "CA certificate"
+	certs, err := readPEMCertificates(path)
+	if err != nil {
+		return doctorCheck{name, checkFail, err.Error()}
+	}
+	return doctorCheck{name, checkPass, fmt.Sprintf("%s, valid until %s", certs[0].Subject.CommonName, certs[0].NotAfter.Format(time.RFC3339))}
+}
+
+// checkServerPublicKey verifies the pinned playbook signing keys are Ed25519 keys
+func checkServerPublicKey(cfg *config.Config) doctorCheck {
+	name := "Server public key"
+	keys, err := cfg.LoadServerPublicKeys()
+	if err != nil {
+		return doctorCheck{name, checkFail, err.Error()}
+	}
+	ids := make([]string, len(keys))
+	for i, key := range keys {
+		ids[i] = playbook.KeyID(key)
+	}
+	return doctorCheck{name, checkPass, fmt.Sprintf("%d Ed25519 key(s) trusted (%s)", len(keys), strings.Join(ids, ", "))}
+}
+
+// checkEndpoint verifies the host of rawURL resolves and accepts TCP connections
+func checkEndpoint(name, rawURL string) doctorCheck {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return doctorCheck{name, checkFail, fmt.Sprintf("invalid URL %q", rawURL)}
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), doctorNetworkTimeout)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupHost(ctx, u.Hostname())
+	if err != nil {
+		return doctorCheck{name, checkFail, fmt.Sprintf("DNS lookup of %s failed: %v", u.Hostname(), err)}
+	}
+
+	address := net.JoinHostPort(u.Hostname(), port)
+	var dialer net.Dialer
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return doctorCheck{name, checkFail, fmt.Sprintf("TCP connection to %s failed: %v", address, err)}
+	}
+	conn.Close()
+
+	return doctorCheck{name, checkPass, fmt.Sprintf("%s resolves to %s, TCP connect %v", u.Hostname(), addrs[0], time.Since(start).Round(time.Millisecond))}
+}
+
+// checkClockSkew compares the local clock with the server time from the
+// Date header of a config request, which is read-only and is measured even
+// when the request is rejected.
+func checkClockSkew(cfg *config.Config) doctorCheck {
+	name := "Clock"
+	apiClient, err := client.NewClient(cfg)
+	if err != nil {
+		return doctorCheck{name, checkFail, err.Error()}
+	}
+
+	_, configErr := apiClient.GetConfig()
+	offset, measured := client.ClockOffset()
+	if !measured {
+		if configErr != nil {
+			return doctorCheck{name, checkFail, fmt.Sprintf("config request failed: %v", configErr)}
+		}
+		return doctorCheck{name, checkSkip, "server did not report its time"}
+	}
+
+	detail := client.DescribeClockOffset(offset)
+	if cfg.CompensateClockSkew {
+		detail += " (compensated)"
+	}
+	switch {
+	case absDuration(offset) > doctorMaxClockSkew && !cfg.CompensateClockSkew:
+		return doctorCheck{name, checkFail, detail + fmt.Sprintf(" (max %v)", doctorMaxClockSkew)}
+	case absDuration(offset) > client.ClockSkewWarning:
+		return doctorCheck{name, checkWarn, detail}
+	}
+	return doctorCheck{name, checkPass, detail}
+}
+
+// readPEMCertificates parses every certificate in a PEM file
+func readPEMCertificates(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no PEM certificate in %s", path)
+	}
+	return certs, nil
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
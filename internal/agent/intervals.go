@@ -0,0 +1,68 @@
+package agent
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cloudronix/agent/internal/client"
+	"github.com/cloudronix/agent/internal/config"
+)
+
+// Where an effective interval comes from
+const (
+	intervalSourceServer = "server" // overridden by the server config
+	intervalSourceConfig = "config" // the local config value
+)
+
+// IntervalInfo shows a timer's locally configured period next to the one
+// actually in use
+type IntervalInfo struct {
+	ConfiguredSeconds int    `json:"configured_seconds"`
+	EffectiveSeconds  int    `json:"effective_seconds"`
+	Source            string `json:"source"` // server or config
+}
+
+// Effective returns the interval in use as a duration
+func (i IntervalInfo) Effective() time.Duration {
+	return time.Duration(i.EffectiveSeconds) * time.Second
+}
+
+// String formats the interval for logs, e.g. "60s (server, configured 300s)"
+func (i IntervalInfo) String() string {
+	if i.Source == intervalSourceServer && i.ConfiguredSeconds != i.EffectiveSeconds {
+		return fmt.Sprintf("%v (server, configured %v)", i.Effective(), time.Duration(i.ConfiguredSeconds)*time.Second)
+	}
+	return fmt.Sprintf("%v (%s)", i.Effective(), i.Source)
+}
+
+// Intervals are the reporting cadences of the agent
+type Intervals struct {
+	Heartbeat     IntervalInfo `json:"heartbeat"`
+	Report        IntervalInfo `json:"report"`
+	JitterPercent int          `json:"jitter_percent"`
+}
+
+// effectiveIntervals resolves the intervals in use: server values win when
+// set, otherwise the local config applies. serverConfig may be nil.
+func effectiveIntervals(cfg *config.Config, serverConfig *client.AgentConfig) Intervals {
+	var heartbeat, report int
+	if serverConfig != nil {
+		heartbeat = serverConfig.HeartbeatIntervalSeconds
+		report = serverConfig.ReportIntervalSeconds
+	}
+	return Intervals{
+		Heartbeat:     resolveInterval(cfg.HeartbeatInterval, heartbeat),
+		Report:        resolveInterval(cfg.ReportInterval, report),
+		JitterPercent: jitterPercent(cfg.JitterPercent),
+	}
+}
+
+// resolveInterval picks the server interval over the configured one
+func resolveInterval(configured, server int) IntervalInfo {
+	info := IntervalInfo{ConfiguredSeconds: configured, EffectiveSeconds: configured, Source: intervalSourceConfig}
+	if server > 0 {
+		info.EffectiveSeconds = server
+		info.Source = intervalSourceServer
+	}
+	return info
+}
@@ -0,0 +1,80 @@
+package agent
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	// Timer jitter when the config doesn't set one, as a percentage of the period
+	defaultJitterPercent = 10
+
+	// Upper bound on the random delay before the first report and job check
+	maxStartupJitter = 30 * time.Second
+)
+
+// jitterTicker is a ticker whose period varies by up to ±percent on every
+// tick, so a fleet restarted at the same moment drifts apart instead of
+// hitting the server in lockstep. Call next after each tick.
+type jitterTicker struct {
+	*time.Ticker
+	period  time.Duration
+	percent int
+}
+
+// newJitterTicker starts a ticker around period
+func newJitterTicker(period time.Duration, percent int) *jitterTicker {
+	return &jitterTicker{
+		Ticker:  time.NewTicker(jitter(period, percent)),
+		period:  period,
+		percent: percent,
+	}
+}
+
+// next picks a new random period for the following tick
+func (t *jitterTicker) next() {
+	t.Reset(jitter(t.period, t.percent))
+}
+
+// setPeriod changes the period around which the ticker varies, taking
+// effect from the next tick
+func (t *jitterTicker) setPeriod(period time.Duration) {
+	if period == t.period {
+		return
+	}
+	t.period = period
+	t.Reset(jitter(period, t.percent))
+}
+
+// jitter returns period varied randomly by up to ±percent
+func jitter(period time.Duration, percent int) time.Duration {
+	spread := period * time.Duration(percent) / 100
+	if spread <= 0 {
+		return period
+	}
+	return period - spread + rand.N(2*spread+1)
+}
+
+// startupJitter returns a random delay of up to percent of period (capped
+// at maxStartupJitter) to wait before the first report and job check
+func startupJitter(period time.Duration, percent int) time.Duration {
+	limit := min(period*time.Duration(percent)/100, maxStartupJitter)
+	if limit <= 0 {
+		return 0
+	}
+	return rand.N(limit)
+}
+
+// jitterPercent resolves the configured jitter (0 = default, negative = off)
+func jitterPercent(configured int) int {
+	switch {
+	case configured == 0:
+		return defaultJitterPercent
+	case configured < 0:
+		return 0
+	case configured > 50:
+		return 50 // keep periods at least half the configured interval
+	default:
+		return configured
+	}
+}
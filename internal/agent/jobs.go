@@ -4,13 +4,16 @@ import (
 	"context"
 	"crypto/ed25519"
 	"fmt"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cloudronix/agent/internal/client"
 	"github.com/cloudronix/agent/internal/config"
 	"github.com/cloudronix/agent/pkg/playbook"
 	"github.com/cloudronix/agent/pkg/playbook/actions"
+	"github.com/cloudronix/agent/pkg/sysinfo"
 )
 
 // JobRunner handles polling for and executing playbook jobs
@@ -18,47 +21,124 @@ type JobRunner struct {
 	cfg       *config.Config
 	apiClient *client.Client
 
-	// Executor with registered handlers
-	executor *playbook.Executor
-
-	// Server's public key for signature verification (obtained during enrollment)
-	serverPublicKey ed25519.PublicKey
+	// Server's public keys for signature verification (obtained during
+	// enrollment, replaced by signed key updates)
+	keysMu           sync.RWMutex
+	serverPublicKeys []ed25519.PublicKey
 
 	// Mutex to prevent concurrent job execution
 	mu        sync.Mutex
 	isRunning bool
 
+	// Playbooks with a job in progress, by playbook ID, so the same playbook
+	// never runs concurrently with itself
+	playbookMu      sync.Mutex
+	activePlaybooks map[string]string // playbook ID -> job ID
+
 	// Callback for job events
 	onJobStart    func(job *client.PendingJob)
 	onJobComplete func(job *client.PendingJob, report *playbook.ExecutionReport)
 	onJobError    func(job *client.PendingJob, err error)
+
+	// Execution reports not yet accepted by the server
+	reports *reportStore
+
+	// Set by agent_control; acted on once the job report is submitted
+	restartPending     atomic.Bool
+	onRestartRequested func()
 }
 
 // JobRunnerConfig holds configuration for the job runner
 type JobRunnerConfig struct {
-	Config          *config.Config
-	APIClient       *client.Client
-	ServerPublicKey ed25519.PublicKey
+	Config           *config.Config
+	APIClient        *client.Client
+	ServerPublicKeys []ed25519.PublicKey
 
 	// Optional callbacks
 	OnJobStart    func(job *client.PendingJob)
 	OnJobComplete func(job *client.PendingJob, report *playbook.ExecutionReport)
 	OnJobError    func(job *client.PendingJob, err error)
+
+	// OnRestartRequested is called after a control playbook asked the agent to restart
+	OnRestartRequested func()
 }
 
 // NewJobRunner creates a new job runner
 func NewJobRunner(cfg JobRunnerConfig) (*JobRunner, error) {
-	if len(cfg.ServerPublicKey) == 0 {
+	if len(cfg.ServerPublicKeys) == 0 {
 		return nil, fmt.Errorf("server public key is required for playbook verification")
 	}
 
-	// Create executor with the server's public key
+	// Fail fast on an unusable key rather than on the first job
+	if _, err := playbook.NewVerifier(cfg.ServerPublicKeys...); err != nil {
+		return nil, fmt.Errorf("invalid server public key: %w", err)
+	}
+
+	return &JobRunner{
+		cfg:              cfg.Config,
+		apiClient:        cfg.APIClient,
+		serverPublicKeys: cfg.ServerPublicKeys,
+		onJobStart:       cfg.OnJobStart,
+		onJobComplete:    cfg.OnJobComplete,
+		onJobError:       cfg.OnJobError,
+		reports:          newReportStore(cfg.Config),
+		activePlaybooks:  make(map[string]string),
+
+		onRestartRequested: cfg.OnRestartRequested,
+	}, nil
+}
+
+// newExecutor creates an executor with all action handlers registered,
+// configured for a single job
+func (r *JobRunner) newExecutor(job *client.PendingJob) (*playbook.Executor, error) {
+	label := ""
+	if job.IsTestRun {
+		label = "[TEST] "
+	}
+
+	// Test runs are limited to safe actions unless explicitly overridden
+	var allowedActions []string
+	if job.IsTestRun && !r.cfg.TestRunAllowAllActions {
+		allowedActions = r.cfg.TestRunAllowedActions
+		if len(allowedActions) == 0 {
+			allowedActions = config.DefaultTestRunAllowedActions
+		}
+	}
+
 	executor, err := playbook.NewExecutor(playbook.ExecutorConfig{
-		ServerPublicKey: cfg.ServerPublicKey,
-		DeviceID:        cfg.Config.DeviceID,
+		ServerPublicKeys: r.ServerPublicKeys(),
+		MaxApprovalAge:   maxApprovalAge(r.cfg),
+		DeviceID:         r.cfg.DeviceID,
 		OnProgress: func(taskName string, status playbook.TaskStatus) {
-			fmt.Printf("  Task '%s': %s\n", taskName, status)
+			fmt.Printf("  %sTask '%s': %s\n", label, taskName, status)
+		},
+		OnProgressUpdate: func(p playbook.Progress) {
+			if p.Elapsed != "" {
+				fmt.Printf("  %sStill running %s (%s), %d/%d tasks done\n", label, p.TaskName, p.Elapsed, p.TasksDone, p.TasksTotal)
+				return
+			}
+			fmt.Printf("  %sProgress: %d/%d tasks (%.0f%%)\n", label, p.TasksDone, p.TasksTotal, p.ProgressPercent)
 		},
+		AllowedActions: allowedActions,
+		ControlHandler: newControlHandler(r.cfg, r),
+
+		WorkdirRoot:            r.cfg.JobWorkdirRoot,
+		RetainWorkdirOnFailure: r.cfg.RetainJobWorkdirOnFailure,
+		PreflightDryRun:        r.cfg.PreflightDryRun,
+		DefaultShell:           r.cfg.DefaultShell,
+		RunOnceDir:             r.cfg.Paths().RunOnce,
+
+		JobID:     job.JobID,
+		Artifacts: r.apiClient,
+
+		Tags:     job.Tags,
+		SkipTags: job.SkipTags,
+
+		PendingReboot:    sysinfo.PendingReboot,
+		OnRebootRequired: r.onRebootRequired,
+
+		AgentVersion: agentVersion,
+		Distro:       sysinfo.Distro(),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create executor: %w", err)
@@ -67,15 +147,50 @@ func NewJobRunner(cfg JobRunnerConfig) (*JobRunner, error) {
 	// Register all action handlers
 	actions.RegisterAllHandlers(executor)
 
-	return &JobRunner{
-		cfg:             cfg.Config,
-		apiClient:       cfg.APIClient,
-		executor:        executor,
-		serverPublicKey: cfg.ServerPublicKey,
-		onJobStart:      cfg.OnJobStart,
-		onJobComplete:   cfg.OnJobComplete,
-		onJobError:      cfg.OnJobError,
-	}, nil
+	return executor, nil
+}
+
+// Capabilities reports the actions and features available to jobs, taken
+// from the handlers registered on a job executor
+func (r *JobRunner) Capabilities() (*playbook.Capabilities, error) {
+	executor, err := r.newExecutor(&client.PendingJob{})
+	if err != nil {
+		return nil, err
+	}
+	return executor.Capabilities(), nil
+}
+
+// ServerPublicKeys returns the keys currently trusted to sign playbooks
+func (r *JobRunner) ServerPublicKeys() []ed25519.PublicKey {
+	r.keysMu.RLock()
+	defer r.keysMu.RUnlock()
+	return r.serverPublicKeys
+}
+
+// setServerPublicKeys replaces the trusted keys; jobs started afterwards
+// verify against the new set
+func (r *JobRunner) setServerPublicKeys(keys []ed25519.PublicKey) {
+	r.keysMu.Lock()
+	defer r.keysMu.Unlock()
+	r.serverPublicKeys = keys
+}
+
+// requestRestart schedules an agent restart after the current job
+func (r *JobRunner) requestRestart() {
+	r.restartPending.Store(true)
+}
+
+// isDryRun reports whether a job should be simulated rather than applied.
+// Test runs are simulated unless the job requests apply mode or the agent
+// is configured to apply test runs.
+func (r *JobRunner) isDryRun(job *client.PendingJob) bool {
+	if !job.IsTestRun {
+		return false
+	}
+	if job.ApplyMode {
+		return false
+	}
+	return r.cfg.TestRunMode != config.TestRunModeApply
 }
 
 // CheckAndRunJobs checks for pending jobs and executes them
@@ -96,16 +211,26 @@ func (r *JobRunner) CheckAndRunJobs(ctx context.Context) (int, error) {
 		r.mu.Unlock()
 	}()
 
-	// Fetch pending jobs
-	jobs, err := r.apiClient.GetPendingJobs()
+	// Fetch a bounded batch of pending jobs
+	jobs, err := r.apiClient.GetPendingJobs(r.cfg.JobBatchSize)
 	if err != nil {
 		return 0, fmt.Errorf("failed to fetch pending jobs: %w", err)
 	}
 
+	// The server is reachable, so retry reports an earlier submission or a
+	// crash left behind. The first job check runs at startup.
+	r.resubmitPendingReports()
+
 	if len(jobs) == 0 {
 		return 0, nil
 	}
 
+	// Enforce the batch size even if the server ignores the limit
+	sortJobs(jobs)
+	if r.cfg.JobBatchSize > 0 && len(jobs) > r.cfg.JobBatchSize {
+		jobs = jobs[:r.cfg.JobBatchSize]
+	}
+
 	executed := 0
 	for _, job := range jobs {
 		select {
@@ -126,16 +251,39 @@ func (r *JobRunner) CheckAndRunJobs(ctx context.Context) (int, error) {
 	return executed, nil
 }
 
+// sortJobs orders jobs by priority (highest first), then by creation time (oldest first)
+func sortJobs(jobs []client.PendingJob) {
+	sort.SliceStable(jobs, func(i, j int) bool {
+		if jobs[i].Priority != jobs[j].Priority {
+			return jobs[i].Priority > jobs[j].Priority
+		}
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+}
+
 // executeJob executes a single job
 func (r *JobRunner) executeJob(ctx context.Context, job *client.PendingJob) error {
 	fmt.Printf("\n========================================\n")
 	fmt.Printf("Executing job: %s\n", job.JobID)
 	fmt.Printf("Playbook: %s (%s)\n", job.PlaybookName, job.PlaybookID)
+	dryRun := r.isDryRun(job)
 	if job.IsTestRun {
-		fmt.Println("Mode: TEST RUN")
+		if dryRun {
+			fmt.Println("Mode: TEST RUN (dry run - no changes will be made)")
+		} else {
+			fmt.Println("Mode: TEST RUN (apply - changes WILL be made)")
+		}
 	}
 	fmt.Printf("========================================\n")
 
+	// The job is not marked started while the playbook is busy, so the
+	// server keeps it pending and it is picked up again later
+	release, err := r.lockPlaybook(job)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	if r.onJobStart != nil {
 		r.onJobStart(job)
 	}
@@ -147,7 +295,6 @@ func (r *JobRunner) executeJob(ctx context.Context, job *client.PendingJob) erro
 
 	// Fetch the playbook content
 	var payload *client.SignedPlaybookPayload
-	var err error
 
 	if job.IsTestRun {
 		payload, err = r.apiClient.GetTestPlaybook(job.JobID, job.PlaybookID)
@@ -162,16 +309,32 @@ func (r *JobRunner) executeJob(ctx context.Context, job *client.PendingJob) erro
 	// Convert to SignedPlaybook for execution
 	signedPlaybook := payload.ToSignedPlaybook()
 
+	executor, err := r.newExecutor(job)
+	if err != nil {
+		return r.reportJobError(job, err)
+	}
+
 	// Execute the playbook (verification happens inside executor)
-	report, execErr := r.executor.Execute(ctx, signedPlaybook)
+	var report *playbook.ExecutionReport
+	var execErr error
+	if dryRun {
+		report, execErr = executor.DryRun(ctx, signedPlaybook)
+	} else {
+		report, execErr = executor.Execute(ctx, signedPlaybook)
+	}
+	report.IsTestRun = job.IsTestRun
 
 	// Always submit the report, even if execution failed
-	if submitErr := r.apiClient.SubmitExecutionReport(job.JobID, report); submitErr != nil {
+	if submitErr := r.submitReport(job.JobID, report); submitErr != nil {
 		fmt.Printf("Warning: failed to submit execution report: %v\n", submitErr)
 	}
 
 	// Print execution summary
-	fmt.Printf("\nExecution Summary:\n")
+	if job.IsTestRun {
+		fmt.Printf("\nExecution Summary [TEST RUN]:\n")
+	} else {
+		fmt.Printf("\nExecution Summary:\n")
+	}
 	fmt.Printf("  Status: %s\n", report.Status)
 	fmt.Printf("  Duration: %s\n", report.TotalDuration)
 	fmt.Printf("  Tasks: %d completed, %d failed, %d skipped\n",
@@ -179,21 +342,57 @@ func (r *JobRunner) executeJob(ctx context.Context, job *client.PendingJob) erro
 	if report.ErrorMessage != "" {
 		fmt.Printf("  Error: %s\n", report.ErrorMessage)
 	}
+	if report.RebootRequired {
+		fmt.Printf("  Reboot required: %s\n", report.RebootReason)
+	}
+	if report.RetainedWorkdir != "" {
+		fmt.Printf("  Working directory kept at: %s\n", report.RetainedWorkdir)
+	}
 	fmt.Printf("========================================\n\n")
 
 	if r.onJobComplete != nil {
 		r.onJobComplete(job, report)
 	}
 
+	// Restart only after the report is out, so the server sees the result
+	if r.restartPending.Swap(false) && r.onRestartRequested != nil {
+		r.onRestartRequested()
+	}
+
 	return execErr
 }
 
+// maxApprovalAge returns the configured playbook approval expiry
+func maxApprovalAge(cfg *config.Config) time.Duration {
+	return time.Duration(cfg.MaxApprovalAgeDays) * 24 * time.Hour
+}
+
+// lockPlaybook marks the job's playbook as running. It fails if another job
+// for the same playbook is still in progress. The returned function releases
+// the lock and must be called when the job ends, however it ends.
+func (r *JobRunner) lockPlaybook(job *client.PendingJob) (func(), error) {
+	r.playbookMu.Lock()
+	defer r.playbookMu.Unlock()
+
+	if running, ok := r.activePlaybooks[job.PlaybookID]; ok {
+		return nil, fmt.Errorf("playbook %s is already running (job %s)", job.PlaybookID, running)
+	}
+	r.activePlaybooks[job.PlaybookID] = job.JobID
+
+	return func() {
+		r.playbookMu.Lock()
+		defer r.playbookMu.Unlock()
+		delete(r.activePlaybooks, job.PlaybookID)
+	}, nil
+}
+
 // reportJobError creates and submits an error report for a job
 func (r *JobRunner) reportJobError(job *client.PendingJob, err error) error {
 	report := &playbook.ExecutionReport{
 		PlaybookID:   job.PlaybookID,
 		PlaybookName: job.PlaybookName,
 		DeviceID:     r.cfg.DeviceID,
+		IsTestRun:    job.IsTestRun,
 		Status:       "failed",
 		StartTime:    time.Now(),
 		EndTime:      time.Now(),
@@ -206,7 +405,7 @@ func (r *JobRunner) reportJobError(job *client.PendingJob, err error) error {
 	}
 	report.TotalDuration = "0s"
 
-	if submitErr := r.apiClient.SubmitExecutionReport(job.JobID, report); submitErr != nil {
+	if submitErr := r.submitReport(job.JobID, report); submitErr != nil {
 		fmt.Printf("Warning: failed to submit error report: %v\n", submitErr)
 	}
 
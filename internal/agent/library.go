@@ -0,0 +1,143 @@
+package agent
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cloudronix/agent/internal/client"
+	"github.com/cloudronix/agent/internal/config"
+	"github.com/cloudronix/agent/pkg/playbook"
+	"gopkg.in/yaml.v3"
+)
+
+// ValidateLibrary checks every signed playbook payload (*.json, in the form
+// the server sends to agents) in dir the way an agent would before running
+// it, and prints which playbooks would run on which platforms.
+//
+// The server public key is read from keyPath, or the key pinned at
+// enrollment when keyPath is empty. It fails if any payload can't be read,
+// fails verification or would run on no platform.
+func ValidateLibrary(cfg *config.Config, dir, keyPath string) error {
+	var keys []ed25519.PublicKey
+	var err error
+	if keyPath != "" {
+		var data []byte
+		if data, err = os.ReadFile(keyPath); err == nil {
+			keys, err = config.ParseServerPublicKeys(data)
+		}
+	} else {
+		keys, err = cfg.LoadServerPublicKeys()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load server public key: %w", err)
+	}
+	verifier, err := playbook.NewVerifier(keys...)
+	if err != nil {
+		return fmt.Errorf("invalid server public key: %w", err)
+	}
+	verifier.SetMaxApprovalAge(maxApprovalAge(cfg))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no signed playbooks (*.json) found in %s", dir)
+	}
+	sort.Strings(files)
+
+	var failures []string
+	var details []string
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "PLAYBOOK\tSTATUS\t%s\n", strings.ToUpper(strings.Join(playbook.AllPlatforms, "\t")))
+	for _, file := range files {
+		name := filepath.Base(file)
+
+		payload, err := loadPlaybookPayload(file)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+
+		check := playbook.CheckLibraryPlaybook(verifier, payload.ToSignedPlaybook())
+		if check.PlaybookName != "" {
+			name = fmt.Sprintf("%s (%s)", check.PlaybookName, name)
+		}
+
+		cells := make([]string, len(playbook.AllPlatforms))
+		runs := 0
+		for i, platform := range playbook.AllPlatforms {
+			if check.RunsOn(platform) {
+				cells[i] = "yes"
+				runs++
+				continue
+			}
+			cells[i] = "-"
+			if !check.Verification.AllChecksPass {
+				continue // reported once below
+			}
+			details = append(details, fmt.Sprintf("%s on %s: %s", name, platform, check.Problems[platform]))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", name, payload.Status, strings.Join(cells, "\t"))
+
+		switch {
+		case !check.Verification.AllChecksPass:
+			failures = append(failures, fmt.Sprintf("%s: verification failed: %s", name, check.Verification.FailureReason))
+		case runs == 0:
+			failures = append(failures, fmt.Sprintf("%s: would not run on any platform", name))
+		}
+	}
+	w.Flush()
+
+	if len(details) > 0 {
+		fmt.Println()
+		for _, detail := range details {
+			fmt.Printf("  %s\n", detail)
+		}
+	}
+
+	if len(failures) > 0 {
+		fmt.Println()
+		for _, failure := range failures {
+			fmt.Printf("FAIL %s\n", failure)
+		}
+		return fmt.Errorf("%d of %d playbooks failed validation", len(failures), len(files))
+	}
+
+	fmt.Printf("\nAll %d playbooks passed validation\n", len(files))
+	return nil
+}
+
+// loadPlaybookPayload reads one signed playbook payload. Files ending in
+// .yaml or .yml hold the same fields as the JSON form.
+func loadPlaybookPayload(path string) (*client.SignedPlaybookPayload, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		// Decode generically and go through JSON, so field names and the
+		// base64 signature are read the same way
+		var doc interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("invalid signed playbook payload: %w", err)
+		}
+		if data, err = json.Marshal(doc); err != nil {
+			return nil, fmt.Errorf("invalid signed playbook payload: %w", err)
+		}
+	}
+
+	var payload client.SignedPlaybookPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("invalid signed playbook payload: %w", err)
+	}
+	return &payload, nil
+}
@@ -0,0 +1,144 @@
+package agent
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/cloudronix/agent/internal/config"
+)
+
+// Log rotation defaults, used when the config leaves a setting at zero
+const (
+	defaultLogMaxSizeMB = 10
+	defaultLogMaxFiles  = 5
+)
+
+// rotatingLog is a log file that is rotated once it reaches maxSize bytes:
+// agent.log becomes agent.log.1, agent.log.1 becomes agent.log.2 and so on,
+// keeping at most maxFiles rotated files
+type rotatingLog struct {
+	mu       sync.Mutex
+	path     string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+}
+
+// openRotatingLog opens (or creates) the log file for appending
+func openRotatingLog(path string, maxSize int64, maxFiles int) (*rotatingLog, error) {
+	l := &rotatingLog{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// open opens the current log file and records its size
+func (l *rotatingLog) open() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.file = f
+	l.size = info.Size()
+	return nil
+}
+
+// Write appends to the log, rotating first if p would overflow it
+func (l *rotatingLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.size > 0 && l.size+int64(len(p)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			// Keep logging to the oversized file rather than losing output
+			fmt.Fprintf(l.file, "log rotation failed: %v\n", err)
+		}
+	}
+
+	n, err := l.file.Write(p)
+	l.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the rotated files up by one, dropping the oldest, and
+// starts a new log file. Called with mu held.
+func (l *rotatingLog) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+
+	os.Remove(fmt.Sprintf("%s.%d", l.path, l.maxFiles))
+	for i := l.maxFiles - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", l.path, i), fmt.Sprintf("%s.%d", l.path, i+1))
+	}
+	renameErr := os.Rename(l.path, l.path+".1")
+
+	if err := l.open(); err != nil {
+		return err
+	}
+	return renameErr
+}
+
+// Close closes the log file
+func (l *rotatingLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+// redirectOutput sends everything the agent prints to stdout and stderr to
+// the configured log file, rotated by size. The returned function restores
+// the original streams once buffered output is written.
+func redirectOutput(cfg *config.Config) (func(), error) {
+	maxSizeMB := cfg.LogMaxSizeMB
+	if maxSizeMB <= 0 {
+		maxSizeMB = defaultLogMaxSizeMB
+	}
+	maxFiles := cfg.LogMaxFiles
+	if maxFiles <= 0 {
+		maxFiles = defaultLogMaxFiles
+	}
+
+	logFile, err := openRotatingLog(cfg.LogFile, int64(maxSizeMB)<<20, maxFiles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	// All output goes through fmt to os.Stdout/os.Stderr, so swap both for
+	// a pipe drained into the log
+	r, w, err := os.Pipe()
+	if err != nil {
+		logFile.Close()
+		return nil, fmt.Errorf("failed to redirect output: %w", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		io.Copy(logFile, r)
+		close(done)
+	}()
+
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = w, w
+
+	return func() {
+		os.Stdout, os.Stderr = stdout, stderr
+		w.Close()
+		<-done
+		r.Close()
+		logFile.Close()
+	}, nil
+}
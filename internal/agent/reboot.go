@@ -0,0 +1,187 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/cloudronix/agent/internal/config"
+	"github.com/cloudronix/agent/pkg/playbook"
+	"github.com/cloudronix/agent/pkg/sysinfo"
+)
+
+// rebootPromptTimeout bounds how long an interactive prompt waits for an answer
+const rebootPromptTimeout = 5 * time.Minute
+
+// onRebootRequired handles on_complete.reboot_prompt. In an interactive
+// session the user is asked; unattended, the reboot is only reported unless
+// the reboot policy schedules it within the maintenance window.
+func (r *JobRunner) onRebootRequired(ctx context.Context, req playbook.RebootRequest) (time.Time, error) {
+	fmt.Printf("  Reboot required by '%s': %s\n", req.PlaybookName, req.Reason)
+	if req.Message != "" {
+		fmt.Printf("  %s\n", req.Message)
+	}
+
+	if isInteractive() {
+		if !promptReboot(ctx) {
+			fmt.Println("  Reboot postponed")
+			return time.Time{}, nil
+		}
+		return scheduleReboot(ctx, time.Now(), rebootMessage(req.PlaybookName))
+	}
+
+	if r.cfg.RebootPolicy != config.RebootPolicySchedule {
+		return time.Time{}, nil
+	}
+
+	window := r.cfg.RebootWindow
+	if window == "" {
+		window = config.DefaultRebootWindow
+	}
+	at, err := nextRebootTime(time.Now(), window)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return scheduleReboot(ctx, at, rebootMessage(req.PlaybookName))
+}
+
+// rebootMessage is shown to logged-in users when a playbook reboot is scheduled
+func rebootMessage(playbookName string) string {
+	return fmt.Sprintf("Cloudronix: reboot required by playbook '%s'", playbookName)
+}
+
+// MaintenanceReboot is run by the timers the maintenance_window action
+// installs. With policy "if_pending" it reboots only when the system flags a
+// pending reboot; "always" reboots unconditionally. The reboot happens at a
+// random moment within the next spread minutes, so devices sharing a window
+// don't all go down at once.
+func MaintenanceReboot(name, policy string, spread int) error {
+	switch policy {
+	case "if_pending":
+		pending, reason := sysinfo.PendingReboot()
+		if !pending {
+			fmt.Println("No reboot pending")
+			return nil
+		}
+		fmt.Printf("Reboot pending: %s\n", reason)
+	case "always":
+	default:
+		return fmt.Errorf("unknown reboot policy '%s'", policy)
+	}
+
+	at := time.Now()
+	if spread > 0 {
+		at = at.Add(rand.N(time.Duration(spread) * time.Minute))
+	}
+	_, err := scheduleReboot(context.Background(), at, fmt.Sprintf("Cloudronix: maintenance window '%s'", name))
+	return err
+}
+
+// isInteractive reports whether the agent runs in the foreground with a
+// user at the terminal, rather than as a service
+func isInteractive() bool {
+	if IsWindowsService() {
+		return false
+	}
+	stdin, err := os.Stdin.Stat()
+	if err != nil || stdin.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	// Service managers connect stdin to the null device, which is a
+	// character device too
+	if null, err := os.Stat(os.DevNull); err == nil && os.SameFile(stdin, null) {
+		return false
+	}
+	return true
+}
+
+// promptReboot asks whether to reboot now; no answer counts as no
+func promptReboot(ctx context.Context) bool {
+	fmt.Print("  Reboot now? [y/N]: ")
+
+	answer := make(chan string, 1)
+	go func() {
+		line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		answer <- strings.ToLower(strings.TrimSpace(line))
+	}()
+
+	select {
+	case a := <-answer:
+		return a == "y" || a == "yes"
+	case <-time.After(rebootPromptTimeout):
+		fmt.Println()
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// nextRebootTime returns the start of the next maintenance window, or now
+// if the window is open. window is "HH:MM-HH:MM" in local time and may
+// wrap past midnight.
+func nextRebootTime(now time.Time, window string) (time.Time, error) {
+	startStr, endStr, ok := strings.Cut(window, "-")
+	if !ok {
+		return time.Time{}, fmt.Errorf("invalid reboot window '%s': expected HH:MM-HH:MM", window)
+	}
+	start, err := time.Parse("15:04", strings.TrimSpace(startStr))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid reboot window start '%s': %w", startStr, err)
+	}
+	end, err := time.Parse("15:04", strings.TrimSpace(endStr))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid reboot window end '%s': %w", endStr, err)
+	}
+
+	// Today's window, or the one that opened yesterday and wraps past midnight
+	for _, day := range []int{-1, 0} {
+		open := time.Date(now.Year(), now.Month(), now.Day()+day, start.Hour(), start.Minute(), 0, 0, now.Location())
+		closeAt := time.Date(open.Year(), open.Month(), open.Day(), end.Hour(), end.Minute(), 0, 0, now.Location())
+		if !closeAt.After(open) {
+			closeAt = closeAt.AddDate(0, 0, 1)
+		}
+		if !now.Before(open) && now.Before(closeAt) {
+			return now, nil
+		}
+	}
+
+	next := time.Date(now.Year(), now.Month(), now.Day(), start.Hour(), start.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next, nil
+}
+
+// scheduleReboot asks the OS to reboot at the given time, showing message
+// to logged-in users. It returns when the reboot will happen (rounded up to
+// whole minutes on Unix).
+func scheduleReboot(ctx context.Context, at time.Time, message string) (time.Time, error) {
+	delay := max(time.Until(at), 0)
+
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		seconds := int(delay.Seconds())
+		cmd = exec.CommandContext(ctx, "shutdown", "/r", "/t", fmt.Sprint(seconds), "/c", message)
+		at = time.Now().Add(time.Duration(seconds) * time.Second)
+	case "linux", "darwin":
+		minutes := int((delay + time.Minute - 1) / time.Minute)
+		cmd = exec.CommandContext(ctx, "shutdown", "-r", fmt.Sprintf("+%d", minutes), message)
+		at = time.Now().Add(time.Duration(minutes) * time.Minute)
+	default:
+		return time.Time{}, fmt.Errorf("scheduling a reboot is not supported on %s", runtime.GOOS)
+	}
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return time.Time{}, fmt.Errorf("failed to schedule reboot: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	fmt.Printf("  Reboot scheduled for %s\n", at.Format(time.RFC3339))
+	return at, nil
+}
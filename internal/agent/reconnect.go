@@ -0,0 +1,52 @@
+package agent
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	// WebSocket reconnect delays: the first retry comes after about
+	// wsReconnectInitial, doubling up to wsReconnectMax
+	wsReconnectInitial = 2 * time.Second
+	wsReconnectMax     = 5 * time.Minute
+
+	// Failed reconnects are logged on the first attempt and then once per
+	// this many attempts, instead of on every retry
+	wsReconnectLogEvery = 10
+
+	// Fallback job polling: frequent while WebSocket notifications are
+	// unavailable, relaxed while they arrive in real time
+	jobPollIntervalWSDown = 30 * time.Second
+	jobPollIntervalWSUp   = 2 * time.Minute
+)
+
+// reconnectBackoff produces capped exponential reconnect delays with
+// jitter, so a fleet that lost the server doesn't reconnect in lockstep
+type reconnectBackoff struct {
+	delay    time.Duration
+	failures int
+}
+
+// next returns the delay before the next attempt and advances the backoff
+func (b *reconnectBackoff) next() time.Duration {
+	if b.delay == 0 {
+		b.delay = wsReconnectInitial
+	}
+	// Equal jitter: at least half the current delay
+	d := b.delay/2 + rand.N(b.delay/2+1)
+	b.delay = min(b.delay*2, wsReconnectMax)
+	return d
+}
+
+// failure records a failed reconnect and reports whether to log it
+func (b *reconnectBackoff) failure() bool {
+	b.failures++
+	return b.failures == 1 || b.failures%wsReconnectLogEvery == 0
+}
+
+// reset starts over after a successful connect
+func (b *reconnectBackoff) reset() {
+	b.delay = 0
+	b.failures = 0
+}
@@ -0,0 +1,264 @@
+package agent
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudronix/agent/internal/client"
+	"github.com/cloudronix/agent/internal/config"
+	"github.com/cloudronix/agent/pkg/playbook"
+)
+
+const (
+	// Remote command timeout when the server doesn't set one, and the hard cap
+	defaultRemoteCommandTimeout = 60 * time.Second
+	maxRemoteCommandTimeout     = 5 * time.Minute
+
+	// Signed commands expiring further out than this are refused, which
+	// bounds how long a captured command could be replayed
+	maxRemoteCommandTTL = 10 * time.Minute
+
+	// Output streamed per command; the rest is dropped
+	maxRemoteCommandOutput = 1024 * 1024
+
+	// Size of the output chunks sent to the server
+	remoteCommandChunk = 4096
+
+	// Prefix of the signed message for a remote command, followed by the
+	// command ID, ":" and the payload hash
+	remoteCommandSignatureDomain = "cloudronix-remote-command:v1:"
+)
+
+// remoteCommander runs one-off diagnostic commands pushed over the
+// WebSocket when the device opts in. Commands are signed by the server
+// key, with a message distinct from playbook signatures, and are refused
+// unless the signature verifies, they are addressed to this device and
+// they haven't expired or been seen before, even across agent restarts.
+type remoteCommander struct {
+	cfg  *config.Config
+	keys func() []ed25519.PublicKey // trusted server keys, which may be rotated
+	ws   *client.WebSocketClient
+
+	// One command at a time
+	running atomic.Bool
+
+	// Command IDs already run, until they expire, to refuse replays. Kept
+	// on disk so a restart doesn't forget them.
+	mu       sync.Mutex
+	seen     map[string]time.Time
+	seenPath string
+}
+
+// newRemoteCommander creates a commander verifying with the keys returned by
+// serverKeys at the time each command arrives
+func newRemoteCommander(cfg *config.Config, serverKeys func() []ed25519.PublicKey, ws *client.WebSocketClient) (*remoteCommander, error) {
+	if _, err := playbook.NewVerifier(serverKeys()...); err != nil {
+		return nil, err
+	}
+	r := &remoteCommander{
+		cfg:      cfg,
+		keys:     serverKeys,
+		ws:       ws,
+		seen:     make(map[string]time.Time),
+		seenPath: cfg.Paths().RemoteCommands,
+	}
+	if data, err := os.ReadFile(r.seenPath); err == nil {
+		if err := json.Unmarshal(data, &r.seen); err != nil {
+			return nil, fmt.Errorf("failed to read seen remote commands: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read seen remote commands: %w", err)
+	}
+	return r, nil
+}
+
+// handle verifies and runs a command, streaming its output to the server
+func (r *remoteCommander) handle(ctx context.Context, command client.RemoteCommand) {
+	result := client.CommandResult{Type: "command_result", CommandID: command.CommandID}
+
+	payload, err := r.verify(command)
+	if err != nil {
+		fmt.Printf("Refused remote command %s: %v\n", command.CommandID, err)
+		result.Status = "rejected"
+		result.Error = err.Error()
+		r.ws.Send(result)
+		return
+	}
+
+	if !r.running.CompareAndSwap(false, true) {
+		result.Status = "rejected"
+		result.Error = "another remote command is running"
+		r.ws.Send(result)
+		return
+	}
+	defer r.running.Store(false)
+
+	fmt.Printf("Running remote command %s: %s\n", payload.CommandID, payload.Command)
+	r.run(ctx, payload, &result)
+	fmt.Printf("Remote command %s %s (exit code %d)\n", payload.CommandID, result.Status, result.ExitCode)
+
+	if err := r.ws.Send(result); err != nil {
+		fmt.Printf("Warning: failed to send remote command result: %v\n", err)
+	}
+}
+
+// verify checks the signature and addressing of a command and decodes it
+func (r *remoteCommander) verify(command client.RemoteCommand) (*client.RemoteCommandPayload, error) {
+	if !r.cfg.EnableRemoteCommands {
+		return nil, errors.New("remote commands are not enabled on this device")
+	}
+
+	verifier, err := playbook.NewVerifier(r.keys()...)
+	if err != nil {
+		return nil, err
+	}
+	domain := remoteCommandSignatureDomain + command.CommandID + ":"
+	if err := verifier.VerifyContent(domain, command.Payload, command.SHA256Hash, command.Signature); err != nil {
+		return nil, err
+	}
+
+	var payload client.RemoteCommandPayload
+	if err := json.Unmarshal([]byte(command.Payload), &payload); err != nil {
+		return nil, fmt.Errorf("invalid command payload: %w", err)
+	}
+
+	now := time.Now()
+	switch {
+	case payload.CommandID == "" || payload.CommandID != command.CommandID:
+		return nil, errors.New("command ID does not match the signed payload")
+	case payload.DeviceID != r.cfg.DeviceID:
+		return nil, errors.New("command is addressed to another device")
+	case payload.Command == "":
+		return nil, errors.New("empty command")
+	case !payload.ExpiresAt.After(now):
+		return nil, errors.New("command has expired")
+	case payload.ExpiresAt.Sub(now) > maxRemoteCommandTTL:
+		return nil, fmt.Errorf("command expiry is more than %v away", maxRemoteCommandTTL)
+	}
+	if _, _, err := remoteShell(payload.Shell); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, expires := range r.seen {
+		if !expires.After(now) {
+			delete(r.seen, id)
+		}
+	}
+	if _, ok := r.seen[payload.CommandID]; ok {
+		return nil, errors.New("command was already run")
+	}
+	r.seen[payload.CommandID] = payload.ExpiresAt
+
+	// Refuse to run what couldn't be recorded, or a restart would allow a replay
+	if err := r.saveSeen(); err != nil {
+		delete(r.seen, payload.CommandID)
+		return nil, fmt.Errorf("failed to record remote command: %w", err)
+	}
+
+	return &payload, nil
+}
+
+// saveSeen writes the IDs of commands already run to disk. r.mu must be held.
+func (r *remoteCommander) saveSeen() error {
+	data, err := json.Marshal(r.seen)
+	if err != nil {
+		return err
+	}
+	return config.WriteFileAtomic(r.seenPath, data, 0600)
+}
+
+// run executes the command under its timeout, streaming stdout and stderr
+func (r *remoteCommander) run(ctx context.Context, payload *client.RemoteCommandPayload, result *client.CommandResult) {
+	start := time.Now()
+	defer func() { result.Duration = time.Since(start).String() }()
+
+	timeout := defaultRemoteCommandTimeout
+	if payload.TimeoutSeconds > 0 {
+		timeout = min(time.Duration(payload.TimeoutSeconds)*time.Second, maxRemoteCommandTimeout)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	name, args, _ := remoteShell(payload.Shell) // checked by verify
+	cmd := exec.CommandContext(ctx, name, append(args, payload.Command)...)
+	cmd.WaitDelay = 5 * time.Second // don't hang on children keeping the pipes open
+
+	budget := &atomic.Int64{}
+	budget.Store(maxRemoteCommandOutput)
+	cmd.Stdout = &outputStream{ws: r.ws, commandID: payload.CommandID, name: "stdout", budget: budget}
+	cmd.Stderr = &outputStream{ws: r.ws, commandID: payload.CommandID, name: "stderr", budget: budget}
+	err := cmd.Run()
+
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		result.Status = "timed_out"
+		result.ExitCode = -1
+		result.Error = fmt.Sprintf("timed out after %v", timeout)
+	case errors.As(err, &exitErr):
+		result.Status = "failed"
+		result.ExitCode = exitErr.ExitCode()
+	case err != nil:
+		result.Status = "failed"
+		result.ExitCode = -1
+		result.Error = err.Error()
+	default:
+		result.Status = "completed"
+	}
+}
+
+// outputStream sends what a command writes to one of its streams to the
+// server in chunks, while the output budget shared by both streams lasts
+type outputStream struct {
+	ws        *client.WebSocketClient
+	commandID string
+	name      string // stdout or stderr
+	budget    *atomic.Int64
+}
+
+// Write sends p, silently dropping output beyond the budget
+func (s *outputStream) Write(p []byte) (int, error) {
+	// Budget left before this write
+	left := s.budget.Add(-int64(len(p))) + int64(len(p))
+	data := p[:max(min(int64(len(p)), left), 0)]
+
+	for len(data) > 0 {
+		chunk := data[:min(len(data), remoteCommandChunk)]
+		data = data[len(chunk):]
+		s.ws.Send(client.CommandOutput{Type: "command_output", CommandID: s.commandID, Stream: s.name, Data: string(chunk)})
+	}
+	return len(p), nil
+}
+
+// remoteShell returns the interpreter and arguments for a command. Only
+// known shells are accepted; the server can't name an arbitrary program.
+func remoteShell(shell string) (string, []string, error) {
+	switch shell {
+	case "powershell", "pwsh":
+		return "powershell", []string{"-NoProfile", "-NonInteractive", "-Command"}, nil
+	case "cmd":
+		return "cmd", []string{"/C"}, nil
+	case "bash":
+		return "/bin/bash", []string{"-c"}, nil
+	case "sh":
+		return "/bin/sh", []string{"-c"}, nil
+	case "":
+		if runtime.GOOS == "windows" {
+			return "cmd", []string{"/C"}, nil
+		}
+		return "/bin/sh", []string{"-c"}, nil
+	default:
+		return "", nil, fmt.Errorf("unsupported shell %q", shell)
+	}
+}
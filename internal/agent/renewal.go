@@ -0,0 +1,90 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cloudronix/agent/internal/client"
+	"github.com/cloudronix/agent/internal/config"
+	"github.com/cloudronix/agent/internal/enroll"
+)
+
+// How often the agent checks whether the device certificate is due for renewal
+const certRenewCheckInterval = 12 * time.Hour
+
+// Maximum time for a renewal, including confirming the new certificate
+const certRenewTimeout = time.Minute
+
+// Days before expiry the certificate is renewed when CertRenewDays is unset
+const defaultCertRenewDays = 30
+
+// certRenewalDue reports whether the device certificate expires within the
+// configured renewal window, and when it expires
+func certRenewalDue(cfg *config.Config, now time.Time) (bool, time.Time, error) {
+	certs, err := readPEMCertificates(cfg.Paths().Certificate)
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	notAfter := certs[0].NotAfter
+
+	days := cfg.CertRenewDays
+	if days == 0 {
+		days = defaultCertRenewDays
+	}
+	return notAfter.Sub(now) < time.Duration(days)*24*time.Hour, notAfter, nil
+}
+
+// renewIfDue renews the device certificate when it is close to expiry and
+// switches apiClient to the new credentials. Callers skip it when
+// CertRenewDays is negative.
+func renewIfDue(ctx context.Context, cfg *config.Config, apiClient *client.Client) error {
+	due, notAfter, err := certRenewalDue(cfg, time.Now())
+	if err != nil || !due {
+		return err
+	}
+
+	fmt.Printf("Device certificate expires %s, renewing\n", notAfter.Format(time.RFC3339))
+	return renew(ctx, cfg, apiClient)
+}
+
+// renew replaces the device certificate and reloads apiClient's credentials
+func renew(ctx context.Context, cfg *config.Config, apiClient *client.Client) error {
+	ctx, cancel := context.WithTimeout(ctx, certRenewTimeout)
+	defer cancel()
+
+	cert, err := enroll.Renew(ctx, cfg, apiClient)
+	if err != nil {
+		return err
+	}
+	if err := apiClient.ReloadCredentials(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Device certificate renewed, valid until %s\n", cert.NotAfter.Format(time.RFC3339))
+	return nil
+}
+
+// RenewCertificate renews the device certificate now, whatever its expiry
+func RenewCertificate(cfg *config.Config) error {
+	if !cfg.IsEnrolled() {
+		return fmt.Errorf("device is not enrolled")
+	}
+	if cfg.CertRevoked {
+		return ErrCertificateRevoked
+	}
+
+	if err := enroll.RecoverRenewal(cfg); err != nil {
+		return err
+	}
+	apiClient, err := client.NewClient(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create API client: %w", err)
+	}
+
+	if err := renew(context.Background(), cfg, apiClient); err != nil {
+		return err
+	}
+	fmt.Println("Restart the agent service for it to use the new certificate")
+	return nil
+}
@@ -0,0 +1,188 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cloudronix/agent/internal/client"
+	"github.com/cloudronix/agent/internal/config"
+	"github.com/cloudronix/agent/pkg/playbook"
+)
+
+// Execution reports kept on disk awaiting submission when the config doesn't set a limit
+const defaultMaxPendingReports = 100
+
+// reportStore keeps execution reports on disk until the server accepts
+// them, so audit records survive a crash or a network outage between
+// running a playbook and submitting its report
+type reportStore struct {
+	dir string
+	max int
+}
+
+// newReportStore returns the report store in the config directory
+func newReportStore(cfg *config.Config) *reportStore {
+	limit := cfg.MaxPendingReports
+	if limit <= 0 {
+		limit = defaultMaxPendingReports
+	}
+	return &reportStore{dir: cfg.Paths().Reports, max: limit}
+}
+
+// path returns the file holding a job's report
+func (s *reportStore) path(jobID string) (string, error) {
+	if jobID == "" || jobID != filepath.Base(jobID) || strings.HasPrefix(jobID, ".") {
+		return "", fmt.Errorf("invalid job ID %q", jobID)
+	}
+	return filepath.Join(s.dir, jobID+".json"), nil
+}
+
+// save writes a report to disk, dropping the oldest pending reports beyond the cap
+func (s *reportStore) save(jobID string, report *playbook.ExecutionReport) error {
+	path, err := s.path(jobID)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return err
+	}
+	if err := config.WriteFileAtomic(path, data, 0600); err != nil {
+		return err
+	}
+	s.prune()
+	return nil
+}
+
+// remove deletes a submitted report
+func (s *reportStore) remove(jobID string) {
+	if path, err := s.path(jobID); err == nil {
+		os.Remove(path)
+	}
+}
+
+// pending returns the job IDs of stored reports, oldest first
+func (s *reportStore) pending() []string {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil
+	}
+
+	type stored struct {
+		jobID   string
+		modTime int64
+	}
+	var reports []stored
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		reports = append(reports, stored{strings.TrimSuffix(name, ".json"), info.ModTime().UnixNano()})
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].modTime < reports[j].modTime })
+
+	jobIDs := make([]string, len(reports))
+	for i, r := range reports {
+		jobIDs[i] = r.jobID
+	}
+	return jobIDs
+}
+
+// load reads a stored report
+func (s *reportStore) load(jobID string) (*playbook.ExecutionReport, error) {
+	path, err := s.path(jobID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var report playbook.ExecutionReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// prune deletes the oldest reports beyond the cap
+func (s *reportStore) prune() {
+	jobIDs := s.pending()
+	for len(jobIDs) > s.max {
+		fmt.Printf("Warning: dropping unsubmitted report for job %s (more than %d pending)\n", jobIDs[0], s.max)
+		s.remove(jobIDs[0])
+		jobIDs = jobIDs[1:]
+	}
+}
+
+// isPermanentRejection reports whether the server refused a report in a way
+// a retry won't fix, e.g. the job no longer exists. Connectivity and auth
+// failures may clear up, so those reports are kept.
+func isPermanentRejection(err error) bool {
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || client.IsConnectivityError(err) || client.IsAuthError(err) {
+		return false
+	}
+	return apiErr.StatusCode >= http.StatusBadRequest && apiErr.StatusCode < http.StatusInternalServerError
+}
+
+// submitReport stores a job's report on disk, then submits it. The stored
+// copy is removed once the server accepts it; otherwise it is submitted
+// again by resubmitPendingReports.
+func (r *JobRunner) submitReport(jobID string, report *playbook.ExecutionReport) error {
+	if err := r.reports.save(jobID, report); err != nil {
+		fmt.Printf("Warning: failed to store execution report: %v\n", err)
+	}
+
+	if err := r.apiClient.SubmitExecutionReport(jobID, report); err != nil {
+		if isPermanentRejection(err) {
+			r.reports.remove(jobID)
+		}
+		return err
+	}
+	r.reports.remove(jobID)
+	return nil
+}
+
+// resubmitPendingReports submits reports left on disk by an earlier failed
+// submission or a crash, oldest first. It stops at the first connectivity
+// failure and leaves the rest for the next attempt.
+func (r *JobRunner) resubmitPendingReports() {
+	for _, jobID := range r.reports.pending() {
+		report, err := r.reports.load(jobID)
+		if err != nil {
+			fmt.Printf("Warning: discarding unreadable stored report for job %s: %v\n", jobID, err)
+			r.reports.remove(jobID)
+			continue
+		}
+
+		err = r.apiClient.SubmitExecutionReport(jobID, report)
+		switch {
+		case err == nil:
+			fmt.Printf("Submitted stored execution report for job %s\n", jobID)
+			r.reports.remove(jobID)
+		case isPermanentRejection(err):
+			fmt.Printf("Warning: server rejected stored report for job %s, discarding: %v\n", jobID, err)
+			r.reports.remove(jobID)
+		default:
+			fmt.Printf("Warning: failed to submit stored report for job %s: %v\n", jobID, err)
+			if client.IsConnectivityError(err) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cloudronix/agent/internal/client"
+	"github.com/cloudronix/agent/internal/config"
+)
+
+// ErrCertificateRevoked is returned by the agent loop when the server has
+// revoked the device certificate. The device must be enrolled again.
+var ErrCertificateRevoked = errors.New("device certificate revoked by server - re-enroll this device to continue")
+
+// Maximum time to wait for a certificate status response
+const certStatusTimeout = 15 * time.Second
+
+// checkCertStatus asks the server whether the device certificate has been
+// revoked. A revocation is recorded in the config so the agent stays stopped
+// across restarts, even while the server is unreachable. Errors reaching the
+// server are returned as-is and don't stop the agent.
+func checkCertStatus(ctx context.Context, cfg *config.Config, apiClient *client.Client) error {
+	ctx, cancel := context.WithTimeout(ctx, certStatusTimeout)
+	defer cancel()
+
+	status, err := apiClient.GetCertStatus(ctx)
+	if err != nil {
+		return err
+	}
+	if !status.Revoked() {
+		return nil
+	}
+
+	msg := "Device certificate has been REVOKED by the server"
+	if status.Reason != "" {
+		msg += ": " + status.Reason
+	}
+	fmt.Println(msg)
+
+	cfg.CertRevoked = true
+	if err := cfg.Save(); err != nil {
+		fmt.Printf("Warning: failed to record certificate revocation: %v\n", err)
+	}
+
+	return ErrCertificateRevoked
+}
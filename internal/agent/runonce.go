@@ -0,0 +1,50 @@
+package agent
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cloudronix/agent/internal/config"
+	"github.com/cloudronix/agent/pkg/playbook"
+)
+
+// ListRunOnce prints the run_once markers of commands that already ran
+func ListRunOnce(cfg *config.Config) error {
+	markers, err := playbook.ListRunOnceMarkers(cfg.Paths().RunOnce)
+	if err != nil {
+		return fmt.Errorf("failed to read run_once markers: %w", err)
+	}
+
+	if len(markers) == 0 {
+		fmt.Println("No run_once markers")
+		return nil
+	}
+	for _, marker := range markers {
+		fmt.Printf("%s\t%s\n", marker.Key, marker.CompletedAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// ResetRunOnce removes run_once markers so their commands run again on the
+// next playbook run. With all set, every marker is removed.
+func ResetRunOnce(cfg *config.Config, keys []string, all bool) error {
+	dir := cfg.Paths().RunOnce
+	if all {
+		markers, err := playbook.ListRunOnceMarkers(dir)
+		if err != nil {
+			return fmt.Errorf("failed to read run_once markers: %w", err)
+		}
+		keys = keys[:0]
+		for _, marker := range markers {
+			keys = append(keys, marker.Key)
+		}
+	}
+
+	for _, key := range keys {
+		if err := playbook.ResetRunOnceMarker(dir, key); err != nil {
+			return err
+		}
+		fmt.Printf("Reset %s\n", key)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/cloudronix/agent/internal/config"
+	"github.com/cloudronix/agent/pkg/playbook"
+	"github.com/cloudronix/agent/pkg/playbook/actions"
+	"github.com/cloudronix/agent/pkg/sysinfo"
+)
+
+// RunPlaybookOptions controls a local playbook run
+type RunPlaybookOptions struct {
+	DryRun   bool
+	Tags     []string
+	SkipTags []string
+}
+
+// RunPlaybook runs a signed playbook payload from a file (JSON or YAML, in
+// the form the server sends to agents) on this device without going
+// through the job queue, and prints the execution report as JSON.
+//
+// The full verification chain applies with the server public key pinned at
+// enrollment, so unsigned, tampered or unapproved files are rejected exactly
+// as they would be for a job. Control playbooks (agent_control) don't run.
+func RunPlaybook(cfg *config.Config, path string, opts RunPlaybookOptions) error {
+	keys, err := cfg.LoadServerPublicKeys()
+	if err != nil {
+		return fmt.Errorf("failed to load server public key: %w", err)
+	}
+
+	payload, err := loadPlaybookPayload(path)
+	if err != nil {
+		return err
+	}
+
+	executor, err := playbook.NewExecutor(playbook.ExecutorConfig{
+		ServerPublicKeys: keys,
+		MaxApprovalAge:   maxApprovalAge(cfg),
+		DeviceID:         cfg.DeviceID,
+		OnProgress: func(taskName string, status playbook.TaskStatus) {
+			fmt.Fprintf(os.Stderr, "  Task '%s': %s\n", taskName, status)
+		},
+
+		WorkdirRoot:            cfg.JobWorkdirRoot,
+		RetainWorkdirOnFailure: cfg.RetainJobWorkdirOnFailure,
+		PreflightDryRun:        cfg.PreflightDryRun,
+		DefaultShell:           cfg.DefaultShell,
+		RunOnceDir:             cfg.Paths().RunOnce,
+
+		Tags:     opts.Tags,
+		SkipTags: opts.SkipTags,
+
+		PendingReboot: sysinfo.PendingReboot,
+
+		AgentVersion: agentVersion,
+		Distro:       sysinfo.Distro(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create executor: %w", err)
+	}
+	actions.RegisterAllHandlers(executor)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Progress goes to stderr so stdout holds only the report
+	if opts.DryRun {
+		fmt.Fprintf(os.Stderr, "Dry run of %s (%s) - no changes will be made\n", payload.Name, path)
+	} else {
+		fmt.Fprintf(os.Stderr, "Running %s (%s)\n", payload.Name, path)
+	}
+
+	var report *playbook.ExecutionReport
+	var execErr error
+	if opts.DryRun {
+		report, execErr = executor.DryRun(ctx, payload.ToSignedPlaybook())
+	} else {
+		report, execErr = executor.Execute(ctx, payload.ToSignedPlaybook())
+	}
+
+	if report == nil {
+		return execErr
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+
+	if execErr != nil {
+		return fmt.Errorf("playbook %s: %w", report.Status, execErr)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cloudronix/agent/internal/client"
+	"github.com/cloudronix/agent/internal/config"
+	"github.com/cloudronix/agent/pkg/sysinfo"
+)
+
+const (
+	// Minimum gap between change events, so a flapping module can't flood
+	// the server. Changes in between are sent with the next event.
+	securityEventMinInterval = 5 * time.Minute
+
+	// Full security status is included in reports at most this often
+	// while the posture is unchanged
+	securityFullReportInterval = time.Hour
+)
+
+// securityCheckInterval returns how often the security posture is
+// collected and checked for changes. Collection runs every module, so it
+// defaults to the report interval and never runs more often than change
+// events may be sent.
+func securityCheckInterval(cfg *config.Config, reportInterval time.Duration) time.Duration {
+	interval := reportInterval
+	if cfg.SecurityCheckInterval > 0 {
+		interval = time.Duration(cfg.SecurityCheckInterval) * time.Second
+	}
+	if interval < securityEventMinInterval {
+		interval = securityEventMinInterval
+	}
+	return interval
+}
+
+// securityMonitor detects security posture changes between reports. It
+// remembers the last posture sent to the server and sends a change event
+// as soon as it differs, while reports only carry the full status when it
+// changed or securityFullReportInterval has passed. It is only used from
+// the agent loop.
+type securityMonitor struct {
+	lastSent     *sysinfo.SecurityStatus
+	lastFullSent time.Time
+	lastEvent    time.Time
+}
+
+// check collects the current posture and sends a change event if it
+// differs from the last one sent. sent reports whether the server was
+// contacted, so only real requests count towards the circuit breaker.
+func (m *securityMonitor) check(ctx context.Context, apiClient *client.Client) (sent bool, err error) {
+	if m.lastSent == nil {
+		return false, nil // nothing to compare against until the first report
+	}
+	if time.Since(m.lastEvent) < securityEventMinInterval {
+		return false, nil
+	}
+
+	current := sysinfo.CollectSecurityStatus(ctx)
+	if ctx.Err() != nil {
+		return false, nil // partial results would look like changes
+	}
+
+	changes := sysinfo.DiffSecurityStatus(m.lastSent, current)
+	if len(changes) == 0 {
+		return false, nil
+	}
+
+	event := sysinfo.NewSecurityEvent(m.lastSent, current, changes)
+	if err := apiClient.SendSecurityEvent(event); err != nil {
+		return true, err
+	}
+
+	fmt.Printf("[Security] Posture changed (%d change(s), priority %s), event sent\n", len(changes), event.Priority)
+	m.lastSent = current
+	m.lastEvent = time.Now()
+	return true, nil
+}
+
+// prepareReport drops the security status from a report when it hasn't
+// changed since it was last sent and a full status isn't due yet
+func (m *securityMonitor) prepareReport(info *sysinfo.SystemInfo) {
+	if info.Security == nil || m.lastSent == nil {
+		return
+	}
+	if len(sysinfo.DiffSecurityStatus(m.lastSent, info.Security)) == 0 &&
+		time.Since(m.lastFullSent) < securityFullReportInterval {
+		info.Security = nil
+	}
+}
+
+// reportSent records the posture of a report the server accepted
+func (m *securityMonitor) reportSent(info *sysinfo.SystemInfo) {
+	if info.Security == nil {
+		return
+	}
+	m.lastSent = info.Security
+	m.lastFullSent = time.Now()
+}
@@ -0,0 +1,90 @@
+package agent
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cloudronix/agent/internal/client"
+	"github.com/cloudronix/agent/internal/config"
+	"github.com/cloudronix/agent/pkg/playbook"
+)
+
+const (
+	// Prefix of the signed message of a server key update
+	serverKeysSignatureDomain = "cloudronix-server-keys:v1:"
+
+	// Most keys trusted at once; rotation needs two
+	maxServerKeys = 8
+)
+
+// serverKeysMessage returns the message signed for a key update
+func serverKeysMessage(serial int64, keys [][]byte) []byte {
+	encoded := make([]string, len(keys))
+	for i, key := range keys {
+		encoded[i] = hex.EncodeToString(key)
+	}
+	return []byte(serverKeysSignatureDomain + strconv.FormatInt(serial, 10) + ":" + strings.Join(encoded, ","))
+}
+
+// verifyServerKeyUpdate checks a key update against the trusted keys and
+// returns the new key set, or nil if the update is not newer than the last
+// one applied
+func verifyServerKeyUpdate(lastSerial int64, trusted []ed25519.PublicKey, update *client.ServerKeyUpdate) ([]ed25519.PublicKey, error) {
+	if update.Serial <= lastSerial {
+		return nil, nil
+	}
+	if len(update.Keys) == 0 || len(update.Keys) > maxServerKeys {
+		return nil, fmt.Errorf("key update has %d keys, expected 1 to %d", len(update.Keys), maxServerKeys)
+	}
+
+	keys := make([]ed25519.PublicKey, len(update.Keys))
+	for i, key := range update.Keys {
+		if len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("key update has a %d-byte key, expected %d (Ed25519)", len(key), ed25519.PublicKeySize)
+		}
+		keys[i] = ed25519.PublicKey(key)
+	}
+
+	message := serverKeysMessage(update.Serial, update.Keys)
+	for _, key := range trusted {
+		if ed25519.Verify(key, message, update.Signature) {
+			return keys, nil
+		}
+	}
+	return nil, errors.New("key update is not signed by a trusted key")
+}
+
+// applyServerKeyUpdate saves a verified key update to server.pub and
+// switches the job runner to the new keys, so enrolled devices follow a
+// key rotation without re-enrolling. Remote commands pick the keys up
+// through the job runner too.
+func applyServerKeyUpdate(cfg *config.Config, runner *JobRunner, update *client.ServerKeyUpdate) {
+	keys, err := verifyServerKeyUpdate(cfg.ServerKeysSerial, runner.ServerPublicKeys(), update)
+	if err != nil {
+		fmt.Printf("Warning: refused server key update %d: %v\n", update.Serial, err)
+		return
+	}
+	if keys == nil {
+		return
+	}
+
+	if err := cfg.SaveServerPublicKeys(keys); err != nil {
+		fmt.Printf("Warning: failed to apply server key update %d: %v\n", update.Serial, err)
+		return
+	}
+	cfg.ServerKeysSerial = update.Serial
+	if err := cfg.Save(); err != nil {
+		fmt.Printf("Warning: failed to save config: %v\n", err)
+	}
+	runner.setServerPublicKeys(keys)
+
+	ids := make([]string, len(keys))
+	for i, key := range keys {
+		ids[i] = playbook.KeyID(key)
+	}
+	fmt.Printf("Server signing keys updated (serial %d): %s\n", update.Serial, strings.Join(ids, ", "))
+}
@@ -31,6 +31,9 @@ func Install(cfg *config.Config) error {
 func Uninstall(cfg *config.Config) error {
 	fmt.Println("Uninstalling Cloudronix Agent...")
 
+	// Tell the server before the credentials are deleted
+	notifyDecommission(cfg, "uninstall")
+
 	// Stop and remove service
 	switch runtime.GOOS {
 	case "windows":
@@ -53,6 +56,49 @@ func Uninstall(cfg *config.Config) error {
 	return nil
 }
 
+// stopService stops the installed agent service if it is running, so it
+// doesn't carry on with an identity that is being removed. It reports
+// whether a running service was stopped.
+func stopService() (bool, error) {
+	switch runtime.GOOS {
+	case "windows":
+		return stopWindowsService()
+	case "linux":
+		if exec.Command("systemctl", "is-active", "--quiet", "cloudronix-agent").Run() != nil {
+			return false, nil
+		}
+		if output, err := exec.Command("systemctl", "stop", "cloudronix-agent").CombinedOutput(); err != nil {
+			return false, fmt.Errorf("systemctl stop: %s - %w", string(output), err)
+		}
+		return true, nil
+	case "darwin":
+		// The service is KeepAlive, so it must be unloaded rather than stopped
+		if exec.Command("launchctl", "list", darwinServiceLabel).Run() != nil {
+			return false, nil
+		}
+		if output, err := exec.Command("launchctl", "unload", darwinPlistPath).CombinedOutput(); err != nil {
+			return false, fmt.Errorf("launchctl unload: %s - %w", string(output), err)
+		}
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// startServiceHint tells the user how to start the service stopped by stopService
+func startServiceHint() string {
+	switch runtime.GOOS {
+	case "windows":
+		return "sc start CloudronixAgent"
+	case "linux":
+		return "systemctl start cloudronix-agent"
+	case "darwin":
+		return "launchctl load " + darwinPlistPath
+	default:
+		return ""
+	}
+}
+
 // installLinux installs the agent as a systemd service
 func installLinux(cfg *config.Config) error {
 	exePath, err := os.Executable()
@@ -127,6 +173,15 @@ func uninstallLinux() {
 	os.Remove("/usr/local/bin/cloudronix-agent")
 }
 
+// darwinLogFile is the agent log used by the launchd service
+const darwinLogFile = "/var/log/cloudronix-agent.log"
+
+// launchd service label and plist
+const (
+	darwinServiceLabel = "io.cloudronix.agent"
+	darwinPlistPath    = "/Library/LaunchDaemons/io.cloudronix.agent.plist"
+)
+
 // installDarwin installs the agent as a launchd service
 func installDarwin(cfg *config.Config) error {
 	exePath, err := os.Executable()
@@ -147,6 +202,15 @@ func installDarwin(cfg *config.Config) error {
 		}
 	}
 
+	// The agent writes and rotates its own log; launchd's output file only
+	// catches what is printed before the log is opened, and crashes
+	if cfg.LogFile == "" {
+		cfg.LogFile = darwinLogFile
+		if err := cfg.Save(); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+	}
+
 	fmt.Println("Installing launchd service...")
 
 	plist := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
@@ -167,14 +231,14 @@ func installDarwin(cfg *config.Config) error {
     <key>KeepAlive</key>
     <true/>
     <key>StandardOutPath</key>
-    <string>/var/log/cloudronix-agent.log</string>
+    <string>/var/log/cloudronix-agent.launchd.log</string>
     <key>StandardErrorPath</key>
-    <string>/var/log/cloudronix-agent.log</string>
+    <string>/var/log/cloudronix-agent.launchd.log</string>
 </dict>
 </plist>
 `, installPath, cfg.ConfigDir)
 
-	plistPath := "/Library/LaunchDaemons/io.cloudronix.agent.plist"
+	plistPath := darwinPlistPath
 	if err := os.WriteFile(plistPath, []byte(plist), 0644); err != nil {
 		return fmt.Errorf("failed to write plist: %w", err)
 	}
@@ -193,7 +257,7 @@ func installDarwin(cfg *config.Config) error {
 
 // uninstallDarwin removes the launchd service
 func uninstallDarwin() {
-	plistPath := "/Library/LaunchDaemons/io.cloudronix.agent.plist"
+	plistPath := darwinPlistPath
 	exec.Command("launchctl", "unload", plistPath).Run()
 	os.Remove(plistPath)
 	os.Remove("/usr/local/bin/cloudronix-agent")
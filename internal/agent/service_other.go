@@ -21,3 +21,8 @@ func installWindows(cfg *config.Config) error {
 
 // uninstallWindows stub for non-Windows platforms (never called due to runtime.GOOS check)
 func uninstallWindows() {}
+
+// stopWindowsService stub for non-Windows platforms (never called due to runtime.GOOS check)
+func stopWindowsService() (bool, error) {
+	return false, nil
+}
@@ -3,6 +3,7 @@
 package agent
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -58,6 +59,10 @@ func (s *cloudronixService) Execute(args []string, r <-chan svc.ChangeRequest, c
 				changes <- c.CurrentStatus
 			}
 		case err := <-errCh:
+			if errors.Is(err, ErrRestartRequested) {
+				// Non-zero exit triggers the configured recovery (restart) actions
+				return true, 1
+			}
 			if err != nil {
 				// Log error somewhere if needed
 			}
@@ -143,6 +148,10 @@ func installWindows(cfg *config.Config) error {
 	if err := s.SetRecoveryActions(recoveryActions, 3600); err != nil {
 		fmt.Printf("Warning: failed to set recovery actions: %v\n", err)
 	}
+	// Also restart when the agent exits with an error (e.g. restart requested)
+	if err := s.SetRecoveryActionsOnNonCrashFailures(true); err != nil {
+		fmt.Printf("Warning: failed to enable recovery on non-crash failures: %v\n", err)
+	}
 
 	// Start the service
 	fmt.Println("Starting service...")
@@ -161,6 +170,39 @@ func installWindows(cfg *config.Config) error {
 	return nil
 }
 
+// stopWindowsService stops the Windows Service if it is running
+func stopWindowsService() (bool, error) {
+	m, err := mgr.Connect()
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return false, nil // Not installed
+	}
+	defer s.Close()
+
+	status, err := s.Query()
+	if err != nil {
+		return false, fmt.Errorf("failed to query service: %w", err)
+	}
+	if status.State == svc.Stopped {
+		return false, nil
+	}
+
+	if _, err := s.Control(svc.Stop); err != nil {
+		return false, fmt.Errorf("failed to stop service: %w", err)
+	}
+	for deadline := time.Now().Add(20 * time.Second); time.Now().Before(deadline); time.Sleep(500 * time.Millisecond) {
+		if status, err = s.Query(); err == nil && status.State == svc.Stopped {
+			return true, nil
+		}
+	}
+	return false, errors.New("timed out waiting for the service to stop")
+}
+
 // uninstallWindows removes the Windows Service
 func uninstallWindows() {
 	m, err := mgr.Connect()
@@ -0,0 +1,108 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cloudronix/agent/internal/client"
+	"github.com/cloudronix/agent/internal/config"
+)
+
+// Maximum time to wait for the server to acknowledge a decommission
+const decommissionTimeout = 10 * time.Second
+
+// UnenrollOptions controls how a device is unenrolled
+type UnenrollOptions struct {
+	// New server URLs to enroll against next (optional)
+	ServerURL string
+	AgentURL  string
+
+	// SkipNotify skips telling the server the device is leaving
+	SkipNotify bool
+}
+
+// Unenroll clears the device identity and credentials but leaves the installed
+// service and binary in place, so the device can be enrolled again - possibly
+// against a different server - without a reinstall. A running service is
+// stopped first, since it would otherwise keep using the old identity it
+// holds in memory.
+func Unenroll(cfg *config.Config, opts UnenrollOptions) error {
+	if !cfg.IsEnrolled() {
+		return fmt.Errorf("device is not enrolled")
+	}
+
+	fmt.Printf("Unenrolling device %s...\n", cfg.DeviceID)
+
+	stopped, err := stopService()
+	if err != nil {
+		return fmt.Errorf("failed to stop the agent service: %w\nStop it manually and run unenroll again", err)
+	}
+	if stopped {
+		fmt.Println("Agent service stopped")
+	}
+
+	// Must happen while the credentials still exist
+	if !opts.SkipNotify {
+		notifyDecommission(cfg, "unenroll")
+	}
+
+	// Remove credentials
+	paths := cfg.Paths()
+	for _, path := range []string{paths.Certificate, paths.PrivateKey, paths.CACert, paths.ServerPublicKey} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+	}
+
+	// Clear identity and optionally point at a new server
+	cfg.DeviceID = ""
+	cfg.ServerKeysSerial = 0
+	if opts.ServerURL != "" {
+		cfg.ServerURL = opts.ServerURL
+	}
+	if opts.AgentURL != "" {
+		cfg.AgentURL = opts.AgentURL
+	}
+	if err := cfg.Save(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	fmt.Println("Unenroll complete - credentials removed, service and binary left in place")
+	fmt.Printf("Server URL: %s\n", cfg.ServerURL)
+	fmt.Println()
+	fmt.Println("Run 'cloudronix-agent enroll <token>' to enroll this device again.")
+	if stopped {
+		fmt.Printf("Then start the service again: %s\n", startServiceHint())
+	}
+	return nil
+}
+
+// notifyDecommission makes a best-effort call telling the server the device is
+// being decommissioned. Failures are reported but never block local cleanup,
+// since the device may be offline.
+func notifyDecommission(cfg *config.Config, reason string) {
+	if !cfg.IsEnrolled() {
+		return
+	}
+
+	fmt.Println("Notifying server...")
+
+	apiClient, err := client.NewClient(cfg)
+	if err != nil {
+		fmt.Printf("Warning: could not notify server: %v\n", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), decommissionTimeout)
+	defer cancel()
+
+	if err := apiClient.Decommission(ctx, reason); err != nil {
+		fmt.Printf("Warning: could not notify server: %v\n", err)
+		fmt.Println("The device may still appear in the dashboard until removed there")
+		return
+	}
+
+	fmt.Println("Server notified - device marked as decommissioned")
+}
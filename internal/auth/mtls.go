@@ -5,6 +5,7 @@ import (
 	"crypto/ecdsa"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/tls"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/hex"
@@ -12,6 +13,7 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/cloudronix/agent/internal/config"
 )
@@ -101,16 +103,91 @@ func (c *Credentials) Sign(message string) (string, error) {
 }
 
 // NewMTLSClient creates an HTTP client for agent communication
-// For Cloudflare mode (https://), uses system CAs - auth is via headers
-// For direct mTLS mode, would use internal CA + client cert (not implemented yet)
+// For Cloudflare mode (auth_mode "headers"), uses system CAs - auth is via headers
+// For direct mTLS mode (auth_mode "mtls"), uses the enrolled CA + client cert
 func NewMTLSClient(cfg *config.Config) (*http.Client, error) {
-	// For both http:// and https:// URLs going through Cloudflare,
-	// we use a standard HTTP client. Authentication is handled via
-	// X-Client-Certificate, X-Client-Timestamp, X-Client-Signature headers
-	// (added by addAuthHeaders in api.go)
+	tlsConfig, err := TLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig == nil {
+		// For both http:// and https:// URLs going through Cloudflare,
+		// we use a standard HTTP client. Authentication is handled via
+		// X-Client-Certificate, X-Client-Timestamp, X-Client-Signature headers
+		// (added by addAuthHeaders in api.go)
+
+		// Use system root CAs for TLS verification (Cloudflare's cert is trusted)
+		return &http.Client{}, nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport}, nil
+}
+
+// TLSConfig returns the TLS configuration for connections to the agent API.
+// In mtls mode it presents the device certificate and trusts only the
+// enrolled CA; in headers mode it returns nil for the system defaults.
+// Configured pins are checked in both modes.
+func TLSConfig(cfg *config.Config) (*tls.Config, error) {
+	var verifyPins func(tls.ConnectionState) error
+	if len(cfg.PinnedSPKI) > 0 {
+		if err := requirePinnable(cfg, cfg.AgentURL); err != nil {
+			return nil, err
+		}
+		var err error
+		if verifyPins, err = pinVerifier(cfg.PinnedSPKI); err != nil {
+			return nil, err
+		}
+	}
+
+	switch cfg.AuthMode {
+	case "", config.AuthModeHeaders:
+		if verifyPins == nil {
+			return nil, nil
+		}
+		return &tls.Config{
+			MinVersion:       tls.VersionTLS12,
+			VerifyConnection: verifyPins,
+		}, nil
+	case config.AuthModeMTLS:
+	default:
+		return nil, fmt.Errorf("unknown auth_mode '%s' (expected '%s' or '%s')", cfg.AuthMode, config.AuthModeHeaders, config.AuthModeMTLS)
+	}
+
+	if !strings.HasPrefix(cfg.AgentURL, "https://") {
+		return nil, fmt.Errorf("auth_mode '%s' requires an https:// agent_url", config.AuthModeMTLS)
+	}
 
-	// Use system root CAs for TLS verification (Cloudflare's cert is trusted)
-	return &http.Client{}, nil
+	paths := cfg.Paths()
+	caPEM, err := os.ReadFile(paths.CACert)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificates found in %s", paths.CACert)
+	}
+
+	// Fail early on unusable credentials
+	if _, err := tls.LoadX509KeyPair(paths.Certificate, paths.PrivateKey); err != nil {
+		return nil, fmt.Errorf("failed to load client certificate: %w", err)
+	}
+
+	return &tls.Config{
+		RootCAs:          roots,
+		MinVersion:       tls.VersionTLS12,
+		VerifyConnection: verifyPins,
+		// Read the key pair for every handshake so a renewed certificate is
+		// used without restarting
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, err := tls.LoadX509KeyPair(paths.Certificate, paths.PrivateKey)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load client certificate: %w", err)
+			}
+			return &cert, nil
+		},
+	}, nil
 }
 
 // GetCertificateFingerprint returns the SHA-256 fingerprint of the device certificate
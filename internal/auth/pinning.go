@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/cloudronix/agent/internal/config"
+)
+
+// ErrPinMismatch is returned by the TLS handshake when the server presents
+// no pinned key. It is never retried.
+var ErrPinMismatch = errors.New("certificate pin mismatch")
+
+// SPKIPin returns the pin of a certificate's public key: the base64 SHA-256
+// of its DER-encoded SubjectPublicKeyInfo, in the "sha256/..." form
+func SPKIPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return "sha256/" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// parsePins decodes configured pins, with or without the "sha256/" prefix
+func parsePins(pins []string) ([][sha256.Size]byte, error) {
+	parsed := make([][sha256.Size]byte, 0, len(pins))
+	for _, pin := range pins {
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(strings.TrimSpace(pin), "sha256/"))
+		if err != nil || len(raw) != sha256.Size {
+			return nil, fmt.Errorf("invalid pin '%s': expected the base64 SHA-256 of a public key", pin)
+		}
+		var sum [sha256.Size]byte
+		copy(sum[:], raw)
+		parsed = append(parsed, sum)
+	}
+	return parsed, nil
+}
+
+// pinVerifier returns a VerifyConnection callback that accepts a connection
+// only if a certificate the server presented has one of the pinned keys.
+// It runs after the normal chain verification, so pinning narrows the
+// trusted certificates but never widens them.
+func pinVerifier(pins []string) (func(tls.ConnectionState) error, error) {
+	parsed, err := parsePins(pins)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(cs tls.ConnectionState) error {
+		for _, cert := range cs.PeerCertificates {
+			sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+			for _, pin := range parsed {
+				if sum == pin {
+					return nil
+				}
+			}
+		}
+		if len(cs.PeerCertificates) == 0 {
+			return fmt.Errorf("%w: no server certificate", ErrPinMismatch)
+		}
+		return fmt.Errorf("%w: server key %s is not pinned", ErrPinMismatch, SPKIPin(cs.PeerCertificates[0]))
+	}, nil
+}
+
+// requirePinnable rejects plain HTTP URLs when pins are configured, since
+// there is no certificate to check
+func requirePinnable(cfg *config.Config, url string) error {
+	if len(cfg.PinnedSPKI) > 0 && !strings.HasPrefix(url, "https://") {
+		return fmt.Errorf("pinned_spki requires an https:// URL, got %s", url)
+	}
+	return nil
+}
+
+// NewEnrollClient creates the HTTP client used for enrollment. It trusts the
+// system CAs, restricted to cfg.PinnedSPKI when pins are configured, so the
+// enrollment token is only ever sent to the pinned server.
+func NewEnrollClient(cfg *config.Config) (*http.Client, error) {
+	if len(cfg.PinnedSPKI) == 0 {
+		return &http.Client{}, nil
+	}
+	if err := requirePinnable(cfg, cfg.ServerURL); err != nil {
+		return nil, err
+	}
+
+	verify, err := pinVerifier(cfg.PinnedSPKI)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		MinVersion:       tls.VersionTLS12,
+		VerifyConnection: verify,
+	}
+	return &http.Client{Transport: transport}, nil
+}
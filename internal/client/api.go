@@ -2,11 +2,16 @@ package client
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/cloudronix/agent/internal/auth"
@@ -17,9 +22,15 @@ import (
 
 // Client is the API client for communicating with the Cloudronix server
 type Client struct {
-	cfg         *config.Config
-	httpClient  *http.Client
+	cfg        *config.Config
+	httpClient *http.Client
+
+	// Replaced by ReloadCredentials after a certificate renewal
+	credMu      sync.RWMutex
 	credentials *auth.Credentials
+
+	// Reports and metrics waiting for the server to be reachable (nil = no buffering)
+	queue *sendQueue
 }
 
 // AgentConfig is the configuration received from the server
@@ -28,12 +39,32 @@ type AgentConfig struct {
 	DeviceName               string `json:"device_name"`
 	HeartbeatIntervalSeconds int    `json:"heartbeat_interval_seconds"`
 	ReportIntervalSeconds    int    `json:"report_interval_seconds"`
+
+	// Disk alert thresholds - override the local config when set
+	DiskWarningPercent  float64 `json:"disk_warning_percent,omitempty"`
+	DiskCriticalPercent float64 `json:"disk_critical_percent,omitempty"`
+
+	// Files to hash for integrity monitoring - replaces the local list when set
+	WatchedFiles []string `json:"watched_files,omitempty"`
 }
 
 // HeartbeatResponse is the response from a heartbeat request
 type HeartbeatResponse struct {
 	Ack        bool      `json:"ack"`
 	ServerTime time.Time `json:"server_time"`
+
+	// Set while the server is rotating its playbook signing keys
+	ServerKeys *ServerKeyUpdate `json:"server_keys,omitempty"`
+}
+
+// ServerKeyUpdate replaces the trusted playbook signing keys of an enrolled
+// device. It must be signed by a key the device already trusts, over
+// "cloudronix-server-keys:v1:<serial>:<hex key>,<hex key>...", and its
+// serial must be higher than that of the last update applied.
+type ServerKeyUpdate struct {
+	Serial    int64    `json:"serial"`
+	Keys      [][]byte `json:"keys"`      // raw Ed25519 public keys, base64 in JSON
+	Signature []byte   `json:"signature"` // base64 in JSON
 }
 
 // NewClient creates a new API client with mTLS authentication
@@ -53,6 +84,7 @@ func NewClient(cfg *config.Config) (*Client, error) {
 		cfg:         cfg,
 		httpClient:  httpClient,
 		credentials: credentials,
+		queue:       newSendQueue(cfg),
 	}, nil
 }
 
@@ -66,12 +98,17 @@ func (c *Client) GetConfig() (*AgentConfig, error) {
 	}
 	c.addAuthHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	start := time.Now()
+	resp, err := c.doIdempotent(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get config: %w", err)
 	}
 	defer resp.Body.Close()
 
+	// Lets status and doctor report clock skew without a heartbeat; a
+	// rejected request still carries the Date header
+	recordDateHeader(resp, start, time.Now())
+
 	if resp.StatusCode != http.StatusOK {
 		return nil, c.parseError(resp)
 	}
@@ -121,8 +158,12 @@ func (c *Client) SendHeartbeat() (*HeartbeatResponse, error) {
 		return nil, fmt.Errorf("failed to send heartbeat: %w", err)
 	}
 	defer resp.Body.Close()
+	end := time.Now()
 
 	if resp.StatusCode != http.StatusOK {
+		// A skewed clock gets every signed request rejected; the Date
+		// header still tells how far off it is
+		recordDateHeader(resp, start, end)
 		return nil, c.parseError(resp)
 	}
 
@@ -130,46 +171,81 @@ func (c *Client) SendHeartbeat() (*HeartbeatResponse, error) {
 	if err := json.NewDecoder(resp.Body).Decode(&heartbeat); err != nil {
 		return nil, fmt.Errorf("failed to parse heartbeat response: %w", err)
 	}
+	recordServerTime(start, end, heartbeat.ServerTime)
 
 	return &heartbeat, nil
 }
 
-// SendReport sends a system report to the server
+// SendReport sends a system report to the server. If the server can't be
+// reached, the report is buffered and sent by FlushPending.
 func (c *Client) SendReport(info *sysinfo.SystemInfo) error {
-	url := c.cfg.AgentURL + "/agent/report"
-
+	info.Labels = c.cfg.Labels
 	body, err := json.Marshal(info)
 	if err != nil {
 		return fmt.Errorf("failed to serialize report: %w", err)
 	}
 
+	return c.sendBuffered(queuedReport, "/agent/report", body)
+}
+
+// SendMetrics sends real-time metrics to the server. If the server can't be
+// reached, the metrics are buffered and sent by FlushPending.
+func (c *Client) SendMetrics(metrics *sysinfo.Metrics) error {
+	metrics.Labels = c.cfg.Labels
+	body, err := json.Marshal(metrics)
+	if err != nil {
+		return fmt.Errorf("failed to serialize metrics: %w", err)
+	}
+
+	return c.sendBuffered(queuedMetrics, "/agent/metrics", body)
+}
+
+// SendSecurityEvent reports a change in the device's security posture as
+// soon as it is detected, ahead of the next full report
+func (c *Client) SendSecurityEvent(event *sysinfo.SecurityEvent) error {
+	url := c.cfg.AgentURL + "/agent/security-events"
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to serialize security event: %w", err)
+	}
+
 	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Priority", event.Priority)
 	c.addAuthHeaders(req)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send report: %w", err)
+		return fmt.Errorf("failed to send security event: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
 		return c.parseError(resp)
 	}
 
 	return nil
 }
 
-// SendMetrics sends real-time metrics to the server
-func (c *Client) SendMetrics(metrics *sysinfo.Metrics) error {
-	url := c.cfg.AgentURL + "/agent/metrics"
+// CapabilitiesReport tells the server which actions and features this agent supports
+type CapabilitiesReport struct {
+	AgentVersion string `json:"agent_version"`
+	Architecture string `json:"architecture"`
+	playbook.Capabilities
+}
 
-	body, err := json.Marshal(metrics)
+// SendCapabilities reports the agent's supported actions and features so the
+// server can avoid dispatching playbooks this agent cannot run
+func (c *Client) SendCapabilities(report *CapabilitiesReport) error {
+	url := c.cfg.AgentURL + "/agent/capabilities"
+
+	body, err := json.Marshal(report)
 	if err != nil {
-		return fmt.Errorf("failed to serialize metrics: %w", err)
+		return fmt.Errorf("failed to serialize capabilities: %w", err)
 	}
 
 	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
@@ -181,36 +257,190 @@ func (c *Client) SendMetrics(metrics *sysinfo.Metrics) error {
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send metrics: %w", err)
+		return fmt.Errorf("failed to send capabilities: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return c.parseError(resp)
+	}
+
+	return nil
+}
+
+// Certificate statuses reported by the server
+const (
+	CertStatusActive  = "active"
+	CertStatusRevoked = "revoked"
+)
+
+// CertStatus is the server's view of the device certificate
+type CertStatus struct {
+	Status    string     `json:"status"` // "active" or "revoked"
+	Reason    string     `json:"reason,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Revoked reports whether the server has revoked the certificate
+func (s *CertStatus) Revoked() bool {
+	return s.Status == CertStatusRevoked
+}
+
+// GetCertStatus asks the server whether the device certificate is still active
+func (c *Client) GetCertStatus(ctx context.Context) (*CertStatus, error) {
+	url := c.cfg.AgentURL + "/agent/cert-status"
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	c.addAuthHeaders(req)
+
+	resp, err := c.doIdempotent(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get certificate status: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
+		return nil, c.parseError(resp)
+	}
+
+	var status CertStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, fmt.Errorf("failed to parse certificate status: %w", err)
+	}
+
+	return &status, nil
+}
+
+// DecommissionRequest tells the server the device is being removed
+type DecommissionRequest struct {
+	Reason string `json:"reason"` // "uninstall" or "unenroll"
+}
+
+// Decommission tells the server this device is leaving the fleet so the
+// dashboard can mark it decommissioned
+func (c *Client) Decommission(ctx context.Context, reason string) error {
+	url := c.cfg.AgentURL + "/agent/decommission"
+
+	body, err := json.Marshal(DecommissionRequest{Reason: reason})
+	if err != nil {
+		return fmt.Errorf("failed to serialize request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.addAuthHeaders(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send decommission: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
 		return c.parseError(resp)
 	}
 
 	return nil
 }
 
+// RenewRequest asks the server to issue a new device certificate for a
+// freshly generated key. It is authenticated with the current certificate.
+type RenewRequest struct {
+	CSRPEM string `json:"csr_pem"`
+}
+
+// RenewResponse carries the renewed device certificate
+type RenewResponse struct {
+	CertificatePEM   string `json:"certificate_pem"`
+	CACertificatePEM string `json:"ca_certificate_pem,omitempty"` // set when the CA changed
+}
+
+// RenewCertificate sends a CSR for a new device key and returns the
+// certificate the server issued for it
+func (c *Client) RenewCertificate(ctx context.Context, csrPEM string) (*RenewResponse, error) {
+	url := c.cfg.AgentURL + "/agent/renew"
+
+	body, err := json.Marshal(RenewRequest{CSRPEM: csrPEM})
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.addAuthHeaders(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send renewal request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.parseError(resp)
+	}
+
+	var renewed RenewResponse
+	if err := json.NewDecoder(resp.Body).Decode(&renewed); err != nil {
+		return nil, fmt.Errorf("failed to parse renewal response: %w", err)
+	}
+	return &renewed, nil
+}
+
+// ReloadCredentials loads the device certificate and key from disk again,
+// e.g. after they were renewed
+func (c *Client) ReloadCredentials() error {
+	credentials, err := auth.LoadCredentials(c.cfg)
+	if err != nil {
+		return fmt.Errorf("failed to load credentials: %w", err)
+	}
+
+	c.credMu.Lock()
+	defer c.credMu.Unlock()
+	c.credentials = credentials
+	return nil
+}
+
+// creds returns the current device credentials
+func (c *Client) creds() *auth.Credentials {
+	c.credMu.RLock()
+	defer c.credMu.RUnlock()
+	return c.credentials
+}
+
 // addAuthHeaders adds device authentication headers to the request
 // These headers provide certificate-based authentication through Cloudflare
 // The server verifies: certificate validity, signature (proves private key possession)
+// In mtls mode the TLS handshake authenticates the device, so only the
+// device ID is sent unless signing is enabled explicitly
 func (c *Client) addAuthHeaders(req *http.Request) {
 	// Legacy headers for backwards compatibility
 	req.Header.Set("X-Device-ID", c.cfg.DeviceID)
-	req.Header.Set("X-Cert-Fingerprint", c.credentials.Fingerprint)
+	if !c.cfg.SignRequests() {
+		return
+	}
+	credentials := c.creds()
+	req.Header.Set("X-Cert-Fingerprint", credentials.Fingerprint)
 
 	// New certificate-based authentication headers for Cloudflare mode
 	// 1. Certificate (base64-encoded DER)
-	req.Header.Set("X-Client-Certificate", c.credentials.CertificateBase64())
+	req.Header.Set("X-Client-Certificate", credentials.CertificateBase64())
 
 	// 2. Timestamp (Unix seconds) - for replay protection
-	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	timestamp := strconv.FormatInt(signingTime(c.cfg).Unix(), 10)
 	req.Header.Set("X-Client-Timestamp", timestamp)
 
 	// 3. Signature of "{timestamp}:{method}:{path}" - proves private key possession
 	message := fmt.Sprintf("%s:%s:%s", timestamp, req.Method, req.URL.Path)
-	if signature, err := c.credentials.Sign(message); err == nil {
+	if signature, err := credentials.Sign(message); err == nil {
 		req.Header.Set("X-Client-Signature", signature)
 	}
 }
@@ -224,11 +454,13 @@ func (c *Client) parseError(resp *http.Response) error {
 		Message string `json:"message"`
 	}
 
+	apiErr := &APIError{StatusCode: resp.StatusCode, Status: resp.Status}
 	if json.Unmarshal(body, &errResp) == nil && errResp.Message != "" {
-		return fmt.Errorf("server error (%d): %s - %s", resp.StatusCode, errResp.Error, errResp.Message)
+		apiErr.Code = errResp.Error
+		apiErr.Message = errResp.Message
 	}
 
-	return fmt.Errorf("server error: %s", resp.Status)
+	return apiErr
 }
 
 // ============================================================================
@@ -240,8 +472,11 @@ type PendingJob struct {
 	JobID        string    `json:"job_id"`
 	PlaybookID   string    `json:"playbook_id"`
 	PlaybookName string    `json:"playbook_name"`
-	Priority     int       `json:"priority"`
+	Priority     int       `json:"priority"` // higher runs first
 	IsTestRun    bool      `json:"is_test_run"`
+	ApplyMode    bool      `json:"apply_mode,omitempty"` // test run explicitly requests real changes
+	Tags         []string  `json:"tags,omitempty"`       // run only tasks with one of these tags
+	SkipTags     []string  `json:"skip_tags,omitempty"`  // skip tasks with any of these tags
 	CreatedAt    time.Time `json:"created_at"`
 }
 
@@ -271,9 +506,13 @@ func (p *SignedPlaybookPayload) ToSignedPlaybook() *playbook.SignedPlaybook {
 	}
 }
 
-// GetPendingJobs fetches all pending jobs for this device
-func (c *Client) GetPendingJobs() ([]PendingJob, error) {
+// GetPendingJobs fetches pending jobs for this device.
+// A positive limit asks the server for at most that many jobs.
+func (c *Client) GetPendingJobs(limit int) ([]PendingJob, error) {
 	url := c.cfg.AgentURL + "/agent/jobs"
+	if limit > 0 {
+		url = fmt.Sprintf("%s?limit=%d", url, limit)
+	}
 
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
@@ -281,7 +520,7 @@ func (c *Client) GetPendingJobs() ([]PendingJob, error) {
 	}
 	c.addAuthHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.doIdempotent(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get pending jobs: %w", err)
 	}
@@ -299,7 +538,9 @@ func (c *Client) GetPendingJobs() ([]PendingJob, error) {
 	return jobs, nil
 }
 
-// MarkJobStarted tells the server that this job has started execution
+// MarkJobStarted tells the server that this job has started execution.
+// It is not retried: the server may already have recorded a start that
+// timed out on the way back.
 func (c *Client) MarkJobStarted(jobID string) error {
 	url := fmt.Sprintf("%s/agent/jobs/%s/start", c.cfg.AgentURL, jobID)
 
@@ -332,7 +573,7 @@ func (c *Client) GetPlaybook(playbookID string) (*SignedPlaybookPayload, error)
 	}
 	c.addAuthHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.doIdempotent(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get playbook: %w", err)
 	}
@@ -360,7 +601,7 @@ func (c *Client) GetTestPlaybook(jobID, playbookID string) (*SignedPlaybookPaylo
 	}
 	c.addAuthHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.doIdempotent(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get test playbook: %w", err)
 	}
@@ -382,6 +623,7 @@ func (c *Client) GetTestPlaybook(jobID, playbookID string) (*SignedPlaybookPaylo
 func (c *Client) SubmitExecutionReport(jobID string, report *playbook.ExecutionReport) error {
 	url := fmt.Sprintf("%s/agent/jobs/%s/report", c.cfg.AgentURL, jobID)
 
+	report.Labels = c.cfg.Labels
 	body, err := json.Marshal(report)
 	if err != nil {
 		return fmt.Errorf("failed to serialize report: %w", err)
@@ -394,6 +636,15 @@ func (c *Client) SubmitExecutionReport(jobID string, report *playbook.ExecutionR
 	req.Header.Set("Content-Type", "application/json")
 	c.addAuthHeaders(req)
 
+	// Sign the report body so the server can verify the results were not
+	// altered after leaving the device. The job ID is bound into the signed
+	// message so a report cannot be replayed against another job.
+	signature, err := c.creds().Sign(reportSigningMessage(jobID, body))
+	if err != nil {
+		return fmt.Errorf("failed to sign report: %w", err)
+	}
+	req.Header.Set("X-Report-Signature", signature)
+
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to submit report: %w", err)
@@ -406,3 +657,63 @@ func (c *Client) SubmitExecutionReport(jobID string, report *playbook.ExecutionR
 
 	return nil
 }
+
+// ArtifactUpload is a file sent from the device for a job
+type ArtifactUpload struct {
+	Name          string `json:"name"`
+	ContentBase64 string `json:"content_base64"`
+	SHA256        string `json:"sha256"`
+	Size          int    `json:"size"`
+}
+
+// ArtifactResponse is the server's reply to an artifact upload
+type ArtifactResponse struct {
+	ArtifactID string `json:"artifact_id"`
+}
+
+// UploadArtifact sends a file collected by a job (e.g. a log) to the server
+// and returns the artifact ID it was stored under
+func (c *Client) UploadArtifact(jobID, name string, data []byte) (string, error) {
+	url := fmt.Sprintf("%s/agent/jobs/%s/artifacts", c.cfg.AgentURL, jobID)
+
+	sum := sha256.Sum256(data)
+	body, err := json.Marshal(ArtifactUpload{
+		Name:          name,
+		ContentBase64: base64.StdEncoding.EncodeToString(data),
+		SHA256:        hex.EncodeToString(sum[:]),
+		Size:          len(data),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize artifact: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.addAuthHeaders(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload artifact: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return "", c.parseError(resp)
+	}
+
+	var artifact ArtifactResponse
+	if err := json.NewDecoder(resp.Body).Decode(&artifact); err != nil {
+		return "", fmt.Errorf("failed to parse artifact response: %w", err)
+	}
+
+	return artifact.ArtifactID, nil
+}
+
+// reportSigningMessage builds the message signed for an execution report:
+// "{job_id}:{body}", where body is the exact JSON sent in the request
+func reportSigningMessage(jobID string, body []byte) string {
+	return jobID + ":" + string(body)
+}
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cloudronix/agent/internal/config"
+)
+
+// ClockSkewWarning is the offset from the server clock above which the agent
+// warns. Signed requests carry a timestamp the server checks for
+// replay protection, so a wrong clock makes every request fail.
+const ClockSkewWarning = time.Minute
+
+// serverClock tracks the offset of the server clock from the local one,
+// measured on heartbeats
+var serverClock struct {
+	mu       sync.Mutex
+	offset   time.Duration // server time minus local time
+	measured bool
+}
+
+// recordServerTime updates the clock offset from a server timestamp received
+// in response to a request sent at start and answered at end. The server
+// time is compared with the middle of the round trip.
+func recordServerTime(start, end, serverTime time.Time) {
+	if serverTime.IsZero() {
+		return
+	}
+	local := start.Add(end.Sub(start) / 2)
+	offset := serverTime.Sub(local)
+
+	serverClock.mu.Lock()
+	defer serverClock.mu.Unlock()
+	serverClock.offset = offset
+	serverClock.measured = true
+}
+
+// recordDateHeader updates the clock offset from the Date header of a
+// response, e.g. when the server rejected a request and sent no body
+// timestamp. The header only has second precision.
+func recordDateHeader(resp *http.Response, start, end time.Time) {
+	if date, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+		recordServerTime(start, end, date)
+	}
+}
+
+// ClockOffset returns the last measured offset of the server clock from the
+// local clock (positive when the local clock is behind), and whether one
+// has been measured
+func ClockOffset() (time.Duration, bool) {
+	serverClock.mu.Lock()
+	defer serverClock.mu.Unlock()
+	return serverClock.offset, serverClock.measured
+}
+
+// DescribeClockOffset describes a clock offset for logs and status output
+func DescribeClockOffset(offset time.Duration) string {
+	direction := "behind"
+	if offset < 0 {
+		direction = "ahead of"
+	}
+	return fmt.Sprintf("local clock is %v %s the server", absDuration(offset).Round(time.Millisecond), direction)
+}
+
+// signingTime returns the time used in request signatures: the local time,
+// corrected by the measured offset when cfg.CompensateClockSkew is set
+func signingTime(cfg *config.Config) time.Time {
+	now := time.Now()
+	if cfg.CompensateClockSkew {
+		if offset, ok := ClockOffset(); ok {
+			now = now.Add(offset)
+		}
+	}
+	return now
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
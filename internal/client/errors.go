@@ -0,0 +1,59 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// APIError is returned when the server responds with a non-success status
+type APIError struct {
+	StatusCode int
+	Status     string
+	Code       string // server error code, if provided
+	Message    string // server error message, if provided
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("server error (%d): %s - %s", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("server error: %s", e.Status)
+}
+
+// IsAuthError reports whether the server rejected the device's credentials
+func IsAuthError(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden
+	}
+	return false
+}
+
+// IsConnectivityError reports whether err means the server could not be reached
+// or is temporarily unavailable. Auth rejections and other client errors are
+// not connectivity errors.
+func IsConnectivityError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests:
+			return true
+		}
+		return apiErr.StatusCode >= 500
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
@@ -0,0 +1,210 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cloudronix/agent/internal/config"
+)
+
+// Buffered requests of each kind kept while the server is unreachable when
+// the config doesn't set a limit
+const defaultSendQueueSize = 100
+
+// Kinds of buffered requests
+const (
+	queuedReport  = "report"
+	queuedMetrics = "metrics"
+)
+
+// queuedRequest is a report or metrics upload that failed to reach the
+// server. The body is kept as serialized, so the data and its timestamps
+// are sent unchanged; CollectedAt is sent as X-Collected-At for payloads
+// that have no timestamp of their own.
+type queuedRequest struct {
+	Kind        string          `json:"kind"`
+	Path        string          `json:"path"`
+	Body        json.RawMessage `json:"body"`
+	CollectedAt time.Time       `json:"collected_at"`
+}
+
+// sendQueue buffers reports and metrics while the server is unreachable.
+// Each kind holds at most max entries; the oldest is dropped when full, so
+// a device offline for hours keeps only its most recent data. If path is
+// set, the queue is also kept on disk and survives a restart.
+type sendQueue struct {
+	mu       sync.Mutex
+	requests []queuedRequest
+	max      int
+	path     string
+}
+
+// newSendQueue creates the queue configured for cfg, loading any requests
+// left on disk. It returns nil when buffering is disabled.
+func newSendQueue(cfg *config.Config) *sendQueue {
+	if cfg.SendQueueSize < 0 {
+		return nil
+	}
+	q := &sendQueue{max: cfg.SendQueueSize}
+	if q.max == 0 {
+		q.max = defaultSendQueueSize
+	}
+
+	if cfg.PersistSendQueue {
+		q.path = cfg.Paths().SendQueue
+		if data, err := os.ReadFile(q.path); err == nil {
+			if err := json.Unmarshal(data, &q.requests); err != nil {
+				fmt.Printf("Warning: discarding unreadable send queue: %v\n", err)
+				q.requests = nil
+			}
+		}
+	}
+	return q
+}
+
+// push adds a failed request, dropping the oldest of its kind when full
+func (q *sendQueue) push(req queuedRequest) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	count := 0
+	oldest := -1
+	for i, queued := range q.requests {
+		if queued.Kind == req.Kind {
+			if oldest < 0 {
+				oldest = i
+			}
+			count++
+		}
+	}
+	if count >= q.max {
+		q.requests = append(q.requests[:oldest], q.requests[oldest+1:]...)
+	}
+
+	q.requests = append(q.requests, req)
+	q.save()
+}
+
+// peek returns the oldest queued request
+func (q *sendQueue) peek() (queuedRequest, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.requests) == 0 {
+		return queuedRequest{}, false
+	}
+	return q.requests[0], true
+}
+
+// pop removes the oldest queued request
+func (q *sendQueue) pop() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.requests) > 0 {
+		q.requests = q.requests[1:]
+		q.save()
+	}
+}
+
+// size returns the number of queued requests
+func (q *sendQueue) size() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.requests)
+}
+
+// save writes the queue to disk if persistence is enabled. Called with mu held.
+func (q *sendQueue) save() {
+	if q.path == "" {
+		return
+	}
+	if len(q.requests) == 0 {
+		os.Remove(q.path)
+		return
+	}
+	data, err := json.Marshal(q.requests)
+	if err == nil {
+		err = config.WriteFileAtomic(q.path, data, 0600)
+	}
+	if err != nil {
+		fmt.Printf("Warning: failed to save send queue: %v\n", err)
+	}
+}
+
+// sendBuffered posts a serialized report or metrics body. If the server
+// can't be reached, the body is queued for FlushPending and the error is
+// still returned so callers can back off.
+func (c *Client) sendBuffered(kind, path string, body []byte) error {
+	err := c.postJSON(kind, path, body, time.Time{})
+	if err != nil && c.queue != nil && IsConnectivityError(err) {
+		c.queue.push(queuedRequest{Kind: kind, Path: path, Body: body, CollectedAt: time.Now().UTC()})
+		return fmt.Errorf("%w (queued for retry)", err)
+	}
+	return err
+}
+
+// postJSON posts a JSON body (described by what, for errors) to an agent API
+// path. A non-zero collectedAt marks a delayed upload of data gathered then.
+func (c *Client) postJSON(what, path string, body []byte, collectedAt time.Time) error {
+	req, err := http.NewRequest("POST", c.cfg.AgentURL+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if !collectedAt.IsZero() {
+		req.Header.Set("X-Collected-At", collectedAt.Format(time.RFC3339Nano))
+	}
+	c.addAuthHeaders(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send %s: %w", what, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return c.parseError(resp)
+	}
+	return nil
+}
+
+// FlushPending sends reports and metrics buffered while the server was
+// unreachable, oldest first, and returns how many were delivered. It stops
+// at the first connectivity failure, keeping the rest queued. Requests the
+// server rejects outright are dropped, since a retry would fail the same way.
+func (c *Client) FlushPending() (int, error) {
+	if c.queue == nil {
+		return 0, nil
+	}
+
+	sent := 0
+	for {
+		req, ok := c.queue.peek()
+		if !ok {
+			return sent, nil
+		}
+
+		err := c.postJSON(req.Kind, req.Path, req.Body, req.CollectedAt)
+		if err != nil && IsConnectivityError(err) {
+			return sent, err
+		}
+		if err != nil {
+			fmt.Printf("Warning: server rejected buffered %s, dropping it: %v\n", req.Kind, err)
+		} else {
+			sent++
+		}
+		c.queue.pop()
+	}
+}
+
+// PendingCount returns the number of buffered reports and metrics
+func (c *Client) PendingCount() int {
+	if c.queue == nil {
+		return 0
+	}
+	return c.queue.size()
+}
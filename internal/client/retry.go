@@ -0,0 +1,142 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/cloudronix/agent/internal/auth"
+)
+
+// Retry policy defaults, used when the config leaves a setting at zero
+const (
+	defaultRetryMaxAttempts  = 4
+	defaultRetryInitialDelay = 500 * time.Millisecond
+	defaultRetryMaxDelay     = 30 * time.Second
+)
+
+// retryPolicy returns the configured attempts, first backoff and delay cap
+func (c *Client) retryPolicy() (attempts int, initial, limit time.Duration) {
+	attempts = c.cfg.RetryMaxAttempts
+	if attempts <= 0 {
+		attempts = defaultRetryMaxAttempts
+	}
+	initial = time.Duration(c.cfg.RetryInitialDelayMs) * time.Millisecond
+	if initial <= 0 {
+		initial = defaultRetryInitialDelay
+	}
+	limit = time.Duration(c.cfg.RetryMaxDelayMs) * time.Millisecond
+	if limit <= 0 {
+		limit = defaultRetryMaxDelay
+	}
+	return attempts, initial, limit
+}
+
+// doIdempotent sends a request that is safe to repeat (a GET without a
+// body), retrying transient network failures and 408/429/5xx responses with
+// exponential backoff and jitter. Retry-After is honoured on 429 and 503.
+// The request is signed again before each retry, and waiting stops when
+// its context is cancelled.
+//
+// Requests with side effects (e.g. MarkJobStarted) must use httpClient.Do.
+func (c *Client) doIdempotent(req *http.Request) (*http.Response, error) {
+	attempts, backoff, limit := c.retryPolicy()
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 {
+			c.addAuthHeaders(req)
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if attempt >= attempts || !shouldRetry(resp, err) {
+			return resp, err
+		}
+
+		delay := backoff/2 + rand.N(backoff/2+1)
+		if resp != nil {
+			if after, ok := retryAfter(resp); ok {
+				delay = after
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		delay = min(delay, limit)
+		backoff = min(backoff*2, limit)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// shouldRetry reports whether a failed attempt may succeed if repeated
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return isTransientError(err)
+	}
+	switch resp.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests,
+		http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// isTransientError reports whether a transport error can go away on its
+// own: timeouts, failed or reset connections and connections closed
+// mid-response. Certificate and pin failures, and anything else, fail at
+// once, since repeating them only delays the same error.
+func isTransientError(err error) bool {
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var invalidCert x509.CertificateInvalidError
+	var hostnameErr x509.HostnameError
+	if errors.As(err, &certErr) || errors.As(err, &unknownAuthority) ||
+		errors.As(err, &invalidCert) || errors.As(err, &hostnameErr) ||
+		errors.Is(err, auth.ErrPinMismatch) {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	// Dial failures cover connection refused and unreachable hosts on every
+	// platform, whatever the underlying errno
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
+
+// retryAfter parses the Retry-After header of a 429 or 503 response, given
+// either in seconds or as an HTTP date
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return 0, false
+	}
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
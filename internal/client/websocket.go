@@ -3,11 +3,15 @@ package client
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/cloudronix/agent/internal/auth"
 	"github.com/cloudronix/agent/internal/config"
 	"github.com/gorilla/websocket"
 )
@@ -19,12 +23,90 @@ type JobNotification struct {
 	PlaybookName string `json:"playbook_name"`
 }
 
+// RemoteCommand is a one-off diagnostic command pushed by the server. Payload
+// is the signed JSON of a RemoteCommandPayload; the agent must verify
+// SHA256Hash and Signature with the pinned server key before decoding it.
+// The signature is over "cloudronix-remote-command:v1:<command_id>:<hash>".
+type RemoteCommand struct {
+	Type       string `json:"type"`
+	CommandID  string `json:"command_id"`
+	Payload    string `json:"payload"`
+	SHA256Hash string `json:"sha256_hash"`
+	Signature  []byte `json:"signature"` // base64 in JSON
+}
+
+// RemoteCommandPayload is the signed content of a RemoteCommand
+type RemoteCommandPayload struct {
+	CommandID      string    `json:"command_id"`
+	DeviceID       string    `json:"device_id"`
+	Command        string    `json:"command"`
+	Shell          string    `json:"shell,omitempty"`
+	TimeoutSeconds int       `json:"timeout_seconds,omitempty"`
+	ExpiresAt      time.Time `json:"expires_at"`
+}
+
+// CommandOutput carries a chunk of remote command output to the server
+type CommandOutput struct {
+	Type      string `json:"type"` // command_output
+	CommandID string `json:"command_id"`
+	Stream    string `json:"stream"` // stdout or stderr
+	Data      string `json:"data"`
+}
+
+// CommandResult reports how a remote command ended, or why it was refused
+type CommandResult struct {
+	Type      string `json:"type"` // command_result
+	CommandID string `json:"command_id"`
+	Status    string `json:"status"` // completed, failed, timed_out, rejected
+	ExitCode  int    `json:"exit_code"`
+	Error     string `json:"error,omitempty"`
+	Duration  string `json:"duration,omitempty"`
+}
+
+// wsHandshake is the first message sent on a new connection. The signature
+// covers "{timestamp}:WS:{path}" so the server can verify possession of the
+// device's private key, the same way it does for REST requests.
+type wsHandshake struct {
+	Type        string `json:"type"`
+	DeviceID    string `json:"device_id"`
+	Fingerprint string `json:"fingerprint"`
+	Certificate string `json:"certificate"`
+	Timestamp   string `json:"timestamp"`
+	Signature   string `json:"signature"`
+}
+
+// PresenceInterval is how often the agent tells the server over the
+// WebSocket that it is alive. It is much shorter than the REST heartbeat
+// interval so the server can tell quickly when an agent goes away.
+const PresenceInterval = 15 * time.Second
+
+// presenceWriteTimeout bounds a presence write on a stalled connection
+const presenceWriteTimeout = 10 * time.Second
+
+// presenceMessage is the application-level liveness signal sent every
+// PresenceInterval
+type presenceMessage struct {
+	Type      string `json:"type"` // presence
+	DeviceID  string `json:"device_id"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// errWSHandshakeUnsupported is returned by the signed handshake when the
+// server reports that it only understands the legacy device ID message
+var errWSHandshakeUnsupported = errors.New("server does not support signed WebSocket handshake")
+
 // WebSocketClient manages the WebSocket connection to the server
 type WebSocketClient struct {
 	cfg        *config.Config
 	conn       *websocket.Conn
 	jobChannel chan JobNotification
 	done       chan struct{}
+
+	// Remote commands received from the server
+	commandChannel chan RemoteCommand
+
+	// Serializes writes; the connection allows only one writer at a time
+	writeMu sync.Mutex
 }
 
 // NewWebSocketClient creates a new WebSocket client
@@ -33,6 +115,8 @@ func NewWebSocketClient(cfg *config.Config) *WebSocketClient {
 		cfg:        cfg,
 		jobChannel: make(chan JobNotification, 100),
 		done:       make(chan struct{}),
+
+		commandChannel: make(chan RemoteCommand, 10),
 	}
 }
 
@@ -41,54 +125,125 @@ func (c *WebSocketClient) Connect(ctx context.Context) error {
 	// Reset done channel for reconnection
 	c.done = make(chan struct{})
 
+	u, err := url.Parse(c.URL())
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+
+	// Reload on every connect so a renewed certificate is picked up
+	credentials, err := auth.LoadCredentials(c.cfg)
+	if err != nil {
+		return fmt.Errorf("failed to load credentials: %w", err)
+	}
+
+	conn, err := c.dial(ctx, u, func(conn *websocket.Conn) error {
+		return c.signedHandshake(conn, credentials, u.Path)
+	})
+	if errors.Is(err, errWSHandshakeUnsupported) {
+		// Only an explicit answer from the server gets the legacy handshake;
+		// any other rejection is final
+		fmt.Println("Warning: server does not support signed WebSocket authentication, using legacy handshake")
+		conn, err = c.dial(ctx, u, c.legacyHandshake)
+	}
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	c.conn = conn
+	c.writeMu.Unlock()
+
+	fmt.Println("WebSocket connected - real-time job notifications enabled")
+
+	// Start reading messages
+	go c.readMessages()
+	go c.sendPresence(conn, c.done)
+
+	return nil
+}
+
+// URL returns the WebSocket endpoint derived from the agent API URL
+func (c *WebSocketClient) URL() string {
 	// Convert http:// to ws:// or https:// to wss://
 	wsURL := c.cfg.AgentURL
 	wsURL = strings.Replace(wsURL, "https://", "wss://", 1)
 	wsURL = strings.Replace(wsURL, "http://", "ws://", 1)
+	return wsURL + "/agent/ws"
+}
 
-	u, err := url.Parse(wsURL + "/agent/ws")
+// dial opens a connection and authenticates it with handshake
+func (c *WebSocketClient) dial(ctx context.Context, u *url.URL, handshake func(*websocket.Conn) error) (*websocket.Conn, error) {
+	tlsConfig, err := auth.TLSConfig(c.cfg)
 	if err != nil {
-		return fmt.Errorf("invalid URL: %w", err)
+		return nil, err
 	}
+	dialer := *websocket.DefaultDialer
+	dialer.TLSClientConfig = tlsConfig
 
-	fmt.Printf("Connecting to WebSocket: %s\n", u.String())
+	conn, _, err := dialer.DialContext(ctx, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	if err := handshake(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
 
-	conn, _, err := websocket.DefaultDialer.DialContext(ctx, u.String(), nil)
+// signedHandshake proves the device identity with a signature over the
+// current timestamp, mirroring the headers used for REST requests
+func (c *WebSocketClient) signedHandshake(conn *websocket.Conn, credentials *auth.Credentials, path string) error {
+	timestamp := strconv.FormatInt(signingTime(c.cfg).Unix(), 10)
+	signature, err := credentials.Sign(fmt.Sprintf("%s:WS:%s", timestamp, path))
 	if err != nil {
-		return fmt.Errorf("failed to connect: %w", err)
+		return err
 	}
-	c.conn = conn
 
-	// Send device ID to authenticate
+	msg, err := json.Marshal(wsHandshake{
+		Type:        "auth",
+		DeviceID:    c.cfg.DeviceID,
+		Fingerprint: credentials.Fingerprint,
+		Certificate: credentials.CertificateBase64(),
+		Timestamp:   timestamp,
+		Signature:   signature,
+	})
+	if err != nil {
+		return err
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+		return fmt.Errorf("failed to send handshake: %w", err)
+	}
+	return readConfirmation(conn)
+}
+
+// legacyHandshake authenticates with the bare device ID, for servers that
+// predate the signed handshake
+func (c *WebSocketClient) legacyHandshake(conn *websocket.Conn) error {
 	if err := conn.WriteMessage(websocket.TextMessage, []byte(c.cfg.DeviceID)); err != nil {
-		conn.Close()
 		return fmt.Errorf("failed to send device ID: %w", err)
 	}
+	return readConfirmation(conn)
+}
 
-	// Wait for confirmation
+// readConfirmation waits for the server to accept the handshake
+func readConfirmation(conn *websocket.Conn) error {
 	_, msg, err := conn.ReadMessage()
 	if err != nil {
-		conn.Close()
 		return fmt.Errorf("failed to read confirmation: %w", err)
 	}
 
 	var resp map[string]interface{}
 	if err := json.Unmarshal(msg, &resp); err != nil {
-		conn.Close()
 		return fmt.Errorf("invalid confirmation: %w", err)
 	}
 
-	if _, ok := resp["connected"]; !ok {
-		conn.Close()
-		return fmt.Errorf("connection rejected: %s", string(msg))
+	if _, ok := resp["connected"]; ok {
+		return nil
 	}
-
-	fmt.Println("WebSocket connected - real-time job notifications enabled")
-
-	// Start reading messages
-	go c.readMessages()
-
-	return nil
+	if code, _ := resp["error"].(string); code == "unsupported_handshake" {
+		return errWSHandshakeUnsupported
+	}
+	return fmt.Errorf("connection rejected: %s", string(msg))
 }
 
 // readMessages reads incoming WebSocket messages
@@ -109,6 +264,19 @@ func (c *WebSocketClient) readMessages() {
 			continue
 		}
 
+		if notification.Type == "command" {
+			var command RemoteCommand
+			if err := json.Unmarshal(msg, &command); err != nil {
+				continue
+			}
+			select {
+			case c.commandChannel <- command:
+			default:
+				c.Send(CommandResult{Type: "command_result", CommandID: command.CommandID, Status: "rejected", Error: "agent busy"})
+			}
+			continue
+		}
+
 		if notification.Type == "new_job" {
 			fmt.Printf(">>> NEW JOB: %s (%s)\n", notification.PlaybookName, notification.JobID[:8])
 			select {
@@ -120,13 +288,79 @@ func (c *WebSocketClient) readMessages() {
 	}
 }
 
+// sendPresence sends a presence message right away and then every
+// PresenceInterval until done is closed. A failed write closes the
+// connection so the read loop notices and the agent reconnects.
+func (c *WebSocketClient) sendPresence(conn *websocket.Conn, done <-chan struct{}) {
+	ticker := time.NewTicker(PresenceInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.writePresence(conn); err != nil {
+			fmt.Printf("WebSocket presence failed: %v\n", err)
+			conn.Close()
+			return
+		}
+
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// writePresence writes a single presence message on conn with a write
+// deadline. Nothing is sent once conn has been replaced by a reconnect.
+func (c *WebSocketClient) writePresence(conn *websocket.Conn) error {
+	data, err := json.Marshal(presenceMessage{
+		Type:      "presence",
+		DeviceID:  c.cfg.DeviceID,
+		Timestamp: signingTime(c.cfg).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.conn != conn {
+		return nil
+	}
+	conn.SetWriteDeadline(time.Now().Add(presenceWriteTimeout))
+	defer conn.SetWriteDeadline(time.Time{})
+	return conn.WriteMessage(websocket.TextMessage, data)
+}
+
 // JobChannel returns the channel for job notifications
 func (c *WebSocketClient) JobChannel() <-chan JobNotification {
 	return c.jobChannel
 }
 
+// CommandChannel returns the channel for remote commands
+func (c *WebSocketClient) CommandChannel() <-chan RemoteCommand {
+	return c.commandChannel
+}
+
+// Send writes a JSON message to the server
+func (c *WebSocketClient) Send(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.conn == nil {
+		return fmt.Errorf("not connected")
+	}
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
+
 // Close closes the WebSocket connection
 func (c *WebSocketClient) Close() error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
 	if c.conn != nil {
 		// Send close message
 		c.conn.WriteMessage(websocket.CloseMessage,
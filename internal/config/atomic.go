@@ -0,0 +1,59 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// StageFile writes data to a temporary file next to path and flushes it to
+// disk, so it can later be renamed over path atomically. The caller renames
+// or removes the returned temp file.
+func StageFile(path string, data []byte, perm os.FileMode) (string, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	tmpPath := f.Name()
+
+	if _, err := f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpPath, perm)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+
+	return tmpPath, nil
+}
+
+// WriteFileAtomic replaces path with data so readers see either the old or
+// the new content, never a partial write, even after a crash
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmpPath, err := StageFile(path, data, perm)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	SyncDir(filepath.Dir(path))
+	return nil
+}
+
+// SyncDir flushes directory entries (e.g. after a rename) to disk. This is
+// best effort: directories can't be opened for syncing on every platform.
+func SyncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+	_ = d.Sync() // not supported for directories on Windows
+}
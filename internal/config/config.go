@@ -1,6 +1,7 @@
 package config
 
 import (
+	"crypto/ed25519"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -17,12 +18,162 @@ type Config struct {
 	ServerURL string `json:"server_url"` // Main API (enrollment)
 	AgentURL  string `json:"agent_url"`  // mTLS agent API
 
+	// How the agent authenticates to the agent API: "headers" (default)
+	// signs every request for a TLS-terminating proxy such as Cloudflare,
+	// "mtls" presents the device certificate in the TLS handshake and trusts
+	// only the enrolled CA. Request signing is skipped in mtls mode unless
+	// MTLSSignRequests is set.
+	AuthMode         string `json:"auth_mode,omitempty"`
+	MTLSSignRequests bool   `json:"mtls_sign_requests,omitempty"`
+
+	// Reject playbooks approved more than this many days ago, forcing
+	// periodic re-approval (0 = approvals don't expire)
+	MaxApprovalAgeDays int `json:"max_approval_age_days,omitempty"`
+
+	// Serial of the last signed server key update applied to server.pub
+	// (0 = keys as received at enrollment)
+	ServerKeysSerial int64 `json:"server_keys_serial,omitempty"`
+
+	// Public key pins ("sha256/<base64 SPKI hash>") for the server and agent
+	// API. When set, TLS connections fail unless a certificate the server
+	// presents has one of these keys, on top of normal chain verification.
+	PinnedSPKI []string `json:"pinned_spki,omitempty"`
+
 	// Device identity (set after enrollment)
 	DeviceID string `json:"device_id,omitempty"`
 
 	// Intervals
 	HeartbeatInterval int `json:"heartbeat_interval"` // seconds
 	ReportInterval    int `json:"report_interval"`    // seconds
+
+	// Random variation of timer periods, as a percentage, so agents started
+	// together don't contact the server in lockstep (0 = default 10, negative = off, max 50)
+	JitterPercent int `json:"jitter_percent,omitempty"`
+
+	// Jobs
+	JobBatchSize int    `json:"job_batch_size,omitempty"` // max pending jobs fetched per poll
+	TestRunMode  string `json:"test_run_mode,omitempty"`  // "dry_run" (default) or "apply"
+
+	// Action types test runs may use, unless TestRunAllowAllActions is set
+	TestRunAllowedActions  []string `json:"test_run_allowed_actions,omitempty"`
+	TestRunAllowAllActions bool     `json:"test_run_allow_all_actions,omitempty"`
+
+	// Per-job scratch directories ({{ job_workdir }})
+	JobWorkdirRoot            string `json:"job_workdir_root,omitempty"`              // parent directory (default: system temp)
+	RetainJobWorkdirOnFailure bool   `json:"retain_job_workdir_on_failure,omitempty"` // keep for debugging when a job fails
+
+	// Agent log file, rotated by size (empty = write to stdout/stderr)
+	LogFile      string `json:"log_file,omitempty"`
+	LogMaxSizeMB int    `json:"log_max_size_mb,omitempty"` // rotate at this size (0 = default 10)
+	LogMaxFiles  int    `json:"log_max_files,omitempty"`   // rotated files kept (0 = default 5)
+
+	// Retries of idempotent API requests (GETs) on network failures and
+	// 408/429/5xx responses, with exponential backoff and jitter
+	RetryMaxAttempts    int `json:"retry_max_attempts,omitempty"`     // total attempts (0 = default 4, 1 = no retries)
+	RetryInitialDelayMs int `json:"retry_initial_delay_ms,omitempty"` // first backoff (0 = default 500)
+	RetryMaxDelayMs     int `json:"retry_max_delay_ms,omitempty"`     // cap on backoff and Retry-After (0 = default 30000)
+
+	// Reports and metrics buffered per kind while the server is unreachable
+	// (0 = default 100, negative disables), optionally kept on disk across restarts
+	SendQueueSize    int  `json:"send_queue_size,omitempty"`
+	PersistSendQueue bool `json:"persist_send_queue,omitempty"`
+
+	// Execution reports kept on disk until submitted (0 = default 100); the
+	// oldest are dropped beyond this
+	MaxPendingReports int `json:"max_pending_reports,omitempty"`
+
+	// Dry run every playbook before applying it and abort if it finds issues
+	PreflightDryRun bool `json:"preflight_dry_run,omitempty"`
+
+	// Reboots requested by playbooks (on_complete.reboot_prompt) while the
+	// agent runs unattended: "report" (default) only flags them in the
+	// execution report, "schedule" reboots at the start of RebootWindow
+	// ("HH:MM-HH:MM" local time, default "02:00-05:00"). Interactive runs prompt.
+	RebootPolicy string `json:"reboot_policy,omitempty"`
+	RebootWindow string `json:"reboot_window,omitempty"`
+
+	// Accept signed one-off diagnostic commands pushed over the WebSocket (off by default)
+	EnableRemoteCommands bool `json:"enable_remote_commands,omitempty"`
+
+	// Shell for command tasks without 'shell' (e.g. "powershell", "bash"; empty = cmd or /bin/sh)
+	DefaultShell string `json:"default_shell,omitempty"`
+
+	// Security scoring
+	ForceFullSecurityScoring bool `json:"force_full_security_scoring,omitempty"` // score host-level modules even in containers
+
+	// Disk alert thresholds (percent used, 0 = default 85/95)
+	DiskWarningPercent  float64 `json:"disk_warning_percent,omitempty"`
+	DiskCriticalPercent float64 `json:"disk_critical_percent,omitempty"`
+
+	// File integrity monitoring - files hashed in each system report
+	WatchedFiles []string `json:"watched_files,omitempty"`
+
+	// Process name globs (e.g. "keepass*") reported as "[redacted]" in top
+	// processes; their CPU and memory usage is still included
+	ProcessDenylist []string `json:"process_denylist,omitempty"`
+
+	// Operator-defined labels (environment, team, location) attached to
+	// reports, metrics and execution reports
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Correct the timestamp of signed requests by the offset from the server
+	// clock measured on heartbeats, for devices whose clock can't be fixed
+	CompensateClockSkew bool `json:"compensate_clock_skew,omitempty"`
+
+	// Renew the device certificate when it expires within this many days
+	// (0 = default 30, negative disables automatic renewal)
+	CertRenewDays int `json:"cert_renew_days,omitempty"`
+
+	// Certificate revocation checks against the server (seconds, 0 = disabled)
+	CertStatusInterval int `json:"cert_status_interval,omitempty"`
+
+	// Security posture checks for change events (seconds, 0 = the report
+	// interval). Values below 5 minutes are raised to 5 minutes.
+	SecurityCheckInterval int `json:"security_check_interval,omitempty"`
+
+	// Set when the server reports the device certificate revoked; the agent
+	// refuses to run until the device is enrolled again
+	CertRevoked bool `json:"cert_revoked,omitempty"`
+}
+
+// Test run modes
+const (
+	TestRunModeDryRun = "dry_run" // Test runs simulate unless the job requests apply mode
+	TestRunModeApply  = "apply"   // Test runs make real changes
+)
+
+// Reboot policies
+const (
+	RebootPolicyReport   = "report"   // Flag required reboots in the execution report only
+	RebootPolicySchedule = "schedule" // Also reboot within the maintenance window
+)
+
+// DefaultRebootWindow is used when the schedule policy has no RebootWindow
+const DefaultRebootWindow = "02:00-05:00"
+
+// DefaultTestRunAllowedActions are the action types test runs may use when
+// TestRunAllowedActions is empty. Only read-only actions are included;
+// command and anything else that changes the device must be listed in
+// TestRunAllowedActions explicitly. It is applied at run time rather than
+// saved in the config, so a tighter default reaches every device.
+var DefaultTestRunAllowedActions = []string{"wait_for"}
+
+// Authentication modes for the agent API
+const (
+	AuthModeHeaders = "headers"
+	AuthModeMTLS    = "mtls"
+)
+
+// UseMTLS reports whether the agent authenticates with a TLS client
+// certificate rather than signed headers
+func (c *Config) UseMTLS() bool {
+	return c.AuthMode == AuthModeMTLS
+}
+
+// SignRequests reports whether agent API requests carry signed
+// authentication headers
+func (c *Config) SignRequests() bool {
+	return !c.UseMTLS() || c.MTLSSignRequests
 }
 
 // Paths returns important file paths
@@ -31,7 +182,11 @@ type Paths struct {
 	Certificate     string // device.crt
 	PrivateKey      string // device.key
 	CACert          string // ca.crt
-	ServerPublicKey string // server.pub (Ed25519 for playbook verification)
+	ServerPublicKey string // server.pub (Ed25519 keys for playbook verification)
+	Reports         string // reports/ (execution reports awaiting submission)
+	SendQueue       string // send-queue.json (reports and metrics buffered while offline)
+	RunOnce         string // run-once/ (markers of run_once command tasks)
+	RemoteCommands  string // remote-commands.json (IDs of remote commands already run)
 }
 
 // DefaultConfig returns a config with default values
@@ -41,6 +196,8 @@ func DefaultConfig() *Config {
 		AgentURL:          "https://agent.alexandrosntonas.com",
 		HeartbeatInterval: 60,
 		ReportInterval:    300,
+		JobBatchSize:      10,
+		TestRunMode:       TestRunModeDryRun,
 	}
 }
 
@@ -90,7 +247,7 @@ func (c *Config) Save() error {
 	}
 
 	configPath := filepath.Join(c.ConfigDir, "config.json")
-	if err := os.WriteFile(configPath, data, 0600); err != nil {
+	if err := WriteFileAtomic(configPath, data, 0600); err != nil {
 		return fmt.Errorf("failed to write config: %w", err)
 	}
 
@@ -105,6 +262,10 @@ func (c *Config) Paths() Paths {
 		PrivateKey:      filepath.Join(c.ConfigDir, "device.key"),
 		CACert:          filepath.Join(c.ConfigDir, "ca.crt"),
 		ServerPublicKey: filepath.Join(c.ConfigDir, "server.pub"),
+		Reports:         filepath.Join(c.ConfigDir, "reports"),
+		SendQueue:       filepath.Join(c.ConfigDir, "send-queue.json"),
+		RunOnce:         filepath.Join(c.ConfigDir, "run-once"),
+		RemoteCommands:  filepath.Join(c.ConfigDir, "remote-commands.json"),
 	}
 }
 
@@ -138,6 +299,30 @@ func (c *Config) LoadServerPublicKey() ([]byte, error) {
 	return data, nil
 }
 
+// LoadServerPublicKeys loads the server's trusted Ed25519 public keys from
+// server.pub, written at enrollment and replaced by signed key updates
+func (c *Config) LoadServerPublicKeys() ([]ed25519.PublicKey, error) {
+	data, err := c.LoadServerPublicKey()
+	if err != nil {
+		return nil, err
+	}
+	return ParseServerPublicKeys(data)
+}
+
+// ParseServerPublicKeys splits the content of server.pub into keys. The file
+// holds one or more raw 32-byte Ed25519 keys back to back, so a file written
+// before key rotation, with a single key, is read unchanged.
+func ParseServerPublicKeys(data []byte) ([]ed25519.PublicKey, error) {
+	if len(data) == 0 || len(data)%ed25519.PublicKeySize != 0 {
+		return nil, fmt.Errorf("server public key file is %d bytes, expected a multiple of %d (Ed25519)", len(data), ed25519.PublicKeySize)
+	}
+	keys := make([]ed25519.PublicKey, 0, len(data)/ed25519.PublicKeySize)
+	for i := 0; i < len(data); i += ed25519.PublicKeySize {
+		keys = append(keys, ed25519.PublicKey(data[i:i+ed25519.PublicKeySize]))
+	}
+	return keys, nil
+}
+
 // SaveServerPublicKey saves the server's Ed25519 public key to disk
 func (c *Config) SaveServerPublicKey(key []byte) error {
 	paths := c.Paths()
@@ -147,6 +332,18 @@ func (c *Config) SaveServerPublicKey(key []byte) error {
 	return nil
 }
 
+// SaveServerPublicKeys replaces server.pub with a set of trusted keys
+func (c *Config) SaveServerPublicKeys(keys []ed25519.PublicKey) error {
+	var data []byte
+	for _, key := range keys {
+		data = append(data, key...)
+	}
+	if err := WriteFileAtomic(c.Paths().ServerPublicKey, data, 0600); err != nil {
+		return fmt.Errorf("failed to write server public keys: %w", err)
+	}
+	return nil
+}
+
 // HasServerPublicKey returns true if the server public key exists
 func (c *Config) HasServerPublicKey() bool {
 	paths := c.Paths()
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Prefix for label keys in Set, e.g. "labels.environment"
+const labelKeyPrefix = "labels."
+
+// Keys managed by enrollment that can't be changed with Set
+var protectedKeys = map[string]bool{
+	"device_id":    true,
+	"cert_revoked": true,
+}
+
+// Set changes a single setting by its config.json key. Values are parsed as
+// JSON when possible, so numbers, booleans and lists work, and fall back to
+// a plain string. Labels are set with "labels.<name>"; an empty value
+// removes the label. The caller saves the config.
+func (c *Config) Set(key, value string) error {
+	if strings.HasPrefix(key, labelKeyPrefix) {
+		name := strings.TrimPrefix(key, labelKeyPrefix)
+		if name == "" {
+			return fmt.Errorf("label name must not be empty")
+		}
+		if value == "" {
+			delete(c.Labels, name)
+			return nil
+		}
+		if c.Labels == nil {
+			c.Labels = make(map[string]string)
+		}
+		c.Labels[name] = value
+		return nil
+	}
+
+	if protectedKeys[key] {
+		return fmt.Errorf("'%s' is managed by enrollment and can't be set", key)
+	}
+	if !isConfigKey(key) {
+		return fmt.Errorf("unknown config key '%s' (known keys: %s)", key, strings.Join(configKeys(), ", "))
+	}
+
+	raw := json.RawMessage(value)
+	if !json.Valid(raw) {
+		quoted, _ := json.Marshal(value)
+		raw = quoted
+	}
+
+	patch, err := json.Marshal(map[string]json.RawMessage{key: raw})
+	if err != nil {
+		return fmt.Errorf("invalid value for '%s': %w", key, err)
+	}
+	if err := json.Unmarshal(patch, c); err != nil {
+		return fmt.Errorf("invalid value for '%s': %w", key, err)
+	}
+	return nil
+}
+
+// configKeys lists the config.json keys that Set accepts
+func configKeys() []string {
+	var keys []string
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" || protectedKeys[name] {
+			continue
+		}
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// isConfigKey reports whether key is a settable config.json key
+func isConfigKey(key string) bool {
+	for _, k := range configKeys() {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
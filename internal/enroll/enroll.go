@@ -2,6 +2,7 @@ package enroll
 
 import (
 	"bytes"
+	"context"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
@@ -13,8 +14,11 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
 	"runtime"
+	"time"
 
+	"github.com/cloudronix/agent/internal/auth"
 	"github.com/cloudronix/agent/internal/config"
 	"github.com/cloudronix/agent/pkg/sysinfo"
 )
@@ -38,6 +42,18 @@ type EnrollmentResponse struct {
 	AgentURL         string `json:"agent_url"`
 	// Server's Ed25519 public key for playbook signature verification (base64 encoded)
 	ServerPublicKey []byte `json:"server_public_key,omitempty"`
+	// Further trusted signing keys while the server rotates its key (base64 encoded)
+	ServerPublicKeys [][]byte `json:"server_public_keys,omitempty"`
+}
+
+// serverPublicKeys returns the content of server.pub: every trusted
+// signing key, raw and back to back
+func (r *EnrollmentResponse) serverPublicKeys() []byte {
+	data := append([]byte(nil), r.ServerPublicKey...)
+	for _, key := range r.ServerPublicKeys {
+		data = append(data, key...)
+	}
+	return data
 }
 
 // Enroll enrolls the device with the Cloudronix server
@@ -46,9 +62,15 @@ func Enroll(cfg *config.Config, token string) error {
 
 	// Check if already enrolled
 	if cfg.IsEnrolled() {
-		return fmt.Errorf("device is already enrolled (device ID: %s)\nUse 'cloudronix-agent uninstall' to remove existing enrollment", cfg.DeviceID)
+		return fmt.Errorf("device is already enrolled (device ID: %s)\nUse 'cloudronix-agent unenroll' to remove the existing enrollment", cfg.DeviceID)
 	}
 
+	release, err := acquireEnrollLock(cfg)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	// Generate ECDSA P-384 key pair
 	fmt.Println("Generating device key pair...")
 	privateKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
@@ -64,7 +86,7 @@ func Enroll(cfg *config.Config, token string) error {
 	}
 
 	// Gather system information
-	sysInfo := sysinfo.Collect()
+	sysInfo := sysinfo.Collect(context.Background())
 
 	// Determine device type
 	deviceType := determineDeviceType()
@@ -82,11 +104,16 @@ func Enroll(cfg *config.Config, token string) error {
 
 	// Send enrollment request
 	fmt.Printf("Enrolling with server at %s...\n", cfg.ServerURL)
-	resp, err := sendEnrollmentRequest(cfg.ServerURL, req)
+	resp, err := sendEnrollmentRequest(cfg, req)
 	if err != nil {
 		return fmt.Errorf("enrollment failed: %w", err)
 	}
 
+	// Make sure the server signed our CSR before storing anything
+	if err := verifyCertificateKey(resp.CertificatePEM, privateKey); err != nil {
+		return fmt.Errorf("enrollment failed: %w", err)
+	}
+
 	// Save credentials
 	fmt.Println("Saving credentials...")
 	if err := saveCredentials(cfg, privateKey, resp); err != nil {
@@ -95,10 +122,14 @@ func Enroll(cfg *config.Config, token string) error {
 
 	// Update config
 	cfg.DeviceID = resp.DeviceID
+	cfg.CertRevoked = false
+	cfg.ServerKeysSerial = 0 // server.pub now holds the keys from enrollment
 	if resp.AgentURL != "" {
 		cfg.AgentURL = resp.AgentURL
 	}
 	if err := cfg.Save(); err != nil {
+		cfg.DeviceID = ""
+		removeCredentials(cfg)
 		return fmt.Errorf("failed to save config: %w", err)
 	}
 
@@ -139,20 +170,23 @@ func createCSR(privateKey *ecdsa.PrivateKey) (string, error) {
 }
 
 // sendEnrollmentRequest sends the enrollment request to the server
-func sendEnrollmentRequest(serverURL string, req EnrollmentRequest) (*EnrollmentResponse, error) {
+func sendEnrollmentRequest(cfg *config.Config, req EnrollmentRequest) (*EnrollmentResponse, error) {
 	body, err := json.Marshal(req)
 	if err != nil {
 		return nil, err
 	}
 
-	url := serverURL + "/api/v1/enroll"
+	url := cfg.ServerURL + "/api/v1/enroll"
 	httpReq, err := http.NewRequest("POST", url, bytes.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
+	client, err := auth.NewEnrollClient(cfg)
+	if err != nil {
+		return nil, err
+	}
 	httpResp, err := client.Do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to server: %w", err)
@@ -183,46 +217,138 @@ func sendEnrollmentRequest(serverURL string, req EnrollmentRequest) (*Enrollment
 	return &resp, nil
 }
 
-// saveCredentials saves the private key and certificates
+// verifyCertificateKey checks that the certificate returned by the server
+// was issued for the private key generated for this enrollment
+func verifyCertificateKey(certPEM string, privateKey *ecdsa.PrivateKey) error {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return fmt.Errorf("server returned an invalid certificate (no PEM certificate block)")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("server returned an invalid certificate: %w", err)
+	}
+
+	pub, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok || !pub.Equal(&privateKey.PublicKey) {
+		return fmt.Errorf("server returned a certificate that does not match this device's private key")
+	}
+
+	return nil
+}
+
+// credentialFile is a credential staged in a temp file, waiting to be moved into place
+type credentialFile struct {
+	name    string // for error messages
+	path    string
+	tmpPath string
+}
+
+// saveCredentials saves the private key and certificates. All files are
+// written and flushed to temp files first, then renamed into place, so a
+// failure or crash never leaves a partial credential set. The config (with
+// the device ID) is saved afterwards and marks enrollment as complete.
 func saveCredentials(cfg *config.Config, privateKey *ecdsa.PrivateKey, resp *EnrollmentResponse) error {
 	paths := cfg.Paths()
 
-	// Save private key
-	keyDER, err := x509.MarshalECPrivateKey(privateKey)
+	keyPEM, err := marshalPrivateKey(privateKey)
 	if err != nil {
-		return fmt.Errorf("failed to marshal private key: %w", err)
-	}
-	keyPEM := pem.EncodeToMemory(&pem.Block{
-		Type:  "EC PRIVATE KEY",
-		Bytes: keyDER,
-	})
-	if err := os.WriteFile(paths.PrivateKey, keyPEM, 0600); err != nil {
-		return fmt.Errorf("failed to write private key: %w", err)
+		return err
 	}
 
-	// Save device certificate
-	if err := os.WriteFile(paths.Certificate, []byte(resp.CertificatePEM), 0644); err != nil {
-		return fmt.Errorf("failed to write certificate: %w", err)
+	type credential struct {
+		name string
+		path string
+		data []byte
+		perm os.FileMode
+	}
+	credentials := []credential{
+		{"private key", paths.PrivateKey, keyPEM, 0600},
+		{"certificate", paths.Certificate, []byte(resp.CertificatePEM), 0644},
+		{"CA certificate", paths.CACert, []byte(resp.CACertificatePEM), 0644},
+	}
+	// Server public key for playbook signature verification
+	if keys := resp.serverPublicKeys(); len(keys) > 0 {
+		if _, err := config.ParseServerPublicKeys(keys); err != nil {
+			return err
+		}
+		credentials = append(credentials, credential{"server public key", paths.ServerPublicKey, keys, 0600})
 	}
 
-	// Save CA certificate
-	if err := os.WriteFile(paths.CACert, []byte(resp.CACertificatePEM), 0644); err != nil {
-		return fmt.Errorf("failed to write CA certificate: %w", err)
+	// Stage everything before touching the real paths
+	staged := make([]credentialFile, 0, len(credentials))
+	for _, c := range credentials {
+		tmpPath, err := config.StageFile(c.path, c.data, c.perm)
+		if err != nil {
+			discardStaged(staged)
+			return fmt.Errorf("failed to write %s: %w", c.name, err)
+		}
+		staged = append(staged, credentialFile{name: c.name, path: c.path, tmpPath: tmpPath})
 	}
 
-	// Save server public key for playbook signature verification
-	if len(resp.ServerPublicKey) > 0 {
-		if err := cfg.SaveServerPublicKey(resp.ServerPublicKey); err != nil {
-			fmt.Printf("Warning: failed to save server public key: %v\n", err)
-			fmt.Println("Playbook execution will be disabled")
-		} else {
-			fmt.Println("Server public key saved - playbook execution enabled")
+	// Move into place; undo on failure so no partial set is left behind
+	for i, f := range staged {
+		if err := os.Rename(f.tmpPath, f.path); err != nil {
+			discardStaged(staged[i:])
+			for _, done := range staged[:i] {
+				os.Remove(done.path)
+			}
+			return fmt.Errorf("failed to install %s: %w", f.name, err)
 		}
 	}
+	config.SyncDir(cfg.ConfigDir)
+
+	if len(resp.serverPublicKeys()) > 0 {
+		fmt.Println("Server public key saved - playbook execution enabled")
+	}
 
 	return nil
 }
 
+// discardStaged removes staged temp files that were not moved into place
+func discardStaged(files []credentialFile) {
+	for _, f := range files {
+		os.Remove(f.tmpPath)
+	}
+}
+
+// removeCredentials deletes installed credentials after a failed enrollment
+func removeCredentials(cfg *config.Config) {
+	paths := cfg.Paths()
+	for _, path := range []string{paths.PrivateKey, paths.Certificate, paths.CACert, paths.ServerPublicKey} {
+		os.Remove(path)
+	}
+}
+
+// How long an enrollment lock is honoured before it's assumed stale
+// (left behind by a crashed enrollment)
+const enrollLockStale = 10 * time.Minute
+
+// acquireEnrollLock prevents two enrollments from writing credentials into
+// the same config directory at once. The returned func releases the lock.
+func acquireEnrollLock(cfg *config.Config) (func(), error) {
+	lockPath := filepath.Join(cfg.ConfigDir, "enroll.lock")
+
+	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if os.IsExist(err) {
+		if info, statErr := os.Stat(lockPath); statErr == nil && time.Since(info.ModTime()) > enrollLockStale {
+			os.Remove(lockPath)
+			f, err = os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		}
+	}
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, fmt.Errorf("another enrollment is in progress (remove %s if it is not)", lockPath)
+		}
+		return nil, fmt.Errorf("failed to create enrollment lock: %w", err)
+	}
+	fmt.Fprintf(f, "%d\n", os.Getpid())
+	f.Close()
+
+	return func() { os.Remove(lockPath) }, nil
+}
+
 // determineDeviceType detects the device type
 func determineDeviceType() string {
 	switch runtime.GOOS {
@@ -0,0 +1,223 @@
+package enroll
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudronix/agent/internal/auth"
+	"github.com/cloudronix/agent/internal/client"
+	"github.com/cloudronix/agent/internal/config"
+)
+
+// Suffix of the copies of the previous key and certificate kept while
+// renewed credentials are moved into place
+const renewBackupSuffix = ".prev"
+
+// Renew replaces the device key and certificate with a new key and a
+// certificate the server issues for it. The request is authenticated with
+// the current credentials, which are only replaced once the new ones have
+// been used successfully against the server, so a failed renewal never
+// locks the device out. Returns the new certificate.
+func Renew(ctx context.Context, cfg *config.Config, apiClient *client.Client) (*x509.Certificate, error) {
+	release, err := acquireEnrollLock(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// Finish or undo an earlier renewal that was interrupted
+	if err := RecoverRenewal(cfg); err != nil {
+		return nil, err
+	}
+
+	privateKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key pair: %w", err)
+	}
+	csrPEM, err := createCSR(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CSR: %w", err)
+	}
+
+	resp, err := apiClient.RenewCertificate(ctx, csrPEM)
+	if err != nil {
+		return nil, fmt.Errorf("renewal failed: %w", err)
+	}
+	if err := verifyCertificateKey(resp.CertificatePEM, privateKey); err != nil {
+		return nil, fmt.Errorf("renewal failed: %w", err)
+	}
+
+	// Stage the new credentials in a directory of their own and prove the
+	// server accepts them before touching the current ones
+	stagingDir, err := os.MkdirTemp(cfg.ConfigDir, "renew-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(stagingDir)
+
+	staged := *cfg
+	staged.ConfigDir = stagingDir
+	staged.PersistSendQueue = false
+
+	caPEM := []byte(resp.CACertificatePEM)
+	if len(caPEM) == 0 {
+		if caPEM, err = os.ReadFile(cfg.Paths().CACert); err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+	}
+	keyPEM, err := marshalPrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	stagedPaths := staged.Paths()
+	files := []struct {
+		path string
+		data []byte
+		perm os.FileMode
+	}{
+		{stagedPaths.PrivateKey, keyPEM, 0600},
+		{stagedPaths.Certificate, []byte(resp.CertificatePEM), 0644},
+		{stagedPaths.CACert, caPEM, 0644},
+	}
+	for _, f := range files {
+		if err := os.WriteFile(f.path, f.data, f.perm); err != nil {
+			return nil, fmt.Errorf("failed to stage renewed credentials: %w", err)
+		}
+	}
+
+	if err := confirmCredentials(ctx, &staged); err != nil {
+		return nil, fmt.Errorf("server did not accept the renewed certificate, keeping the current one: %w", err)
+	}
+
+	if err := installRenewed(cfg, stagedPaths, len(resp.CACertificatePEM) > 0); err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode([]byte(resp.CertificatePEM))
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// confirmCredentials checks that the server accepts the credentials in cfg
+// by asking for their certificate status
+func confirmCredentials(ctx context.Context, cfg *config.Config) error {
+	stagedClient, err := client.NewClient(cfg)
+	if err != nil {
+		return err
+	}
+	status, err := stagedClient.GetCertStatus(ctx)
+	if err != nil {
+		return err
+	}
+	if status.Revoked() {
+		return fmt.Errorf("renewed certificate is reported revoked")
+	}
+	return nil
+}
+
+// installRenewed moves staged credentials into place. The current key and
+// certificate are copied aside first, so RecoverRenewal can put them back
+// if the agent stops between the two renames.
+func installRenewed(cfg *config.Config, staged config.Paths, withCA bool) error {
+	paths := cfg.Paths()
+	for _, path := range []string{paths.PrivateKey, paths.Certificate} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to back up %s: %w", filepath.Base(path), err)
+		}
+		if err := config.WriteFileAtomic(path+renewBackupSuffix, data, 0600); err != nil {
+			return fmt.Errorf("failed to back up %s: %w", filepath.Base(path), err)
+		}
+	}
+
+	moves := [][2]string{
+		{staged.PrivateKey, paths.PrivateKey},
+		{staged.Certificate, paths.Certificate},
+	}
+	if withCA {
+		moves = append(moves, [2]string{staged.CACert, paths.CACert})
+	}
+	for _, m := range moves {
+		if err := os.Rename(m[0], m[1]); err != nil {
+			restoreBackups(cfg)
+			return fmt.Errorf("failed to install renewed credentials: %w", err)
+		}
+	}
+	config.SyncDir(cfg.ConfigDir)
+
+	removeBackups(cfg)
+	return nil
+}
+
+// RecoverRenewal cleans up after a renewal interrupted while moving the new
+// credentials into place. If the installed key and certificate don't belong
+// together, the previous ones are restored.
+func RecoverRenewal(cfg *config.Config) error {
+	paths := cfg.Paths()
+	if _, err := os.Stat(paths.PrivateKey + renewBackupSuffix); os.IsNotExist(err) {
+		return nil
+	}
+
+	if credentialsMatch(cfg) {
+		removeBackups(cfg)
+		return nil
+	}
+
+	fmt.Println("Restoring the previous device certificate after an interrupted renewal")
+	if err := restoreBackups(cfg); err != nil {
+		return fmt.Errorf("failed to restore previous credentials: %w", err)
+	}
+	return nil
+}
+
+// credentialsMatch reports whether the installed key belongs to the
+// installed certificate
+func credentialsMatch(cfg *config.Config) bool {
+	credentials, err := auth.LoadCredentials(cfg)
+	if err != nil {
+		return false
+	}
+	cert, err := x509.ParseCertificate(credentials.CertificateDER)
+	if err != nil {
+		return false
+	}
+	return credentials.PrivateKey.PublicKey.Equal(cert.PublicKey)
+}
+
+// restoreBackups puts the previous key and certificate back
+func restoreBackups(cfg *config.Config) error {
+	paths := cfg.Paths()
+	for _, path := range []string{paths.PrivateKey, paths.Certificate} {
+		if err := os.Rename(path+renewBackupSuffix, path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	config.SyncDir(cfg.ConfigDir)
+	return nil
+}
+
+// removeBackups deletes the copies of the previous key and certificate
+func removeBackups(cfg *config.Config) {
+	paths := cfg.Paths()
+	os.Remove(paths.PrivateKey + renewBackupSuffix)
+	os.Remove(paths.Certificate + renewBackupSuffix)
+}
+
+// marshalPrivateKey encodes a device key the way enrollment stores it
+func marshalPrivateKey(privateKey *ecdsa.PrivateKey) ([]byte, error) {
+	keyDER, err := x509.MarshalECPrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal private key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  "EC PRIVATE KEY",
+		Bytes: keyDER,
+	}), nil
+}
@@ -3,7 +3,11 @@ package actions
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
 	"runtime"
 	"strings"
@@ -12,7 +16,15 @@ import (
 	"github.com/cloudronix/agent/pkg/playbook"
 )
 
-// CommandHandler executes shell commands
+// CommandHandler executes shell commands.
+//
+// Commands are assumed to make changes, so repeated runs need one of:
+//   - creates: skip when this file exists, i.e. the command's own output
+//     shows it already ran (removes: skip when the file is gone)
+//   - run_once: skip when the agent recorded that the command succeeded
+//     before on this device, even in another playbook run. For one-time
+//     bootstrap steps that leave no file to check; markers are kept in the
+//     agent's config directory and cleared with 'cloudronix-agent run-once reset'.
 type CommandHandler struct{}
 
 // NewCommandHandler creates a new command handler
@@ -27,8 +39,13 @@ func (h *CommandHandler) Supports() []string {
 
 // Validate checks if the params are valid
 func (h *CommandHandler) Validate(params map[string]interface{}) error {
-	if _, ok := params["command"]; !ok {
-		return fmt.Errorf("command action requires 'command' parameter")
+	_, hasCommand := params["command"]
+	_, hasArgv := params["argv"]
+	if !hasCommand && !hasArgv {
+		return fmt.Errorf("command action requires 'command' or 'argv' parameter")
+	}
+	if hasCommand && hasArgv {
+		return fmt.Errorf("'command' and 'argv' are mutually exclusive")
 	}
 	return nil
 }
@@ -40,15 +57,53 @@ func (h *CommandHandler) Execute(ctx context.Context, params map[string]interfac
 		Status:    playbook.TaskStatusRunning,
 	}
 
-	// Get command string
-	cmdStr, ok := params["command"].(string)
-	if !ok || cmdStr == "" {
+	if err := h.Validate(params); err != nil {
+		return nil, err
+	}
+
+	// Get command string, or the argument vector for the no-shell form
+	var argv []string
+	cmdStr, _ := params["command"].(string)
+	if _, ok := params["argv"]; ok {
+		var err error
+		if argv, err = commandArgv(params["argv"]); err != nil {
+			return nil, err
+		}
+	} else if cmdStr == "" {
 		return nil, fmt.Errorf("command parameter must be a non-empty string")
 	}
 
-	// Get optional parameters
+	env, err := commandEnv(params, vars)
+	if err != nil {
+		return nil, playbook.NewPermanentError(err)
+	}
+
+	// Idempotency: 'creates' and 'removes' check an external file the
+	// command is known to create or remove, 'run_once' an agent-managed
+	// marker recorded after the command first succeeded
+	if skip := commandSkipMessage(params, vars); skip != "" {
+		result.Status = playbook.TaskStatusSkipped
+		result.Message = skip
+		result.EndTime = time.Now()
+		result.Duration = result.EndTime.Sub(result.StartTime).String()
+		return result, nil
+	}
+	runOnceDir, runOnceKey, err := runOnceMarker(params, vars, cmdStr, argv)
+	if err != nil {
+		return nil, playbook.NewPermanentError(err)
+	}
+	if runOnceKey != "" && playbook.HasRunOnceMarker(runOnceDir, runOnceKey) {
+		result.Status = playbook.TaskStatusSkipped
+		result.Message = fmt.Sprintf("Skipped: already run (run_once '%s')", runOnceKey)
+		result.EndTime = time.Now()
+		result.Duration = result.EndTime.Sub(result.StartTime).String()
+		return result, nil
+	}
+
+	// Get optional parameters. Without chdir commands run in the agent's
+	// working directory; use chdir: "{{ job_workdir }}" for the job's scratch directory.
 	var workDir string
-	if wd, ok := params["chdir"].(string); ok {
+	if wd, ok := pathParam(params, "chdir", vars); ok {
 		workDir = wd
 	}
 
@@ -56,6 +111,9 @@ func (h *CommandHandler) Execute(ctx context.Context, params map[string]interfac
 	var shellArgs []string
 	if s, ok := params["shell"].(string); ok {
 		shell = s
+	} else if vars != nil {
+		// Fleet-wide default from the agent configuration
+		shell = vars.DefaultShell()
 	}
 
 	// Get timeout (default 5 minutes)
@@ -93,8 +151,13 @@ func (h *CommandHandler) Execute(ctx context.Context, params map[string]interfac
 		}
 	}
 
-	// Build command
-	cmdArgs := append(shellArgs, cmdStr)
+	// Build command. argv runs the program directly, without a shell.
+	var cmdArgs []string
+	if argv != nil {
+		shell, cmdArgs = argv[0], argv[1:]
+	} else {
+		cmdArgs = append(shellArgs, prepareCommand(shell, cmdStr))
+	}
 	cmd := exec.CommandContext(ctx, shell, cmdArgs...)
 
 	if workDir != "" {
@@ -106,30 +169,29 @@ func (h *CommandHandler) Execute(ctx context.Context, params map[string]interfac
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
-	// Set up environment
-	if envMap, ok := params["environment"].(map[string]interface{}); ok {
-		for key, val := range envMap {
-			if strVal, ok := val.(string); ok {
-				cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, strVal))
-			}
-		}
-	}
-
 	// Create timeout context
 	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 	cmd = exec.CommandContext(timeoutCtx, shell, cmdArgs...)
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
+	cmd.Env = env
+
+	// Feed stdin. exec copies it through a pipe, so large input is streamed
+	// to the process and stdin is closed once it has been written. The
+	// value is never echoed into the result, so secrets stay out of reports.
+	if input, ok := params["stdin"].(string); ok {
+		cmd.Stdin = strings.NewReader(input)
+	}
 	if workDir != "" {
 		cmd.Dir = workDir
 	}
 
 	// Execute
-	err := cmd.Run()
+	err = cmd.Run()
 
-	result.Stdout = strings.TrimSpace(stdout.String())
-	result.Stderr = strings.TrimSpace(stderr.String())
+	result.Stdout = strings.TrimSpace(decodeOutput(stdout.Bytes()))
+	result.Stderr = strings.TrimSpace(decodeOutput(stderr.Bytes()))
 	result.EndTime = time.Now()
 	result.Duration = result.EndTime.Sub(result.StartTime).String()
 
@@ -145,6 +207,11 @@ func (h *CommandHandler) Execute(ctx context.Context, params map[string]interfac
 			return result, fmt.Errorf("command timed out after %v", timeout)
 		}
 
+		// A missing or non-executable program fails the same way on retry
+		if errors.Is(err, exec.ErrNotFound) || errors.Is(err, os.ErrPermission) {
+			return result, playbook.NewPermanentError(fmt.Errorf("failed to start command: %w", err))
+		}
+
 		// Check if caller wants to fail on non-zero exit
 		if failOnError, ok := params["fail_on_error"].(bool); ok && !failOnError {
 			// Don't treat non-zero exit as error
@@ -153,25 +220,135 @@ func (h *CommandHandler) Execute(ctx context.Context, params map[string]interfac
 			return result, nil
 		}
 
-		return result, fmt.Errorf("command failed with exit code %d: %s", result.ExitCode, result.Stderr)
+		cmdErr := fmt.Errorf("command failed with exit code %d: %s", result.ExitCode, result.Stderr)
+		if argv == nil && isShellNotFoundExit(result.ExitCode) {
+			return result, playbook.NewPermanentError(cmdErr)
+		}
+		return result, cmdErr
 	}
 
 	result.ExitCode = 0
 	result.Status = playbook.TaskStatusCompleted
 	result.Changed = true // Commands are assumed to make changes
 
-	// Check creates/removes for idempotency
-	if creates, ok := params["creates"].(string); ok && creates != "" {
-		// If the file exists, the command was already run
-		if fileExists(creates) {
-			result.Changed = false
-			result.Message = fmt.Sprintf("Skipped: '%s' already exists", creates)
+	if runOnceKey != "" {
+		// Retrying would run the command again, so fail permanently
+		if err := playbook.WriteRunOnceMarker(runOnceDir, runOnceKey); err != nil {
+			return result, playbook.NewPermanentError(fmt.Errorf("command succeeded but its run_once marker could not be recorded: %w", err))
 		}
 	}
 
 	return result, nil
 }
 
+// commandEnv returns the command's environment: the agent's own, then the
+// variables of env_file, then those of 'environment', later ones taking
+// precedence. It returns nil, inheriting the agent's environment unchanged,
+// when neither param is set.
+func commandEnv(params map[string]interface{}, vars *playbook.Variables) ([]string, error) {
+	var extra []string
+	if path, ok := pathParam(params, "env_file", vars); ok {
+		fileEnv, err := loadEnvFile(path)
+		if err != nil {
+			return nil, err
+		}
+		extra = append(extra, fileEnv...)
+	}
+	if envMap, ok := params["environment"].(map[string]interface{}); ok {
+		for key, val := range envMap {
+			if strVal, ok := val.(string); ok {
+				extra = append(extra, fmt.Sprintf("%s=%s", key, strVal))
+			}
+		}
+	}
+	if extra == nil {
+		return nil, nil
+	}
+	// exec keeps the last value of duplicate keys
+	return append(os.Environ(), extra...), nil
+}
+
+// commandSkipMessage returns why the command can be skipped because of its
+// creates/removes params, or "" if it must run
+func commandSkipMessage(params map[string]interface{}, vars *playbook.Variables) string {
+	if creates, ok := pathParam(params, "creates", vars); ok && creates != "" && fileExists(creates) {
+		return fmt.Sprintf("Skipped: '%s' already exists", creates)
+	}
+	if removes, ok := pathParam(params, "removes", vars); ok && removes != "" && !fileExists(removes) {
+		return fmt.Sprintf("Skipped: '%s' does not exist", removes)
+	}
+	return ""
+}
+
+// runOnceMarker returns the marker directory and key of a run_once command,
+// or an empty key without run_once. 'run_once: true' keys the marker by a
+// hash of the command; a string names the marker, which keeps it stable
+// when the command changes or contains per-run values.
+func runOnceMarker(params map[string]interface{}, vars *playbook.Variables, cmdStr string, argv []string) (string, string, error) {
+	var key string
+	switch v := params["run_once"].(type) {
+	case nil:
+		return "", "", nil
+	case bool:
+		if !v {
+			return "", "", nil
+		}
+		command := cmdStr
+		if argv != nil {
+			command = strings.Join(argv, "\x00")
+		}
+		sum := sha256.Sum256([]byte(command))
+		key = "cmd-" + hex.EncodeToString(sum[:8])
+	case string:
+		if !playbook.ValidRunOnceKey(v) {
+			return "", "", fmt.Errorf("run_once name '%s' may only contain letters, digits, '.', '_' and '-'", v)
+		}
+		key = v
+	default:
+		return "", "", fmt.Errorf("run_once must be true or a marker name")
+	}
+
+	var dir string
+	if vars != nil {
+		dir = vars.RunOnceDir()
+	}
+	if dir == "" {
+		return "", "", fmt.Errorf("run_once is not available: the agent has no marker directory")
+	}
+	return dir, key, nil
+}
+
+// isShellNotFoundExit reports whether a shell exit code means the command
+// could not be found or executed (126/127 for sh, 9009 for cmd)
+func isShellNotFoundExit(code int) bool {
+	switch code {
+	case 126, 127, 9009:
+		return true
+	}
+	return false
+}
+
+// commandArgv reads the argv param as a non-empty list of strings
+func commandArgv(param interface{}) ([]string, error) {
+	list, ok := param.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil, fmt.Errorf("argv parameter must be a non-empty list")
+	}
+
+	argv := make([]string, len(list))
+	for i, item := range list {
+		arg, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("argv must contain only strings")
+		}
+		argv[i] = arg
+	}
+	if argv[0] == "" {
+		return nil, fmt.Errorf("argv[0] must name the program to run")
+	}
+	return argv, nil
+}
+
 // fileExists checks if a file or directory exists
 func fileExists(path string) bool {
 	_, err := exec.Command("test", "-e", path).Output()
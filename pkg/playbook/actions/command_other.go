@@ -0,0 +1,14 @@
+//go:build !windows
+
+package actions
+
+// prepareCommand returns the command unchanged; Unix shells already emit
+// output in the locale's encoding
+func prepareCommand(shell, cmdStr string) string {
+	return cmdStr
+}
+
+// decodeOutput returns captured command output as a string
+func decodeOutput(data []byte) string {
+	return string(data)
+}
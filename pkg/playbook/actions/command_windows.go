@@ -0,0 +1,88 @@
+//go:build windows
+
+package actions
+
+import (
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"golang.org/x/sys/windows"
+)
+
+// oemCodePage is the CP_OEMCP identifier used by console programs
+const oemCodePage = 1
+
+// prepareCommand forces UTF-8 console output for the built-in Windows shells,
+// so stdout/stderr don't come back in the system code page
+func prepareCommand(shell, cmdStr string) string {
+	switch shell {
+	case "cmd":
+		return "chcp 65001 >NUL & " + cmdStr
+	case "powershell":
+		return "[Console]::OutputEncoding = [System.Text.Encoding]::UTF8; " +
+			"$OutputEncoding = [System.Text.Encoding]::UTF8; " + cmdStr
+	}
+	return cmdStr
+}
+
+// decodeOutput converts captured command output to UTF-8.
+// Handles UTF-16LE (as written by some PowerShell cmdlets and redirected
+// console programs) and falls back to the OEM code page for programs that
+// ignore the console code page.
+func decodeOutput(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+
+	if isUTF16LE(data) {
+		if len(data) >= 2 && data[0] == 0xFF && data[1] == 0xFE {
+			data = data[2:]
+		}
+		u16 := make([]uint16, len(data)/2)
+		for i := range u16 {
+			u16[i] = uint16(data[2*i]) | uint16(data[2*i+1])<<8
+		}
+		return string(utf16.Decode(u16))
+	}
+
+	if utf8.Valid(data) {
+		return string(data)
+	}
+
+	return decodeCodePage(data, oemCodePage)
+}
+
+// isUTF16LE detects UTF-16LE by BOM or by NUL bytes in odd positions
+func isUTF16LE(data []byte) bool {
+	if len(data) >= 2 && data[0] == 0xFF && data[1] == 0xFE {
+		return true
+	}
+	if len(data) < 4 || len(data)%2 != 0 {
+		return false
+	}
+
+	zeros := 0
+	for i := 1; i < len(data); i += 2 {
+		if data[i] == 0 {
+			zeros++
+		}
+	}
+	// Mostly-ASCII UTF-16 text has a NUL high byte for nearly every character
+	return zeros*2 >= len(data)/2
+}
+
+// decodeCodePage converts bytes in the given Windows code page to UTF-8
+func decodeCodePage(data []byte, codePage uint32) string {
+	n, err := windows.MultiByteToWideChar(codePage, 0, &data[0], int32(len(data)), nil, 0)
+	if err != nil || n == 0 {
+		return string(data)
+	}
+
+	u16 := make([]uint16, n)
+	n, err = windows.MultiByteToWideChar(codePage, 0, &data[0], int32(len(data)), &u16[0], n)
+	if err != nil {
+		return string(data)
+	}
+
+	return string(utf16.Decode(u16[:n]))
+}
@@ -0,0 +1,254 @@
+package actions
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudronix/agent/pkg/playbook"
+)
+
+// CopyHandler puts content at a destination: a local file, a directory
+// tree, or inline content. Unlike file, copies keep the source file mode
+// unless 'mode' is given.
+type CopyHandler struct{}
+
+// NewCopyHandler creates a new copy handler
+func NewCopyHandler() *CopyHandler {
+	return &CopyHandler{}
+}
+
+// Supports returns all platforms
+func (h *CopyHandler) Supports() []string {
+	return []string{"windows", "linux", "darwin", "android"}
+}
+
+// Validate checks if the params are valid
+func (h *CopyHandler) Validate(params map[string]interface{}) error {
+	if _, ok := params["dest"]; !ok {
+		return fmt.Errorf("copy action requires 'dest' parameter")
+	}
+	_, hasSrc := params["src"]
+	_, hasContent := params["content"]
+	if hasSrc == hasContent {
+		return fmt.Errorf("copy action requires exactly one of 'src' or 'content'")
+	}
+	return nil
+}
+
+// Execute performs the copy
+func (h *CopyHandler) Execute(ctx context.Context, params map[string]interface{}, vars *playbook.Variables) (*playbook.TaskResult, error) {
+	result := &playbook.TaskResult{
+		StartTime: time.Now(),
+		Status:    playbook.TaskStatusRunning,
+	}
+
+	if err := h.Validate(params); err != nil {
+		return nil, err
+	}
+
+	dest, ok := pathParam(params, "dest", vars)
+	if !ok || dest == "" {
+		return nil, fmt.Errorf("dest parameter must be a non-empty string")
+	}
+
+	var mode *os.FileMode
+	if m, ok := params["mode"].(string); ok {
+		parsed, err := strconv.ParseUint(m, 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mode '%s': %w", m, err)
+		}
+		fileMode := os.FileMode(parsed)
+		mode = &fileMode
+	}
+
+	var copied int
+	var backups []string
+	var err error
+	if content, ok := params["content"].(string); ok {
+		writer := newFileWriter(ctx, params)
+		var changed bool
+		changed, err = h.copyData(dest, []byte(content), mode, 0644, params, writer)
+		if changed {
+			copied = 1
+		}
+		if writer.backupPath != "" {
+			backups = append(backups, writer.backupPath)
+		}
+	} else {
+		src, ok := pathParam(params, "src", vars)
+		if !ok || src == "" {
+			return nil, fmt.Errorf("src parameter must be a non-empty string")
+		}
+		copied, backups, err = h.copySource(ctx, src, dest, mode, params)
+	}
+
+	result.EndTime = time.Now()
+	result.Duration = result.EndTime.Sub(result.StartTime).String()
+
+	if err != nil {
+		result.Status = playbook.TaskStatusFailed
+		result.Error = err.Error()
+		return result, err
+	}
+
+	result.Changed = copied > 0
+	if result.Changed {
+		result.Message = fmt.Sprintf("Copied %d file(s) to '%s'", copied, dest)
+	} else {
+		result.Message = fmt.Sprintf("'%s' is up to date", dest)
+	}
+	if len(backups) > 0 {
+		result.Message += fmt.Sprintf(" (Backup saved to '%s')", strings.Join(backups, "', '"))
+	}
+	result.Status = playbook.TaskStatusCompleted
+	return result, nil
+}
+
+// copySource copies a file or, recursively, the contents of a directory.
+// A file copied onto an existing directory lands inside it. Returns the
+// number of files written and any backups made.
+func (h *CopyHandler) copySource(ctx context.Context, src, dest string, mode *os.FileMode, params map[string]interface{}) (int, []string, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read source '%s': %w", src, err)
+	}
+
+	if !info.IsDir() {
+		if destInfo, err := os.Stat(dest); err == nil && destInfo.IsDir() {
+			dest = filepath.Join(dest, filepath.Base(src))
+		}
+		writer := newFileWriter(ctx, params)
+		changed, err := h.copyFile(src, dest, info, mode, params, writer)
+		var backups []string
+		if writer.backupPath != "" {
+			backups = append(backups, writer.backupPath)
+		}
+		if changed {
+			return 1, backups, err
+		}
+		return 0, backups, err
+	}
+
+	copied := 0
+	var backups []string
+	err = filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+
+		// Follow file symlinks, but don't descend into linked directories
+		entryInfo, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("failed to read source '%s': %w", path, err)
+		}
+		if entryInfo.IsDir() {
+			if d.Type()&fs.ModeSymlink != 0 {
+				return nil
+			}
+			if err := os.MkdirAll(target, entryInfo.Mode().Perm()); err != nil {
+				return fmt.Errorf("failed to create directory '%s': %w", target, err)
+			}
+			return nil
+		}
+		if !entryInfo.Mode().IsRegular() {
+			return nil // devices, sockets, pipes
+		}
+
+		writer := newFileWriter(ctx, params)
+		changed, err := h.copyFile(path, target, entryInfo, mode, params, writer)
+		if writer.backupPath != "" {
+			backups = append(backups, writer.backupPath)
+		}
+		if err != nil {
+			return err
+		}
+		if changed {
+			copied++
+		}
+		return nil
+	})
+
+	return copied, backups, err
+}
+
+// copyFile copies one regular file, keeping its mode unless one is given
+func (h *CopyHandler) copyFile(src, dest string, srcInfo os.FileInfo, mode *os.FileMode, params map[string]interface{}, writer *fileWriter) (bool, error) {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return false, fmt.Errorf("failed to read source file '%s': %w", src, err)
+	}
+	if mode == nil {
+		srcMode := srcInfo.Mode().Perm()
+		mode = &srcMode
+	}
+	return h.copyData(dest, data, mode, srcInfo.Mode().Perm(), params, writer)
+}
+
+// copyData writes data to dest unless its checksum already matches, then
+// applies the mode and owner/group. newMode is used for new files when no
+// mode is given; existing files then keep their mode.
+func (h *CopyHandler) copyData(dest string, data []byte, mode *os.FileMode, newMode os.FileMode, params map[string]interface{}, writer *fileWriter) (bool, error) {
+	changed := false
+
+	existing, err := os.ReadFile(dest)
+	switch {
+	case err == nil && sha256.Sum256(existing) == sha256.Sum256(data):
+		// Same content
+	case err == nil || os.IsNotExist(err):
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return false, fmt.Errorf("failed to create parent directory: %w", err)
+		}
+		writeMode := newMode
+		if mode != nil {
+			writeMode = *mode
+		}
+		if err := writer.write(dest, data, writeMode); err != nil {
+			return false, err
+		}
+		changed = true
+	default:
+		return false, err
+	}
+
+	// Existing files keep their mode on write, so set it explicitly
+	if mode != nil {
+		info, err := os.Stat(dest)
+		if err != nil {
+			return changed, err
+		}
+		if info.Mode().Perm() != mode.Perm() {
+			if err := os.Chmod(dest, *mode); err != nil {
+				return changed, fmt.Errorf("failed to set mode: %w", err)
+			}
+			changed = true
+		}
+	}
+
+	owner, _ := params["owner"].(string)
+	group, _ := params["group"].(string)
+	if owner != "" || group != "" {
+		ownerChanged, err := setOwnership(dest, owner, group)
+		if err != nil {
+			return changed, err
+		}
+		changed = changed || ownerChanged
+	}
+
+	return changed, nil
+}
@@ -4,7 +4,7 @@ package actions
 
 import (
 	"context"
-	"fmt"
+	"runtime"
 
 	"github.com/cloudronix/agent/pkg/playbook"
 )
@@ -24,10 +24,10 @@ func (h *DefaultsHandler) Supports() []string {
 
 // Validate checks if the params are valid
 func (h *DefaultsHandler) Validate(params map[string]interface{}) error {
-	return fmt.Errorf("defaults action is only available on macOS")
+	return playbook.NewUnsupportedActionError(playbook.ActionDefaults, runtime.GOOS)
 }
 
 // Execute is not available on non-macOS platforms
 func (h *DefaultsHandler) Execute(ctx context.Context, params map[string]interface{}, vars *playbook.Variables) (*playbook.TaskResult, error) {
-	return nil, fmt.Errorf("defaults action is only available on macOS")
+	return nil, playbook.NewUnsupportedActionError(playbook.ActionDefaults, runtime.GOOS)
 }
@@ -0,0 +1,128 @@
+package actions
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// envKeyPattern matches valid environment variable names
+var envKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// loadEnvFile reads KEY=value lines from a .env file and returns them as
+// "KEY=value" entries in file order
+func loadEnvFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("env_file '%s' does not exist", path)
+		}
+		return nil, fmt.Errorf("failed to read env_file '%s': %w", path, err)
+	}
+
+	env, err := parseEnvFile(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("env_file '%s': %w", path, err)
+	}
+	return env, nil
+}
+
+// parseEnvFile parses .env content. Blank lines and lines starting with '#'
+// are skipped and an 'export ' prefix is allowed. Values may be:
+//   - unquoted: trimmed, and cut at a ' #' comment
+//   - single-quoted: taken literally
+//   - double-quoted: with \n, \r, \t, \", \\ and \$ escapes, and may span lines
+//
+// Values are not expanded; ${VAR} references are passed through as is.
+func parseEnvFile(content string) ([]string, error) {
+	content = strings.TrimPrefix(content, "\ufeff")
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	lines := strings.Split(content, "\n")
+
+	var env []string
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected KEY=value", lineNo)
+		}
+		key = strings.TrimSpace(key)
+		if !envKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("line %d: invalid variable name '%s'", lineNo, key)
+		}
+		value = strings.TrimLeft(value, " \t")
+
+		switch {
+		case strings.HasPrefix(value, "'"):
+			end := strings.Index(value[1:], "'")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated single quote", lineNo)
+			}
+			value = value[1 : end+1]
+
+		case strings.HasPrefix(value, `"`):
+			// A double-quoted value continues on the following lines
+			// until its closing quote
+			raw := value[1:]
+			for {
+				unquoted, closed := unquoteEnvValue(raw)
+				if closed {
+					value = unquoted
+					break
+				}
+				i++
+				if i >= len(lines) {
+					return nil, fmt.Errorf("line %d: unterminated double quote", lineNo)
+				}
+				raw += "\n" + lines[i]
+			}
+
+		default:
+			if idx := strings.Index(value, " #"); idx >= 0 {
+				value = value[:idx]
+			}
+			value = strings.TrimSpace(value)
+		}
+
+		env = append(env, key+"="+value)
+	}
+	return env, nil
+}
+
+// unquoteEnvValue decodes a double-quoted value up to its closing quote,
+// reporting whether the quote was found
+func unquoteEnvValue(raw string) (string, bool) {
+	var b strings.Builder
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		switch {
+		case c == '"':
+			return b.String(), true
+		case c == '\\' && i+1 < len(raw):
+			i++
+			switch raw[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case '"', '\\', '$':
+				b.WriteByte(raw[i])
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(raw[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", false
+}
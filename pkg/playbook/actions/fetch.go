@@ -0,0 +1,126 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/cloudronix/agent/pkg/playbook"
+)
+
+// Default and hard upper limit for fetched file size
+const (
+	defaultFetchMaxSize = 10 * 1024 * 1024
+	maxFetchSize        = 100 * 1024 * 1024
+)
+
+// FetchHandler uploads a file from the device to the server as a job
+// artifact, e.g. to pull a log into the dashboard for troubleshooting
+type FetchHandler struct{}
+
+// NewFetchHandler creates a new fetch handler
+func NewFetchHandler() *FetchHandler {
+	return &FetchHandler{}
+}
+
+// Supports returns all platforms
+func (h *FetchHandler) Supports() []string {
+	return []string{"windows", "linux", "darwin", "android"}
+}
+
+// Validate checks if the params are valid
+func (h *FetchHandler) Validate(params map[string]interface{}) error {
+	if _, ok := params["path"]; !ok {
+		return fmt.Errorf("fetch action requires 'path' parameter")
+	}
+	return nil
+}
+
+// Execute reads the file and uploads it. The artifact ID is returned in
+// Stdout so it can be registered.
+func (h *FetchHandler) Execute(ctx context.Context, params map[string]interface{}, vars *playbook.Variables) (*playbook.TaskResult, error) {
+	result := &playbook.TaskResult{
+		StartTime: time.Now(),
+		Status:    playbook.TaskStatusRunning,
+	}
+
+	path, ok := pathParam(params, "path", vars)
+	if !ok || path == "" {
+		return nil, fmt.Errorf("path parameter must be a non-empty string")
+	}
+
+	maxSize := int64(defaultFetchMaxSize)
+	if m, ok := params["max_size"]; ok {
+		n, err := strconv.ParseInt(fmt.Sprint(m), 10, 64)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("max_size must be a positive number of bytes")
+		}
+		maxSize = n
+	}
+	if maxSize > maxFetchSize {
+		maxSize = maxFetchSize
+	}
+
+	name, _ := params["name"].(string)
+	if name == "" {
+		name = filepath.Base(path)
+	}
+
+	artifactID, size, err := h.fetch(path, name, maxSize, vars)
+
+	result.EndTime = time.Now()
+	result.Duration = result.EndTime.Sub(result.StartTime).String()
+
+	if err != nil {
+		result.Status = playbook.TaskStatusFailed
+		result.Error = err.Error()
+		return result, err
+	}
+
+	result.Stdout = artifactID
+	result.Message = fmt.Sprintf("Uploaded '%s' (%d bytes) as artifact %s", path, size, artifactID)
+	result.Changed = false // Nothing on the device changes
+	result.Status = playbook.TaskStatusCompleted
+	return result, nil
+}
+
+// fetch reads path, enforcing maxSize, and uploads it
+func (h *FetchHandler) fetch(path, name string, maxSize int64, vars *playbook.Variables) (string, int, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read '%s': %w", path, err)
+	}
+	if !info.Mode().IsRegular() {
+		return "", 0, fmt.Errorf("'%s' is not a regular file", path)
+	}
+	if info.Size() > maxSize {
+		return "", 0, fetchTooLargeError(path, info.Size(), maxSize)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read '%s': %w", path, err)
+	}
+	// The file may have grown since Stat
+	if int64(len(data)) > maxSize {
+		return "", 0, fetchTooLargeError(path, int64(len(data)), maxSize)
+	}
+
+	if vars == nil {
+		return "", 0, fmt.Errorf("artifact upload is not available outside a server job")
+	}
+	artifactID, err := vars.UploadArtifact(name, data)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to upload '%s': %w", path, err)
+	}
+	return artifactID, len(data), nil
+}
+
+// fetchTooLargeError reports a file too large to fetch. Retrying won't
+// help, so the error is permanent.
+func fetchTooLargeError(path string, size, maxSize int64) error {
+	return playbook.NewPermanentError(fmt.Errorf("'%s' is %d bytes, over the max_size limit of %d bytes", path, size, maxSize))
+}
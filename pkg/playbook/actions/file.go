@@ -8,7 +8,6 @@ import (
 	"io"
 	"os"
 	"path/filepath"
-	"runtime"
 	"strconv"
 	"time"
 
@@ -43,7 +42,7 @@ func (h *FileHandler) Execute(ctx context.Context, params map[string]interface{}
 		Status:    playbook.TaskStatusRunning,
 	}
 
-	path, ok := params["path"].(string)
+	path, ok := pathParam(params, "path", vars)
 	if !ok || path == "" {
 		return nil, fmt.Errorf("path parameter must be a non-empty string")
 	}
@@ -54,6 +53,8 @@ func (h *FileHandler) Execute(ctx context.Context, params map[string]interface{}
 		state = s
 	}
 
+	writer := newFileWriter(ctx, params)
+
 	var err error
 	switch state {
 	case "absent":
@@ -61,7 +62,7 @@ func (h *FileHandler) Execute(ctx context.Context, params map[string]interface{}
 	case "directory":
 		result.Changed, err = h.ensureDirectory(path, params)
 	case "file":
-		result.Changed, err = h.ensureFile(path, params)
+		result.Changed, err = h.ensureFile(path, params, vars, writer)
 	case "touch":
 		result.Changed, err = h.touchFile(path, params)
 	case "link":
@@ -72,6 +73,7 @@ func (h *FileHandler) Execute(ctx context.Context, params map[string]interface{}
 
 	result.EndTime = time.Now()
 	result.Duration = result.EndTime.Sub(result.StartTime).String()
+	writer.annotate(result)
 
 	if err != nil {
 		result.Status = playbook.TaskStatusFailed
@@ -130,15 +132,17 @@ func (h *FileHandler) ensureDirectory(path string, params map[string]interface{}
 	}
 
 	// Set permissions (for Unix systems)
-	h.setPermissions(path, params)
+	if _, err := h.setPermissions(path, params); err != nil {
+		return true, err
+	}
 
 	return true, nil
 }
 
 // ensureFile creates or updates a file
-func (h *FileHandler) ensureFile(path string, params map[string]interface{}) (bool, error) {
+func (h *FileHandler) ensureFile(path string, params map[string]interface{}, vars *playbook.Variables, writer *fileWriter) (bool, error) {
 	content, hasContent := params["content"].(string)
-	src, hasSrc := params["src"].(string)
+	src, hasSrc := pathParam(params, "src", vars)
 
 	if hasContent && hasSrc {
 		return false, fmt.Errorf("cannot specify both 'content' and 'src'")
@@ -190,8 +194,8 @@ func (h *FileHandler) ensureFile(path string, params map[string]interface{}) (bo
 	}
 
 	if len(newContent) > 0 {
-		if err := os.WriteFile(path, newContent, mode); err != nil {
-			return false, fmt.Errorf("failed to write file '%s': %w", path, err)
+		if err := writer.write(path, newContent, mode); err != nil {
+			return false, err
 		}
 	} else {
 		// Create empty file
@@ -203,7 +207,9 @@ func (h *FileHandler) ensureFile(path string, params map[string]interface{}) (bo
 	}
 
 	// Set permissions
-	h.setPermissions(path, params)
+	if _, err := h.setPermissions(path, params); err != nil {
+		return true, err
+	}
 
 	return true, nil
 }
@@ -226,7 +232,9 @@ func (h *FileHandler) touchFile(path string, params map[string]interface{}) (boo
 			return false, fmt.Errorf("failed to create file '%s': %w", path, err)
 		}
 		f.Close()
-		h.setPermissions(path, params)
+		if _, err := h.setPermissions(path, params); err != nil {
+			return true, err
+		}
 		return true, nil
 	}
 
@@ -248,6 +256,8 @@ func (h *FileHandler) ensureLink(path string, params map[string]interface{}) (bo
 	if !ok || target == "" {
 		return false, fmt.Errorf("link state requires 'src' parameter for link target")
 	}
+	// Relative targets are relative to the link, so only expand ~
+	target = expandHome(target)
 
 	// Check if link already exists and points to correct target
 	existingTarget, err := os.Readlink(path)
@@ -305,15 +315,14 @@ func (h *FileHandler) setPermissions(path string, params map[string]interface{})
 	}
 
 	// Set ownership (Unix only)
-	if runtime.GOOS != "windows" {
-		owner, hasOwner := params["owner"].(string)
-		group, hasGroup := params["group"].(string)
-		if hasOwner || hasGroup {
-			// Would need to look up UID/GID and use os.Chown
-			// For now, skip ownership changes
-			_ = owner
-			_ = group
+	owner, _ := params["owner"].(string)
+	group, _ := params["group"].(string)
+	if owner != "" || group != "" {
+		ownerChanged, err := setOwnership(path, owner, group)
+		if err != nil {
+			return changed, err
 		}
+		changed = changed || ownerChanged
 	}
 
 	return changed, nil
@@ -13,6 +13,15 @@ func RegisterAllHandlers(executor *playbook.Executor) {
 	executor.RegisterHandler(playbook.ActionLineinfile, NewLineinfileHandler())
 	executor.RegisterHandler(playbook.ActionEnv, NewEnvHandler())
 	executor.RegisterHandler(playbook.ActionService, NewServiceHandler())
+	executor.RegisterHandler(playbook.ActionPackage, NewPackageHandler())
+	executor.RegisterHandler(playbook.ActionTemplate, NewTemplateHandler())
+	executor.RegisterHandler(playbook.ActionWaitFor, NewWaitForHandler())
+	executor.RegisterHandler(playbook.ActionReplace, NewReplaceHandler())
+	executor.RegisterHandler(playbook.ActionUser, NewUserHandler())
+	executor.RegisterHandler(playbook.ActionCopy, NewCopyHandler())
+	executor.RegisterHandler(playbook.ActionFetch, NewFetchHandler())
+	executor.RegisterHandler(playbook.ActionKeyvalFile, NewKeyvalFileHandler())
+	executor.RegisterHandler(playbook.ActionMaintenanceWindow, NewMaintenanceWindowHandler())
 
 	// Platform-specific actions (stubs on unsupported platforms)
 	executor.RegisterHandler(playbook.ActionRegistry, NewRegistryHandler())
@@ -33,6 +42,24 @@ func CreateHandler(actionType string) playbook.ActionHandler {
 		return NewEnvHandler()
 	case playbook.ActionService:
 		return NewServiceHandler()
+	case playbook.ActionPackage:
+		return NewPackageHandler()
+	case playbook.ActionTemplate:
+		return NewTemplateHandler()
+	case playbook.ActionWaitFor:
+		return NewWaitForHandler()
+	case playbook.ActionReplace:
+		return NewReplaceHandler()
+	case playbook.ActionUser:
+		return NewUserHandler()
+	case playbook.ActionCopy:
+		return NewCopyHandler()
+	case playbook.ActionFetch:
+		return NewFetchHandler()
+	case playbook.ActionKeyvalFile:
+		return NewKeyvalFileHandler()
+	case playbook.ActionMaintenanceWindow:
+		return NewMaintenanceWindowHandler()
 	case playbook.ActionRegistry:
 		return NewRegistryHandler()
 	case playbook.ActionSysctl:
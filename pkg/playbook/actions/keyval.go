@@ -0,0 +1,259 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cloudronix/agent/pkg/playbook"
+)
+
+// defaultKeyvalSeparator is written between key and value unless the task
+// sets 'separator'
+const defaultKeyvalSeparator = " = "
+
+// KeyvalFileHandler manages one entry in a key/value config file, such as
+// sysctl.conf ("key = value"), /etc/default files ("key=value") or
+// limits.conf ("* soft nofile 4096", with separator " ").
+//
+// Lines are matched by key; whitespace around the separator and between
+// the words of a key is not significant. Comments, blank lines, other
+// entries and the formatting of unchanged entries are preserved. If the key
+// appears more than once, state=present keeps the first entry and removes
+// the duplicates so the value set is the one that takes effect.
+type KeyvalFileHandler struct{}
+
+// NewKeyvalFileHandler creates a new keyval_file handler
+func NewKeyvalFileHandler() *KeyvalFileHandler {
+	return &KeyvalFileHandler{}
+}
+
+// Supports returns all platforms
+func (h *KeyvalFileHandler) Supports() []string {
+	return []string{"all"}
+}
+
+// Validate checks if the params are valid
+func (h *KeyvalFileHandler) Validate(params map[string]interface{}) error {
+	if _, ok := params["path"]; !ok {
+		return fmt.Errorf("keyval_file action requires 'path' parameter")
+	}
+	if key, _ := params["key"].(string); strings.TrimSpace(key) == "" {
+		return fmt.Errorf("keyval_file action requires 'key' parameter")
+	}
+	return nil
+}
+
+// keyvalFormat describes how entries of a file are written and parsed
+type keyvalFormat struct {
+	separator string   // written between key and value
+	token     string   // separator without padding; "" splits on whitespace
+	comments  []string // line prefixes marking comments
+}
+
+// Execute performs the keyval_file operation
+func (h *KeyvalFileHandler) Execute(ctx context.Context, params map[string]interface{}, vars *playbook.Variables) (*playbook.TaskResult, error) {
+	result := &playbook.TaskResult{
+		StartTime: time.Now(),
+		Status:    playbook.TaskStatusRunning,
+	}
+
+	if err := h.Validate(params); err != nil {
+		return nil, err
+	}
+
+	path, ok := pathParam(params, "path", vars)
+	if !ok || path == "" {
+		return nil, fmt.Errorf("path parameter must be a non-empty string")
+	}
+	key, _ := params["key"].(string)
+
+	format := keyvalFormat{separator: defaultKeyvalSeparator, comments: []string{"#", ";"}}
+	if sep, ok := params["separator"].(string); ok {
+		if sep == "" {
+			return nil, fmt.Errorf("separator must not be empty")
+		}
+		format.separator = sep
+	}
+	format.token = strings.TrimSpace(format.separator)
+	if c, ok := params["comment"].(string); ok && c != "" {
+		format.comments = []string{c}
+	}
+
+	state := "present"
+	if s, ok := params["state"].(string); ok {
+		state = s
+	}
+
+	writer := newFileWriter(ctx, params)
+
+	var err error
+	switch state {
+	case "present":
+		value, ok := params["value"]
+		if !ok {
+			return nil, fmt.Errorf("'value' parameter is required for state 'present'")
+		}
+		create := true
+		if c, ok := params["create"].(bool); ok {
+			create = c
+		}
+		result.Changed, err = h.ensurePresent(path, key, fmt.Sprint(value), create, format, writer)
+	case "absent":
+		result.Changed, err = h.ensureAbsent(path, key, format, writer)
+	default:
+		return nil, fmt.Errorf("unknown state '%s'", state)
+	}
+
+	result.EndTime = time.Now()
+	result.Duration = result.EndTime.Sub(result.StartTime).String()
+
+	if err != nil {
+		result.Status = playbook.TaskStatusFailed
+		result.Error = err.Error()
+		return result, err
+	}
+
+	switch {
+	case result.Changed && state == "absent":
+		result.Message = fmt.Sprintf("Removed '%s' from '%s'", key, path)
+	case result.Changed:
+		result.Message = fmt.Sprintf("Updated '%s' in '%s'", key, path)
+	default:
+		result.Message = fmt.Sprintf("'%s' in '%s' is up to date", key, path)
+	}
+	writer.annotate(result)
+	result.Status = playbook.TaskStatusCompleted
+	return result, nil
+}
+
+// ensurePresent sets key to value, adding the entry at the end of the file
+// if it doesn't exist yet
+func (h *KeyvalFileHandler) ensurePresent(path, key, value string, create bool, format keyvalFormat, writer *fileWriter) (bool, error) {
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		if !create {
+			return false, fmt.Errorf("file '%s' does not exist and create=false", path)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return false, fmt.Errorf("failed to create directory: %w", err)
+		}
+	} else if err != nil {
+		return false, err
+	}
+
+	lines, trailingNewline := splitLines(content)
+	entry := normalizeKey(key) + format.separator + value
+
+	kept := make([]string, 0, len(lines)+1)
+	found := false
+	changed := false
+	for _, line := range lines {
+		current, matches := format.match(line, key)
+		switch {
+		case !matches:
+			kept = append(kept, line)
+		case found:
+			changed = true // duplicate entry
+		default:
+			found = true
+			if format.normalizeValue(current) == format.normalizeValue(value) {
+				kept = append(kept, line)
+			} else {
+				kept = append(kept, entry)
+				changed = true
+			}
+		}
+	}
+	if !found {
+		kept = append(kept, entry)
+		changed = true
+	}
+
+	if !changed {
+		return false, nil
+	}
+	if err := writer.writeAtomic(path, []byte(joinLines(kept, trailingNewline)), 0644); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ensureAbsent removes every entry for key
+func (h *KeyvalFileHandler) ensureAbsent(path, key string, format keyvalFormat, writer *fileWriter) (bool, error) {
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	lines, trailingNewline := splitLines(content)
+	kept := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if _, matches := format.match(line, key); !matches {
+			kept = append(kept, line)
+		}
+	}
+
+	if len(kept) == len(lines) {
+		return false, nil
+	}
+	if err := writer.writeAtomic(path, []byte(joinLines(kept, trailingNewline)), 0644); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// match reports whether line is an entry for key, returning its value.
+// Comments and blank lines never match.
+func (f keyvalFormat) match(line, key string) (string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" {
+		return "", false
+	}
+	for _, prefix := range f.comments {
+		if strings.HasPrefix(trimmed, prefix) {
+			return "", false
+		}
+	}
+
+	if f.token == "" {
+		// Whitespace-separated: the key is the first len(key words) fields
+		keyFields := strings.Fields(key)
+		fields := strings.Fields(trimmed)
+		if len(fields) < len(keyFields) {
+			return "", false
+		}
+		for i, field := range keyFields {
+			if fields[i] != field {
+				return "", false
+			}
+		}
+		return strings.Join(fields[len(keyFields):], " "), true
+	}
+
+	lineKey, value, ok := strings.Cut(trimmed, f.token)
+	if !ok || normalizeKey(lineKey) != normalizeKey(key) {
+		return "", false
+	}
+	return strings.TrimSpace(value), true
+}
+
+// normalizeValue makes values comparable regardless of padding, and of
+// spacing between words when entries are whitespace-separated
+func (f keyvalFormat) normalizeValue(value string) string {
+	if f.token == "" {
+		return strings.Join(strings.Fields(value), " ")
+	}
+	return strings.TrimSpace(value)
+}
+
+// normalizeKey collapses the whitespace in a key
+func normalizeKey(key string) string {
+	return strings.Join(strings.Fields(key), " ")
+}
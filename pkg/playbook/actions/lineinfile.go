@@ -40,7 +40,7 @@ func (h *LineinfileHandler) Execute(ctx context.Context, params map[string]inter
 		Status:    playbook.TaskStatusRunning,
 	}
 
-	path, ok := params["path"].(string)
+	path, ok := pathParam(params, "path", vars)
 	if !ok || path == "" {
 		return nil, fmt.Errorf("path parameter must be a non-empty string")
 	}
@@ -51,18 +51,21 @@ func (h *LineinfileHandler) Execute(ctx context.Context, params map[string]inter
 		state = s
 	}
 
+	writer := newFileWriter(ctx, params)
+
 	var err error
 	switch state {
 	case "present":
-		result.Changed, err = h.ensurePresent(path, params)
+		result.Changed, err = h.ensurePresent(path, params, writer)
 	case "absent":
-		result.Changed, err = h.ensureAbsent(path, params)
+		result.Changed, err = h.ensureAbsent(path, params, writer)
 	default:
 		return nil, fmt.Errorf("unknown state '%s'", state)
 	}
 
 	result.EndTime = time.Now()
 	result.Duration = result.EndTime.Sub(result.StartTime).String()
+	writer.annotate(result)
 
 	if err != nil {
 		result.Status = playbook.TaskStatusFailed
@@ -75,7 +78,7 @@ func (h *LineinfileHandler) Execute(ctx context.Context, params map[string]inter
 }
 
 // ensurePresent ensures a line is present in the file
-func (h *LineinfileHandler) ensurePresent(path string, params map[string]interface{}) (bool, error) {
+func (h *LineinfileHandler) ensurePresent(path string, params map[string]interface{}, writer *fileWriter) (bool, error) {
 	line, hasLine := params["line"].(string)
 	regexStr, hasRegex := params["regexp"].(string)
 
@@ -107,7 +110,7 @@ func (h *LineinfileHandler) ensurePresent(path string, params map[string]interfa
 		}
 	}
 
-	lines := strings.Split(string(content), "\n")
+	lines, trailingNewline := splitLines(content)
 	changed := false
 
 	// Handle different insertion modes
@@ -117,25 +120,34 @@ func (h *LineinfileHandler) ensurePresent(path string, params map[string]interfa
 
 	// If we have a regex, find and replace matching lines
 	if hasRegex {
-		regex, err := regexp.Compile(regexStr)
+		regex, err := playbook.CompileRegex(regexStr)
 		if err != nil {
 			return false, fmt.Errorf("invalid regexp: %w", err)
 		}
 
+		// With backrefs, 'line' may reference capture groups as \1
+		backrefs, _ := params["backrefs"].(bool)
+		template := expandBackrefs(line)
+
 		found := false
 		for i, l := range lines {
-			if regex.MatchString(l) {
+			if match := regex.FindStringSubmatchIndex(l); match != nil {
 				found = true
-				if hasLine && l != line {
-					lines[i] = line
+				newLine := line
+				if backrefs {
+					newLine = string(regex.ExpandString(nil, template, l, match))
+				}
+				if hasLine && l != newLine {
+					lines[i] = newLine
 					changed = true
 				}
 				break // Only replace first match by default
 			}
 		}
 
-		// If no match and we have a line to insert
-		if !found && hasLine {
+		// If no match and we have a line to insert. With backrefs the file
+		// is left alone, since the line can't be built without a match.
+		if !found && hasLine && !backrefs {
 			lines, changed = h.insertLine(lines, line, insertAfter, hasInsertAfter, insertBefore, hasInsertBefore)
 		}
 	} else if hasLine {
@@ -155,17 +167,53 @@ func (h *LineinfileHandler) ensurePresent(path string, params map[string]interfa
 
 	if changed {
 		// Write back to file
-		newContent := strings.Join(lines, "\n")
-		if err := os.WriteFile(path, []byte(newContent), 0644); err != nil {
-			return false, fmt.Errorf("failed to write file: %w", err)
+		newContent := joinLines(lines, trailingNewline)
+		if err := writer.write(path, []byte(newContent), 0644); err != nil {
+			return false, err
 		}
 	}
 
 	return changed, nil
 }
 
+// splitLines splits file content into lines and reports whether it ended
+// with a newline, so the final newline doesn't become a phantom empty line
+func splitLines(content []byte) ([]string, bool) {
+	if len(content) == 0 {
+		// New or empty files get a trailing newline once lines are added
+		return nil, true
+	}
+	text := string(content)
+	trailingNewline := strings.HasSuffix(text, "\n")
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n"), trailingNewline
+}
+
+// joinLines joins lines back into file content, restoring the original
+// trailing newline state
+func joinLines(lines []string, trailingNewline bool) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	content := strings.Join(lines, "\n")
+	if trailingNewline {
+		content += "\n"
+	}
+	return content
+}
+
+// backrefPattern matches Ansible-style \1 backreferences
+var backrefPattern = regexp.MustCompile(`\\(\d+)`)
+
+// expandBackrefs converts \1 backreferences to the ${1} form used by
+// regexp.Expand. Only the \1 form is supported, as in Ansible; any literal
+// $ is escaped so "PATH=$PATH" stays as written.
+func expandBackrefs(line string) string {
+	line = strings.ReplaceAll(line, "$", "$$")
+	return backrefPattern.ReplaceAllString(line, `$${$1}`)
+}
+
 // ensureAbsent ensures a line is not present in the file
-func (h *LineinfileHandler) ensureAbsent(path string, params map[string]interface{}) (bool, error) {
+func (h *LineinfileHandler) ensureAbsent(path string, params map[string]interface{}, writer *fileWriter) (bool, error) {
 	line, hasLine := params["line"].(string)
 	regexStr, hasRegex := params["regexp"].(string)
 
@@ -182,13 +230,13 @@ func (h *LineinfileHandler) ensureAbsent(path string, params map[string]interfac
 		return false, err
 	}
 
-	lines := strings.Split(string(content), "\n")
+	lines, trailingNewline := splitLines(content)
 	var newLines []string
 	changed := false
 
 	var regex *regexp.Regexp
 	if hasRegex {
-		regex, err = regexp.Compile(regexStr)
+		regex, err = playbook.CompileRegex(regexStr)
 		if err != nil {
 			return false, fmt.Errorf("invalid regexp: %w", err)
 		}
@@ -211,9 +259,9 @@ func (h *LineinfileHandler) ensureAbsent(path string, params map[string]interfac
 	}
 
 	if changed {
-		newContent := strings.Join(newLines, "\n")
-		if err := os.WriteFile(path, []byte(newContent), 0644); err != nil {
-			return false, fmt.Errorf("failed to write file: %w", err)
+		newContent := joinLines(newLines, trailingNewline)
+		if err := writer.write(path, []byte(newContent), 0644); err != nil {
+			return false, err
 		}
 	}
 
@@ -229,7 +277,7 @@ func (h *LineinfileHandler) insertLine(lines []string, line string, insertAfter
 		}
 
 		// Find the line to insert after
-		regex, err := regexp.Compile(insertAfter)
+		regex, err := playbook.CompileRegex(insertAfter)
 		if err == nil {
 			for i, l := range lines {
 				if regex.MatchString(l) {
@@ -254,7 +302,7 @@ func (h *LineinfileHandler) insertLine(lines []string, line string, insertAfter
 		}
 
 		// Find the line to insert before
-		regex, err := regexp.Compile(insertBefore)
+		regex, err := playbook.CompileRegex(insertBefore)
 		if err == nil {
 			for i, l := range lines {
 				if regex.MatchString(l) {
@@ -301,7 +349,7 @@ func (h *BlockinfileHandler) Execute(ctx context.Context, params map[string]inte
 		Status:    playbook.TaskStatusRunning,
 	}
 
-	path, ok := params["path"].(string)
+	path, ok := pathParam(params, "path", vars)
 	if !ok || path == "" {
 		return nil, fmt.Errorf("path parameter must be a non-empty string")
 	}
@@ -324,18 +372,21 @@ func (h *BlockinfileHandler) Execute(ctx context.Context, params map[string]inte
 		state = s
 	}
 
+	writer := newFileWriter(ctx, params)
+
 	var err error
 	switch state {
 	case "present":
-		result.Changed, err = h.ensureBlockPresent(path, block, beginMarker, endMarker, params)
+		result.Changed, err = h.ensureBlockPresent(path, block, beginMarker, endMarker, params, writer)
 	case "absent":
-		result.Changed, err = h.ensureBlockAbsent(path, beginMarker, endMarker)
+		result.Changed, err = h.ensureBlockAbsent(path, beginMarker, endMarker, writer)
 	default:
 		return nil, fmt.Errorf("unknown state '%s'", state)
 	}
 
 	result.EndTime = time.Now()
 	result.Duration = result.EndTime.Sub(result.StartTime).String()
+	writer.annotate(result)
 
 	if err != nil {
 		result.Status = playbook.TaskStatusFailed
@@ -348,7 +399,7 @@ func (h *BlockinfileHandler) Execute(ctx context.Context, params map[string]inte
 }
 
 // ensureBlockPresent ensures a block is present in the file
-func (h *BlockinfileHandler) ensureBlockPresent(path, block, beginMarker, endMarker string, params map[string]interface{}) (bool, error) {
+func (h *BlockinfileHandler) ensureBlockPresent(path, block, beginMarker, endMarker string, params map[string]interface{}, writer *fileWriter) (bool, error) {
 	// Create file if doesn't exist
 	create := true
 	if c, ok := params["create"].(bool); ok {
@@ -371,7 +422,7 @@ func (h *BlockinfileHandler) ensureBlockPresent(path, block, beginMarker, endMar
 		}
 	}
 
-	lines := strings.Split(string(content), "\n")
+	lines, trailingNewline := splitLines(content)
 
 	// Find existing block
 	beginIdx := -1
@@ -388,7 +439,9 @@ func (h *BlockinfileHandler) ensureBlockPresent(path, block, beginMarker, endMar
 	// Prepare new block
 	newBlock := []string{beginMarker}
 	if block != "" {
-		newBlock = append(newBlock, strings.Split(block, "\n")...)
+		// A YAML literal block ends in a newline; it must not become an
+		// empty line before the end marker
+		newBlock = append(newBlock, strings.Split(strings.TrimSuffix(block, "\n"), "\n")...)
 	}
 	newBlock = append(newBlock, endMarker)
 
@@ -415,7 +468,7 @@ func (h *BlockinfileHandler) ensureBlockPresent(path, block, beginMarker, endMar
 				newLines = append(lines, newBlock...)
 				inserted = true
 			} else {
-				regex, err := regexp.Compile(insertAfter)
+				regex, err := playbook.CompileRegex(insertAfter)
 				if err == nil {
 					for i, l := range lines {
 						newLines = append(newLines, l)
@@ -435,7 +488,7 @@ func (h *BlockinfileHandler) ensureBlockPresent(path, block, beginMarker, endMar
 				newLines = append(newBlock, lines...)
 				inserted = true
 			} else {
-				regex, err := regexp.Compile(insertBefore)
+				regex, err := playbook.CompileRegex(insertBefore)
 				if err == nil {
 					for i, l := range lines {
 						if regex.MatchString(l) {
@@ -456,16 +509,16 @@ func (h *BlockinfileHandler) ensureBlockPresent(path, block, beginMarker, endMar
 		}
 	}
 
-	newContent := strings.Join(newLines, "\n")
-	if err := os.WriteFile(path, []byte(newContent), 0644); err != nil {
-		return false, fmt.Errorf("failed to write file: %w", err)
+	newContent := joinLines(newLines, trailingNewline)
+	if err := writer.write(path, []byte(newContent), 0644); err != nil {
+		return false, err
 	}
 
 	return true, nil
 }
 
 // ensureBlockAbsent removes a block from the file
-func (h *BlockinfileHandler) ensureBlockAbsent(path, beginMarker, endMarker string) (bool, error) {
+func (h *BlockinfileHandler) ensureBlockAbsent(path, beginMarker, endMarker string, writer *fileWriter) (bool, error) {
 	content, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -474,7 +527,7 @@ func (h *BlockinfileHandler) ensureBlockAbsent(path, beginMarker, endMarker stri
 		return false, err
 	}
 
-	lines := strings.Split(string(content), "\n")
+	lines, trailingNewline := splitLines(content)
 
 	beginIdx := -1
 	endIdx := -1
@@ -493,10 +546,10 @@ func (h *BlockinfileHandler) ensureBlockAbsent(path, beginMarker, endMarker stri
 
 	// Remove block
 	newLines := append(lines[:beginIdx], lines[endIdx+1:]...)
-	newContent := strings.Join(newLines, "\n")
+	newContent := joinLines(newLines, trailingNewline)
 
-	if err := os.WriteFile(path, []byte(newContent), 0644); err != nil {
-		return false, fmt.Errorf("failed to write file: %w", err)
+	if err := writer.write(path, []byte(newContent), 0644); err != nil {
+		return false, err
 	}
 
 	return true, nil
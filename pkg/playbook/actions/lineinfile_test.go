@@ -0,0 +1,133 @@
+package actions
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudronix/agent/pkg/playbook"
+)
+
+const testBlock = "# BEGIN MANAGED BLOCK\nx\n# END MANAGED BLOCK"
+
+// TestLineinfileTrailingNewline checks that lineinfile and blockinfile keep
+// the trailing newline state of a file and that running them again with the
+// same params changes nothing
+func TestLineinfileTrailingNewline(t *testing.T) {
+	type handler interface {
+		Execute(context.Context, map[string]interface{}, *playbook.Variables) (*playbook.TaskResult, error)
+	}
+
+	tests := []struct {
+		name    string
+		handler handler
+		params  map[string]interface{}
+		content string
+		want    string
+	}{
+		{"lineinfile present empty", NewLineinfileHandler(), map[string]interface{}{"line": "b"}, "", "b\n"},
+		{"lineinfile present no newline", NewLineinfileHandler(), map[string]interface{}{"line": "b"}, "a", "a\nb"},
+		{"lineinfile present newline", NewLineinfileHandler(), map[string]interface{}{"line": "b"}, "a\n", "a\nb\n"},
+		{"lineinfile present blank line", NewLineinfileHandler(), map[string]interface{}{"line": "b"}, "\n", "\nb\n"},
+
+		{"lineinfile absent empty", NewLineinfileHandler(), map[string]interface{}{"line": "a", "state": "absent"}, "", ""},
+		{"lineinfile absent no newline", NewLineinfileHandler(), map[string]interface{}{"line": "a", "state": "absent"}, "a", ""},
+		{"lineinfile absent newline", NewLineinfileHandler(), map[string]interface{}{"line": "a", "state": "absent"}, "a\n", ""},
+		{"lineinfile absent blank line", NewLineinfileHandler(), map[string]interface{}{"line": "a", "state": "absent"}, "\n", "\n"},
+
+		{"blockinfile present empty", NewBlockinfileHandler(), map[string]interface{}{"block": "x"}, "", testBlock + "\n"},
+		{"blockinfile present no newline", NewBlockinfileHandler(), map[string]interface{}{"block": "x"}, "a", "a\n" + testBlock},
+		{"blockinfile present newline", NewBlockinfileHandler(), map[string]interface{}{"block": "x"}, "a\n", "a\n" + testBlock + "\n"},
+		{"blockinfile present blank line", NewBlockinfileHandler(), map[string]interface{}{"block": "x"}, "\n", "\n" + testBlock + "\n"},
+
+		{"blockinfile absent empty", NewBlockinfileHandler(), map[string]interface{}{"state": "absent"}, "", ""},
+		{"blockinfile absent no newline", NewBlockinfileHandler(), map[string]interface{}{"state": "absent"}, "a", "a"},
+		{"blockinfile absent newline", NewBlockinfileHandler(), map[string]interface{}{"state": "absent"}, "a\n", "a\n"},
+		{"blockinfile absent blank line", NewBlockinfileHandler(), map[string]interface{}{"state": "absent"}, "\n", "\n"},
+		{"blockinfile absent block no newline", NewBlockinfileHandler(), map[string]interface{}{"state": "absent"}, "a\n" + testBlock, "a"},
+		{"blockinfile absent block newline", NewBlockinfileHandler(), map[string]interface{}{"state": "absent"}, "a\n" + testBlock + "\n", "a\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "file")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			params := map[string]interface{}{"path": path}
+			for k, v := range tt.params {
+				params[k] = v
+			}
+
+			first, err := tt.handler.Execute(context.Background(), params, playbook.NewVariables())
+			if err != nil {
+				t.Fatalf("first run: %v", err)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("first run wrote %q, want %q", got, tt.want)
+			}
+			if first.Changed != (tt.content != tt.want) {
+				t.Errorf("first run changed = %v, want %v", first.Changed, tt.content != tt.want)
+			}
+
+			second, err := tt.handler.Execute(context.Background(), params, playbook.NewVariables())
+			if err != nil {
+				t.Fatalf("second run: %v", err)
+			}
+			if second.Changed {
+				t.Error("second run reported changed = true")
+			}
+			again, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(again) != tt.want {
+				t.Errorf("second run changed the file to %q, want %q", again, tt.want)
+			}
+		})
+	}
+}
+
+func TestLineinfileBackrefs(t *testing.T) {
+	tests := []struct {
+		name   string
+		regexp string
+		line   string
+		want   string
+	}{
+		{"literal dollar", `^PATH=(.*)$`, `PATH=$PATH:\1`, "PATH=$PATH:/usr/bin\n"},
+		{"literal braces", `^(PATH)=.*$`, `\1=${HOME}`, "PATH=${HOME}\n"},
+		{"dollar group not expanded", `^PATH=(.*)$`, `$1 \1`, "$1 /usr/bin\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "file")
+			if err := os.WriteFile(path, []byte("PATH=/usr/bin\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			params := map[string]interface{}{
+				"path":     path,
+				"regexp":   tt.regexp,
+				"line":     tt.line,
+				"backrefs": true,
+			}
+			if _, err := NewLineinfileHandler().Execute(context.Background(), params, playbook.NewVariables()); err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+package actions
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cloudronix/agent/pkg/playbook"
+)
+
+// Reboot policies of a maintenance window
+const (
+	maintenanceIfPending = "if_pending" // reboot only when the system flags a pending reboot
+	maintenanceAlways    = "always"     // reboot every time the window opens
+)
+
+// maintenanceNamePattern restricts window names to what is safe in unit
+// file, launchd label and scheduled task names
+var maintenanceNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// weekdayNames maps accepted day names to weekdays
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// maintenanceWindow is a recurring reboot window
+type maintenanceWindow struct {
+	name   string
+	days   []time.Weekday // empty = every day
+	hour   int
+	minute int
+	spread int    // minutes over which the reboot is randomly delayed
+	policy string // if_pending or always
+
+	// Agent binary the timer runs
+	executable string
+}
+
+// command returns the arguments for the agent's maintenance-reboot command
+func (w maintenanceWindow) command() []string {
+	return []string{
+		w.executable, "maintenance-reboot",
+		"--name", w.name,
+		"--policy", w.policy,
+		"--spread", fmt.Sprint(w.spread),
+	}
+}
+
+// fingerprint identifies the window settings, to detect changes where the
+// installed definition can't be compared directly
+func (w maintenanceWindow) fingerprint() string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%v|%d:%d|%s", w.days, w.hour, w.minute, strings.Join(w.command(), " "))))
+	return hex.EncodeToString(sum[:8])
+}
+
+// MaintenanceWindowHandler installs a recurring maintenance reboot: a
+// systemd timer on Linux, a launchd calendar job on macOS or a scheduled
+// task on Windows. When the window opens, the agent's maintenance-reboot
+// command reboots the device if a reboot is pending (policy if_pending) or
+// unconditionally (policy always). Each window is managed by name, so
+// re-running a playbook only changes what differs.
+type MaintenanceWindowHandler struct{}
+
+// NewMaintenanceWindowHandler creates a new maintenance_window handler
+func NewMaintenanceWindowHandler() *MaintenanceWindowHandler {
+	return &MaintenanceWindowHandler{}
+}
+
+// Supports returns the platforms with a scheduler
+func (h *MaintenanceWindowHandler) Supports() []string {
+	return []string{"linux", "windows", "darwin"}
+}
+
+// Validate checks if the params are valid
+func (h *MaintenanceWindowHandler) Validate(params map[string]interface{}) error {
+	name, _ := params["name"].(string)
+	if name == "" {
+		return fmt.Errorf("maintenance_window action requires 'name' parameter")
+	}
+	if !maintenanceNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid window name '%s': use letters, digits, '-' and '_'", name)
+	}
+	return nil
+}
+
+// Execute performs the maintenance_window operation
+func (h *MaintenanceWindowHandler) Execute(ctx context.Context, params map[string]interface{}, vars *playbook.Variables) (*playbook.TaskResult, error) {
+	result := &playbook.TaskResult{
+		StartTime: time.Now(),
+		Status:    playbook.TaskStatusRunning,
+	}
+
+	if err := h.Validate(params); err != nil {
+		return nil, err
+	}
+	name := params["name"].(string)
+
+	state := "present"
+	if s, ok := params["state"].(string); ok {
+		state = s
+	}
+
+	var err error
+	switch state {
+	case "present":
+		var window maintenanceWindow
+		window, err = parseMaintenanceWindow(name, params)
+		if err != nil {
+			return nil, err
+		}
+		result.Changed, err = installMaintenanceWindow(ctx, window)
+		if err == nil {
+			result.Message = fmt.Sprintf("Maintenance window '%s' at %02d:%02d (%s, policy %s)",
+				name, window.hour, window.minute, describeDays(window.days), window.policy)
+		}
+	case "absent":
+		result.Changed, err = removeMaintenanceWindow(ctx, name)
+		if err == nil {
+			result.Message = fmt.Sprintf("Maintenance window '%s' removed", name)
+		}
+	default:
+		return nil, fmt.Errorf("unknown state '%s'", state)
+	}
+
+	result.EndTime = time.Now()
+	result.Duration = result.EndTime.Sub(result.StartTime).String()
+
+	if err != nil {
+		result.Status = playbook.TaskStatusFailed
+		result.Error = err.Error()
+		return result, err
+	}
+
+	result.Status = playbook.TaskStatusCompleted
+	return result, nil
+}
+
+// parseMaintenanceWindow reads the schedule and policy params
+func parseMaintenanceWindow(name string, params map[string]interface{}) (maintenanceWindow, error) {
+	window := maintenanceWindow{name: name, policy: maintenanceIfPending}
+
+	start, _ := params["time"].(string)
+	if start == "" {
+		return window, fmt.Errorf("'time' parameter (HH:MM) is required for state 'present'")
+	}
+	t, err := time.Parse("15:04", start)
+	if err != nil {
+		return window, fmt.Errorf("invalid time '%s': expected HH:MM", start)
+	}
+	window.hour, window.minute = t.Hour(), t.Minute()
+
+	days, err := dayNames(params["days"])
+	if err != nil {
+		return window, err
+	}
+	seen := make(map[time.Weekday]bool)
+	for _, day := range days {
+		weekday, ok := weekdayNames[strings.ToLower(strings.TrimSpace(day))]
+		if !ok {
+			return window, fmt.Errorf("invalid day '%s'", day)
+		}
+		if !seen[weekday] {
+			seen[weekday] = true
+			window.days = append(window.days, weekday)
+		}
+	}
+	sort.Slice(window.days, func(i, j int) bool { return window.days[i] < window.days[j] })
+	if len(window.days) == 7 {
+		window.days = nil
+	}
+
+	switch d := params["duration"].(type) {
+	case nil:
+	case int:
+		window.spread = d
+	case float64:
+		window.spread = int(d)
+	default:
+		return window, fmt.Errorf("duration must be a number of minutes")
+	}
+	if window.spread < 0 {
+		return window, fmt.Errorf("duration must not be negative")
+	}
+
+	if p, ok := params["policy"].(string); ok {
+		if p != maintenanceIfPending && p != maintenanceAlways {
+			return window, fmt.Errorf("unknown reboot policy '%s' (expected %s or %s)", p, maintenanceIfPending, maintenanceAlways)
+		}
+		window.policy = p
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return window, fmt.Errorf("failed to locate agent executable: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	window.executable = exe
+
+	return window, nil
+}
+
+// dayNames reads the days param as a list or comma-separated string
+func dayNames(param interface{}) ([]string, error) {
+	switch val := param.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return strings.Split(val, ","), nil
+	case []interface{}:
+		days := make([]string, 0, len(val))
+		for _, item := range val {
+			day, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("days list must contain only strings")
+			}
+			days = append(days, day)
+		}
+		return days, nil
+	default:
+		return nil, fmt.Errorf("days must be a list or comma-separated string")
+	}
+}
+
+// describeDays formats window days for messages
+func describeDays(days []time.Weekday) string {
+	if len(days) == 0 {
+		return "daily"
+	}
+	names := make([]string, len(days))
+	for i, day := range days {
+		names[i] = day.String()[:3]
+	}
+	return strings.Join(names, ",")
+}
+
+// unsupportedMaintenanceWindow is returned on platforms without a scheduler
+func unsupportedMaintenanceWindow() error {
+	return playbook.NewUnsupportedActionError(playbook.ActionMaintenanceWindow, runtime.GOOS)
+}
@@ -0,0 +1,99 @@
+//go:build darwin
+
+package actions
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// launchDaemonDir holds the managed launchd jobs
+const launchDaemonDir = "/Library/LaunchDaemons"
+
+// maintenanceLabel returns the launchd label for a window
+func maintenanceLabel(name string) string {
+	return "io.cloudronix.maintenance." + name
+}
+
+// installMaintenanceWindow writes a launchd daemon with a calendar
+// schedule and loads it
+func installMaintenanceWindow(ctx context.Context, w maintenanceWindow) (bool, error) {
+	label := maintenanceLabel(w.name)
+	path := filepath.Join(launchDaemonDir, label+".plist")
+
+	var args strings.Builder
+	for _, arg := range w.command() {
+		args.WriteString("        <string>")
+		xml.EscapeText(&args, []byte(arg))
+		args.WriteString("</string>\n")
+	}
+
+	// One calendar entry per day; launchd numbers days from Sunday = 0
+	var intervals strings.Builder
+	entry := func(weekday string) {
+		intervals.WriteString("        <dict>\n")
+		intervals.WriteString(weekday)
+		fmt.Fprintf(&intervals, "            <key>Hour</key>\n            <integer>%d</integer>\n", w.hour)
+		fmt.Fprintf(&intervals, "            <key>Minute</key>\n            <integer>%d</integer>\n", w.minute)
+		intervals.WriteString("        </dict>\n")
+	}
+	if len(w.days) == 0 {
+		entry("")
+	}
+	for _, day := range w.days {
+		entry(fmt.Sprintf("            <key>Weekday</key>\n            <integer>%d</integer>\n", int(day)))
+	}
+
+	plist := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<!-- Managed by Cloudronix - do not edit -->
+<plist version="1.0">
+<dict>
+    <key>Label</key>
+    <string>%s</string>
+    <key>ProgramArguments</key>
+    <array>
+%s    </array>
+    <key>StartCalendarInterval</key>
+    <array>
+%s    </array>
+</dict>
+</plist>
+`, label, args.String(), intervals.String())
+
+	loaded := exec.CommandContext(ctx, "launchctl", "list", label).Run() == nil
+	if current, err := os.ReadFile(path); err == nil && bytes.Equal(current, []byte(plist)) && loaded {
+		return false, nil
+	}
+
+	if loaded {
+		exec.CommandContext(ctx, "launchctl", "unload", path).Run()
+	}
+	if err := os.WriteFile(path, []byte(plist), 0644); err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if output, err := exec.CommandContext(ctx, "launchctl", "load", "-w", path).CombinedOutput(); err != nil {
+		return false, fmt.Errorf("failed to load %s: %s - %w", label, strings.TrimSpace(string(output)), err)
+	}
+	return true, nil
+}
+
+// removeMaintenanceWindow unloads and deletes the launchd job
+func removeMaintenanceWindow(ctx context.Context, name string) (bool, error) {
+	path := filepath.Join(launchDaemonDir, maintenanceLabel(name)+".plist")
+	if !fileExists(path) {
+		return false, nil
+	}
+
+	exec.CommandContext(ctx, "launchctl", "unload", path).Run()
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("failed to remove %s: %w", path, err)
+	}
+	return true, nil
+}
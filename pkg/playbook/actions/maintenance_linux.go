@@ -0,0 +1,130 @@
+//go:build linux
+
+package actions
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// systemdUnitDir holds the managed timer and service units
+const systemdUnitDir = "/etc/systemd/system"
+
+// maintenanceUnit returns the base unit name for a window
+func maintenanceUnit(name string) string {
+	return "cloudronix-maintenance-" + name
+}
+
+// installMaintenanceWindow writes a systemd timer and the oneshot service it
+// starts, then enables the timer
+func installMaintenanceWindow(ctx context.Context, w maintenanceWindow) (bool, error) {
+	unit := maintenanceUnit(w.name)
+
+	service := fmt.Sprintf(`# Managed by Cloudronix - do not edit
+[Unit]
+Description=Cloudronix maintenance reboot '%s'
+
+[Service]
+Type=oneshot
+ExecStart=%s
+`, w.name, systemdCommand(w.command()))
+
+	calendar := "*-*-*"
+	if len(w.days) > 0 {
+		calendar = describeDays(w.days) + " *-*-*"
+	}
+	timer := fmt.Sprintf(`# Managed by Cloudronix - do not edit
+[Unit]
+Description=Cloudronix maintenance window '%s'
+
+[Timer]
+OnCalendar=%s %02d:%02d:00
+
+[Install]
+WantedBy=timers.target
+`, w.name, calendar, w.hour, w.minute)
+
+	changed := false
+	for _, file := range []struct{ name, content string }{
+		{unit + ".service", service},
+		{unit + ".timer", timer},
+	} {
+		path := filepath.Join(systemdUnitDir, file.name)
+		if current, err := os.ReadFile(path); err == nil && bytes.Equal(current, []byte(file.content)) {
+			continue
+		}
+		if err := os.WriteFile(path, []byte(file.content), 0644); err != nil {
+			return false, fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		changed = true
+	}
+
+	if changed {
+		if err := runSystemctl(ctx, "daemon-reload"); err != nil {
+			return false, err
+		}
+	}
+
+	// Also repairs a timer someone stopped or disabled by hand
+	active := exec.CommandContext(ctx, "systemctl", "is-active", "--quiet", unit+".timer").Run() == nil
+	enabled := exec.CommandContext(ctx, "systemctl", "is-enabled", "--quiet", unit+".timer").Run() == nil
+	if changed || !active || !enabled {
+		if err := runSystemctl(ctx, "enable", unit+".timer"); err != nil {
+			return false, err
+		}
+		// restart picks up a changed schedule
+		if err := runSystemctl(ctx, "restart", unit+".timer"); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+
+	return changed, nil
+}
+
+// removeMaintenanceWindow disables the timer and deletes both units
+func removeMaintenanceWindow(ctx context.Context, name string) (bool, error) {
+	unit := maintenanceUnit(name)
+	timerPath := filepath.Join(systemdUnitDir, unit+".timer")
+	servicePath := filepath.Join(systemdUnitDir, unit+".service")
+
+	if !fileExists(timerPath) && !fileExists(servicePath) {
+		return false, nil
+	}
+
+	// The timer may already be gone or stopped
+	runSystemctl(ctx, "disable", "--now", unit+".timer")
+
+	for _, path := range []string{timerPath, servicePath} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return false, fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+	}
+	return true, runSystemctl(ctx, "daemon-reload")
+}
+
+// runSystemctl runs systemctl, including its output in errors
+func runSystemctl(ctx context.Context, args ...string) error {
+	output, err := exec.CommandContext(ctx, "systemctl", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("systemctl %s failed: %s - %w", strings.Join(args, " "), strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
+
+// systemdCommand quotes arguments for ExecStart=
+func systemdCommand(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if strings.ContainsAny(arg, " \t\"\\") {
+			arg = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
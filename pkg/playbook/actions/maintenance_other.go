@@ -0,0 +1,15 @@
+//go:build !linux && !windows && !darwin
+
+package actions
+
+import "context"
+
+// installMaintenanceWindow is not available on this platform
+func installMaintenanceWindow(ctx context.Context, w maintenanceWindow) (bool, error) {
+	return false, unsupportedMaintenanceWindow()
+}
+
+// removeMaintenanceWindow is not available on this platform
+func removeMaintenanceWindow(ctx context.Context, name string) (bool, error) {
+	return false, unsupportedMaintenanceWindow()
+}
@@ -0,0 +1,91 @@
+//go:build windows
+
+package actions
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// maintenanceTaskPath is the Task Scheduler folder of the managed tasks
+const maintenanceTaskPath = `\Cloudronix\`
+
+// maintenanceTask returns the scheduled task name for a window
+func maintenanceTask(name string) string {
+	return "Maintenance-" + name
+}
+
+// installMaintenanceWindow registers a scheduled task running as SYSTEM.
+// Task definitions can't be compared reliably after Task Scheduler
+// normalizes them, so the description carries a fingerprint of the settings.
+func installMaintenanceWindow(ctx context.Context, w maintenanceWindow) (bool, error) {
+	task := maintenanceTask(w.name)
+	description := fmt.Sprintf("Managed by Cloudronix - do not edit (%s)", w.fingerprint())
+
+	current, err := runMaintenancePowerShell(ctx, fmt.Sprintf(
+		`$t = Get-ScheduledTask -TaskName '%s' -TaskPath '%s' -ErrorAction SilentlyContinue; if ($t) { "$($t.Description)|$($t.State)" }`,
+		task, maintenanceTaskPath))
+	if err != nil {
+		return false, err
+	}
+	if desc, state, _ := strings.Cut(current, "|"); desc == description && state != "Disabled" {
+		return false, nil
+	}
+
+	trigger := fmt.Sprintf("New-ScheduledTaskTrigger -Daily -At '%02d:%02d'", w.hour, w.minute)
+	if len(w.days) > 0 {
+		days := make([]string, len(w.days))
+		for i, day := range w.days {
+			days[i] = day.String()
+		}
+		trigger = fmt.Sprintf("New-ScheduledTaskTrigger -Weekly -DaysOfWeek %s -At '%02d:%02d'", strings.Join(days, ","), w.hour, w.minute)
+	}
+
+	args := w.command()
+	script := strings.Join([]string{
+		fmt.Sprintf("$action = New-ScheduledTaskAction -Execute '%s' -Argument '%s'",
+			escapeForPowerShell(args[0]), escapeForPowerShell(windowsCommandLine(args[1:]))),
+		"$trigger = " + trigger,
+		"$principal = New-ScheduledTaskPrincipal -UserId 'SYSTEM' -LogonType ServiceAccount -RunLevel Highest",
+		fmt.Sprintf("Register-ScheduledTask -TaskName '%s' -TaskPath '%s' -Description '%s' -Action $action -Trigger $trigger -Principal $principal -Force | Out-Null",
+			task, maintenanceTaskPath, escapeForPowerShell(description)),
+	}, "; ")
+	if _, err := runMaintenancePowerShell(ctx, script); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// removeMaintenanceWindow unregisters the scheduled task
+func removeMaintenanceWindow(ctx context.Context, name string) (bool, error) {
+	output, err := runMaintenancePowerShell(ctx, fmt.Sprintf(
+		`$t = Get-ScheduledTask -TaskName '%s' -TaskPath '%s' -ErrorAction SilentlyContinue; if ($t) { $t | Unregister-ScheduledTask -Confirm:$false; 'removed' }`,
+		maintenanceTask(name), maintenanceTaskPath))
+	if err != nil {
+		return false, err
+	}
+	return output == "removed", nil
+}
+
+// runMaintenancePowerShell runs a PowerShell script, returning its trimmed output
+func runMaintenancePowerShell(ctx context.Context, script string) (string, error) {
+	output, err := exec.CommandContext(ctx, "powershell", "-NoProfile", "-NonInteractive", "-Command", script).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("scheduled task operation failed: %v - %s", err, strings.TrimSpace(string(output)))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// windowsCommandLine joins arguments, quoting those with spaces
+func windowsCommandLine(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if strings.ContainsAny(arg, " \t\"") {
+			arg = `"` + strings.ReplaceAll(arg, `"`, `\"`) + `"`
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
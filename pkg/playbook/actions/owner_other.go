@@ -0,0 +1,85 @@
+//go:build !windows
+
+package actions
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// setOwnership changes the owner and/or group of path. Names are looked up
+// with os/user; numeric IDs are accepted as well. Returns whether the
+// ownership actually changed.
+func setOwnership(path, owner, group string) (bool, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return false, err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return false, fmt.Errorf("cannot read ownership of '%s'", path)
+	}
+
+	uid, gid := int(stat.Uid), int(stat.Gid)
+	wantUID, wantGID := uid, gid
+
+	if owner != "" {
+		if wantUID, err = lookupUID(owner); err != nil {
+			return false, err
+		}
+	}
+	if group != "" {
+		if wantGID, err = lookupGID(group); err != nil {
+			return false, err
+		}
+	}
+
+	if wantUID == uid && wantGID == gid {
+		return false, nil
+	}
+
+	if err := os.Lchown(path, wantUID, wantGID); err != nil {
+		return false, fmt.Errorf("failed to set ownership: %w", err)
+	}
+	return true, nil
+}
+
+// copyOwnership gives path the owner and group from info, e.g. so a file
+// replaced by a rename keeps the original's ownership
+func copyOwnership(info os.FileInfo, path string) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	if err := os.Lchown(path, int(stat.Uid), int(stat.Gid)); err != nil && !os.IsPermission(err) {
+		return err
+	}
+	return nil
+}
+
+// lookupUID resolves a user name or numeric UID
+func lookupUID(owner string) (int, error) {
+	if id, err := strconv.Atoi(owner); err == nil {
+		return id, nil
+	}
+	u, err := user.Lookup(owner)
+	if err != nil {
+		return 0, fmt.Errorf("unknown owner '%s': %w", owner, err)
+	}
+	return strconv.Atoi(u.Uid)
+}
+
+// lookupGID resolves a group name or numeric GID
+func lookupGID(group string) (int, error) {
+	if id, err := strconv.Atoi(group); err == nil {
+		return id, nil
+	}
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		return 0, fmt.Errorf("unknown group '%s': %w", group, err)
+	}
+	return strconv.Atoi(g.Gid)
+}
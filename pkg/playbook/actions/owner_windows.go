@@ -0,0 +1,20 @@
+//go:build windows
+
+package actions
+
+import (
+	"fmt"
+	"os"
+)
+
+// setOwnership is not supported on Windows, where ownership is managed
+// through ACLs rather than owner/group IDs
+func setOwnership(path, owner, group string) (bool, error) {
+	return false, fmt.Errorf("ownership changes not supported on Windows")
+}
+
+// copyOwnership does nothing on Windows; a replaced file inherits the ACLs
+// of its directory
+func copyOwnership(info os.FileInfo, path string) error {
+	return nil
+}
@@ -0,0 +1,433 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/cloudronix/agent/pkg/playbook"
+)
+
+// PackageHandler installs and removes packages with the system package
+// manager (apt/dnf/yum on Linux, brew on macOS, winget/choco on Windows)
+type PackageHandler struct{}
+
+// NewPackageHandler creates a new package handler
+func NewPackageHandler() *PackageHandler {
+	return &PackageHandler{}
+}
+
+// Supports returns all desktop platforms
+func (h *PackageHandler) Supports() []string {
+	return []string{"windows", "linux", "darwin"}
+}
+
+// Validate checks if the params are valid
+func (h *PackageHandler) Validate(params map[string]interface{}) error {
+	if _, err := packageNames(params["name"]); err != nil {
+		return err
+	}
+	if state, ok := params["state"].(string); ok {
+		switch state {
+		case "present", "absent", "latest":
+		default:
+			return fmt.Errorf("unknown state '%s' (expected present, absent or latest)", state)
+		}
+	}
+	return nil
+}
+
+// Execute brings each package to the requested state
+func (h *PackageHandler) Execute(ctx context.Context, params map[string]interface{}, vars *playbook.Variables) (*playbook.TaskResult, error) {
+	result := &playbook.TaskResult{
+		StartTime: time.Now(),
+		Status:    playbook.TaskStatusRunning,
+	}
+
+	if err := h.Validate(params); err != nil {
+		return nil, err
+	}
+	names, _ := packageNames(params["name"])
+
+	state := "present"
+	if s, ok := params["state"].(string); ok {
+		state = s
+	}
+
+	managerName, _ := params["manager"].(string)
+	mgr, err := findPackageManager(managerName)
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr []string
+	var changed []string
+	fail := func(err error) (*playbook.TaskResult, error) {
+		result.Stdout = strings.Join(stdout, "\n")
+		result.Stderr = strings.Join(stderr, "\n")
+		result.Status = playbook.TaskStatusFailed
+		result.Error = err.Error()
+		result.EndTime = time.Now()
+		result.Duration = result.EndTime.Sub(result.StartTime).String()
+		return result, err
+	}
+
+	// Refresh the package index first if requested and stale
+	cacheUpdated := false
+	if update, _ := params["update_cache"].(bool); update {
+		updated, out, errOut, err := mgr.refreshCache(ctx, cacheValidTime(params))
+		if out != "" {
+			stdout = append(stdout, out)
+		}
+		if errOut != "" {
+			stderr = append(stderr, errOut)
+		}
+		if err != nil {
+			return fail(fmt.Errorf("%s cache update failed: %w", mgr.name, err))
+		}
+		cacheUpdated = updated
+	}
+
+	for _, name := range names {
+		installed, err := mgr.isInstalled(ctx, name)
+		if err != nil {
+			return fail(err)
+		}
+
+		var args []string
+		switch state {
+		case "present":
+			if !installed {
+				args = mgr.install(name)
+			}
+		case "absent":
+			if installed {
+				args = mgr.remove(name)
+			}
+		case "latest":
+			if !installed {
+				args = mgr.install(name)
+			} else {
+				outdated, err := mgr.isOutdated(ctx, name)
+				if err != nil {
+					return fail(err)
+				}
+				if outdated {
+					args = mgr.upgrade(name)
+				}
+			}
+		}
+
+		if args == nil {
+			continue // already in the requested state
+		}
+
+		out, errOut, exitCode, err := runPackageCommand(ctx, args)
+		if out != "" {
+			stdout = append(stdout, out)
+		}
+		if errOut != "" {
+			stderr = append(stderr, errOut)
+		}
+		if err != nil {
+			result.ExitCode = exitCode
+			return fail(fmt.Errorf("%s failed for package '%s': %w", mgr.name, name, err))
+		}
+		changed = append(changed, name)
+	}
+
+	result.Stdout = strings.Join(stdout, "\n")
+	result.Stderr = strings.Join(stderr, "\n")
+	result.Changed = len(changed) > 0
+	if result.Changed {
+		result.Message = fmt.Sprintf("Package(s) %s: %s", state, strings.Join(changed, ", "))
+	} else {
+		result.Message = fmt.Sprintf("All packages already %s", state)
+	}
+	if cacheUpdated {
+		result.Message += " (package cache updated)"
+	}
+	result.EndTime = time.Now()
+	result.Duration = result.EndTime.Sub(result.StartTime).String()
+	result.Status = playbook.TaskStatusCompleted
+	return result, nil
+}
+
+// packageNames reads the name param as a string or list of strings
+func packageNames(param interface{}) ([]string, error) {
+	var names []string
+	switch val := param.(type) {
+	case string:
+		names = []string{val}
+	case []interface{}:
+		for _, item := range val {
+			name, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("name list must contain only strings")
+			}
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 0 {
+		return nil, fmt.Errorf("package action requires 'name' parameter (string or list)")
+	}
+	for _, name := range names {
+		if name == "" || strings.HasPrefix(name, "-") {
+			return nil, fmt.Errorf("invalid package name '%s'", name)
+		}
+	}
+	return names, nil
+}
+
+// packageManager describes how to drive one package manager
+type packageManager struct {
+	name string
+
+	// Commands that change state
+	install func(pkg string) []string
+	remove  func(pkg string) []string
+	upgrade func(pkg string) []string
+
+	// State checks
+	isInstalled func(ctx context.Context, pkg string) (bool, error)
+	isOutdated  func(ctx context.Context, pkg string) (bool, error)
+
+	// Package index refresh (update_cache). cachePaths are checked for
+	// their modification time to honor cache_valid_time; when none exist
+	// the cache is treated as stale.
+	updateCache []string
+	cachePaths  []string
+}
+
+// cacheValidTime reads the cache_valid_time param (seconds)
+func cacheValidTime(params map[string]interface{}) time.Duration {
+	switch v := params["cache_valid_time"].(type) {
+	case int:
+		return time.Duration(v) * time.Second
+	case float64:
+		return time.Duration(v) * time.Second
+	}
+	return 0
+}
+
+// refreshCache updates the package index unless it was refreshed within
+// validFor. Reports whether the index was refreshed.
+func (m *packageManager) refreshCache(ctx context.Context, validFor time.Duration) (updated bool, stdout, stderr string, err error) {
+	if m.updateCache == nil {
+		return false, "", "", nil // manager refreshes its index on demand
+	}
+	if validFor > 0 {
+		if age, ok := m.cacheAge(); ok && age < validFor {
+			return false, "", "", nil
+		}
+	}
+
+	stdout, stderr, _, err = runPackageCommand(ctx, m.updateCache)
+	return err == nil, stdout, stderr, err
+}
+
+// cacheAge returns the time since the package index was last refreshed,
+// using the newest modification time among the cache paths
+func (m *packageManager) cacheAge() (time.Duration, bool) {
+	var newest time.Time
+	for _, path := range m.cachePaths {
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+	}
+	if newest.IsZero() {
+		return 0, false
+	}
+	return time.Since(newest), true
+}
+
+// packageManagers lists the supported managers per platform in order of preference
+var packageManagers = map[string][]string{
+	"linux":   {"apt", "dnf", "yum"},
+	"darwin":  {"brew"},
+	"windows": {"winget", "choco"},
+}
+
+// findPackageManager returns the requested manager, or the first one
+// available on this platform
+func findPackageManager(name string) (*packageManager, error) {
+	candidates := packageManagers[runtime.GOOS]
+	if name != "" {
+		candidates = []string{name}
+	}
+
+	for _, candidate := range candidates {
+		mgr := newPackageManager(candidate)
+		if mgr == nil {
+			return nil, fmt.Errorf("unknown package manager '%s'", candidate)
+		}
+		binary := candidate
+		if candidate == "apt" {
+			binary = "apt-get"
+		}
+		if _, err := exec.LookPath(binary); err == nil {
+			return mgr, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no supported package manager found (tried %s)", strings.Join(candidates, ", "))
+}
+
+// newPackageManager returns the definition for a package manager by name
+func newPackageManager(name string) *packageManager {
+	switch name {
+	case "apt":
+		return &packageManager{
+			name:    name,
+			install: func(pkg string) []string { return []string{"apt-get", "install", "-y", pkg} },
+			remove:  func(pkg string) []string { return []string{"apt-get", "remove", "-y", pkg} },
+			upgrade: func(pkg string) []string { return []string{"apt-get", "install", "--only-upgrade", "-y", pkg} },
+			isInstalled: func(ctx context.Context, pkg string) (bool, error) {
+				out, _, _, err := runPackageCommand(ctx, []string{"dpkg-query", "-W", "-f=${Status}", pkg})
+				return err == nil && strings.Contains(out, "install ok installed"), nil
+			},
+			isOutdated: func(ctx context.Context, pkg string) (bool, error) {
+				out, _, _, err := runPackageCommand(ctx, []string{"apt", "list", "--upgradable", pkg})
+				if err != nil {
+					return false, fmt.Errorf("failed to check for updates: %w", err)
+				}
+				return strings.Contains(out, pkg+"/"), nil
+			},
+			updateCache: []string{"apt-get", "update"},
+			cachePaths: []string{
+				"/var/lib/apt/periodic/update-success-stamp",
+				"/var/lib/apt/lists/partial",
+				"/var/lib/apt/lists",
+			},
+		}
+
+	case "dnf", "yum":
+		return &packageManager{
+			name:    name,
+			install: func(pkg string) []string { return []string{name, "install", "-y", pkg} },
+			remove:  func(pkg string) []string { return []string{name, "remove", "-y", pkg} },
+			upgrade: func(pkg string) []string { return []string{name, "upgrade", "-y", pkg} },
+			isInstalled: func(ctx context.Context, pkg string) (bool, error) {
+				_, _, _, err := runPackageCommand(ctx, []string{"rpm", "-q", pkg})
+				return err == nil, nil
+			},
+			isOutdated: func(ctx context.Context, pkg string) (bool, error) {
+				// check-update exits 100 when updates are available
+				_, _, exitCode, err := runPackageCommand(ctx, []string{name, "check-update", "-q", pkg})
+				if exitCode == 100 {
+					return true, nil
+				}
+				if err != nil {
+					return false, fmt.Errorf("failed to check for updates: %w", err)
+				}
+				return false, nil
+			},
+			updateCache: []string{name, "makecache", "-q"},
+			cachePaths:  []string{"/var/cache/" + name},
+		}
+
+	case "brew":
+		return &packageManager{
+			name:    name,
+			install: func(pkg string) []string { return []string{"brew", "install", pkg} },
+			remove:  func(pkg string) []string { return []string{"brew", "uninstall", pkg} },
+			upgrade: func(pkg string) []string { return []string{"brew", "upgrade", pkg} },
+			isInstalled: func(ctx context.Context, pkg string) (bool, error) {
+				out, _, _, err := runPackageCommand(ctx, []string{"brew", "list", "--versions", pkg})
+				return err == nil && out != "", nil
+			},
+			isOutdated: func(ctx context.Context, pkg string) (bool, error) {
+				out, _, _, _ := runPackageCommand(ctx, []string{"brew", "outdated", "--quiet", pkg})
+				return out != "", nil
+			},
+			updateCache: []string{"brew", "update", "--quiet"},
+		}
+
+	case "winget":
+		agree := []string{"--accept-source-agreements"}
+		return &packageManager{
+			name: name,
+			install: func(pkg string) []string {
+				return append([]string{"winget", "install", "--exact", "--id", pkg, "--silent", "--accept-package-agreements"}, agree...)
+			},
+			remove: func(pkg string) []string {
+				return append([]string{"winget", "uninstall", "--exact", "--id", pkg, "--silent"}, agree...)
+			},
+			upgrade: func(pkg string) []string {
+				return append([]string{"winget", "upgrade", "--exact", "--id", pkg, "--silent", "--accept-package-agreements"}, agree...)
+			},
+			isInstalled: func(ctx context.Context, pkg string) (bool, error) {
+				_, _, _, err := runPackageCommand(ctx, append([]string{"winget", "list", "--exact", "--id", pkg}, agree...))
+				return err == nil, nil
+			},
+			isOutdated: func(ctx context.Context, pkg string) (bool, error) {
+				_, _, _, err := runPackageCommand(ctx, append([]string{"winget", "list", "--upgrade-available", "--exact", "--id", pkg}, agree...))
+				return err == nil, nil
+			},
+			updateCache: []string{"winget", "source", "update"},
+		}
+
+	case "choco":
+		return &packageManager{
+			name:    name,
+			install: func(pkg string) []string { return []string{"choco", "install", "-y", "--no-progress", pkg} },
+			remove:  func(pkg string) []string { return []string{"choco", "uninstall", "-y", pkg} },
+			upgrade: func(pkg string) []string { return []string{"choco", "upgrade", "-y", "--no-progress", pkg} },
+			isInstalled: func(ctx context.Context, pkg string) (bool, error) {
+				out, _, _, err := runPackageCommand(ctx, []string{"choco", "list", "--exact", "--limit-output", pkg})
+				return err == nil && hasChocoPackage(out, pkg), nil
+			},
+			isOutdated: func(ctx context.Context, pkg string) (bool, error) {
+				out, _, _, err := runPackageCommand(ctx, []string{"choco", "outdated", "--limit-output"})
+				if err != nil {
+					return false, fmt.Errorf("failed to check for updates: %w", err)
+				}
+				return hasChocoPackage(out, pkg), nil
+			},
+		}
+
+	default:
+		return nil
+	}
+}
+
+// hasChocoPackage checks choco --limit-output lines ("name|version...") for a package
+func hasChocoPackage(output, pkg string) bool {
+	for _, line := range strings.Split(output, "\n") {
+		if name, _, ok := strings.Cut(strings.TrimSpace(line), "|"); ok && strings.EqualFold(name, pkg) {
+			return true
+		}
+	}
+	return false
+}
+
+// runPackageCommand runs a package manager command non-interactively
+func runPackageCommand(ctx context.Context, args []string) (stdout, stderr string, exitCode int, err error) {
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), "DEBIAN_FRONTEND=noninteractive", "HOMEBREW_NO_AUTO_UPDATE=1")
+
+	var outBuf, errBuf bytes.Buffer
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &errBuf
+
+	err = cmd.Run()
+	stdout = strings.TrimSpace(decodeOutput(outBuf.Bytes()))
+	stderr = strings.TrimSpace(decodeOutput(errBuf.Bytes()))
+
+	if err != nil {
+		exitCode = -1
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			exitCode = exitErr.ExitCode()
+		}
+		if stderr != "" {
+			err = fmt.Errorf("%w - %s", err, stderr)
+		}
+	}
+	return stdout, stderr, exitCode, err
+}
@@ -0,0 +1,84 @@
+package actions
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/cloudronix/agent/pkg/playbook"
+)
+
+// envRefPattern matches $NAME and ${NAME} environment variable references
+var envRefPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}|\$([A-Za-z_][A-Za-z0-9_]*)`)
+
+// expandPath normalizes a path param: a leading ~ or ~user becomes the home
+// directory, $NAME and ${NAME} are replaced by environment variables (unset
+// ones are left as written), and relative paths are resolved against the
+// job workdir when there is one.
+func expandPath(path string, vars *playbook.Variables) string {
+	if path == "" {
+		return path
+	}
+
+	path = envRefPattern.ReplaceAllStringFunc(path, func(ref string) string {
+		name := strings.Trim(ref, "${}")
+		if value, ok := os.LookupEnv(name); ok {
+			return value
+		}
+		return ref
+	})
+
+	path = expandHome(path)
+
+	if !filepath.IsAbs(path) && vars != nil {
+		if workdir := vars.JobWorkdir(); workdir != "" {
+			path = filepath.Join(workdir, path)
+		}
+	}
+
+	return filepath.Clean(path)
+}
+
+// expandHome replaces a leading ~ with the agent user's home directory and
+// ~user with that user's. Unknown users are left as written.
+func expandHome(path string) string {
+	if !strings.HasPrefix(path, "~") {
+		return path
+	}
+
+	name, rest := path[1:], ""
+	for i := 0; i < len(name); i++ {
+		if os.IsPathSeparator(name[i]) {
+			name, rest = name[:i], name[i:]
+			break
+		}
+	}
+
+	var home string
+	if name == "" {
+		dir, err := os.UserHomeDir()
+		if err != nil {
+			return path
+		}
+		home = dir
+	} else {
+		u, err := user.Lookup(name)
+		if err != nil || u.HomeDir == "" {
+			return path
+		}
+		home = u.HomeDir
+	}
+	return home + rest
+}
+
+// pathParam reads a path param and normalizes it with expandPath.
+// ok is false when the param is missing or not a string.
+func pathParam(params map[string]interface{}, key string, vars *playbook.Variables) (string, bool) {
+	path, ok := params[key].(string)
+	if !ok {
+		return "", false
+	}
+	return expandPath(path, vars), true
+}
@@ -4,7 +4,7 @@ package actions
 
 import (
 	"context"
-	"fmt"
+	"runtime"
 
 	"github.com/cloudronix/agent/pkg/playbook"
 )
@@ -24,10 +24,10 @@ func (h *RegistryHandler) Supports() []string {
 
 // Validate checks if the params are valid
 func (h *RegistryHandler) Validate(params map[string]interface{}) error {
-	return fmt.Errorf("registry action is only available on Windows")
+	return playbook.NewUnsupportedActionError(playbook.ActionRegistry, runtime.GOOS)
 }
 
 // Execute is not available on non-Windows platforms
 func (h *RegistryHandler) Execute(ctx context.Context, params map[string]interface{}, vars *playbook.Variables) (*playbook.TaskResult, error) {
-	return nil, fmt.Errorf("registry action is only available on Windows")
+	return nil, playbook.NewUnsupportedActionError(playbook.ActionRegistry, runtime.GOOS)
 }
@@ -0,0 +1,169 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cloudronix/agent/pkg/playbook"
+)
+
+// ReplaceHandler replaces every match of a regexp in a file
+type ReplaceHandler struct{}
+
+// NewReplaceHandler creates a new replace handler
+func NewReplaceHandler() *ReplaceHandler {
+	return &ReplaceHandler{}
+}
+
+// Supports returns all platforms
+func (h *ReplaceHandler) Supports() []string {
+	return []string{"all"}
+}
+
+// Validate checks if the params are valid
+func (h *ReplaceHandler) Validate(params map[string]interface{}) error {
+	if _, ok := params["path"]; !ok {
+		return fmt.Errorf("replace action requires 'path' parameter")
+	}
+	if _, ok := params["regexp"]; !ok {
+		return fmt.Errorf("replace action requires 'regexp' parameter")
+	}
+	return nil
+}
+
+// Execute performs the replacement. The replacement string may reference
+// capture groups as $1 or ${name}. Optional 'after'/'before' regexps limit
+// the replacement to the region between their first matches.
+func (h *ReplaceHandler) Execute(ctx context.Context, params map[string]interface{}, vars *playbook.Variables) (*playbook.TaskResult, error) {
+	result := &playbook.TaskResult{
+		StartTime: time.Now(),
+		Status:    playbook.TaskStatusRunning,
+	}
+
+	path, ok := pathParam(params, "path", vars)
+	if !ok || path == "" {
+		return nil, fmt.Errorf("path parameter must be a non-empty string")
+	}
+
+	writer := newFileWriter(ctx, params)
+	count, err := h.replace(path, params, writer)
+	result.Changed = count > 0
+
+	result.EndTime = time.Now()
+	result.Duration = result.EndTime.Sub(result.StartTime).String()
+
+	if err != nil {
+		result.Status = playbook.TaskStatusFailed
+		result.Error = err.Error()
+		return result, err
+	}
+
+	if count > 0 {
+		result.Message = fmt.Sprintf("Replaced %d occurrence(s) in '%s'", count, path)
+	} else {
+		result.Message = fmt.Sprintf("No changes needed in '%s'", path)
+	}
+	writer.annotate(result)
+	result.Status = playbook.TaskStatusCompleted
+	return result, nil
+}
+
+// replace rewrites the file and returns the number of replaced matches.
+// A newly created file counts as one change.
+func (h *ReplaceHandler) replace(path string, params map[string]interface{}, writer *fileWriter) (int, error) {
+	regexStr, ok := params["regexp"].(string)
+	if !ok || regexStr == "" {
+		return 0, fmt.Errorf("regexp parameter must be a non-empty string")
+	}
+	regex, err := playbook.CompileRegex(regexStr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid regexp: %w", err)
+	}
+	replacement, _ := params["replace"].(string)
+
+	create := false
+	if c, ok := params["create"].(bool); ok {
+		create = c
+	}
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		if !create {
+			return 0, fmt.Errorf("file '%s' does not exist and create=false", path)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return 0, fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			return 0, fmt.Errorf("failed to create file: %w", err)
+		}
+		return 1, nil // nothing to replace in an empty file
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	start, end, err := replaceRegion(content, params)
+	if err != nil {
+		return 0, err
+	}
+
+	region := content[start:end]
+	count := len(regex.FindAllIndex(region, -1))
+	if count == 0 {
+		return 0, nil
+	}
+
+	var updated bytes.Buffer
+	updated.Write(content[:start])
+	updated.Write(regex.ReplaceAll(region, []byte(replacement)))
+	updated.Write(content[end:])
+
+	if bytes.Equal(updated.Bytes(), content) {
+		return 0, nil // matches replaced with identical text
+	}
+
+	if err := writer.write(path, updated.Bytes(), info.Mode().Perm()); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// replaceRegion returns the byte range the replacement applies to: after the
+// first match of 'after' and before the first following match of 'before'
+func replaceRegion(content []byte, params map[string]interface{}) (start, end int, err error) {
+	start, end = 0, len(content)
+
+	if after, ok := params["after"].(string); ok && after != "" {
+		regex, err := playbook.CompileRegex(after)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid 'after' regexp: %w", err)
+		}
+		loc := regex.FindIndex(content)
+		if loc == nil {
+			return end, end, nil // marker not found, nothing to replace
+		}
+		start = loc[1]
+	}
+
+	if before, ok := params["before"].(string); ok && before != "" {
+		regex, err := playbook.CompileRegex(before)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid 'before' regexp: %w", err)
+		}
+		if loc := regex.FindIndex(content[start:]); loc != nil {
+			end = start + loc[0]
+		}
+	}
+
+	return start, end, nil
+}
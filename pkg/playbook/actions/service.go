@@ -2,6 +2,7 @@ package actions
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os/exec"
 	"runtime"
@@ -39,17 +40,22 @@ func (h *ServiceHandler) Execute(ctx context.Context, params map[string]interfac
 		Status:    playbook.TaskStatusRunning,
 	}
 
-	name, ok := params["name"].(string)
-	if !ok || name == "" {
-		return nil, fmt.Errorf("name parameter must be a non-empty string")
-	}
-
 	// Determine operation
 	state := "" // default is to not change state, just check enabled
 	if s, ok := params["state"].(string); ok {
 		state = s
 	}
 
+	// Read-only status gathering, accepts a single name or a list
+	if state == "status" {
+		return h.gatherStatus(params["name"], result)
+	}
+
+	name, ok := params["name"].(string)
+	if !ok || name == "" {
+		return nil, fmt.Errorf("name parameter must be a non-empty string")
+	}
+
 	enabled := ""
 	if e, ok := params["enabled"].(bool); ok {
 		if e {
@@ -63,21 +69,56 @@ func (h *ServiceHandler) Execute(ctx context.Context, params map[string]interfac
 
 	var err error
 
+	// Pick up new or changed unit files before acting on them
+	if reload, ok := params["daemon_reload"].(bool); ok && reload {
+		if err := h.daemonReload(); err != nil {
+			result.Status = playbook.TaskStatusFailed
+			result.Error = err.Error()
+			result.EndTime = time.Now()
+			result.Duration = result.EndTime.Sub(result.StartTime).String()
+			return result, err
+		}
+	}
+
+	// Mask or unmask before state changes, so an unmasked unit can be started
+	if masked, ok := params["masked"].(bool); ok {
+		maskChanged, err := h.setMasked(name, masked)
+		if err != nil {
+			result.Status = playbook.TaskStatusFailed
+			result.Error = err.Error()
+			result.EndTime = time.Now()
+			result.Duration = result.EndTime.Sub(result.StartTime).String()
+			return result, err
+		}
+		if maskChanged {
+			result.Changed = true
+		}
+	}
+
 	// Handle state changes
 	if state != "" {
+		var stateChanged bool
 		switch state {
 		case "started":
-			result.Changed, err = h.ensureStarted(name)
+			stateChanged, err = h.ensureStarted(name)
 		case "stopped":
-			result.Changed, err = h.ensureStopped(name)
+			stateChanged, err = h.ensureStopped(name)
 		case "restarted":
-			result.Changed, err = h.restart(name)
+			stateChanged, err = h.restart(name)
 		case "reloaded":
-			result.Changed, err = h.reload(name)
+			stateChanged, err = h.reload(name)
 		default:
 			return nil, fmt.Errorf("unknown state '%s'", state)
 		}
 
+		// A service that crashes on startup must not count as restarted
+		if err == nil && (state == "restarted" || state == "reloaded") {
+			err = h.waitForState(ctx, name, true, serviceStateTimeout)
+		}
+		if stateChanged {
+			result.Changed = true
+		}
+
 		if err != nil {
 			result.Status = playbook.TaskStatusFailed
 			result.Error = err.Error()
@@ -108,6 +149,105 @@ func (h *ServiceHandler) Execute(ctx context.Context, params map[string]interfac
 	return result, nil
 }
 
+// ServiceStatus is the state of a service as reported by state: status
+type ServiceStatus struct {
+	Name    string `json:"name"`
+	Running bool   `json:"running"`
+	Enabled bool   `json:"enabled"`
+}
+
+// gatherStatus reports the running and enabled state of one or more
+// services as JSON in Stdout without changing anything. A single name
+// produces an object, a list produces an array.
+func (h *ServiceHandler) gatherStatus(nameParam interface{}, result *playbook.TaskResult) (*playbook.TaskResult, error) {
+	var names []string
+	single := false
+	switch n := nameParam.(type) {
+	case string:
+		names = []string{n}
+		single = true
+	case []interface{}:
+		for _, item := range n {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("name list must contain only strings")
+			}
+			names = append(names, s)
+		}
+	}
+	for _, name := range names {
+		if name == "" {
+			return nil, fmt.Errorf("name parameter must be a non-empty string or list of strings")
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("name parameter must be a non-empty string or list of strings")
+	}
+
+	statuses := make([]ServiceStatus, 0, len(names))
+	for _, name := range names {
+		running, err := h.isRunning(name)
+		if err != nil {
+			result.Status = playbook.TaskStatusFailed
+			result.Error = err.Error()
+			result.EndTime = time.Now()
+			result.Duration = result.EndTime.Sub(result.StartTime).String()
+			return result, err
+		}
+		statuses = append(statuses, ServiceStatus{
+			Name:    name,
+			Running: running,
+			Enabled: h.isEnabled(name),
+		})
+	}
+
+	var data []byte
+	var err error
+	if single {
+		data, err = json.Marshal(statuses[0])
+	} else {
+		data, err = json.Marshal(statuses)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize service status: %w", err)
+	}
+
+	result.Stdout = string(data)
+	result.Message = fmt.Sprintf("Gathered status of %d service(s)", len(statuses))
+	result.Changed = false
+	result.EndTime = time.Now()
+	result.Duration = result.EndTime.Sub(result.StartTime).String()
+	result.Status = playbook.TaskStatusCompleted
+	return result, nil
+}
+
+// isEnabled checks if a service starts at boot
+func (h *ServiceHandler) isEnabled(name string) bool {
+	switch runtime.GOOS {
+	case "windows":
+		output, err := exec.Command("sc", "qc", name).Output()
+		if err != nil {
+			return false
+		}
+		return strings.Contains(string(output), "AUTO_START")
+
+	case "linux":
+		return exec.Command("systemctl", "is-enabled", "--quiet", name).Run() == nil
+
+	case "darwin":
+		// launchd jobs are enabled unless explicitly disabled
+		output, err := exec.Command("launchctl", "print-disabled", "system").Output()
+		if err != nil {
+			return false
+		}
+		return !strings.Contains(string(output), fmt.Sprintf("\"%s\" => disabled", name)) &&
+			!strings.Contains(string(output), fmt.Sprintf("\"%s\" => true", name))
+
+	default:
+		return false
+	}
+}
+
 // ensureStarted starts a service if not running
 func (h *ServiceHandler) ensureStarted(name string) (bool, error) {
 	running, err := h.isRunning(name)
@@ -171,6 +311,41 @@ func (h *ServiceHandler) isRunning(name string) (bool, error) {
 	}
 }
 
+// How long to wait for a service to reach the expected state after a
+// restart or reload, and how often to check
+const (
+	serviceStateTimeout  = 30 * time.Second
+	serviceStateInterval = 500 * time.Millisecond
+)
+
+// waitForState polls until the service is running (or stopped), failing if
+// it doesn't get there within timeout
+func (h *ServiceHandler) waitForState(ctx context.Context, name string, running bool, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		current, err := h.isRunning(name)
+		if err != nil {
+			return err
+		}
+		if current == running {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			want := "running"
+			if !running {
+				want = "stopped"
+			}
+			return fmt.Errorf("service '%s' is not %s %v after the operation", name, want, timeout)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("gave up waiting for service '%s': %w", name, ctx.Err())
+		case <-time.After(serviceStateInterval):
+		}
+	}
+}
+
 // start starts a service
 func (h *ServiceHandler) start(name string) error {
 	switch runtime.GOOS {
@@ -249,9 +424,11 @@ func (h *ServiceHandler) stop(name string) error {
 func (h *ServiceHandler) restart(name string) (bool, error) {
 	switch runtime.GOOS {
 	case "windows":
-		// Stop then start
+		// Stop then start, once the service has actually stopped
 		exec.Command("sc", "stop", name).Run()
-		time.Sleep(2 * time.Second)
+		if err := h.waitForState(context.Background(), name, false, serviceStateTimeout); err != nil {
+			return false, fmt.Errorf("failed to restart service: %w", err)
+		}
 		cmd := exec.Command("sc", "start", name)
 		output, err := cmd.CombinedOutput()
 		if err != nil {
@@ -318,6 +495,45 @@ func (h *ServiceHandler) reload(name string) (bool, error) {
 	}
 }
 
+// daemonReload makes systemd re-read unit files
+func (h *ServiceHandler) daemonReload() error {
+	if runtime.GOOS != "linux" {
+		return fmt.Errorf("daemon_reload is only supported with systemd on Linux")
+	}
+	output, err := exec.Command("systemctl", "daemon-reload").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to reload systemd: %v - %s", err, string(output))
+	}
+	return nil
+}
+
+// isMasked checks if a systemd unit is masked (permanently or at runtime)
+func (h *ServiceHandler) isMasked(name string) bool {
+	// is-enabled exits non-zero for masked units, so only the output matters
+	output, _ := exec.Command("systemctl", "is-enabled", name).Output()
+	return strings.HasPrefix(strings.TrimSpace(string(output)), "masked")
+}
+
+// setMasked masks or unmasks a systemd unit, reporting whether it changed
+func (h *ServiceHandler) setMasked(name string, masked bool) (bool, error) {
+	if runtime.GOOS != "linux" {
+		return false, fmt.Errorf("masking services is only supported with systemd on Linux")
+	}
+	if h.isMasked(name) == masked {
+		return false, nil
+	}
+
+	action := "mask"
+	if !masked {
+		action = "unmask"
+	}
+	output, err := exec.Command("systemctl", action, name).CombinedOutput()
+	if err != nil {
+		return false, fmt.Errorf("failed to %s service: %v - %s", action, err, string(output))
+	}
+	return true, nil
+}
+
 // setEnabled enables or disables a service at boot
 func (h *ServiceHandler) setEnabled(name string, enabled bool) (bool, error) {
 	switch runtime.GOOS {
@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -29,12 +30,23 @@ func (h *SysctlHandler) Supports() []string {
 
 // Validate checks if the params are valid
 func (h *SysctlHandler) Validate(params map[string]interface{}) error {
-	if _, ok := params["name"]; !ok {
-		return fmt.Errorf("sysctl action requires 'name' parameter")
+	_, hasName := params["name"]
+	_, hasParams := params["params"]
+	if !hasName && !hasParams {
+		return fmt.Errorf("sysctl action requires 'name' or 'params' parameter")
 	}
 	return nil
 }
 
+// sysctlSetting is a single kernel parameter and its desired value
+type sysctlSetting struct {
+	name  string
+	value string
+}
+
+// Default file for persisted values
+const defaultSysctlFile = "/etc/sysctl.d/99-cloudronix.conf"
+
 // Execute performs the sysctl operation
 func (h *SysctlHandler) Execute(ctx context.Context, params map[string]interface{}, vars *playbook.Variables) (*playbook.TaskResult, error) {
 	result := &playbook.TaskResult{
@@ -42,9 +54,8 @@ func (h *SysctlHandler) Execute(ctx context.Context, params map[string]interface
 		Status:    playbook.TaskStatusRunning,
 	}
 
-	name, ok := params["name"].(string)
-	if !ok || name == "" {
-		return nil, fmt.Errorf("name parameter must be a non-empty string")
+	if err := h.Validate(params); err != nil {
+		return nil, err
 	}
 
 	// Determine operation
@@ -53,14 +64,18 @@ func (h *SysctlHandler) Execute(ctx context.Context, params map[string]interface
 		state = s
 	}
 
+	sysctlFile := defaultSysctlFile
+	if f, ok := params["sysctl_file"].(string); ok {
+		sysctlFile = f
+	}
+
 	var err error
 	switch state {
 	case "present":
-		value, hasValue := params["value"]
-		if !hasValue {
-			return nil, fmt.Errorf("'value' parameter required for state 'present'")
+		settings, err := sysctlSettings(params)
+		if err != nil {
+			return nil, err
 		}
-		valueStr := fmt.Sprintf("%v", value)
 
 		// Check if sysctl file should be created for persistence
 		sysctl := true
@@ -74,9 +89,19 @@ func (h *SysctlHandler) Execute(ctx context.Context, params map[string]interface
 			reload = r
 		}
 
-		result.Changed, err = h.ensurePresent(name, valueStr, sysctl, reload, params)
+		// Whether a missing sysctl file may be created
+		create := true
+		if c, ok := params["create"].(bool); ok {
+			create = c
+		}
+
+		result.Changed, err = h.ensurePresent(settings, sysctl, reload, create, sysctlFile)
 
 	case "absent":
+		name, ok := params["name"].(string)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("name parameter must be a non-empty string")
+		}
 		result.Changed, err = h.ensureAbsent(name, params)
 
 	default:
@@ -96,37 +121,64 @@ func (h *SysctlHandler) Execute(ctx context.Context, params map[string]interface
 	return result, nil
 }
 
-// ensurePresent sets a sysctl value
-func (h *SysctlHandler) ensurePresent(name, value string, sysctl, reload bool, params map[string]interface{}) (bool, error) {
-	changed := false
+// sysctlSettings reads the parameters to set: either 'name' and 'value',
+// or a 'params' map of name to value, sorted by name
+func sysctlSettings(params map[string]interface{}) ([]sysctlSetting, error) {
+	if multi, ok := params["params"]; ok {
+		values, ok := multi.(map[string]interface{})
+		if !ok || len(values) == 0 {
+			return nil, fmt.Errorf("params parameter must be a non-empty map of name to value")
+		}
+		settings := make([]sysctlSetting, 0, len(values))
+		for name, value := range values {
+			settings = append(settings, sysctlSetting{name: name, value: fmt.Sprintf("%v", value)})
+		}
+		sort.Slice(settings, func(i, j int) bool { return settings[i].name < settings[j].name })
+		return settings, nil
+	}
 
-	// Get current value
-	currentValue, err := h.getCurrentValue(name)
-	if err != nil {
-		// Parameter might not exist, which is fine
-		currentValue = ""
+	name, ok := params["name"].(string)
+	if !ok || name == "" {
+		return nil, fmt.Errorf("name parameter must be a non-empty string")
 	}
+	value, hasValue := params["value"]
+	if !hasValue {
+		return nil, fmt.Errorf("'value' parameter required for state 'present'")
+	}
+	return []sysctlSetting{{name: name, value: fmt.Sprintf("%v", value)}}, nil
+}
+
+// ensurePresent sets sysctl values, applying them immediately and
+// persisting them with a single write of the sysctl file
+func (h *SysctlHandler) ensurePresent(settings []sysctlSetting, sysctl, reload, create bool, sysctlFile string) (bool, error) {
+	changed := false
+
+	for _, setting := range settings {
+		// Get current value; the parameter might not exist, which is fine
+		currentValue, _ := h.getCurrentValue(setting.name)
+		if sysctlValuesEqual(currentValue, setting.value) || !reload {
+			continue
+		}
 
-	// Compare values
-	if strings.TrimSpace(currentValue) != strings.TrimSpace(value) {
 		// Apply immediately using sysctl command or /proc/sys
-		if reload {
-			err := h.applyValue(name, value)
-			if err != nil {
-				return false, fmt.Errorf("failed to apply sysctl value: %w", err)
-			}
-			changed = true
+		if err := h.applyValue(setting.name, setting.value); err != nil {
+			return changed, fmt.Errorf("failed to apply sysctl value for '%s': %w", setting.name, err)
 		}
+
+		// Some keys are read-only or clamp values; make sure it stuck
+		applied, err := h.getCurrentValue(setting.name)
+		if err != nil {
+			return changed, fmt.Errorf("failed to verify sysctl value for '%s': %w", setting.name, err)
+		}
+		if !sysctlValuesEqual(applied, setting.value) {
+			return changed, fmt.Errorf("sysctl '%s' is '%s' after setting '%s' (read-only or rejected by the kernel)", setting.name, applied, setting.value)
+		}
+		changed = true
 	}
 
 	// Write to sysctl.conf for persistence
 	if sysctl {
-		sysctlFile := "/etc/sysctl.d/99-cloudronix.conf"
-		if f, ok := params["sysctl_file"].(string); ok {
-			sysctlFile = f
-		}
-
-		persistChanged, err := h.persistValue(name, value, sysctlFile)
+		persistChanged, err := h.persistValues(settings, sysctlFile, create)
 		if err != nil {
 			return changed, fmt.Errorf("failed to persist sysctl value: %w", err)
 		}
@@ -138,9 +190,32 @@ func (h *SysctlHandler) ensurePresent(name, value string, sysctl, reload bool, p
 	return changed, nil
 }
 
+// parseSysctlLine splits a "key = value" line from a sysctl file.
+// Comments and lines without '=' are not settings.
+func parseSysctlLine(line string) (key, value string, ok bool) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, ";") {
+		return "", "", false
+	}
+	// A leading '-' tells systemd-sysctl to ignore errors for the key
+	trimmed = strings.TrimPrefix(trimmed, "-")
+
+	key, value, ok = strings.Cut(trimmed, "=")
+	if !ok {
+		return "", "", false
+	}
+	return strings.TrimSpace(key), strings.TrimSpace(value), true
+}
+
+// sysctlValuesEqual compares values ignoring whitespace differences, since
+// multi-value keys are tab-separated in /proc/sys
+func sysctlValuesEqual(a, b string) bool {
+	return strings.Join(strings.Fields(a), " ") == strings.Join(strings.Fields(b), " ")
+}
+
 // ensureAbsent removes a sysctl value from config
 func (h *SysctlHandler) ensureAbsent(name string, params map[string]interface{}) (bool, error) {
-	sysctlFile := "/etc/sysctl.d/99-cloudronix.conf"
+	sysctlFile := defaultSysctlFile
 	if f, ok := params["sysctl_file"].(string); ok {
 		sysctlFile = f
 	}
@@ -160,8 +235,7 @@ func (h *SysctlHandler) ensureAbsent(name string, params map[string]interface{})
 	found := false
 
 	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		if strings.HasPrefix(trimmed, name+" ") || strings.HasPrefix(trimmed, name+"=") {
+		if key, _, ok := parseSysctlLine(line); ok && key == name {
 			found = true
 			continue
 		}
@@ -213,54 +287,59 @@ func (h *SysctlHandler) applyValue(name, value string) error {
 	return cmd.Run()
 }
 
-// persistValue writes the sysctl value to a config file
-func (h *SysctlHandler) persistValue(name, value, sysctlFile string) (bool, error) {
-	// Ensure directory exists
-	dir := filepath.Dir(sysctlFile)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return false, err
-	}
-
+// persistValues writes the sysctl values to a config file, rewriting it
+// at most once for all settings
+func (h *SysctlHandler) persistValues(settings []sysctlSetting, sysctlFile string, create bool) (bool, error) {
 	// Read existing file
 	var content string
 	existingContent, err := os.ReadFile(sysctlFile)
 	if err == nil {
 		content = string(existingContent)
+	} else if !os.IsNotExist(err) {
+		return false, err
+	} else if !create {
+		return false, fmt.Errorf("sysctl file '%s' does not exist and create=false", sysctlFile)
 	}
 
-	// Check if parameter already exists with same value
-	targetLine := fmt.Sprintf("%s = %s", name, value)
-	lines := strings.Split(content, "\n")
-	found := false
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	if content == "" {
+		lines = nil
+	}
 
-	for i, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		if strings.HasPrefix(trimmed, name+" ") || strings.HasPrefix(trimmed, name+"=") {
-			// Check if value is the same
-			if strings.Contains(trimmed, value) {
-				return false, nil // Already set to correct value
+	changed := false
+	for _, setting := range settings {
+		targetLine := fmt.Sprintf("%s = %s", setting.name, setting.value)
+
+		found := false
+		for i, line := range lines {
+			key, value, ok := parseSysctlLine(line)
+			if !ok || key != setting.name {
+				continue
 			}
-			// Update the line
-			lines[i] = targetLine
 			found = true
+			if !sysctlValuesEqual(value, setting.value) {
+				lines[i] = targetLine
+				changed = true
+			}
 			break
 		}
-	}
 
-	if !found {
-		// Add new line
-		if content != "" && !strings.HasSuffix(content, "\n") {
-			lines = append(lines, "")
+		if !found {
+			lines = append(lines, targetLine)
+			changed = true
 		}
-		lines = append(lines, targetLine)
 	}
 
-	// Write back
-	newContent := strings.Join(lines, "\n")
-	if !strings.HasSuffix(newContent, "\n") {
-		newContent += "\n"
+	if !changed {
+		return false, nil // Already set to correct values
+	}
+
+	// Ensure directory exists
+	if err := os.MkdirAll(filepath.Dir(sysctlFile), 0755); err != nil {
+		return false, err
 	}
 
+	newContent := strings.Join(lines, "\n") + "\n"
 	if err := os.WriteFile(sysctlFile, []byte(newContent), 0644); err != nil {
 		return false, err
 	}
@@ -4,7 +4,7 @@ package actions
 
 import (
 	"context"
-	"fmt"
+	"runtime"
 
 	"github.com/cloudronix/agent/pkg/playbook"
 )
@@ -24,10 +24,10 @@ func (h *SysctlHandler) Supports() []string {
 
 // Validate checks if the params are valid
 func (h *SysctlHandler) Validate(params map[string]interface{}) error {
-	return fmt.Errorf("sysctl action is only available on Linux")
+	return playbook.NewUnsupportedActionError(playbook.ActionSysctl, runtime.GOOS)
 }
 
 // Execute is not available on non-Linux platforms
 func (h *SysctlHandler) Execute(ctx context.Context, params map[string]interface{}, vars *playbook.Variables) (*playbook.TaskResult, error) {
-	return nil, fmt.Errorf("sysctl action is only available on Linux")
+	return nil, playbook.NewUnsupportedActionError(playbook.ActionSysctl, runtime.GOOS)
 }
@@ -0,0 +1,148 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/cloudronix/agent/pkg/playbook"
+)
+
+// TemplateHandler renders Go text/template files with the playbook variables
+type TemplateHandler struct{}
+
+// NewTemplateHandler creates a new template handler
+func NewTemplateHandler() *TemplateHandler {
+	return &TemplateHandler{}
+}
+
+// Supports returns all platforms
+func (h *TemplateHandler) Supports() []string {
+	return []string{"all"}
+}
+
+// Validate checks if the params are valid
+func (h *TemplateHandler) Validate(params map[string]interface{}) error {
+	if _, ok := params["src"]; !ok {
+		return fmt.Errorf("template action requires 'src' parameter")
+	}
+	if _, ok := params["path"]; !ok {
+		return fmt.Errorf("template action requires 'path' parameter")
+	}
+	return nil
+}
+
+// Execute renders the template and writes it to path if the output changed.
+// Variables are available as {{ .name }}, e.g. {{ .hostname }}.
+func (h *TemplateHandler) Execute(ctx context.Context, params map[string]interface{}, vars *playbook.Variables) (*playbook.TaskResult, error) {
+	result := &playbook.TaskResult{
+		StartTime: time.Now(),
+		Status:    playbook.TaskStatusRunning,
+	}
+
+	src, ok := pathParam(params, "src", vars)
+	if !ok || src == "" {
+		return nil, fmt.Errorf("src parameter must be a non-empty string")
+	}
+	path, ok := pathParam(params, "path", vars)
+	if !ok || path == "" {
+		return nil, fmt.Errorf("path parameter must be a non-empty string")
+	}
+
+	rendered, err := renderTemplate(src, vars)
+	if err == nil {
+		result.Changed, err = h.writeIfChanged(path, rendered, params)
+	}
+
+	result.EndTime = time.Now()
+	result.Duration = result.EndTime.Sub(result.StartTime).String()
+
+	if err != nil {
+		result.Status = playbook.TaskStatusFailed
+		result.Error = err.Error()
+		return result, err
+	}
+
+	if result.Changed {
+		result.Message = fmt.Sprintf("Rendered '%s' to '%s'", src, path)
+	} else {
+		result.Message = fmt.Sprintf("'%s' is up to date", path)
+	}
+	result.Status = playbook.TaskStatusCompleted
+	return result, nil
+}
+
+// renderTemplate renders a template file. Unknown variables are an error
+// rather than silently rendering "<no value>".
+func renderTemplate(src string, vars *playbook.Variables) ([]byte, error) {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template '%s': %w", src, err)
+	}
+
+	tmpl, err := template.New(filepath.Base(src)).
+		Option("missingkey=error").
+		Funcs(template.FuncMap{
+			"env":   os.Getenv,
+			"upper": strings.ToUpper,
+			"lower": strings.ToLower,
+			"trim":  strings.TrimSpace,
+		}).
+		Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template '%s': %w", src, err)
+	}
+
+	var values map[string]string
+	if vars != nil {
+		values = vars.Values()
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, values); err != nil {
+		return nil, fmt.Errorf("failed to render template '%s': %w", src, err)
+	}
+	return out.Bytes(), nil
+}
+
+// writeIfChanged writes content to path unless the file already has the
+// same content, then applies mode/owner/group
+func (h *TemplateHandler) writeIfChanged(path string, content []byte, params map[string]interface{}) (bool, error) {
+	files := NewFileHandler()
+
+	existing, err := os.ReadFile(path)
+	if err == nil && sha256.Sum256(existing) == sha256.Sum256(content) {
+		return files.setPermissions(path, params)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, fmt.Errorf("failed to create parent directory: %w", err)
+	}
+
+	mode := os.FileMode(0644)
+	if m, ok := params["mode"].(string); ok {
+		parsed, err := strconv.ParseUint(m, 8, 32)
+		if err == nil {
+			mode = os.FileMode(parsed)
+		}
+	}
+
+	if err := os.WriteFile(path, content, mode); err != nil {
+		return false, fmt.Errorf("failed to write file '%s': %w", path, err)
+	}
+
+	if _, err := files.setPermissions(path, params); err != nil {
+		return true, err
+	}
+	return true, nil
+}
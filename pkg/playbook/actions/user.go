@@ -0,0 +1,461 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"os/user"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/cloudronix/agent/pkg/playbook"
+)
+
+// UserHandler manages local user accounts
+type UserHandler struct{}
+
+// NewUserHandler creates a new user handler
+func NewUserHandler() *UserHandler {
+	return &UserHandler{}
+}
+
+// Supports returns all desktop platforms
+func (h *UserHandler) Supports() []string {
+	return []string{"windows", "linux", "darwin"}
+}
+
+// Validate checks if the params are valid
+func (h *UserHandler) Validate(params map[string]interface{}) error {
+	if _, ok := params["name"]; !ok {
+		return fmt.Errorf("user action requires 'name' parameter")
+	}
+	return nil
+}
+
+// userOptions are the account settings requested by a task
+type userOptions struct {
+	groups   []string
+	shell    string
+	home     string
+	system   bool
+	password string // pre-hashed (crypt format), never logged
+	remove   bool   // delete the home directory with the account
+}
+
+// Execute performs the user operation
+func (h *UserHandler) Execute(ctx context.Context, params map[string]interface{}, vars *playbook.Variables) (*playbook.TaskResult, error) {
+	result := &playbook.TaskResult{
+		StartTime: time.Now(),
+		Status:    playbook.TaskStatusRunning,
+	}
+
+	if err := h.Validate(params); err != nil {
+		return nil, err
+	}
+
+	name, ok := params["name"].(string)
+	if !ok || name == "" {
+		return nil, fmt.Errorf("name parameter must be a non-empty string")
+	}
+
+	state := "present"
+	if s, ok := params["state"].(string); ok {
+		state = s
+	}
+
+	opts, err := parseUserOptions(params)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []string
+	switch state {
+	case "present":
+		changes, err = h.ensurePresent(ctx, name, opts)
+	case "absent":
+		changes, err = h.ensureAbsent(ctx, name, opts)
+	default:
+		return nil, fmt.Errorf("unknown state '%s'", state)
+	}
+
+	result.EndTime = time.Now()
+	result.Duration = result.EndTime.Sub(result.StartTime).String()
+
+	if err != nil {
+		result.Status = playbook.TaskStatusFailed
+		result.Error = err.Error()
+		return result, err
+	}
+
+	result.Changed = len(changes) > 0
+	if result.Changed {
+		result.Message = fmt.Sprintf("User '%s': %s", name, strings.Join(changes, ", "))
+	} else {
+		result.Message = fmt.Sprintf("User '%s' already in desired state", name)
+	}
+	result.Status = playbook.TaskStatusCompleted
+	return result, nil
+}
+
+// parseUserOptions reads the account settings, rejecting ones the current
+// platform can't apply
+func parseUserOptions(params map[string]interface{}) (userOptions, error) {
+	var opts userOptions
+
+	switch g := params["groups"].(type) {
+	case nil:
+	case string:
+		for _, group := range strings.Split(g, ",") {
+			if group = strings.TrimSpace(group); group != "" {
+				opts.groups = append(opts.groups, group)
+			}
+		}
+	case []interface{}:
+		for _, item := range g {
+			group, ok := item.(string)
+			if !ok || group == "" {
+				return opts, fmt.Errorf("groups must contain only non-empty strings")
+			}
+			opts.groups = append(opts.groups, group)
+		}
+	default:
+		return opts, fmt.Errorf("groups parameter must be a list or comma-separated string")
+	}
+
+	opts.shell, _ = params["shell"].(string)
+	opts.home, _ = params["home"].(string)
+	opts.system, _ = params["system"].(bool)
+	opts.password, _ = params["password"].(string)
+	opts.remove, _ = params["remove"].(bool)
+
+	switch runtime.GOOS {
+	case "windows":
+		if opts.shell != "" || opts.home != "" || opts.system {
+			return opts, fmt.Errorf("shell, home and system are not supported on Windows")
+		}
+		if opts.password != "" {
+			return opts, fmt.Errorf("pre-hashed passwords are not supported on Windows")
+		}
+	case "darwin":
+		if opts.system {
+			return opts, fmt.Errorf("system accounts are not supported on macOS")
+		}
+		if opts.password != "" {
+			return opts, fmt.Errorf("pre-hashed passwords are not supported on macOS")
+		}
+	}
+
+	return opts, nil
+}
+
+// userExists checks whether a local account exists
+func userExists(name string) (bool, error) {
+	_, err := user.Lookup(name)
+	if err == nil {
+		return true, nil
+	}
+	var unknown user.UnknownUserError
+	if errors.As(err, &unknown) {
+		return false, nil
+	}
+	return false, fmt.Errorf("failed to look up user '%s': %w", name, err)
+}
+
+// ensurePresent creates the account or brings it in line with opts,
+// returning a description of each change made
+func (h *UserHandler) ensurePresent(ctx context.Context, name string, opts userOptions) ([]string, error) {
+	exists, err := userExists(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		if err := h.create(ctx, name, opts); err != nil {
+			return nil, err
+		}
+		changes := []string{"created"}
+		// Windows and macOS can't add groups at creation time
+		if runtime.GOOS != "linux" && len(opts.groups) > 0 {
+			if err := h.addToGroups(ctx, name, opts.groups); err != nil {
+				return changes, err
+			}
+		}
+		return changes, nil
+	}
+
+	var changes []string
+
+	if opts.shell != "" {
+		current, err := currentShell(ctx, name)
+		if err != nil {
+			return changes, err
+		}
+		if current != opts.shell {
+			if err := h.setShell(ctx, name, opts.shell); err != nil {
+				return changes, err
+			}
+			changes = append(changes, "shell updated")
+		}
+	}
+
+	if opts.home != "" {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return changes, fmt.Errorf("failed to look up user '%s': %w", name, err)
+		}
+		if u.HomeDir != opts.home {
+			if err := h.setHome(ctx, name, opts.home); err != nil {
+				return changes, err
+			}
+			changes = append(changes, "home updated")
+		}
+	}
+
+	if len(opts.groups) > 0 {
+		current, err := userGroups(ctx, name)
+		if err != nil {
+			return changes, err
+		}
+		var missing []string
+		for _, group := range opts.groups {
+			if !current[strings.ToLower(group)] {
+				missing = append(missing, group)
+			}
+		}
+		if len(missing) > 0 {
+			if err := h.addToGroups(ctx, name, missing); err != nil {
+				return changes, err
+			}
+			changes = append(changes, "added to "+strings.Join(missing, ", "))
+		}
+	}
+
+	if opts.password != "" {
+		current, err := runUserCommand(ctx, "read password hash", "getent", "shadow", name)
+		if err != nil {
+			return changes, err
+		}
+		fields := strings.Split(strings.TrimSpace(current), ":")
+		if len(fields) < 2 || fields[1] != opts.password {
+			if _, err := runUserCommand(ctx, "set password", "usermod", "-p", opts.password, name); err != nil {
+				return changes, err
+			}
+			changes = append(changes, "password updated")
+		}
+	}
+
+	return changes, nil
+}
+
+// ensureAbsent deletes the account if it exists
+func (h *UserHandler) ensureAbsent(ctx context.Context, name string, opts userOptions) ([]string, error) {
+	exists, err := userExists(name)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	switch runtime.GOOS {
+	case "windows":
+		_, err = runUserCommand(ctx, "delete user", "net", "user", name, "/delete")
+	case "linux":
+		args := []string{name}
+		if opts.remove {
+			args = []string{"-r", name}
+		}
+		_, err = runUserCommand(ctx, "delete user", "userdel", args...)
+	case "darwin":
+		args := []string{"-deleteUser", name}
+		if !opts.remove {
+			args = append(args, "-keepHome")
+		}
+		_, err = runUserCommand(ctx, "delete user", "sysadminctl", args...)
+	default:
+		err = fmt.Errorf("unsupported platform: %s", runtime.GOOS)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{"removed"}, nil
+}
+
+// create adds a new account
+func (h *UserHandler) create(ctx context.Context, name string, opts userOptions) error {
+	switch runtime.GOOS {
+	case "windows":
+		_, err := runUserCommand(ctx, "create user", "net", "user", name, "/add")
+		return err
+
+	case "linux":
+		var args []string
+		if opts.system {
+			args = append(args, "-r")
+		} else {
+			args = append(args, "-m")
+		}
+		if opts.shell != "" {
+			args = append(args, "-s", opts.shell)
+		}
+		if opts.home != "" {
+			args = append(args, "-d", opts.home)
+		}
+		if len(opts.groups) > 0 {
+			args = append(args, "-G", strings.Join(opts.groups, ","))
+		}
+		if opts.password != "" {
+			args = append(args, "-p", opts.password)
+		}
+		args = append(args, name)
+		_, err := runUserCommand(ctx, "create user", "useradd", args...)
+		return err
+
+	case "darwin":
+		args := []string{"-addUser", name}
+		if opts.shell != "" {
+			args = append(args, "-shell", opts.shell)
+		}
+		if opts.home != "" {
+			args = append(args, "-home", opts.home)
+		}
+		_, err := runUserCommand(ctx, "create user", "sysadminctl", args...)
+		return err
+
+	default:
+		return fmt.Errorf("unsupported platform: %s", runtime.GOOS)
+	}
+}
+
+// setShell changes the login shell
+func (h *UserHandler) setShell(ctx context.Context, name, shell string) error {
+	var err error
+	switch runtime.GOOS {
+	case "linux":
+		_, err = runUserCommand(ctx, "set shell", "usermod", "-s", shell, name)
+	case "darwin":
+		_, err = runUserCommand(ctx, "set shell", "dscl", ".", "-create", "/Users/"+name, "UserShell", shell)
+	default:
+		err = fmt.Errorf("unsupported platform: %s", runtime.GOOS)
+	}
+	return err
+}
+
+// setHome changes the home directory
+func (h *UserHandler) setHome(ctx context.Context, name, home string) error {
+	var err error
+	switch runtime.GOOS {
+	case "linux":
+		_, err = runUserCommand(ctx, "set home", "usermod", "-d", home, "-m", name)
+	case "darwin":
+		_, err = runUserCommand(ctx, "set home", "dscl", ".", "-create", "/Users/"+name, "NFSHomeDirectory", home)
+	default:
+		err = fmt.Errorf("unsupported platform: %s", runtime.GOOS)
+	}
+	return err
+}
+
+// addToGroups adds the account to each group, keeping existing memberships
+func (h *UserHandler) addToGroups(ctx context.Context, name string, groups []string) error {
+	switch runtime.GOOS {
+	case "linux":
+		_, err := runUserCommand(ctx, "add to groups", "usermod", "-a", "-G", strings.Join(groups, ","), name)
+		return err
+
+	case "darwin":
+		for _, group := range groups {
+			if _, err := runUserCommand(ctx, "add to group "+group, "dseditgroup", "-o", "edit", "-a", name, "-t", "user", group); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case "windows":
+		for _, group := range groups {
+			if _, err := runUserCommand(ctx, "add to group "+group, "net", "localgroup", group, name, "/add"); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unsupported platform: %s", runtime.GOOS)
+	}
+}
+
+// currentShell reads the account's login shell
+func currentShell(ctx context.Context, name string) (string, error) {
+	switch runtime.GOOS {
+	case "linux":
+		output, err := runUserCommand(ctx, "read user", "getent", "passwd", name)
+		if err != nil {
+			return "", err
+		}
+		fields := strings.Split(strings.TrimSpace(output), ":")
+		if len(fields) < 7 {
+			return "", fmt.Errorf("unexpected passwd entry for '%s'", name)
+		}
+		return fields[6], nil
+
+	case "darwin":
+		output, err := runUserCommand(ctx, "read user", "dscl", ".", "-read", "/Users/"+name, "UserShell")
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(output), "UserShell:")), nil
+
+	default:
+		return "", fmt.Errorf("unsupported platform: %s", runtime.GOOS)
+	}
+}
+
+// userGroups returns the account's group names, lowercased
+func userGroups(ctx context.Context, name string) (map[string]bool, error) {
+	groups := make(map[string]bool)
+
+	if runtime.GOOS == "windows" {
+		output, err := runUserCommand(ctx, "read user", "net", "user", name)
+		if err != nil {
+			return nil, err
+		}
+		// Memberships are listed as "*Group" entries after this header,
+		// possibly wrapping onto following lines
+		inLocal := false
+		for _, line := range strings.Split(output, "\n") {
+			if strings.HasPrefix(line, "Local Group Memberships") {
+				inLocal = true
+				line = strings.TrimPrefix(line, "Local Group Memberships")
+			} else if inLocal && !strings.HasPrefix(line, " ") {
+				break
+			}
+			if !inLocal {
+				continue
+			}
+			for _, group := range strings.Split(line, "*")[1:] {
+				if group = strings.TrimSpace(group); group != "" {
+					groups[strings.ToLower(group)] = true
+				}
+			}
+		}
+		return groups, nil
+	}
+
+	output, err := runUserCommand(ctx, "read groups", "id", "-Gn", name)
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range strings.Fields(output) {
+		groups[strings.ToLower(group)] = true
+	}
+	return groups, nil
+}
+
+// runUserCommand runs an account management command. Errors describe the
+// operation rather than the command line, which may contain a password hash.
+func runUserCommand(ctx context.Context, operation, name string, args ...string) (string, error) {
+	output, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to %s: %v - %s", operation, err, strings.TrimSpace(string(output)))
+	}
+	return string(output), nil
+}
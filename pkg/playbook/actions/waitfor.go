@@ -0,0 +1,216 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudronix/agent/pkg/playbook"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// Default wait_for timings
+const (
+	defaultWaitTimeout = 300 * time.Second
+	defaultWaitSleep   = 1 * time.Second
+)
+
+// WaitForHandler waits for a port, file or process to reach a state.
+// It only observes the system, so it never reports a change.
+type WaitForHandler struct{}
+
+// NewWaitForHandler creates a new wait_for handler
+func NewWaitForHandler() *WaitForHandler {
+	return &WaitForHandler{}
+}
+
+// Supports returns all platforms
+func (h *WaitForHandler) Supports() []string {
+	return []string{"all"}
+}
+
+// Validate checks if the params are valid
+func (h *WaitForHandler) Validate(params map[string]interface{}) error {
+	targets := 0
+	for _, key := range []string{"port", "path", "process"} {
+		if _, ok := params[key]; ok {
+			targets++
+		}
+	}
+	if targets != 1 {
+		return fmt.Errorf("wait_for action requires exactly one of 'port', 'path' or 'process'")
+	}
+
+	if state, ok := params["state"].(string); ok {
+		switch state {
+		case "present", "absent", "started", "stopped":
+		default:
+			return fmt.Errorf("unknown state '%s' (expected present, absent, started or stopped)", state)
+		}
+	}
+	return nil
+}
+
+// Execute polls until the condition holds or the timeout expires
+func (h *WaitForHandler) Execute(ctx context.Context, params map[string]interface{}, vars *playbook.Variables) (*playbook.TaskResult, error) {
+	result := &playbook.TaskResult{
+		StartTime: time.Now(),
+		Status:    playbook.TaskStatusRunning,
+	}
+
+	if err := h.Validate(params); err != nil {
+		return nil, err
+	}
+
+	// started/present wait for the target to appear, stopped/absent for it to go away
+	want := true
+	if state, _ := params["state"].(string); state == "absent" || state == "stopped" {
+		want = false
+	}
+
+	check, desc, err := h.condition(params, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := durationParam(params, "timeout", defaultWaitTimeout)
+	sleep := durationParam(params, "sleep", defaultWaitSleep)
+	delay := durationParam(params, "delay", 0)
+
+	deadline := time.Now().Add(timeout)
+	waitErr := func() error {
+		if delay > 0 {
+			if err := sleepCtx(ctx, delay); err != nil {
+				return err
+			}
+		}
+		for {
+			if check(ctx) == want {
+				return nil
+			}
+			if time.Now().Add(sleep).After(deadline) {
+				return fmt.Errorf("timed out after %v waiting for %s to be %s", timeout, desc, waitStateName(want))
+			}
+			if err := sleepCtx(ctx, sleep); err != nil {
+				return err
+			}
+		}
+	}()
+
+	result.EndTime = time.Now()
+	result.Duration = result.EndTime.Sub(result.StartTime).String()
+
+	if waitErr != nil {
+		result.Status = playbook.TaskStatusFailed
+		result.Error = waitErr.Error()
+		return result, waitErr
+	}
+
+	result.Status = playbook.TaskStatusCompleted
+	result.Message = fmt.Sprintf("%s is %s after %s", desc, waitStateName(want), result.EndTime.Sub(result.StartTime).Round(time.Millisecond))
+	return result, nil
+}
+
+// condition returns the check for the requested target and a description
+// of it for messages
+func (h *WaitForHandler) condition(params map[string]interface{}, vars *playbook.Variables) (func(ctx context.Context) bool, string, error) {
+	if port, ok := params["port"]; ok {
+		host, _ := params["host"].(string)
+		if host == "" {
+			host = "127.0.0.1"
+		}
+		portStr := fmt.Sprint(port)
+		if n, err := strconv.Atoi(portStr); err != nil || n < 1 || n > 65535 {
+			return nil, "", fmt.Errorf("invalid port '%v'", port)
+		}
+		addr := net.JoinHostPort(host, portStr)
+		return func(ctx context.Context) bool {
+			return portOpen(ctx, addr)
+		}, "port " + addr, nil
+	}
+
+	if path, ok := pathParam(params, "path", vars); ok && path != "" {
+		return func(ctx context.Context) bool {
+			_, err := os.Stat(path)
+			return err == nil
+		}, "path '" + path + "'", nil
+	}
+
+	if name, ok := params["process"].(string); ok && name != "" {
+		return func(ctx context.Context) bool {
+			return processRunning(ctx, name)
+		}, "process '" + name + "'", nil
+	}
+
+	return nil, "", fmt.Errorf("wait_for target must be a non-empty string or port number")
+}
+
+// portOpen reports whether a TCP connection to addr succeeds
+func portOpen(ctx context.Context, addr string) bool {
+	dialer := net.Dialer{Timeout: 2 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// processRunning reports whether a process with the given name is running.
+// The match is case-insensitive and ignores a trailing .exe.
+func processRunning(ctx context.Context, name string) bool {
+	procs, err := process.ProcessesWithContext(ctx)
+	if err != nil {
+		return false
+	}
+	want := strings.TrimSuffix(strings.ToLower(name), ".exe")
+	for _, p := range procs {
+		pname, err := p.NameWithContext(ctx)
+		if err != nil {
+			continue
+		}
+		if strings.TrimSuffix(strings.ToLower(pname), ".exe") == want {
+			return true
+		}
+	}
+	return false
+}
+
+// waitStateName describes the awaited state in messages
+func waitStateName(present bool) string {
+	if present {
+		return "present"
+	}
+	return "absent"
+}
+
+// durationParam reads a duration param given in seconds
+func durationParam(params map[string]interface{}, key string, def time.Duration) time.Duration {
+	switch v := params[key].(type) {
+	case int:
+		return time.Duration(v) * time.Second
+	case float64:
+		return time.Duration(v * float64(time.Second))
+	case string:
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return time.Duration(n * float64(time.Second))
+		}
+	}
+	return def
+}
+
+// sleepCtx waits for d or until ctx is cancelled
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,199 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/cloudronix/agent/pkg/playbook"
+)
+
+// fileWriter writes new file contents for the file-editing actions (file,
+// copy, lineinfile, blockinfile, replace, keyval_file) and applies their
+// shared write options:
+//
+//	backup: true         copy the original to <path>.<timestamp>.bak before writing
+//	validate: "cmd %s"   check the new content in a temp file first; the
+//	                     original is only replaced if the command exits 0
+//
+// It is only called once a change is about to be made, so no-op runs never
+// create backups or run validation.
+type fileWriter struct {
+	ctx      context.Context
+	backup   bool
+	validate string
+
+	// Set once a backup has been made
+	backupPath string
+}
+
+// newFileWriter reads the write options from task params
+func newFileWriter(ctx context.Context, params map[string]interface{}) *fileWriter {
+	w := &fileWriter{ctx: ctx}
+	w.backup, _ = params["backup"].(bool)
+	w.validate, _ = params["validate"].(string)
+	return w
+}
+
+// write replaces the contents of path
+func (w *fileWriter) write(path string, data []byte, mode os.FileMode) error {
+	if err := w.prepare(path, data); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path, data, mode); err != nil {
+		return fmt.Errorf("failed to write file '%s': %w", path, err)
+	}
+	return nil
+}
+
+// writeAtomic replaces the contents of path like write, but through a temp
+// file renamed over it, so a reader or a crash never sees a partial file.
+// An existing file keeps its mode and ownership; mode applies to new files.
+func (w *fileWriter) writeAtomic(path string, data []byte, mode os.FileMode) error {
+	if err := w.prepare(path, data); err != nil {
+		return err
+	}
+
+	// Replace the target of a symlink, not the link itself
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	existing, err := os.Stat(path)
+	if err == nil {
+		mode = existing.Mode().Perm()
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write file '%s': %w", path, err)
+	}
+	tmpPath := tmp.Name()
+
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpPath, mode)
+	}
+	if err == nil && existing != nil {
+		err = copyOwnership(existing, tmpPath)
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write file '%s': %w", path, err)
+	}
+	return nil
+}
+
+// prepare validates new content and backs up the original before a write
+func (w *fileWriter) prepare(path string, data []byte) error {
+	if w.validate != "" {
+		if err := w.validateContent(path, data); err != nil {
+			return err
+		}
+	}
+
+	if w.backup && w.backupPath == "" {
+		backupPath, err := backupFile(path)
+		if err != nil {
+			return err
+		}
+		w.backupPath = backupPath
+	}
+	return nil
+}
+
+// validateContent writes data to a temp file and runs the validate command
+// against it, with %s replaced by the temp file path
+func (w *fileWriter) validateContent(path string, data []byte) error {
+	if !strings.Contains(w.validate, "%s") {
+		return fmt.Errorf("validate command must contain '%%s' for the file to check")
+	}
+
+	tmp, err := os.CreateTemp("", "cloudronix-validate-*-"+filepath.Base(path))
+	if err != nil {
+		return fmt.Errorf("failed to create validation file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write validation file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write validation file: %w", err)
+	}
+
+	cmdStr := strings.ReplaceAll(w.validate, "%s", tmp.Name())
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(w.ctx, "cmd", "/C", prepareCommand("cmd", cmdStr))
+	} else {
+		cmd = exec.CommandContext(w.ctx, "/bin/sh", "-c", cmdStr)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		output := strings.TrimSpace(decodeOutput(stderr.Bytes()))
+		if output == "" {
+			output = strings.TrimSpace(decodeOutput(stdout.Bytes()))
+		}
+		if output == "" {
+			return fmt.Errorf("validation failed for '%s': %v", path, err)
+		}
+		return fmt.Errorf("validation failed for '%s' (%v): %s", path, err, output)
+	}
+	return nil
+}
+
+// annotate records the backup location in the task result message
+func (w *fileWriter) annotate(result *playbook.TaskResult) {
+	if w.backupPath == "" {
+		return
+	}
+	note := fmt.Sprintf("Backup saved to '%s'", w.backupPath)
+	if result.Message != "" {
+		result.Message += " (" + note + ")"
+	} else {
+		result.Message = note
+	}
+}
+
+// backupFile copies path to "<path>.<timestamp>.bak", keeping its mode.
+// Returns "" when the file doesn't exist yet, since there is nothing to keep.
+func backupFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	backupPath := fmt.Sprintf("%s.%s.bak", path, time.Now().Format("20060102T150405.000"))
+	if err := CopyFile(path, backupPath); err != nil {
+		os.Remove(backupPath)
+		return "", fmt.Errorf("failed to back up '%s': %w", path, err)
+	}
+	if err := os.Chmod(backupPath, info.Mode().Perm()); err != nil {
+		return "", fmt.Errorf("failed to back up '%s': %w", path, err)
+	}
+	return backupPath, nil
+}
@@ -0,0 +1,61 @@
+package playbook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Default seconds between completion checks of an async task
+const defaultAsyncPoll = 10
+
+// runAsync runs a long task in the background for at most task.Async
+// seconds. While it runs, a progress update with the elapsed time is
+// emitted every poll interval so the job doesn't appear hung.
+func (e *Executor) runAsync(ctx context.Context, run *executionState, task *Task, handler ActionHandler, params map[string]interface{}, vars *Variables) (*TaskResult, error) {
+	taskCtx, cancel := context.WithTimeout(ctx, time.Duration(task.Async)*time.Second)
+	defer cancel()
+
+	type outcome struct {
+		result *TaskResult
+		err    error
+	}
+	done := make(chan outcome, 1)
+	start := time.Now()
+	go func() {
+		result, err := handler.Execute(taskCtx, params, vars)
+		done <- outcome{result, err}
+	}()
+
+	poll := task.Poll
+	if poll <= 0 {
+		poll = defaultAsyncPoll
+	}
+	ticker := time.NewTicker(time.Duration(poll) * time.Second)
+	defer ticker.Stop()
+
+	timeoutErr := fmt.Errorf("async task did not finish within %ds", task.Async)
+	for {
+		select {
+		case out := <-done:
+			if out.err != nil && errors.Is(taskCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+				return out.result, timeoutErr
+			}
+			return out.result, out.err
+
+		case <-ticker.C:
+			if e.onProgressUpdate != nil && run != nil {
+				update := run.progress(task.Name, TaskStatusRunning)
+				update.Elapsed = time.Since(start).Round(time.Second).String()
+				e.onProgressUpdate(update)
+			}
+
+		case <-taskCtx.Done():
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, timeoutErr
+		}
+	}
+}
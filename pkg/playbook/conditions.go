@@ -8,6 +8,26 @@ import (
 	"strings"
 )
 
+// Patterns used to validate condition syntax
+var (
+	validOperatorPattern = regexp.MustCompile(`(==|!=|>=|<=|>|<| contains | not contains | in | not in | version_(?:eq|ne|lt|le|gt|ge) | and | or |^not | is (?:un)?defined$)`)
+	identPattern         = regexp.MustCompile(`^[a-zA-Z_](?:[a-zA-Z0-9_.]|\[\d+\])*$`)
+)
+
+// versionOperators pairs each version comparison operator with a test on
+// the result of compareVersions
+var versionOperators = []struct {
+	op   string
+	test func(int) bool
+}{
+	{" version_eq ", func(c int) bool { return c == 0 }},
+	{" version_ne ", func(c int) bool { return c != 0 }},
+	{" version_lt ", func(c int) bool { return c < 0 }},
+	{" version_le ", func(c int) bool { return c <= 0 }},
+	{" version_gt ", func(c int) bool { return c > 0 }},
+	{" version_ge ", func(c int) bool { return c >= 0 }},
+}
+
 // Condition evaluates conditional expressions for task execution
 //
 // Supported expressions:
@@ -17,9 +37,18 @@ import (
 //   - result.stdout contains "installed"
 //   - env.DEBUG == "true"
 //   - variable_name == "value"
+//   - platform in ["linux", "darwin"]
+//   - installed_ver version_ge "1.2.0"
+//   - proxy_url is defined / proxy_url is undefined
 //   - true / false (literal)
 //
-// Operators: ==, !=, contains, not contains, and, or
+// Operators: ==, !=, contains, not contains, in, not in, and, or, and the
+// version comparisons version_eq, version_ne, version_lt, version_le,
+// version_gt, version_ge
+//
+// Undefined references compare as the empty string, so `x == ""` can't
+// tell an empty variable from a missing one. "is defined" checks presence
+// instead: a variable set to "" is defined.
 type Condition struct {
 	vars *Variables
 }
@@ -75,7 +104,7 @@ func (c *Condition) Evaluate(expression string) (bool, error) {
 	}
 
 	// Handle parentheses
-	if strings.HasPrefix(expression, "(") && strings.HasSuffix(expression, ")") {
+	if wrappedInParens(expression) {
 		return c.Evaluate(expression[1 : len(expression)-1])
 	}
 
@@ -94,9 +123,71 @@ func (c *Condition) Evaluate(expression string) (bool, error) {
 
 // evaluateComparison handles single comparison expressions
 func (c *Condition) evaluateComparison(expression string) (bool, error) {
+	// Check for presence tests: "x is defined", "x is undefined"
+	for _, test := range []string{" is defined", " is undefined"} {
+		ref, ok := strings.CutSuffix(expression, test)
+		if !ok {
+			continue
+		}
+		ref = strings.TrimSpace(ref)
+		if !identPattern.MatchString(ref) {
+			return false, fmt.Errorf("'%s' needs a variable name, got: %s", strings.TrimSpace(test), ref)
+		}
+		return c.isDefined(ref) == (test == " is defined"), nil
+	}
+
+	// Check for list membership: "not in" before "in"
+	for _, op := range []string{" not in ", " in "} {
+		parts := splitOperatorOnce(expression, op)
+		if len(parts) != 2 || !strings.HasPrefix(strings.TrimSpace(parts[1]), "[") {
+			continue
+		}
+		left, err := c.resolveValue(strings.TrimSpace(parts[0]))
+		if err != nil {
+			return false, err
+		}
+		items, err := parseConditionList(parts[1])
+		if err != nil {
+			return false, err
+		}
+		found := false
+		for _, item := range items {
+			val, err := c.resolveValue(item)
+			if err != nil {
+				return false, err
+			}
+			if val == left {
+				found = true
+				break
+			}
+		}
+		return found == (op == " in "), nil
+	}
+
+	// Check for version comparisons
+	for _, vo := range versionOperators {
+		parts := splitOperatorOnce(expression, vo.op)
+		if len(parts) != 2 {
+			continue
+		}
+		left, err := c.resolveValue(strings.TrimSpace(parts[0]))
+		if err != nil {
+			return false, err
+		}
+		right, err := c.resolveValue(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return false, err
+		}
+		cmp, err := compareVersions(left, right)
+		if err != nil {
+			return false, err
+		}
+		return vo.test(cmp), nil
+	}
+
 	// Check for "not contains" first (before "contains")
-	if strings.Contains(expression, " not contains ") {
-		parts := strings.SplitN(expression, " not contains ", 2)
+	if indexOperator(expression, " not contains ") >= 0 {
+		parts := splitOperatorOnce(expression, " not contains ")
 		if len(parts) == 2 {
 			left, err := c.resolveValue(strings.TrimSpace(parts[0]))
 			if err != nil {
@@ -111,8 +202,8 @@ func (c *Condition) evaluateComparison(expression string) (bool, error) {
 	}
 
 	// Check for "contains"
-	if strings.Contains(expression, " contains ") {
-		parts := strings.SplitN(expression, " contains ", 2)
+	if indexOperator(expression, " contains ") >= 0 {
+		parts := splitOperatorOnce(expression, " contains ")
 		if len(parts) == 2 {
 			left, err := c.resolveValue(strings.TrimSpace(parts[0]))
 			if err != nil {
@@ -127,8 +218,8 @@ func (c *Condition) evaluateComparison(expression string) (bool, error) {
 	}
 
 	// Check for "!=" (before "==" to avoid partial match)
-	if strings.Contains(expression, " != ") {
-		parts := strings.SplitN(expression, " != ", 2)
+	if indexOperator(expression, " != ") >= 0 {
+		parts := splitOperatorOnce(expression, " != ")
 		if len(parts) == 2 {
 			left, err := c.resolveValue(strings.TrimSpace(parts[0]))
 			if err != nil {
@@ -143,8 +234,8 @@ func (c *Condition) evaluateComparison(expression string) (bool, error) {
 	}
 
 	// Check for "=="
-	if strings.Contains(expression, " == ") {
-		parts := strings.SplitN(expression, " == ", 2)
+	if indexOperator(expression, " == ") >= 0 {
+		parts := splitOperatorOnce(expression, " == ")
 		if len(parts) == 2 {
 			left, err := c.resolveValue(strings.TrimSpace(parts[0]))
 			if err != nil {
@@ -160,8 +251,8 @@ func (c *Condition) evaluateComparison(expression string) (bool, error) {
 
 	// Check for numeric comparisons: >, <, >=, <=
 	for _, op := range []string{" >= ", " <= ", " > ", " < "} {
-		if strings.Contains(expression, op) {
-			parts := strings.SplitN(expression, op, 2)
+		if indexOperator(expression, op) >= 0 {
+			parts := splitOperatorOnce(expression, op)
 			if len(parts) == 2 {
 				leftStr, err := c.resolveValue(strings.TrimSpace(parts[0]))
 				if err != nil {
@@ -246,6 +337,12 @@ func (c *Condition) resolveValue(ref string) (string, error) {
 		property := parts[1]
 
 		if result, ok := c.vars.GetTaskResult(taskName); ok {
+			// JSON stdout: result_name.json.path, missing paths are empty
+			if isJSONProperty(property) {
+				val, _ := jsonProperty(result, property)
+				return val, nil
+			}
+
 			switch property {
 			case "stdout":
 				return result.Stdout, nil
@@ -272,49 +369,141 @@ func (c *Condition) resolveValue(ref string) (string, error) {
 	return "", nil
 }
 
-// splitOnOperator splits an expression on an operator, respecting parentheses
+// isDefined reports whether a reference exists, including the condition
+// built-ins platform and arch
+func (c *Condition) isDefined(ref string) bool {
+	if ref == "platform" || ref == "arch" {
+		return true
+	}
+	return c.vars.Defined(ref)
+}
+
+// splitOnOperator splits an expression on an operator that appears outside
+// parentheses and quoted strings. It returns nil when there is no such
+// occurrence.
 func splitOnOperator(expr, op string) []string {
 	var parts []string
-	var current strings.Builder
+	for {
+		i := indexOperator(expr, op)
+		if i < 0 {
+			break
+		}
+		parts = append(parts, strings.TrimSpace(expr[:i]))
+		expr = expr[i+len(op):]
+	}
+
+	if parts == nil {
+		return nil
+	}
+	return append(parts, strings.TrimSpace(expr))
+}
+
+// splitOperatorOnce is strings.SplitN(expr, op, 2) for an operator outside
+// parentheses and quoted strings
+func splitOperatorOnce(expr, op string) []string {
+	i := indexOperator(expr, op)
+	if i < 0 {
+		return []string{expr}
+	}
+	return []string{expr[:i], expr[i+len(op):]}
+}
+
+// indexOperator returns the index of the first occurrence of op outside
+// parentheses and quoted strings, or -1 if there is none
+func indexOperator(expr, op string) int {
+	var quote byte
 	depth := 0
+	for i := 0; i < len(expr); i++ {
+		ch := expr[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '(':
+			depth++
+		case ch == ')':
+			if depth > 0 {
+				depth--
+			}
+		case depth == 0 && strings.HasPrefix(expr[i:], op):
+			return i
+		}
+	}
+	return -1
+}
+
+// wrappedInParens reports whether the whole expression is enclosed in one
+// pair of matching parentheses, e.g. "(a or b)" but not "(a) or (b)"
+func wrappedInParens(expr string) bool {
+	if !strings.HasPrefix(expr, "(") || !strings.HasSuffix(expr, ")") {
+		return false
+	}
 
-	// Simple split - doesn't handle nested parens perfectly but works for most cases
-	tokens := strings.Split(expr, op)
-	for i, token := range tokens {
-		if i == 0 {
-			current.WriteString(token)
-		} else {
-			// Check if previous part has unbalanced parens
-			prev := current.String()
-			openCount := strings.Count(prev, "(")
-			closeCount := strings.Count(prev, ")")
-
-			if openCount > closeCount {
-				// We're inside parens, keep concatenating
-				current.WriteString(op)
-				current.WriteString(token)
-				depth = openCount - closeCount
-			} else {
-				// Complete part
-				parts = append(parts, strings.TrimSpace(current.String()))
-				current.Reset()
-				current.WriteString(token)
-				depth = 0
+	var quote byte
+	depth := 0
+	for i := 0; i < len(expr); i++ {
+		ch := expr[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '(':
+			depth++
+		case ch == ')':
+			depth--
+			if depth == 0 && i < len(expr)-1 {
+				return false
 			}
 		}
 	}
+	return depth == 0
+}
 
-	// Add last part
-	if current.Len() > 0 {
-		parts = append(parts, strings.TrimSpace(current.String()))
+// parseConditionList splits a bracketed list such as ["linux", 'darwin', x]
+// into its items. Commas inside quoted strings don't separate items.
+func parseConditionList(list string) ([]string, error) {
+	list = strings.TrimSpace(list)
+	if !strings.HasPrefix(list, "[") || !strings.HasSuffix(list, "]") {
+		return nil, fmt.Errorf("invalid list: %s", list)
+	}
+	inner := list[1 : len(list)-1]
+	if strings.TrimSpace(inner) == "" {
+		return nil, nil
 	}
 
-	// If only one part after all processing, return nil to indicate no split
-	if len(parts) <= 1 && depth == 0 {
-		return nil
+	var items []string
+	var quote rune
+	start := 0
+	for i, r := range inner {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == ',':
+			items = append(items, strings.TrimSpace(inner[start:i]))
+			start = i + 1
+		}
 	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated string in list: %s", list)
+	}
+	items = append(items, strings.TrimSpace(inner[start:]))
 
-	return parts
+	for _, item := range items {
+		if item == "" {
+			return nil, fmt.Errorf("empty item in list: %s", list)
+		}
+	}
+	return items, nil
 }
 
 // isTruthy determines if a string value is considered "true"
@@ -337,16 +526,23 @@ func ValidateCondition(expression string) error {
 		return nil
 	}
 
+	// Syntax checks ignore whatever is inside quoted strings
+	syntax := blankQuoted(expression)
+
 	// Check for balanced parentheses
-	openCount := strings.Count(expression, "(")
-	closeCount := strings.Count(expression, ")")
+	openCount := strings.Count(syntax, "(")
+	closeCount := strings.Count(syntax, ")")
 	if openCount != closeCount {
 		return fmt.Errorf("unbalanced parentheses in condition: %s", expression)
 	}
 
+	// Check for balanced list brackets
+	if strings.Count(syntax, "[") != strings.Count(syntax, "]") {
+		return fmt.Errorf("unbalanced brackets in condition: %s", expression)
+	}
+
 	// Check for valid operators
-	validOperatorPattern := regexp.MustCompile(`(==|!=|>=|<=|>|<| contains | not contains | and | or |^not )`)
-	if !validOperatorPattern.MatchString(expression) {
+	if !validOperatorPattern.MatchString(syntax) {
 		// Could be a single variable reference - that's valid
 		if !isValidIdentifier(expression) {
 			return fmt.Errorf("invalid condition syntax: %s", expression)
@@ -356,6 +552,27 @@ func ValidateCondition(expression string) error {
 	return nil
 }
 
+// blankQuoted empties every quoted string in an expression, keeping the
+// quotes: `a == "x and (y"` becomes `a == ""`
+func blankQuoted(expr string) string {
+	var b strings.Builder
+	var quote byte
+	for i := 0; i < len(expr); i++ {
+		ch := expr[i]
+		switch {
+		case quote != 0:
+			if ch != quote {
+				continue
+			}
+			quote = 0
+		case ch == '"' || ch == '\'':
+			quote = ch
+		}
+		b.WriteByte(ch)
+	}
+	return b.String()
+}
+
 // isValidIdentifier checks if a string is a valid variable identifier
 func isValidIdentifier(s string) bool {
 	s = strings.TrimSpace(s)
@@ -375,6 +592,5 @@ func isValidIdentifier(s string) bool {
 	}
 
 	// Check identifier pattern (allows dots for nested references)
-	identPattern := regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.]*$`)
 	return identPattern.MatchString(s)
 }
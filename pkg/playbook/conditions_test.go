@@ -0,0 +1,95 @@
+package playbook
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConditionEvaluate(t *testing.T) {
+	vars := NewVariables()
+	vars.Set("a", "1")
+	vars.Set("b", "5")
+	vars.Set("c", "3")
+	vars.Set("msg", "x and y")
+	vars.SetTaskResult("result", &TaskResult{Stdout: "left == right or (unbalanced"})
+	cond := NewCondition(vars)
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		// Nested parentheses
+		{`(a == 1 or b == 2) and c == 3`, true},
+		{`(a == 2 or b == 2) and c == 3`, false},
+		{`(a == 1) or (b == 2)`, true},
+		{`(a == 2) or (b == 2)`, false},
+		{`(a == "1" and (b == "2" or c == "3")) or b == "4"`, true},
+		{`(a == "1" and (b == "2" or c == "4")) or b == "4"`, false},
+		{`((a == 1) and ((c == 3)))`, true},
+		{`not (a == 2 or b == 2)`, true},
+
+		// Operators inside quoted literals
+		{`msg == 'x and y'`, true},
+		{`msg == "x and y"`, true},
+		{`msg != 'x or y'`, true},
+		{`msg == 'x and y' and a == 1`, true},
+		{`result.stdout contains " == "`, true},
+		{`result.stdout contains "(unbalanced"`, true},
+		{`result.stdout not contains " != "`, true},
+		{`"1 > 2" == "1 > 2"`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			if err := ValidateCondition(tt.expr); err != nil {
+				t.Fatalf("ValidateCondition: %v", err)
+			}
+			got, err := cond.Evaluate(tt.expr)
+			if err != nil {
+				t.Fatalf("Evaluate: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Evaluate = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitOnOperator(t *testing.T) {
+	tests := []struct {
+		expr string
+		op   string
+		want []string
+	}{
+		{`(a == 1 or b == 2) and c == 3`, " and ", []string{`(a == 1 or b == 2)`, `c == 3`}},
+		{`(a == 1 or b == 2) and c == 3`, " or ", nil},
+		{`(a == 1) or (b == 2)`, " or ", []string{`(a == 1)`, `(b == 2)`}},
+		{`msg == 'x and y'`, " and ", nil},
+		{`msg == "x and y" and (p or q)`, " and ", []string{`msg == "x and y"`, `(p or q)`}},
+	}
+
+	for _, tt := range tests {
+		if got := splitOnOperator(tt.expr, tt.op); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitOnOperator(%q, %q) = %q, want %q", tt.expr, tt.op, got, tt.want)
+		}
+	}
+}
+
+func TestWrappedInParens(t *testing.T) {
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`(a == 1 or b == 2)`, true},
+		{`((a == 1) and (b == 2))`, true},
+		{`(a == 1) or (b == 2)`, false},
+		{`(msg == ")")`, true},
+		{`a == 1`, false},
+	}
+
+	for _, tt := range tests {
+		if got := wrappedInParens(tt.expr); got != tt.want {
+			t.Errorf("wrappedInParens(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
@@ -24,6 +24,7 @@ var (
 	ErrActionFailed        = errors.New("action execution failed")
 	ErrVariableNotFound    = errors.New("variable not found")
 	ErrInvalidVariableName = errors.New("invalid variable name")
+	ErrActionNotSupported  = errors.New("action not supported on this platform")
 )
 
 // ParseError wraps parsing errors with context
@@ -74,6 +75,26 @@ func (e *TaskError) Unwrap() error {
 	return e.Cause
 }
 
+// UnsupportedActionError reports an action used on a platform it doesn't support
+type UnsupportedActionError struct {
+	Action   string
+	Platform string
+}
+
+// NewUnsupportedActionError creates the uniform error for an action that is
+// not available on a platform
+func NewUnsupportedActionError(action, platform string) error {
+	return &UnsupportedActionError{Action: action, Platform: platform}
+}
+
+func (e *UnsupportedActionError) Error() string {
+	return fmt.Sprintf("action '%s' is not supported on %s", e.Action, e.Platform)
+}
+
+func (e *UnsupportedActionError) Unwrap() error {
+	return ErrActionNotSupported
+}
+
 // ConditionError represents an error in condition evaluation
 type ConditionError struct {
 	Expression string
@@ -101,3 +122,36 @@ func (e *VariableError) Error() string {
 func (e *VariableError) Unwrap() error {
 	return e.Cause
 }
+
+// PermanentError marks a task failure that retrying cannot fix, such as
+// bad params, a missing program or denied permissions. The executor skips
+// the remaining retries when a handler returns one.
+type PermanentError struct {
+	Cause error
+}
+
+// NewPermanentError marks err as non-retryable
+func NewPermanentError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Cause: err}
+}
+
+func (e *PermanentError) Error() string {
+	return e.Cause.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Cause
+}
+
+// IsRetryable reports whether a task failure may succeed on another attempt.
+// Errors are retryable unless marked permanent or the action is unsupported.
+func IsRetryable(err error) bool {
+	var permanent *PermanentError
+	if errors.As(err, &permanent) {
+		return false
+	}
+	return !errors.Is(err, ErrActionNotSupported)
+}
@@ -3,9 +3,16 @@ package playbook
 import (
 	"context"
 	"crypto/ed25519"
+	"errors"
 	"fmt"
+	"math"
+	"os"
 	"runtime"
+	"sort"
+	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 )
 
 // Executor manages the execution of verified playbooks
@@ -28,8 +35,56 @@ type Executor struct {
 	// Device ID for reporting
 	deviceID string
 
-	// Callback for progress reporting
-	onProgress func(taskName string, status TaskStatus)
+	// Callbacks for progress reporting
+	onProgress       func(taskName string, status TaskStatus)
+	onProgressUpdate func(Progress)
+
+	// Permitted action types (nil = all registered actions)
+	allowedActions map[string]bool
+
+	// Internal agent_control handler (never registered through RegisterHandler)
+	controlHandler ActionHandler
+
+	// Whether the playbook being executed may use agent_control
+	controlAllowed bool
+
+	// Total stdout/stderr bytes kept in a report (negative = unlimited)
+	maxReportOutput int
+
+	// Parent directory for per-job scratch directories
+	workdirRoot string
+
+	// Keep the scratch directory when the playbook fails
+	retainWorkdirOnFailure bool
+
+	// Maximum tasks of a parallel group running at once
+	maxParallel int
+
+	// Reported in ExecutionReport.Environment
+	agentVersion string
+	distro       string
+
+	// Run a dry run before every apply and abort if it finds issues
+	preflightDryRun bool
+
+	// Shell for command tasks that don't set one ("" = platform default)
+	defaultShell string
+
+	// Markers of run_once command tasks
+	runOnceDir string
+
+	// Server job being executed, and where its artifacts are uploaded
+	jobID     string
+	artifacts ArtifactUploader
+
+	// Tag filters: run only tasks with one of tags, never those with one of
+	// skipTags (nil = no filter)
+	tags     map[string]bool
+	skipTags map[string]bool
+
+	// Reboot detection and on_complete.reboot_prompt handling (nil = off)
+	pendingReboot    func() (bool, string)
+	onRebootRequired func(context.Context, RebootRequest) (time.Time, error)
 }
 
 // ActionHandler is the interface for action implementations
@@ -44,27 +99,126 @@ type ActionHandler interface {
 	Validate(params map[string]interface{}) error
 }
 
+// ArtifactUploader sends files collected by tasks (e.g. fetch) to the server,
+// returning the server's artifact ID
+type ArtifactUploader interface {
+	UploadArtifact(jobID, name string, data []byte) (string, error)
+}
+
 // ExecutorConfig holds configuration for the executor
 type ExecutorConfig struct {
-	// ServerPublicKey for signature verification (required)
+	// ServerPublicKey for signature verification (this or ServerPublicKeys
+	// is required)
 	ServerPublicKey ed25519.PublicKey
 
+	// ServerPublicKeys are additional trusted keys, e.g. the old and new key
+	// while the server rotates its signing key
+	ServerPublicKeys []ed25519.PublicKey
+
+	// MaxApprovalAge rejects approved playbooks approved longer ago than
+	// this with ErrApprovalExpired (0 = approvals don't expire)
+	MaxApprovalAge time.Duration
+
 	// DeviceID for execution reports
 	DeviceID string
 
 	// OnProgress callback for progress updates
 	OnProgress func(taskName string, status TaskStatus)
+
+	// OnProgressUpdate is called after each task finishes with the overall
+	// progress of the playbook
+	OnProgressUpdate func(Progress)
+
+	// AllowedActions restricts execution to these action types.
+	// Tasks using any other action are rejected. Empty allows all actions.
+	AllowedActions []string
+
+	// ControlHandler serves the agent_control action. Only the agent itself
+	// provides it; it is used solely for approved playbooks with ScopeControl.
+	ControlHandler ActionHandler
+
+	// MaxReportOutputBytes caps the stdout/stderr captured across all tasks
+	// of a playbook. Output beyond the budget is truncated in the report.
+	// Zero uses DefaultMaxReportOutputBytes, negative disables the limit.
+	MaxReportOutputBytes int
+
+	// WorkdirRoot is where per-job scratch directories ({{ job_workdir }})
+	// are created. Empty uses the system temp directory.
+	WorkdirRoot string
+
+	// RetainWorkdirOnFailure keeps the scratch directory of a failed
+	// playbook for debugging instead of removing it
+	RetainWorkdirOnFailure bool
+
+	// MaxParallel bounds how many tasks of a parallel group run at once.
+	// Zero uses DefaultMaxParallel.
+	MaxParallel int
+
+	// AgentVersion and Distro describe the running agent in reports
+	AgentVersion string
+	Distro       string
+
+	// PreflightDryRun runs DryRun before Execute applies a playbook. If the
+	// dry run finds issues (unknown handlers, unsupported or disallowed
+	// actions, invalid conditions) the playbook is rejected before any
+	// task makes a change.
+	PreflightDryRun bool
+
+	// DefaultShell is used by command tasks without a 'shell' param, e.g.
+	// "powershell" on Windows or "bash" on Linux. Empty uses cmd on
+	// Windows and /bin/sh elsewhere.
+	DefaultShell string
+
+	// RunOnceDir holds the markers of command tasks with run_once, which
+	// make them run only once per device. Empty makes run_once tasks fail.
+	RunOnceDir string
+
+	// JobID identifies the server job being executed ({{ job_id }}), and
+	// Artifacts uploads files for it. Without both, fetch tasks fail.
+	JobID     string
+	Artifacts ArtifactUploader
+
+	// Tags limits execution to tasks carrying at least one of these tags
+	// (or TagAlways); SkipTags excludes tasks carrying any of them. Other
+	// tasks are reported as skipped. Notified handlers are not filtered.
+	Tags     []string
+	SkipTags []string
+
+	// PendingReboot reports whether the operating system itself has flagged
+	// a pending reboot, and why. It is consulted after an apply that changed
+	// something, so reboots needed by e.g. package updates are reported.
+	PendingReboot func() (bool, string)
+
+	// OnRebootRequired is called after a successful apply that left the
+	// system needing a reboot, when the playbook sets
+	// on_complete.reboot_prompt. It returns when the reboot was scheduled,
+	// or the zero time if it wasn't.
+	OnRebootRequired func(context.Context, RebootRequest) (time.Time, error)
 }
 
+// DefaultMaxParallel is the default concurrency limit for parallel groups
+const DefaultMaxParallel = 4
+
+// DefaultMaxReportOutputBytes is the default playbook-wide output budget
+const DefaultMaxReportOutputBytes = 10 * 1024 * 1024
+
+// outputTruncatedMarker is appended to output cut by the report budget
+const outputTruncatedMarker = "\n... [truncated: playbook output budget exceeded]"
+
 // NewExecutor creates a new playbook executor
 //
 // SECURITY: The server public key is required and must be obtained during
 // device enrollment. It should be stored securely and not fetched at runtime.
 func NewExecutor(config ExecutorConfig) (*Executor, error) {
-	verifier, err := NewVerifier(config.ServerPublicKey)
+	keys := config.ServerPublicKeys
+	if config.ServerPublicKey != nil {
+		keys = append([]ed25519.PublicKey{config.ServerPublicKey}, keys...)
+	}
+	verifier, err := NewVerifier(keys...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create verifier: %w", err)
 	}
+	verifier.SetMaxApprovalAge(config.MaxApprovalAge)
 
 	e := &Executor{
 		verifier:   verifier,
@@ -73,16 +227,100 @@ func NewExecutor(config ExecutorConfig) (*Executor, error) {
 		platform:   runtime.GOOS,
 		deviceID:   config.DeviceID,
 		onProgress: config.OnProgress,
+
+		onProgressUpdate: config.OnProgressUpdate,
+
+		controlHandler:  config.ControlHandler,
+		maxReportOutput: config.MaxReportOutputBytes,
+
+		workdirRoot:            config.WorkdirRoot,
+		retainWorkdirOnFailure: config.RetainWorkdirOnFailure,
+		maxParallel:            config.MaxParallel,
+
+		agentVersion: config.AgentVersion,
+		distro:       config.Distro,
+
+		preflightDryRun: config.PreflightDryRun,
+		defaultShell:    config.DefaultShell,
+		runOnceDir:      config.RunOnceDir,
+
+		jobID:     config.JobID,
+		artifacts: config.Artifacts,
+
+		tags:     tagSet(config.Tags),
+		skipTags: tagSet(config.SkipTags),
+
+		pendingReboot:    config.PendingReboot,
+		onRebootRequired: config.OnRebootRequired,
+	}
+
+	if e.maxParallel <= 0 {
+		e.maxParallel = DefaultMaxParallel
+	}
+
+	if e.maxReportOutput == 0 {
+		e.maxReportOutput = DefaultMaxReportOutputBytes
+	}
+
+	if len(config.AllowedActions) > 0 {
+		e.allowedActions = make(map[string]bool, len(config.AllowedActions))
+		for _, action := range config.AllowedActions {
+			e.allowedActions[action] = true
+		}
 	}
 
 	return e, nil
 }
 
 // RegisterHandler registers an action handler
+//
+// SECURITY: agent_control is reserved and cannot be registered here - it is
+// only available through ExecutorConfig.ControlHandler.
 func (e *Executor) RegisterHandler(actionType string, handler ActionHandler) {
+	if actionType == ActionAgentControl {
+		return
+	}
 	e.handlers[actionType] = handler
 }
 
+// handlerFor returns the handler for an action type
+func (e *Executor) handlerFor(action string) (ActionHandler, bool) {
+	if action == ActionAgentControl {
+		if !e.controlAllowed || e.controlHandler == nil {
+			return nil, false
+		}
+		return e.controlHandler, true
+	}
+	handler, ok := e.handlers[action]
+	return handler, ok
+}
+
+// Capabilities reports the action types usable on this platform and the
+// supported engine features. Actions come from the registered handlers, so
+// stubs for other platforms are left out.
+func (e *Executor) Capabilities() *Capabilities {
+	caps := &Capabilities{
+		SchemaVersion:  SchemaVersion,
+		SchemaVersions: SupportedSchemaVersions(),
+		Platform:       e.parser.GetPlatform(),
+		Actions:        []string{},
+		Features:       append([]string(nil), Features...),
+	}
+
+	for action, handler := range e.handlers {
+		if e.supportsPlatform(handler) {
+			caps.Actions = append(caps.Actions, action)
+		}
+	}
+	if e.controlHandler != nil {
+		caps.Actions = append(caps.Actions, ActionAgentControl)
+		caps.Features = append(caps.Features, FeatureControlPlay)
+	}
+	sort.Strings(caps.Actions)
+
+	return caps
+}
+
 // Execute runs a signed playbook after verification
 //
 // SECURITY CRITICAL: This is the main entry point for playbook execution.
@@ -96,6 +334,7 @@ func (e *Executor) Execute(ctx context.Context, sp *SignedPlaybook) (*ExecutionR
 		StartTime:  time.Now(),
 		Status:     "pending",
 	}
+	report.Environment = e.environment(false)
 
 	// =========================================================================
 	// STEP 1: SECURITY VERIFICATION (MANDATORY)
@@ -147,89 +386,712 @@ func (e *Executor) Execute(ctx context.Context, sp *SignedPlaybook) (*ExecutionR
 		}
 	}
 
+	// Playbooks may require a minimum agent version. Executors that don't
+	// know their version (e.g. embedded use) skip the check.
+	if playbook.MinAgentVersion != "" && e.agentVersion != "" {
+		cmp, err := compareVersions(e.agentVersion, playbook.MinAgentVersion)
+		if err != nil || cmp < 0 {
+			report.Status = "rejected"
+			report.EndTime = time.Now()
+			report.TotalDuration = report.EndTime.Sub(report.StartTime).String()
+			report.ErrorMessage = fmt.Sprintf("Playbook requires agent version %s or later, this agent is %s", playbook.MinAgentVersion, e.agentVersion)
+			if err != nil {
+				report.ErrorMessage = fmt.Sprintf("Cannot check minimum agent version: %v", err)
+			}
+			return report, ErrAgentVersionTooLow
+		}
+	}
+
+	// =========================================================================
+	// STEP 3b: CONTROL SCOPE CHECK
+	// =========================================================================
+	// Agent lifecycle operations require an approved (not test) control playbook
+	if playbook.Scope == ScopeControl {
+		if sp.Status != StatusApproved || e.controlHandler == nil {
+			report.Status = "rejected"
+			report.EndTime = time.Now()
+			report.TotalDuration = report.EndTime.Sub(report.StartTime).String()
+			report.ErrorMessage = "control playbooks must be approved and require agent control support"
+			return report, fmt.Errorf("control playbook rejected: %s", report.ErrorMessage)
+		}
+		e.controlAllowed = true
+		defer func() { e.controlAllowed = false }()
+	}
+
+	// =========================================================================
+	// STEP 3c: PREFLIGHT DRY RUN (OPTIONAL)
+	// =========================================================================
+	if e.preflightDryRun {
+		if err := e.preflight(ctx, sp, report); err != nil {
+			return report, err
+		}
+	}
+
 	// =========================================================================
 	// STEP 4: EXECUTE TASKS
 	// =========================================================================
 	report.Status = "running"
-	report.TasksTotal = len(playbook.Tasks)
+	report.TasksTotal = countTasks(playbook.Tasks)
 
 	vars := NewVariables()
+
+	// Scratch directory for this job, removed when the playbook finishes
+	workdir, err := os.MkdirTemp(e.workdirRoot, "cloudronix-job-")
+	if err != nil {
+		report.Status = "failed"
+		report.EndTime = time.Now()
+		report.TotalDuration = report.EndTime.Sub(report.StartTime).String()
+		report.ErrorMessage = fmt.Sprintf("failed to create job working directory: %v", err)
+		return report, fmt.Errorf("failed to create job working directory: %w", err)
+	}
+	defer e.cleanupWorkdir(report, workdir)
+	vars.SetBuiltin(BuiltinJobWorkdir, workdir)
+	if e.defaultShell != "" {
+		vars.SetBuiltin(BuiltinDefaultShell, e.defaultShell)
+	}
+	if e.runOnceDir != "" {
+		vars.SetBuiltin(BuiltinRunOnceDir, e.runOnceDir)
+	}
+	if e.jobID != "" {
+		vars.SetBuiltin(BuiltinJobID, e.jobID)
+	}
+	vars.SetArtifactUploader(e.artifacts)
+
 	vars.SetUserVars(playbook.Variables)
 
-	// Track which handlers to notify
-	notifiedHandlers := make(map[string]bool)
+	run := &executionState{
+		playbook:         playbook,
+		report:           report,
+		vars:             vars,
+		notifiedHandlers: make(map[string]bool),
+		outputBudget:     e.maxReportOutput,
+		taskStatus:       make(map[string]TaskStatus),
+	}
+
+	if err := e.runTasks(ctx, run, playbook.Tasks); err != nil {
+		return failRun(ctx, report, err)
+	}
+
+	// =========================================================================
+	// STEP 5: RUN NOTIFIED HANDLERS
+	// =========================================================================
+	// A failed handler fails the run like a failed task, unless it sets
+	// ignore_errors or the playbook's on_error strategy continues
+	if err := e.runNotifiedHandlers(ctx, run); err != nil {
+		return failRun(ctx, report, err)
+	}
+
+	// =========================================================================
+	// STEP 6: COMPLETE
+	// =========================================================================
+	report.Status = "completed"
+	report.EndTime = time.Now()
+	report.TotalDuration = report.EndTime.Sub(report.StartTime).String()
+	e.completeReboot(ctx, run)
+
+	return report, nil
+}
+
+// failRun finishes the report of a run stopped by err
+func failRun(ctx context.Context, report *ExecutionReport, err error) (*ExecutionReport, error) {
+	report.EndTime = time.Now()
+	report.TotalDuration = report.EndTime.Sub(report.StartTime).String()
+
+	if ctx.Err() != nil {
+		report.Status = "cancelled"
+		return report, ctx.Err()
+	}
+
+	report.Status = "failed"
+	if taskErr, ok := err.(*TaskError); ok {
+		report.ErrorMessage = taskErr.Cause.Error()
+	} else {
+		report.ErrorMessage = err.Error()
+	}
+	return report, err
+}
+
+// preflight runs a dry run of the playbook. When it finds issues, the
+// failing simulated results are copied into the report and the apply is
+// aborted before any task runs.
+func (e *Executor) preflight(ctx context.Context, sp *SignedPlaybook, report *ExecutionReport) error {
+	dryRun, err := e.DryRun(ctx, sp)
+	if err == nil {
+		return nil
+	}
+
+	var issues []string
+	for _, result := range dryRun.TaskResults {
+		if result.Status == TaskStatusFailed || result.Status == TaskStatusRejected {
+			report.TaskResults = append(report.TaskResults, result)
+			issues = append(issues, fmt.Sprintf("task '%s': %s", result.TaskName, result.Error))
+		}
+	}
+	report.TasksTotal = dryRun.TasksTotal
+	report.TasksFailed = len(issues)
+
+	report.Status = "failed"
+	report.EndTime = time.Now()
+	report.TotalDuration = report.EndTime.Sub(report.StartTime).String()
+	if len(issues) > 0 {
+		report.ErrorMessage = fmt.Sprintf("preflight dry run found %d issue(s): %s", len(issues), strings.Join(issues, "; "))
+	} else {
+		report.ErrorMessage = fmt.Sprintf("preflight dry run failed: %v", err)
+	}
+	return fmt.Errorf("preflight dry run failed: %w", err)
+}
+
+// environment describes the agent and system for an execution report
+func (e *Executor) environment(dryRun bool) *ExecutionEnvironment {
+	return &ExecutionEnvironment{
+		AgentVersion: e.agentVersion,
+		Platform:     e.parser.GetPlatform(),
+		Arch:         runtime.GOARCH,
+		Distro:       e.distro,
+		Elevated:     isElevated(),
+		DryRun:       dryRun,
+	}
+}
+
+// cleanupWorkdir removes a job's scratch directory, keeping it for failed
+// playbooks when configured to and recording the kept path in the report
+func (e *Executor) cleanupWorkdir(report *ExecutionReport, workdir string) {
+	if e.retainWorkdirOnFailure && report.Status == "failed" {
+		report.RetainedWorkdir = workdir
+		return
+	}
+	os.RemoveAll(workdir)
+}
+
+// executionState carries the mutable state of a single playbook run
+type executionState struct {
+	playbook         *Playbook
+	report           *ExecutionReport
+	vars             *Variables
+	notifiedHandlers map[string]bool
+
+	// Remaining bytes of task output the report may hold (negative = unlimited)
+	outputBudget int
+
+	// Tasks finished so far, for progress updates
+	tasksDone int
+
+	// Set while notified handlers run; tag filters don't apply to them
+	inHandlers bool
+
+	// Enclosing blocks with a rescue section. Inside one, a failure stops
+	// the block whatever on_error says, so the rescue can handle it.
+	rescuable int
+
+	// How each finished task with an ID ended, for tasks that require it
+	taskStatus map[string]TaskStatus
+}
+
+// addResult appends a task result to the report, truncating its output
+// once the playbook-wide output budget is used up. Statuses and exit codes
+// are always kept intact.
+func (run *executionState) addResult(result *TaskResult) {
+	reported := *result
+	reported.parsedStdout = nil // the report copy may have truncated stdout
+	if run.outputBudget >= 0 {
+		reported.Stdout = run.takeOutput(reported.Stdout)
+		reported.Stderr = run.takeOutput(reported.Stderr)
+	}
+	run.report.TaskResults = append(run.report.TaskResults, reported)
+}
+
+// takeOutput charges output against the budget, truncating it if needed
+func (run *executionState) takeOutput(output string) string {
+	if len(output) <= run.outputBudget {
+		run.outputBudget -= len(output)
+		return output
+	}
+
+	// Cut on a UTF-8 boundary
+	cut := run.outputBudget
+	for cut > 0 && !utf8.RuneStart(output[cut]) {
+		cut--
+	}
+	run.outputBudget = 0
+	run.report.OutputTruncated = true
+	return output[:cut] + outputTruncatedMarker
+}
+
+// runTasks executes a list of tasks in order, descending into blocks.
+// It returns a *TaskError when a failure should stop the playbook, or the
+// context error when execution is cancelled.
+func (e *Executor) runTasks(ctx context.Context, run *executionState, tasks []Task) error {
+	for i := 0; i < len(tasks); i++ {
+		task := &tasks[i]
 
-	for _, task := range playbook.Tasks {
 		select {
 		case <-ctx.Done():
-			report.Status = "cancelled"
-			report.EndTime = time.Now()
-			report.TotalDuration = report.EndTime.Sub(report.StartTime).String()
-			return report, ctx.Err()
+			return ctx.Err()
 		default:
 		}
 
-		result := e.executeTask(ctx, &task, vars)
-		report.TaskResults = append(report.TaskResults, *result)
+		if task.ParallelGroup != "" {
+			end := i + 1
+			for end < len(tasks) && tasks[end].ParallelGroup == task.ParallelGroup {
+				end++
+			}
+			if err := e.runParallel(ctx, run, tasks[i:end]); err != nil {
+				return err
+			}
+			i = end - 1
+			continue
+		}
+
+		if task.Meta == MetaFlushHandlers {
+			if err := e.runNotifiedHandlers(ctx, run); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if reason := unmetRequirement(task, run.requirementStatus); reason != "" {
+			e.skipTasks(run, []Task{*task}, SkipReasonRequirementUnmet, reason)
+			continue
+		}
+
+		if len(task.Block) > 0 {
+			if err := e.runBlock(ctx, run, task); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if task.Loop != nil {
+			if err := e.runLoop(ctx, run, task); err != nil {
+				return err
+			}
+			continue
+		}
+
+		result := e.executeTask(ctx, run, task, run.vars)
+		if err := e.recordResult(run, task, result); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
 
-		switch result.Status {
-		case TaskStatusCompleted:
-			report.TasksCompleted++
-			// Track notified handlers
-			for _, handlerName := range task.Notify {
-				if result.Changed {
-					notifiedHandlers[handlerName] = true
+// runNotifiedHandlers runs the notified handlers in the order they are
+// defined and clears the notifications, so a handler notified again later
+// runs again. It returns a *TaskError if a handler failed and on_error says
+// to stop.
+func (e *Executor) runNotifiedHandlers(ctx context.Context, run *executionState) error {
+	run.inHandlers = true
+	defer func() { run.inHandlers = false }()
+
+	var stopErr error
+	for i := range run.playbook.Handlers {
+		handler := &run.playbook.Handlers[i]
+		if !run.notifiedHandlers[handler.Name] {
+			continue
+		}
+		delete(run.notifiedHandlers, handler.Name)
+
+		if reason := unmetRequirement(handler, run.requirementStatus); reason != "" {
+			e.skipTasks(run, []Task{*handler}, SkipReasonRequirementUnmet, reason)
+			continue
+		}
+
+		result := e.executeTask(ctx, run, handler, run.vars)
+		run.addResult(result)
+
+		if result.Status == TaskStatusFailed && !handler.IgnoreErrors {
+			run.report.TasksFailed++
+			if stopErr == nil && (run.playbook.OnError == nil || run.playbook.OnError.Strategy == "stop") {
+				stopErr = &TaskError{
+					TaskName: handler.Name,
+					TaskID:   handler.ID,
+					Action:   handler.Action,
+					Cause:    errors.New(result.Error),
 				}
 			}
-		case TaskStatusFailed:
-			report.TasksFailed++
-			if !task.IgnoreErrors {
-				// Stop execution on failure (unless error handling says otherwise)
-				if playbook.OnError == nil || playbook.OnError.Strategy == "stop" {
-					report.Status = "failed"
-					report.EndTime = time.Now()
-					report.TotalDuration = report.EndTime.Sub(report.StartTime).String()
-					report.ErrorMessage = result.Error
-					return report, fmt.Errorf("task '%s' failed: %s", task.Name, result.Error)
+		}
+	}
+	return stopErr
+}
+
+// runParallel executes a parallel group concurrently, bounded by maxParallel.
+//
+// Results are recorded in playbook order after the whole group finishes, so
+// tasks in a group cannot see each other's registered results. A failure
+// does not interrupt tasks already running in the group; it stops the
+// playbook afterwards under the usual ignore_errors/on_error rules.
+//
+// A task that requires other tasks of the group waits for them before
+// taking a slot, and is skipped unless they all completed.
+func (e *Executor) runParallel(ctx context.Context, run *executionState, tasks []Task) error {
+	results := make([]*TaskResult, len(tasks))
+	sem := make(chan struct{}, e.maxParallel)
+
+	peers := make(map[string]int)
+	finished := make([]chan struct{}, len(tasks))
+	for i := range tasks {
+		if tasks[i].ID != "" {
+			peers[tasks[i].ID] = i
+		}
+		finished[i] = make(chan struct{})
+	}
+	// Peers are looked up only after they finished; anything else was
+	// recorded before the group started
+	status := func(id string) (TaskStatus, bool) {
+		if j, ok := peers[id]; ok {
+			if results[j] == nil {
+				return "", false
+			}
+			return results[j].Status, true
+		}
+		return run.requirementStatus(id)
+	}
+
+	var wg sync.WaitGroup
+	for i := range tasks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer close(finished[i])
+
+			for _, id := range tasks[i].Requires {
+				if j, ok := peers[id]; ok {
+					select {
+					case <-finished[j]:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+			if reason := unmetRequirement(&tasks[i], status); reason != "" {
+				now := time.Now()
+				results[i] = &TaskResult{
+					TaskName:   tasks[i].Name,
+					TaskID:     tasks[i].ID,
+					Status:     TaskStatusSkipped,
+					SkipReason: SkipReasonRequirementUnmet,
+					Message:    reason,
+					ResultMeta: tasks[i].Result,
+					StartTime:  now,
+					EndTime:    now,
+					Duration:   "0s",
 				}
+				return
+			}
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				return
 			}
-		case TaskStatusSkipped:
-			report.TasksSkipped++
+
+			results[i] = e.executeTask(ctx, run, &tasks[i], run.vars)
+		}(i)
+	}
+	wg.Wait()
+
+	var stopErr error
+	for i := range tasks {
+		if results[i] == nil {
+			continue // never started - cancelled
+		}
+		if err := e.recordResult(run, &tasks[i], results[i]); err != nil && stopErr == nil {
+			stopErr = err
 		}
+	}
 
-		// Store result for variable reference if registered
-		if task.Register != "" {
-			vars.SetTaskResult(task.Register, result)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return stopErr
+}
+
+// runBlock executes the sections of a block task.
+//
+// The block's platform filter and condition are evaluated once, before any
+// child runs. Children inherit become, tags and ignore_errors from the block.
+// If a child fails, the rest of the block is abandoned and the rescue
+// section runs; a completed rescue clears the failure. The always section
+// runs last in every case except cancellation.
+func (e *Executor) runBlock(ctx context.Context, run *executionState, block *Task) error {
+	if block.Platform != "" && block.Platform != e.platform {
+		e.skipTasks(run, []Task{*block}, SkipReasonPlatformFilter, fmt.Sprintf("Skipped: block '%s' platform filter '%s' doesn't match '%s'", block.Name, block.Platform, e.platform))
+		return nil
+	}
+
+	if block.When != "" {
+		condition := NewCondition(run.vars)
+		condResult, err := condition.Evaluate(block.When)
+		if err != nil {
+			now := time.Now()
+			result := &TaskResult{
+				TaskName:  block.Name,
+				TaskID:    block.ID,
+				Status:    TaskStatusFailed,
+				Error:     fmt.Sprintf("block condition evaluation failed: %v", err),
+				StartTime: now,
+				EndTime:   now,
+				Duration:  "0s",
+			}
+			return e.recordResult(run, block, result)
+		}
+		if !condResult {
+			e.skipTasks(run, []Task{*block}, SkipReasonConditionFalse, fmt.Sprintf("Skipped: block '%s' condition '%s' evaluated to false", block.Name, block.When))
+			return nil
 		}
 	}
 
-	// =========================================================================
-	// STEP 5: RUN NOTIFIED HANDLERS
-	// =========================================================================
-	for _, handler := range playbook.Handlers {
-		if notifiedHandlers[handler.Name] {
-			result := e.executeTask(ctx, &handler, vars)
-			report.TaskResults = append(report.TaskResults, *result)
+	rescue := expandBlock(block, block.Rescue, SectionRescue)
+	if len(rescue) > 0 {
+		run.rescuable++
+	}
+	err := e.runTasks(ctx, run, expandBlock(block, block.Block, SectionBlock))
+	if len(rescue) > 0 {
+		run.rescuable--
+
+		var taskErr *TaskError
+		switch {
+		case err == nil:
+			e.skipTasks(run, rescue, SkipReasonRescueNotNeeded, fmt.Sprintf("Skipped: block '%s' did not fail", block.Name))
+		case errors.As(err, &taskErr) && ctx.Err() == nil:
+			run.vars.SetBuiltin(BuiltinFailedTask, taskErr.TaskName)
+			err = e.runTasks(ctx, run, rescue)
+		}
+	}
 
-			if result.Status == TaskStatusFailed && !handler.IgnoreErrors {
-				report.TasksFailed++
+	if len(block.Always) > 0 {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// A failure that wasn't rescued takes precedence in the report
+		if alwaysErr := e.runTasks(ctx, run, expandBlock(block, block.Always, SectionAlways)); err == nil {
+			err = alwaysErr
+		}
+	}
+
+	if err != nil {
+		run.setTaskStatus(block.ID, TaskStatusFailed)
+	} else {
+		run.setTaskStatus(block.ID, TaskStatusCompleted)
+	}
+	return err
+}
+
+// expandBlock returns copies of one section of a block with the block's
+// become, tags and ignore_errors applied
+func expandBlock(block *Task, tasks []Task, section string) []Task {
+	children := make([]Task, len(tasks))
+	for i, child := range tasks {
+		child.Become = child.Become || block.Become
+		child.IgnoreErrors = child.IgnoreErrors || block.IgnoreErrors
+		child.Tags = mergeTags(block.Tags, child.Tags)
+		child.section = section
+		children[i] = child
+	}
+	return children
+}
+
+// mergeTags returns the union of two tag lists, preserving order
+func mergeTags(inherited, own []string) []string {
+	if len(inherited) == 0 {
+		return own
+	}
+
+	seen := make(map[string]bool, len(inherited)+len(own))
+	merged := make([]string, 0, len(inherited)+len(own))
+	for _, tag := range append(append([]string{}, inherited...), own...) {
+		if !seen[tag] {
+			seen[tag] = true
+			merged = append(merged, tag)
+		}
+	}
+	return merged
+}
+
+// skipTasks records every leaf task in the list as skipped with the given reason and message
+func (e *Executor) skipTasks(run *executionState, tasks []Task, reason SkipReason, message string) {
+	for i := range tasks {
+		task := &tasks[i]
+		if task.Meta != "" {
+			continue
+		}
+		if len(task.Block) > 0 {
+			e.skipTasks(run, expandBlock(task, task.Block, SectionBlock), reason, message)
+			e.skipTasks(run, expandBlock(task, task.Rescue, SectionRescue), reason, message)
+			e.skipTasks(run, expandBlock(task, task.Always, SectionAlways), reason, message)
+			run.setTaskStatus(task.ID, TaskStatusSkipped)
+			continue
+		}
+
+		now := time.Now()
+		result := &TaskResult{
+			TaskName:   task.Name,
+			TaskID:     task.ID,
+			Section:    task.section,
+			Status:     TaskStatusSkipped,
+			SkipReason: reason,
+			Message:    message,
+			ResultMeta: task.Result,
+			StartTime:  now,
+			EndTime:    now,
+			Duration:   "0s",
+		}
+		// Skipped results never stop execution
+		_ = e.recordResult(run, task, result)
+	}
+}
+
+// recordResult adds a task result to the report, tracks handler notifications
+// and registered results, and decides whether execution must stop
+func (e *Executor) recordResult(run *executionState, task *Task, result *TaskResult) error {
+	report := run.report
+	if result.Section == "" {
+		result.Section = task.section
+	}
+	run.addResult(result)
+
+	var stopErr error
+
+	switch result.Status {
+	case TaskStatusCompleted:
+		report.TasksCompleted++
+		if result.RebootRequired && !report.RebootRequired {
+			report.RebootRequired = true
+			report.RebootReason = fmt.Sprintf("task '%s' requires a reboot", task.Name)
+		}
+		// Track notified handlers
+		for _, handlerName := range task.Notify {
+			if result.Changed {
+				run.notifiedHandlers[handlerName] = true
+			}
+		}
+	case TaskStatusFailed, TaskStatusRejected:
+		report.TasksFailed++
+		if !task.IgnoreErrors {
+			// Stop execution on failure (unless error handling says otherwise)
+			if run.rescuable > 0 || run.playbook.OnError == nil || run.playbook.OnError.Strategy == "stop" {
+				stopErr = &TaskError{
+					TaskName: task.Name,
+					TaskID:   task.ID,
+					Action:   task.Action,
+					Cause:    errors.New(result.Error),
+				}
 			}
 		}
+	case TaskStatusSkipped:
+		report.TasksSkipped++
 	}
 
-	// =========================================================================
-	// STEP 6: COMPLETE
-	// =========================================================================
-	report.Status = "completed"
-	report.EndTime = time.Now()
-	report.TotalDuration = report.EndTime.Sub(report.StartTime).String()
-	report.RebootRequired = playbook.RequiresReboot
+	// Store result for variable reference if registered
+	if task.Register != "" {
+		run.vars.SetTaskResult(task.Register, result)
+	}
+	run.setTaskStatus(task.ID, requirementOutcome(result))
 
-	return report, nil
+	run.tasksDone++
+	e.emitProgress(run, result)
+
+	return stopErr
+}
+
+// emitProgress reports overall progress after a task result is recorded
+func (e *Executor) emitProgress(run *executionState, result *TaskResult) {
+	if e.onProgressUpdate == nil {
+		return
+	}
+	e.onProgressUpdate(run.progress(result.TaskName, result.Status))
+}
+
+// progress builds a progress update from the tasks finished so far.
+// TasksTotal already includes block children and loop iterations.
+func (run *executionState) progress(taskName string, status TaskStatus) Progress {
+	total := run.report.TasksTotal
+	percent := 100.0
+	if total > 0 && run.tasksDone < total {
+		percent = float64(run.tasksDone) / float64(total) * 100
+	}
+
+	return Progress{
+		TaskName:        taskName,
+		Status:          status,
+		TasksDone:       run.tasksDone,
+		TasksTotal:      total,
+		ProgressPercent: math.Round(percent*10) / 10,
+	}
+}
+
+// supportsPlatform checks whether a handler supports the current platform
+func (e *Executor) supportsPlatform(handler ActionHandler) bool {
+	for _, p := range handler.Supports() {
+		if p == e.platform || p == "all" {
+			return true
+		}
+	}
+	return false
+}
+
+// isActionAllowed checks an action against the configured allowlist
+func (e *Executor) isActionAllowed(action string) bool {
+	return e.allowedActions == nil || e.allowedActions[action]
+}
+
+// countTasks returns the number of leaf tasks, counting block children
+func countTasks(tasks []Task) int {
+	count := 0
+	for _, task := range tasks {
+		if task.Meta != "" {
+			continue // not reported as a task
+		}
+		if len(task.Block) > 0 {
+			count += countTasks(task.Block) + countTasks(task.Rescue) + countTasks(task.Always)
+		} else {
+			count++
+		}
+	}
+	return count
+}
+
+// runHandler runs a handler, bounded by the task timeout (seconds, 0 =
+// unlimited). Handlers that ignore their context are abandoned once the
+// timeout expires so a blocked subprocess can't hang the playbook.
+func runHandler(ctx context.Context, handler ActionHandler, params map[string]interface{}, vars *Variables, timeout int) (*TaskResult, error) {
+	if timeout <= 0 {
+		return handler.Execute(ctx, params, vars)
+	}
+
+	taskCtx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	type outcome struct {
+		result *TaskResult
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := handler.Execute(taskCtx, params, vars)
+		done <- outcome{result, err}
+	}()
+
+	timeoutErr := fmt.Errorf("task exceeded timeout of %ds", timeout)
+	select {
+	case out := <-done:
+		if out.err != nil && errors.Is(taskCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+			return out.result, timeoutErr
+		}
+		return out.result, out.err
+	case <-taskCtx.Done():
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, timeoutErr
+	}
 }
 
 // executeTask executes a single task with retry logic
-func (e *Executor) executeTask(ctx context.Context, task *Task, vars *Variables) *TaskResult {
+func (e *Executor) executeTask(ctx context.Context, run *executionState, task *Task, vars *Variables) *TaskResult {
 	result := &TaskResult{
 		TaskName:   task.Name,
 		TaskID:     task.ID,
@@ -246,12 +1108,37 @@ func (e *Executor) executeTask(ctx context.Context, task *Task, vars *Variables)
 	// Check platform filter
 	if task.Platform != "" && task.Platform != e.platform {
 		result.Status = TaskStatusSkipped
+		result.SkipReason = SkipReasonPlatformFilter
 		result.Message = fmt.Sprintf("Skipped: platform filter '%s' doesn't match '%s'", task.Platform, e.platform)
 		result.EndTime = time.Now()
 		result.Duration = result.EndTime.Sub(result.StartTime).String()
 		return result
 	}
 
+	// Check tag filters
+	if !run.inHandlers {
+		if reason := e.tagSkipReason(task); reason != "" {
+			result.Status = TaskStatusSkipped
+			result.SkipReason = SkipReasonTagFilter
+			result.Message = reason
+			result.EndTime = time.Now()
+			result.Duration = result.EndTime.Sub(result.StartTime).String()
+			return result
+		}
+	}
+
+	// Check the action is permitted for this execution
+	if !e.isActionAllowed(task.Action) {
+		result.Status = TaskStatusRejected
+		result.Error = fmt.Sprintf("action '%s' is not permitted for this execution", task.Action)
+		result.EndTime = time.Now()
+		result.Duration = result.EndTime.Sub(result.StartTime).String()
+		if e.onProgress != nil {
+			e.onProgress(task.Name, TaskStatusRejected)
+		}
+		return result
+	}
+
 	// Evaluate condition
 	if task.When != "" {
 		condition := NewCondition(vars)
@@ -265,6 +1152,7 @@ func (e *Executor) executeTask(ctx context.Context, task *Task, vars *Variables)
 		}
 		if !condResult {
 			result.Status = TaskStatusSkipped
+			result.SkipReason = SkipReasonConditionFalse
 			result.Message = fmt.Sprintf("Skipped: condition '%s' evaluated to false", task.When)
 			result.EndTime = time.Now()
 			result.Duration = result.EndTime.Sub(result.StartTime).String()
@@ -272,27 +1160,34 @@ func (e *Executor) executeTask(ctx context.Context, task *Task, vars *Variables)
 		}
 	}
 
+	// Check privilege requirement
+	if task.Become && !isElevated() {
+		result.Status = TaskStatusFailed
+		result.Error = "task requires elevated privileges (become) but the agent is not running as root/Administrator"
+		result.EndTime = time.Now()
+		result.Duration = result.EndTime.Sub(result.StartTime).String()
+		return result
+	}
+
 	// Get the handler
-	handler, ok := e.handlers[task.Action]
+	handler, ok := e.handlerFor(task.Action)
 	if !ok {
 		result.Status = TaskStatusFailed
-		result.Error = fmt.Sprintf("no handler registered for action '%s'", task.Action)
+		if task.Action != ActionAgentControl && e.parser.isValidAction(task.Action) {
+			// A known action with no implementation on this platform
+			result.Error = NewUnsupportedActionError(task.Action, e.platform).Error()
+		} else {
+			result.Error = fmt.Sprintf("no handler registered for action '%s'", task.Action)
+		}
 		result.EndTime = time.Now()
 		result.Duration = result.EndTime.Sub(result.StartTime).String()
 		return result
 	}
 
 	// Check platform support
-	supported := false
-	for _, p := range handler.Supports() {
-		if p == e.platform || p == "all" {
-			supported = true
-			break
-		}
-	}
-	if !supported {
+	if !e.supportsPlatform(handler) {
 		result.Status = TaskStatusFailed
-		result.Error = fmt.Sprintf("action '%s' does not support platform '%s'", task.Action, e.platform)
+		result.Error = NewUnsupportedActionError(task.Action, e.platform).Error()
 		result.EndTime = time.Now()
 		result.Duration = result.EndTime.Sub(result.StartTime).String()
 		return result
@@ -312,14 +1207,35 @@ func (e *Executor) executeTask(ctx context.Context, task *Task, vars *Variables)
 	maxAttempts := task.Retries + 1
 	var lastErr error
 
+	// Invalid params fail the same way on every attempt, so check them once
+	if err := handler.Validate(params); err != nil {
+		lastErr = NewPermanentError(err)
+		maxAttempts = 0
+	}
+
 	for attempt := 1; attempt <= maxAttempts; attempt++ {
 		result.Status = TaskStatusRunning
 
-		execResult, execErr := handler.Execute(ctx, params, vars)
+		var execResult *TaskResult
+		var execErr error
+		if task.Async > 0 {
+			execResult, execErr = e.runAsync(ctx, run, task, handler, params, vars)
+		} else {
+			execResult, execErr = runHandler(ctx, handler, params, vars, task.Timeout)
+		}
 		if execErr == nil && execResult != nil {
 			// Success
 			result.Status = TaskStatusCompleted
+			if execResult.Status == TaskStatusSkipped {
+				// The handler found nothing to do
+				result.Status = TaskStatusSkipped
+				result.SkipReason = execResult.SkipReason
+				if result.SkipReason == "" {
+					result.SkipReason = SkipReasonAlreadySatisfied
+				}
+			}
 			result.Changed = execResult.Changed
+			result.RebootRequired = execResult.RebootRequired || (task.RequiresReboot && result.Changed)
 			result.Stdout = execResult.Stdout
 			result.Stderr = execResult.Stderr
 			result.ExitCode = execResult.ExitCode
@@ -340,6 +1256,11 @@ func (e *Executor) executeTask(ctx context.Context, task *Task, vars *Variables)
 			result.ExitCode = execResult.ExitCode
 		}
 
+		// Permanent failures won't succeed on another attempt
+		if !IsRetryable(execErr) {
+			break
+		}
+
 		// Retry delay
 		if attempt < maxAttempts && task.RetryDelay > 0 {
 			select {
@@ -367,7 +1288,10 @@ func (e *Executor) executeTask(ctx context.Context, task *Task, vars *Variables)
 
 	// Execute rollback if defined
 	if task.Rollback != nil {
-		rollbackResult := e.executeTask(ctx, task.Rollback, vars)
+		// The rollback inherits the task's tags so filters can't separate them
+		rollback := *task.Rollback
+		rollback.Tags = mergeTags(task.Tags, rollback.Tags)
+		rollbackResult := e.executeTask(ctx, run, &rollback, vars)
 		if rollbackResult.Status == TaskStatusFailed {
 			result.Error = fmt.Sprintf("%s (rollback also failed: %s)", result.Error, rollbackResult.Error)
 		} else {
@@ -390,9 +1314,11 @@ func (e *Executor) DryRun(ctx context.Context, sp *SignedPlaybook) (*ExecutionRe
 	report := &ExecutionReport{
 		PlaybookID: sp.PlaybookID,
 		DeviceID:   e.deviceID,
+		DryRun:     true,
 		StartTime:  time.Now(),
 		Status:     "dry_run",
 	}
+	report.Environment = e.environment(true)
 
 	// Still verify!
 	verificationRecord, verifyErr := e.verifier.Verify(sp)
@@ -417,16 +1343,65 @@ func (e *Executor) DryRun(ctx context.Context, sp *SignedPlaybook) (*ExecutionRe
 	}
 
 	report.PlaybookName = playbook.Name
-	report.TasksTotal = len(playbook.Tasks)
+	report.TasksTotal = countTasks(playbook.Tasks)
 
 	// Simulate each task
-	vars := NewVariables()
-	vars.SetUserVars(playbook.Variables)
+	e.simulateTasks(report, playbook.Tasks, "")
+
+	report.EndTime = time.Now()
+	report.TotalDuration = report.EndTime.Sub(report.StartTime).String()
+
+	if report.TasksFailed > 0 {
+		report.Status = "dry_run_failed"
+		return report, fmt.Errorf("dry run found %d issues", report.TasksFailed)
+	}
+
+	report.Status = "dry_run_ok"
+	return report, nil
+}
+
+// simulateTasks appends simulated results for each leaf task to a dry run report.
+// blockNote describes an enclosing block condition, if any.
+func (e *Executor) simulateTasks(report *ExecutionReport, tasks []Task, blockNote string) {
+	for _, task := range tasks {
+		if task.Meta != "" {
+			continue
+		}
+		if len(task.Block) > 0 {
+			note := blockNote
+			if task.Platform != "" && task.Platform != e.platform {
+				note = fmt.Sprintf("block '%s' platform filter", task.Name)
+			} else if task.When != "" {
+				if err := ValidateCondition(task.When); err != nil {
+					now := time.Now()
+					report.TaskResults = append(report.TaskResults, TaskResult{
+						TaskName:  task.Name,
+						TaskID:    task.ID,
+						Status:    TaskStatusFailed,
+						Error:     fmt.Sprintf("Invalid block condition: %v", err),
+						StartTime: now,
+						EndTime:   now,
+						Duration:  "0s",
+					})
+					report.TasksFailed++
+					continue
+				}
+				note = fmt.Sprintf("block condition '%s' is true", task.When)
+			}
+			e.simulateTasks(report, expandBlock(&task, task.Block, SectionBlock), note)
+			rescueNote := fmt.Sprintf("block '%s' fails", task.Name)
+			if note != "" {
+				rescueNote = note + " and " + rescueNote
+			}
+			e.simulateTasks(report, expandBlock(&task, task.Rescue, SectionRescue), rescueNote)
+			e.simulateTasks(report, expandBlock(&task, task.Always, SectionAlways), note)
+			continue
+		}
 
-	for _, task := range playbook.Tasks {
 		simResult := &TaskResult{
 			TaskName:  task.Name,
 			TaskID:    task.ID,
+			Section:   task.section,
 			StartTime: time.Now(),
 			Status:    TaskStatusPending,
 		}
@@ -434,7 +1409,12 @@ func (e *Executor) DryRun(ctx context.Context, sp *SignedPlaybook) (*ExecutionRe
 		// Check platform filter
 		if task.Platform != "" && task.Platform != e.platform {
 			simResult.Status = TaskStatusSkipped
+			simResult.SkipReason = SkipReasonPlatformFilter
 			simResult.Message = "Would skip: platform filter"
+		} else if e.tagSkipReason(&task) != "" {
+			simResult.Status = TaskStatusSkipped
+			simResult.SkipReason = SkipReasonTagFilter
+			simResult.Message = "Would skip: tag filter"
 		} else if task.When != "" {
 			// We can't fully evaluate conditions in dry run, but we can validate syntax
 			if err := ValidateCondition(task.When); err != nil {
@@ -450,26 +1430,39 @@ func (e *Executor) DryRun(ctx context.Context, sp *SignedPlaybook) (*ExecutionRe
 			simResult.Message = "Would execute"
 		}
 
-		// Validate handler exists
-		if _, ok := e.handlers[task.Action]; !ok {
+		if task.Loop != nil && simResult.Status == TaskStatusPending {
+			simResult.Message += " for each loop item"
+		}
+
+		if len(task.Requires) > 0 && simResult.Status == TaskStatusPending {
+			simResult.Message = fmt.Sprintf("%s once %s completed", simResult.Message, strings.Join(task.Requires, ", "))
+		}
+
+		if blockNote != "" && simResult.Status == TaskStatusPending {
+			simResult.Message = fmt.Sprintf("%s (if %s)", simResult.Message, blockNote)
+		}
+
+		// Validate handler exists and is permitted
+		handler, ok := e.handlers[task.Action]
+		if task.Action == ActionAgentControl {
+			handler, ok = e.controlHandler, e.controlHandler != nil
+		}
+		if !ok {
 			simResult.Status = TaskStatusFailed
 			simResult.Error = fmt.Sprintf("No handler for action '%s'", task.Action)
 			report.TasksFailed++
+		} else if !e.supportsPlatform(handler) && simResult.Status != TaskStatusSkipped {
+			simResult.Status = TaskStatusFailed
+			simResult.Error = NewUnsupportedActionError(task.Action, e.platform).Error()
+			report.TasksFailed++
+		} else if !e.isActionAllowed(task.Action) {
+			simResult.Status = TaskStatusRejected
+			simResult.Error = fmt.Sprintf("Action '%s' is not permitted for this execution", task.Action)
+			report.TasksFailed++
 		}
 
 		simResult.EndTime = time.Now()
 		simResult.Duration = simResult.EndTime.Sub(simResult.StartTime).String()
 		report.TaskResults = append(report.TaskResults, *simResult)
 	}
-
-	report.EndTime = time.Now()
-	report.TotalDuration = report.EndTime.Sub(report.StartTime).String()
-
-	if report.TasksFailed > 0 {
-		report.Status = "dry_run_failed"
-		return report, fmt.Errorf("dry run found %d issues", report.TasksFailed)
-	}
-
-	report.Status = "dry_run_ok"
-	return report, nil
 }
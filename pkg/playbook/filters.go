@@ -0,0 +1,106 @@
+package playbook
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// filterPattern matches one "| name" or "| name(arg)" step of a filter
+// chain. The argument is a double-quoted string with Go escapes, a
+// single-quoted string taken literally, or a bare word such as a number.
+var filterPattern = regexp.MustCompile(`\|\s*([a-z_]+)(\(\s*("(?:[^"\\]|\\.)*"|'[^']*'|[^()"'\s]*)\s*\))?`)
+
+// variableFilter is one step of a {{ var | filter }} chain
+type variableFilter struct {
+	name   string
+	arg    string
+	hasArg bool
+}
+
+// Filters available in {{ var | filter }} and whether they take an argument
+var variableFilters = map[string]bool{
+	"default":   true,  // default(value): value when the variable is undefined
+	"upper":     false, // upper-case
+	"lower":     false, // lower-case
+	"trim":      false, // strip leading and trailing whitespace
+	"from_json": false, // parse as JSON and render it compactly
+}
+
+// parseFilters parses the filter chain following a variable name, e.g.
+// ` | default("x") | upper`
+func parseFilters(chain string) ([]variableFilter, error) {
+	var filters []variableFilter
+	for _, m := range filterPattern.FindAllStringSubmatch(chain, -1) {
+		f := variableFilter{name: m[1], hasArg: m[2] != ""}
+
+		takesArg, known := variableFilters[f.name]
+		switch {
+		case !known:
+			return nil, fmt.Errorf("unknown filter '%s'", f.name)
+		case takesArg && !f.hasArg:
+			return nil, fmt.Errorf("filter '%s' requires an argument", f.name)
+		case !takesArg && f.hasArg:
+			return nil, fmt.Errorf("filter '%s' takes no argument", f.name)
+		}
+
+		if f.hasArg {
+			arg, err := unquoteFilterArg(m[3])
+			if err != nil {
+				return nil, fmt.Errorf("filter '%s': %w", f.name, err)
+			}
+			f.arg = arg
+		}
+		filters = append(filters, f)
+	}
+	return filters, nil
+}
+
+// unquoteFilterArg decodes a filter argument
+func unquoteFilterArg(arg string) (string, error) {
+	switch {
+	case strings.HasPrefix(arg, `"`):
+		s, err := strconv.Unquote(arg)
+		if err != nil {
+			return "", fmt.Errorf("invalid string %s", arg)
+		}
+		return s, nil
+	case strings.HasPrefix(arg, "'"):
+		return arg[1 : len(arg)-1], nil
+	default:
+		return arg, nil
+	}
+}
+
+// applyFilters runs value through filters in order. defined reports whether
+// the variable exists; only default can make an undefined value defined,
+// the other filters pass it through untouched.
+func applyFilters(value string, defined bool, filters []variableFilter) (string, bool, error) {
+	for _, f := range filters {
+		if f.name == "default" {
+			if !defined {
+				value, defined = f.arg, true
+			}
+			continue
+		}
+		if !defined {
+			continue
+		}
+		switch f.name {
+		case "upper":
+			value = strings.ToUpper(value)
+		case "lower":
+			value = strings.ToLower(value)
+		case "trim":
+			value = strings.TrimSpace(value)
+		case "from_json":
+			parsed, err := decodeJSON(value)
+			if err != nil {
+				return "", false, fmt.Errorf("filter 'from_json': %w", err)
+			}
+			value = formatJSONValue(parsed)
+		}
+	}
+	return value, defined, nil
+}
@@ -0,0 +1,132 @@
+package playbook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// jsonPathSegment matches one step of a JSON path: a key followed by any
+// number of [index] suffixes, or only indexes
+var jsonPathSegment = regexp.MustCompile(`^([^\[\]]*)((?:\[\d+\])*)$`)
+
+// stdoutJSON caches the parsed stdout of a registered task result
+type stdoutJSON struct {
+	once  sync.Once
+	value interface{}
+	err   error
+}
+
+// StdoutJSON parses the task's stdout as JSON. The result is cached once
+// the task result has been registered.
+func (r *TaskResult) StdoutJSON() (interface{}, error) {
+	if r.parsedStdout == nil {
+		return decodeJSON(r.Stdout)
+	}
+	r.parsedStdout.once.Do(func() {
+		r.parsedStdout.value, r.parsedStdout.err = decodeJSON(r.Stdout)
+	})
+	return r.parsedStdout.value, r.parsedStdout.err
+}
+
+// decodeJSON parses a JSON document, keeping numbers as written
+func decodeJSON(data string) (interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader(data))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return nil, fmt.Errorf("not valid JSON: %w", err)
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("not valid JSON: unexpected data after the document")
+	}
+	return value, nil
+}
+
+// isJSONProperty reports whether a task result property addresses the
+// parsed stdout: "json", "json.key" or "json[0]"
+func isJSONProperty(property string) bool {
+	return property == "json" || strings.HasPrefix(property, "json.") || strings.HasPrefix(property, "json[")
+}
+
+// jsonProperty resolves a json property of a task result, e.g.
+// "json.items[0].name". It reports false when stdout is not JSON or the
+// path does not exist.
+func jsonProperty(result *TaskResult, property string) (string, bool) {
+	doc, err := result.StdoutJSON()
+	if err != nil {
+		return "", false
+	}
+	value, ok := walkJSONPath(doc, strings.TrimPrefix(property, "json"))
+	if !ok {
+		return "", false
+	}
+	return formatJSONValue(value), true
+}
+
+// walkJSONPath follows a path such as ".items[0].name" into a decoded
+// JSON value
+func walkJSONPath(value interface{}, path string) (interface{}, bool) {
+	path = strings.TrimPrefix(path, ".")
+	if path == "" {
+		return value, true
+	}
+
+	for _, segment := range strings.Split(path, ".") {
+		m := jsonPathSegment.FindStringSubmatch(segment)
+		if m == nil {
+			return nil, false
+		}
+
+		if key := m[1]; key != "" {
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if value, ok = object[key]; !ok {
+				return nil, false
+			}
+		}
+
+		for _, index := range strings.Split(strings.Trim(m[2], "[]"), "][") {
+			if index == "" {
+				continue
+			}
+			list, ok := value.([]interface{})
+			if !ok {
+				return nil, false
+			}
+			i, err := strconv.Atoi(index)
+			if err != nil || i >= len(list) {
+				return nil, false
+			}
+			value = list[i]
+		}
+	}
+	return value, true
+}
+
+// formatJSONValue renders a decoded JSON value as a variable string:
+// strings as is, null as empty, everything else as compact JSON
+func formatJSONValue(value interface{}) string {
+	switch val := value.(type) {
+	case string:
+		return val
+	case nil:
+		return ""
+	case json.Number:
+		return val.String()
+	default:
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(val); err != nil {
+			return fmt.Sprint(val)
+		}
+		return strings.TrimSuffix(buf.String(), "\n")
+	}
+}
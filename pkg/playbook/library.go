@@ -0,0 +1,64 @@
+package playbook
+
+import (
+	"fmt"
+)
+
+// AllPlatforms lists every platform a playbook can target
+var AllPlatforms = []string{PlatformWindows, PlatformLinux, PlatformDarwin, PlatformAndroid}
+
+// LibraryCheck is the offline verdict for one signed playbook: whether it
+// passes verification and, per platform, whether an agent would run it.
+type LibraryCheck struct {
+	PlaybookID   string
+	PlaybookName string
+
+	// Verification record; the playbook runs nowhere unless AllChecksPass
+	Verification *VerificationRecord
+
+	// Problems maps each platform to why the playbook would not run there.
+	// Platforms it would run on are absent.
+	Problems map[string]string
+}
+
+// RunsOn reports whether the playbook would run on the platform
+func (c *LibraryCheck) RunsOn(platform string) bool {
+	_, blocked := c.Problems[platform]
+	return !blocked
+}
+
+// CheckLibraryPlaybook applies the checks Execute performs before running
+// any task - verification, parsing and platform compatibility - for every
+// platform, without executing anything. It lets playbook authors check a
+// whole signed library before upload.
+func CheckLibraryPlaybook(verifier *Verifier, sp *SignedPlaybook) *LibraryCheck {
+	check := &LibraryCheck{
+		PlaybookID: sp.PlaybookID,
+		Problems:   make(map[string]string),
+	}
+
+	record, err := verifier.Verify(sp)
+	check.Verification = record
+	if err != nil {
+		for _, platform := range AllPlatforms {
+			check.Problems[platform] = fmt.Sprintf("verification failed: %s", record.FailureReason)
+		}
+		return check
+	}
+
+	for _, platform := range AllPlatforms {
+		pb, err := NewParserForPlatform(platform).Parse(sp.Content)
+		if err != nil {
+			check.Problems[platform] = err.Error()
+			continue
+		}
+		check.PlaybookName = pb.Name
+
+		// The parser has already rejected platforms the playbook doesn't target
+		if pb.Scope == ScopeControl && sp.Status != StatusApproved {
+			check.Problems[platform] = "control playbooks must be approved"
+		}
+	}
+
+	return check
+}
@@ -0,0 +1,134 @@
+package playbook
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// runLoop executes a task once per loop item.
+//
+// Each iteration is reported separately as "name [index]" and evaluates the
+// task's when condition against its own item. A registered variable holds
+// the combined result: changed if any iteration changed, failed if any failed.
+func (e *Executor) runLoop(ctx context.Context, run *executionState, task *Task) error {
+	items, err := resolveLoopItems(task.Loop, run.vars)
+	if err != nil || len(items) == 0 {
+		now := time.Now()
+		result := &TaskResult{
+			TaskName:   task.Name,
+			TaskID:     task.ID,
+			Status:     TaskStatusSkipped,
+			SkipReason: SkipReasonNoLoopItems,
+			Message:    "Skipped: loop has no items",
+			StartTime:  now,
+			EndTime:    now,
+			Duration:   "0s",
+		}
+		if err != nil {
+			result.Status = TaskStatusFailed
+			result.SkipReason = ""
+			result.Message = ""
+			result.Error = fmt.Sprintf("loop resolution failed: %v", err)
+		}
+		return e.recordResult(run, task, result)
+	}
+
+	// The task was counted once; the report gets one entry per item
+	run.report.TasksTotal += len(items) - 1
+
+	iteration := *task
+	iteration.Loop = nil
+	iteration.Register = ""
+
+	combined := &TaskResult{
+		TaskName:  task.Name,
+		TaskID:    task.ID,
+		Status:    TaskStatusSkipped,
+		StartTime: time.Now(),
+	}
+	var outputs []string
+
+	var stopErr error
+	for i, item := range items {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		iteration.Name = fmt.Sprintf("%s [%d]", task.Name, i)
+		result := e.executeTask(ctx, run, &iteration, run.vars.WithItem(item, i))
+
+		combined.Changed = combined.Changed || result.Changed
+		if result.Stdout != "" {
+			outputs = append(outputs, result.Stdout)
+		}
+		switch {
+		case result.Status == TaskStatusFailed || result.Status == TaskStatusRejected:
+			combined.Status = TaskStatusFailed
+			combined.ExitCode = result.ExitCode
+			combined.Error = result.Error
+		case result.Status == TaskStatusCompleted && combined.Status == TaskStatusSkipped:
+			combined.Status = TaskStatusCompleted
+			combined.SkipReason = ""
+		case result.Status == TaskStatusSkipped && combined.Status == TaskStatusSkipped:
+			combined.SkipReason = result.SkipReason
+		}
+
+		if err := e.recordResult(run, &iteration, result); err != nil {
+			stopErr = err
+			break
+		}
+	}
+
+	if task.Register != "" {
+		combined.Stdout = strings.Join(outputs, "\n")
+		combined.EndTime = time.Now()
+		combined.Duration = combined.EndTime.Sub(combined.StartTime).String()
+		run.vars.SetTaskResult(task.Register, combined)
+	}
+	run.setTaskStatus(task.ID, requirementOutcome(combined))
+
+	return stopErr
+}
+
+// resolveLoopItems expands a task's loop into its items. A list is used
+// as-is after variable substitution. A string is a variable reference
+// ("name" or "{{ name }}") whose value is parsed as a YAML list, falling
+// back to one item per non-empty line (e.g. a registered stdout).
+func resolveLoopItems(loop interface{}, vars *Variables) ([]interface{}, error) {
+	switch val := loop.(type) {
+	case []interface{}:
+		return vars.substituteSlice(val)
+
+	case string:
+		ref := strings.TrimSpace(val)
+		if !strings.Contains(ref, "{{") {
+			ref = "{{ " + ref + " }}"
+		}
+		resolved, err := vars.Substitute(ref)
+		if err != nil {
+			return nil, err
+		}
+
+		var items []interface{}
+		if err := yaml.Unmarshal([]byte(resolved), &items); err == nil {
+			return items, nil
+		}
+
+		items = nil
+		for _, line := range strings.Split(resolved, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				items = append(items, line)
+			}
+		}
+		return items, nil
+
+	default:
+		return nil, fmt.Errorf("loop must be a list or a variable reference")
+	}
+}
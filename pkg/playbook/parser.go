@@ -26,22 +26,44 @@ func NewParser() *Parser {
 	return &Parser{platform: platform}
 }
 
+// NewParserForPlatform creates a parser that validates as an agent on the
+// given platform would, e.g. to check playbooks for other platforms offline
+func NewParserForPlatform(platform string) *Parser {
+	return &Parser{platform: platform}
+}
+
 // Parse parses YAML content into a Playbook struct
 //
 // This performs:
 //   1. YAML syntax parsing
-//   2. Schema validation
-//   3. Platform compatibility check
+//   2. Migration of older schema versions to the current one
+//   3. Schema validation
+//   4. Platform compatibility check
 func (p *Parser) Parse(content string) (*Playbook, error) {
-	var pb Playbook
+	var doc yaml.Node
 
 	// Parse YAML
-	if err := yaml.Unmarshal([]byte(content), &pb); err != nil {
+	if err := yaml.Unmarshal([]byte(content), &doc); err != nil {
+		return nil, &ParseError{
+			Message: fmt.Sprintf("YAML parse failed: %v", err),
+			Cause:   ErrInvalidYAML,
+		}
+	}
+
+	// Upgrade playbooks written for an older schema before decoding
+	version, err := migrateSchema(&doc, documentVersion(&doc))
+	if err != nil {
+		return nil, &ValidationError{Field: "version", Message: err.Error()}
+	}
+
+	var pb Playbook
+	if err := doc.Decode(&pb); err != nil {
 		return nil, &ParseError{
 			Message: fmt.Sprintf("YAML parse failed: %v", err),
 			Cause:   ErrInvalidYAML,
 		}
 	}
+	pb.Version = version
 
 	// Validate the playbook
 	if err := p.Validate(&pb); err != nil {
@@ -60,7 +82,7 @@ func (p *Parser) Validate(pb *Playbook) error {
 	if !p.isSupportedVersion(pb.Version) {
 		return &ValidationError{
 			Field:   "version",
-			Message: fmt.Sprintf("version '%s' is not supported, expected '%s'", pb.Version, SchemaVersion),
+			Message: fmt.Sprintf("version '%s' is not supported, expected %s (older versions are migrated by Parse)", pb.Version, strings.Join(SupportedSchemaVersions(), ", ")),
 		}
 	}
 
@@ -93,16 +115,62 @@ func (p *Parser) Validate(pb *Playbook) error {
 		}
 	}
 
+	// Validate minimum agent version
+	if pb.MinAgentVersion != "" {
+		if _, err := parseVersion(pb.MinAgentVersion); err != nil {
+			return &ValidationError{Field: "min_agent_version", Message: err.Error()}
+		}
+	}
+
+	// Validate scope
+	if pb.Scope != "" && pb.Scope != ScopeControl {
+		return &ValidationError{
+			Field:   "scope",
+			Message: fmt.Sprintf("unknown scope '%s'", pb.Scope),
+		}
+	}
+	if pb.Scope != ScopeControl &&
+		(usesAction(pb.Tasks, ActionAgentControl) || usesAction(pb.Handlers, ActionAgentControl)) {
+		return &ValidationError{
+			Field:   "scope",
+			Message: fmt.Sprintf("%s action requires scope '%s'", ActionAgentControl, ScopeControl),
+		}
+	}
+
 	// Validate each task
 	for i, task := range pb.Tasks {
-		if err := p.validateTask(&task, i); err != nil {
+		if err := p.validateTask(&task, fmt.Sprintf("tasks[%d]", i)); err != nil {
 			return err
 		}
 	}
 
 	// Validate handlers
 	for i, handler := range pb.Handlers {
-		if err := p.validateTask(&handler, i); err != nil {
+		if len(handler.Block) > 0 {
+			return &ValidationError{
+				Field:   fmt.Sprintf("handlers[%d]", i),
+				Message: "handlers cannot be blocks",
+			}
+		}
+		if handler.Loop != nil {
+			return &ValidationError{
+				Field:   fmt.Sprintf("handlers[%d].loop", i),
+				Message: "handlers cannot loop",
+			}
+		}
+		if handler.Meta != "" {
+			return &ValidationError{
+				Field:   fmt.Sprintf("handlers[%d].meta", i),
+				Message: "handlers cannot be meta directives",
+			}
+		}
+		if handler.ParallelGroup != "" {
+			return &ValidationError{
+				Field:   fmt.Sprintf("handlers[%d].parallel_group", i),
+				Message: "handlers cannot run in a parallel group",
+			}
+		}
+		if err := p.validateTask(&handler, fmt.Sprintf("tasks[%d]", i)); err != nil {
 			return &ValidationError{
 				Field:   fmt.Sprintf("handlers[%d]", i),
 				Message: err.Error(),
@@ -110,12 +178,15 @@ func (p *Parser) Validate(pb *Playbook) error {
 		}
 	}
 
-	return nil
+	return validateRequires(pb)
 }
 
 // validateTask validates a single task definition
-func (p *Parser) validateTask(task *Task, index int) error {
-	fieldPrefix := fmt.Sprintf("tasks[%d]", index)
+func (p *Parser) validateTask(task *Task, fieldPrefix string) error {
+	// Meta directives need no name and take no other options
+	if task.Meta != "" {
+		return validateMeta(task, fieldPrefix)
+	}
 
 	// Task name is required
 	if task.Name == "" {
@@ -125,6 +196,18 @@ func (p *Parser) validateTask(task *Task, index int) error {
 		}
 	}
 
+	if len(task.Block) == 0 && (len(task.Rescue) > 0 || len(task.Always) > 0) {
+		return &ValidationError{
+			Field:   fieldPrefix + ".block",
+			Message: "rescue and always require a block",
+		}
+	}
+
+	// Blocks carry child tasks instead of an action
+	if len(task.Block) > 0 {
+		return p.validateBlock(task, fieldPrefix)
+	}
+
 	// Action is required
 	if task.Action == "" {
 		return &ValidationError{
@@ -154,6 +237,11 @@ func (p *Parser) validateTask(task *Task, index int) error {
 		return err
 	}
 
+	// Validate loop
+	if err := validateLoop(task, fieldPrefix); err != nil {
+		return err
+	}
+
 	// Validate retries
 	if task.Retries < 0 {
 		return &ValidationError{
@@ -169,6 +257,114 @@ func (p *Parser) validateTask(task *Task, index int) error {
 		}
 	}
 
+	if task.Timeout < 0 {
+		return &ValidationError{
+			Field:   fieldPrefix + ".timeout",
+			Message: "timeout cannot be negative",
+		}
+	}
+
+	if task.Async < 0 || task.Poll < 0 {
+		return &ValidationError{
+			Field:   fieldPrefix + ".async",
+			Message: "async and poll cannot be negative",
+		}
+	}
+
+	if task.Poll > 0 && task.Async == 0 {
+		return &ValidationError{
+			Field:   fieldPrefix + ".poll",
+			Message: "poll requires async",
+		}
+	}
+
+	if task.Async > 0 && task.Action != ActionCommand {
+		return &ValidationError{
+			Field:   fieldPrefix + ".async",
+			Message: "async is only supported for command tasks",
+		}
+	}
+
+	return nil
+}
+
+// validateMeta validates a meta directive
+func validateMeta(task *Task, fieldPrefix string) error {
+	if task.Meta != MetaFlushHandlers {
+		return &ValidationError{
+			Field:   fieldPrefix + ".meta",
+			Message: fmt.Sprintf("unknown meta directive '%s'", task.Meta),
+		}
+	}
+	if task.Action != "" || len(task.Block) > 0 || task.Loop != nil || task.ParallelGroup != "" {
+		return &ValidationError{
+			Field:   fieldPrefix + ".meta",
+			Message: "a meta directive cannot have an action, block, loop or parallel group",
+		}
+	}
+	return nil
+}
+
+// validateBlock validates a block and its child tasks
+func (p *Parser) validateBlock(block *Task, fieldPrefix string) error {
+	if block.Action != "" {
+		return &ValidationError{
+			Field:   fieldPrefix + ".action",
+			Message: "a block cannot also define an action",
+		}
+	}
+
+	if block.ParallelGroup != "" {
+		return &ValidationError{
+			Field:   fieldPrefix + ".parallel_group",
+			Message: "blocks cannot run in a parallel group",
+		}
+	}
+
+	if block.Loop != nil {
+		return &ValidationError{
+			Field:   fieldPrefix + ".loop",
+			Message: "blocks cannot loop",
+		}
+	}
+
+	for i, child := range block.Block {
+		if err := p.validateTask(&child, fmt.Sprintf("%s.block[%d]", fieldPrefix, i)); err != nil {
+			return err
+		}
+	}
+	for i, child := range block.Rescue {
+		if err := p.validateTask(&child, fmt.Sprintf("%s.rescue[%d]", fieldPrefix, i)); err != nil {
+			return err
+		}
+	}
+	for i, child := range block.Always {
+		if err := p.validateTask(&child, fmt.Sprintf("%s.always[%d]", fieldPrefix, i)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateLoop checks the loop is a list or a variable reference
+func validateLoop(task *Task, fieldPrefix string) error {
+	switch task.Loop.(type) {
+	case nil, []interface{}, string:
+	default:
+		return &ValidationError{
+			Field:   fieldPrefix + ".loop",
+			Message: "loop must be a list or a variable reference",
+		}
+	}
+
+	if task.Loop != nil && task.ParallelGroup != "" {
+		return &ValidationError{
+			Field:   fieldPrefix + ".loop",
+			Message: "looping tasks cannot run in a parallel group",
+		}
+	}
+
 	return nil
 }
 
@@ -176,11 +372,42 @@ func (p *Parser) validateTask(task *Task, index int) error {
 func (p *Parser) validateActionParams(action string, params map[string]interface{}, fieldPrefix string) error {
 	switch action {
 	case ActionCommand:
-		// command action requires 'command' param
-		if _, ok := params["command"]; !ok {
+		// command action requires 'command' or 'argv' param
+		_, hasCommand := params["command"]
+		_, hasArgv := params["argv"]
+		if !hasCommand && !hasArgv {
 			return &ValidationError{
 				Field:   fieldPrefix + ".params.command",
-				Message: "command action requires 'command' parameter",
+				Message: "command action requires 'command' or 'argv' parameter",
+			}
+		}
+		if hasCommand && hasArgv {
+			return &ValidationError{
+				Field:   fieldPrefix + ".params.argv",
+				Message: "'command' and 'argv' are mutually exclusive",
+			}
+		}
+		if envFile, ok := params["env_file"]; ok {
+			if s, isString := envFile.(string); !isString || s == "" {
+				return &ValidationError{
+					Field:   fieldPrefix + ".params.env_file",
+					Message: "env_file must be a file path",
+				}
+			}
+		}
+		switch runOnce := params["run_once"].(type) {
+		case nil, bool:
+		case string:
+			if !ValidRunOnceKey(runOnce) {
+				return &ValidationError{
+					Field:   fieldPrefix + ".params.run_once",
+					Message: "run_once name may only contain letters, digits, '.', '_' and '-'",
+				}
+			}
+		default:
+			return &ValidationError{
+				Field:   fieldPrefix + ".params.run_once",
+				Message: "run_once must be true or a marker name",
 			}
 		}
 
@@ -203,11 +430,13 @@ func (p *Parser) validateActionParams(action string, params map[string]interface
 		}
 
 	case ActionSysctl:
-		// sysctl action requires 'name' param
-		if _, ok := params["name"]; !ok {
+		// sysctl action requires 'name' param, or a 'params' map of names to values
+		_, hasName := params["name"]
+		_, hasParams := params["params"]
+		if !hasName && !hasParams {
 			return &ValidationError{
 				Field:   fieldPrefix + ".params.name",
-				Message: "sysctl action requires 'name' parameter",
+				Message: "sysctl action requires 'name' or 'params' parameter",
 			}
 		}
 
@@ -268,6 +497,122 @@ func (p *Parser) validateActionParams(action string, params map[string]interface
 			}
 		}
 
+	case ActionAgentControl:
+		// agent_control action requires a known 'operation' param
+		op, _ := params["operation"].(string)
+		switch op {
+		case "update", "restart", "reenroll":
+		default:
+			return &ValidationError{
+				Field:   fieldPrefix + ".params.operation",
+				Message: "agent_control action requires 'operation' parameter (update, restart, reenroll)",
+			}
+		}
+
+	case ActionReplace:
+		// replace action requires 'path' and 'regexp' params
+		if _, ok := params["path"]; !ok {
+			return &ValidationError{
+				Field:   fieldPrefix + ".params.path",
+				Message: "replace action requires 'path' parameter",
+			}
+		}
+		if _, ok := params["regexp"]; !ok {
+			return &ValidationError{
+				Field:   fieldPrefix + ".params.regexp",
+				Message: "replace action requires 'regexp' parameter",
+			}
+		}
+
+	case ActionCopy:
+		// copy action requires 'dest' and exactly one of 'src' or 'content'
+		if _, ok := params["dest"]; !ok {
+			return &ValidationError{
+				Field:   fieldPrefix + ".params.dest",
+				Message: "copy action requires 'dest' parameter",
+			}
+		}
+		_, hasSrc := params["src"]
+		_, hasContent := params["content"]
+		if hasSrc == hasContent {
+			return &ValidationError{
+				Field:   fieldPrefix + ".params",
+				Message: "copy action requires exactly one of 'src' or 'content'",
+			}
+		}
+
+	case ActionFetch:
+		// fetch action requires 'path' param
+		if _, ok := params["path"]; !ok {
+			return &ValidationError{
+				Field:   fieldPrefix + ".params.path",
+				Message: "fetch action requires 'path' parameter",
+			}
+		}
+
+	case ActionKeyvalFile:
+		// keyval_file action requires 'path' and 'key' params
+		if _, ok := params["path"]; !ok {
+			return &ValidationError{
+				Field:   fieldPrefix + ".params.path",
+				Message: "keyval_file action requires 'path' parameter",
+			}
+		}
+		if _, ok := params["key"]; !ok {
+			return &ValidationError{
+				Field:   fieldPrefix + ".params.key",
+				Message: "keyval_file action requires 'key' parameter",
+			}
+		}
+
+	case ActionMaintenanceWindow:
+		// maintenance_window action requires 'name' param
+		if _, ok := params["name"]; !ok {
+			return &ValidationError{
+				Field:   fieldPrefix + ".params.name",
+				Message: "maintenance_window action requires 'name' parameter",
+			}
+		}
+
+	case ActionUser:
+		// user action requires 'name' param
+		if _, ok := params["name"]; !ok {
+			return &ValidationError{
+				Field:   fieldPrefix + ".params.name",
+				Message: "user action requires 'name' parameter",
+			}
+		}
+
+	case ActionWaitFor:
+		// wait_for action requires exactly one target
+		targets := 0
+		for _, key := range []string{"port", "path", "process"} {
+			if _, ok := params[key]; ok {
+				targets++
+			}
+		}
+		if targets != 1 {
+			return &ValidationError{
+				Field:   fieldPrefix + ".params",
+				Message: "wait_for action requires exactly one of 'port', 'path' or 'process'",
+			}
+		}
+
+	case ActionTemplate:
+		// template action requires 'src' and 'path' params
+		if _, ok := params["src"]; !ok {
+			return &ValidationError{
+				Field:   fieldPrefix + ".params.src",
+				Message: "template action requires 'src' parameter",
+			}
+		}
+		if _, ok := params["path"]; !ok {
+			return &ValidationError{
+				Field:   fieldPrefix + ".params.path",
+				Message: "template action requires 'path' parameter",
+			}
+		}
+
 	case ActionPackage:
 		// package action requires 'name' param
 		if _, ok := params["name"]; !ok {
@@ -303,20 +648,24 @@ func (p *Parser) validateActionPlatform(action, taskPlatform string) error {
 		if platform != PlatformDarwin {
 			return fmt.Errorf("defaults action is only available on macOS")
 		}
-	case ActionSettings, ActionPackage:
+	case ActionSettings:
 		if platform != PlatformAndroid {
-			return fmt.Errorf("%s action is only available on Android", action)
+			return fmt.Errorf("settings action is only available on Android")
 		}
 	}
 
 	return nil
 }
 
-// isSupportedVersion checks if a schema version is supported
+// isSupportedVersion checks if a playbook in the current model can have
+// this schema version: SchemaVersion or a newer minor version of it
 func (p *Parser) isSupportedVersion(version string) bool {
-	// For now, only support exact match
-	// In future, could support semantic versioning
-	return version == SchemaVersion || version == "1" || version == "1.0"
+	v, err := parseSchemaVersion(version)
+	if err != nil {
+		return false
+	}
+	current, _ := parseSchemaVersion(SchemaVersion)
+	return v.major == current.major && v.minor >= current.minor
 }
 
 // isValidPlatform checks if a platform name is valid
@@ -343,13 +692,29 @@ func (p *Parser) isPlatformSupported(platforms []string) bool {
 func (p *Parser) isValidAction(action string) bool {
 	switch action {
 	case ActionCommand, ActionFile, ActionLineinfile, ActionEnv, ActionService,
-		ActionRegistry, ActionSysctl, ActionDefaults, ActionSettings, ActionPackage:
+		ActionRegistry, ActionSysctl, ActionDefaults, ActionSettings, ActionPackage,
+		ActionTemplate, ActionWaitFor, ActionReplace, ActionUser, ActionCopy,
+		ActionFetch, ActionKeyvalFile, ActionMaintenanceWindow, ActionAgentControl:
 		return true
 	default:
 		return false
 	}
 }
 
+// usesAction reports whether any task, block section or rollback uses the action
+func usesAction(tasks []Task, action string) bool {
+	for _, task := range tasks {
+		if task.Action == action || usesAction(task.Block, action) ||
+			usesAction(task.Rescue, action) || usesAction(task.Always, action) {
+			return true
+		}
+		if task.Rollback != nil && usesAction([]Task{*task.Rollback}, action) {
+			return true
+		}
+	}
+	return false
+}
+
 // GetPlatform returns the current platform
 func (p *Parser) GetPlatform() string {
 	return p.platform
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package playbook
+
+import "os"
+
+// isElevated reports whether the agent process runs as root
+func isElevated() bool {
+	return os.Geteuid() == 0
+}
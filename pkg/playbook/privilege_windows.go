@@ -0,0 +1,10 @@
+//go:build windows
+
+package playbook
+
+import "golang.org/x/sys/windows"
+
+// isElevated reports whether the agent process runs with an elevated token
+func isElevated() bool {
+	return windows.GetCurrentProcessToken().IsElevated()
+}
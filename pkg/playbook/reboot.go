@@ -0,0 +1,64 @@
+package playbook
+
+import (
+	"context"
+	"fmt"
+)
+
+// RebootRequest describes a reboot needed after a playbook run, passed to
+// ExecutorConfig.OnRebootRequired
+type RebootRequest struct {
+	PlaybookID   string
+	PlaybookName string
+	Reason       string
+	Message      string // on_complete.message, if any
+}
+
+// completeReboot works out whether the run left the system needing a
+// reboot and, if the playbook asks for it, hands the reboot to the agent.
+// Failures to schedule are noted in the report but don't fail the run.
+func (e *Executor) completeReboot(ctx context.Context, run *executionState) {
+	report := run.report
+
+	switch {
+	case report.RebootRequired:
+		// A task asked for it
+	case run.playbook.RequiresReboot:
+		report.RebootRequired = true
+		report.RebootReason = "playbook requires a reboot"
+	case e.pendingReboot != nil && run.changed():
+		if pending, reason := e.pendingReboot(); pending {
+			report.RebootRequired = true
+			report.RebootReason = reason
+		}
+	}
+
+	onComplete := run.playbook.OnComplete
+	if !report.RebootRequired || onComplete == nil || !onComplete.RebootPrompt || e.onRebootRequired == nil {
+		return
+	}
+
+	at, err := e.onRebootRequired(ctx, RebootRequest{
+		PlaybookID:   report.PlaybookID,
+		PlaybookName: report.PlaybookName,
+		Reason:       report.RebootReason,
+		Message:      onComplete.Message,
+	})
+	if err != nil {
+		report.RebootReason = fmt.Sprintf("%s (reboot not scheduled: %v)", report.RebootReason, err)
+		return
+	}
+	if !at.IsZero() {
+		report.RebootScheduledAt = &at
+	}
+}
+
+// changed reports whether any task of the run made a change
+func (run *executionState) changed() bool {
+	for _, result := range run.report.TaskResults {
+		if result.Changed {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package playbook
+
+import (
+	"container/list"
+	"regexp"
+	"sync"
+)
+
+// Maximum number of compiled patterns kept by CompileRegex
+const regexCacheSize = 256
+
+// regexCache is a bounded LRU of compiled regular expressions, so patterns
+// used repeatedly by lineinfile, blockinfile and conditions are compiled once
+type regexCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List // most recently used at the front
+	entries map[string]*list.Element
+}
+
+type regexCacheEntry struct {
+	pattern string
+	regex   *regexp.Regexp
+}
+
+var compiledRegexes = newRegexCache(regexCacheSize)
+
+func newRegexCache(size int) *regexCache {
+	return &regexCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// CompileRegex compiles a pattern, reusing a cached result when the same
+// pattern was compiled recently. Compiled regexps are safe for concurrent use.
+func CompileRegex(pattern string) (*regexp.Regexp, error) {
+	return compiledRegexes.compile(pattern)
+}
+
+func (c *regexCache) compile(pattern string) (*regexp.Regexp, error) {
+	c.mu.Lock()
+	if elem, ok := c.entries[pattern]; ok {
+		c.order.MoveToFront(elem)
+		c.mu.Unlock()
+		return elem.Value.(*regexCacheEntry).regex, nil
+	}
+	c.mu.Unlock()
+
+	// Compile outside the lock; invalid patterns are not cached
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[pattern]; ok {
+		c.order.MoveToFront(elem)
+		return elem.Value.(*regexCacheEntry).regex, nil
+	}
+	c.entries[pattern] = c.order.PushFront(&regexCacheEntry{pattern: pattern, regex: regex})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*regexCacheEntry).pattern)
+	}
+	return regex, nil
+}
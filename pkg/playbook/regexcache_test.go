@@ -0,0 +1,57 @@
+package playbook
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// BenchmarkLineinfileLargeFile compares cached and uncached compilation of
+// a lineinfile-style regex used to find the last matching line of a large
+// config file, as a looped lineinfile task does on every item
+func BenchmarkLineinfileLargeFile(b *testing.B) {
+	var sb strings.Builder
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&sb, "# setting %d\nkey_%d = value_%d\n", i, i, i)
+	}
+	path := filepath.Join(b.TempDir(), "large.conf")
+	if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+		b.Fatal(err)
+	}
+
+	const pattern = `^\s*(?:#\s*)?key_(\d+)\s*=\s*(?:"[^"]*"|\S+)\s*(?:#.*)?$`
+
+	compilers := []struct {
+		name    string
+		compile func(string) (*regexp.Regexp, error)
+	}{
+		{"cached", CompileRegex},
+		{"uncached", regexp.Compile},
+	}
+	for _, c := range compilers {
+		b.Run(c.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				content, err := os.ReadFile(path)
+				if err != nil {
+					b.Fatal(err)
+				}
+				regex, err := c.compile(pattern)
+				if err != nil {
+					b.Fatal(err)
+				}
+				last := -1
+				for n, line := range strings.Split(string(content), "\n") {
+					if regex.MatchString(line) {
+						last = n
+					}
+				}
+				if last < 0 {
+					b.Fatal("no line matched")
+				}
+			}
+		})
+	}
+}
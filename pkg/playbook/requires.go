@@ -0,0 +1,194 @@
+package playbook
+
+import (
+	"fmt"
+)
+
+// requirementStatus returns the status recorded for the task with id, and
+// whether it has finished
+func (run *executionState) requirementStatus(id string) (TaskStatus, bool) {
+	status, ok := run.taskStatus[id]
+	return status, ok
+}
+
+// setTaskStatus records how the task with id ended, for tasks that require it
+func (run *executionState) setTaskStatus(id string, status TaskStatus) {
+	if id != "" {
+		run.taskStatus[id] = status
+	}
+}
+
+// requirementOutcome is the status a result counts as for tasks that
+// require it. A task skipped because its work was already done (creates,
+// removes, run_once) satisfies requirements like a completed one; other
+// skips don't.
+func requirementOutcome(result *TaskResult) TaskStatus {
+	if result.Status == TaskStatusSkipped && result.SkipReason == SkipReasonAlreadySatisfied {
+		return TaskStatusCompleted
+	}
+	return result.Status
+}
+
+// unmetRequirement returns why task can't run because a task it requires
+// did not complete successfully, or "" when every required task completed
+func unmetRequirement(task *Task, status func(id string) (TaskStatus, bool)) string {
+	for _, id := range task.Requires {
+		s, ok := status(id)
+		if !ok {
+			return fmt.Sprintf("Skipped: required task '%s' did not run", id)
+		}
+		if s != TaskStatusCompleted {
+			return fmt.Sprintf("Skipped: required task '%s' did not complete (%s)", id, s)
+		}
+	}
+	return ""
+}
+
+// requiresValidator checks that every requires entry names a task that
+// finishes before the requiring task starts: a task earlier in the
+// playbook, or another task of the same parallel group, without cycles
+type requiresValidator struct {
+	seen map[string]bool // IDs of tasks that run earlier
+}
+
+// validateRequires checks the requires of tasks and, against every task ID,
+// of handlers, which run after the tasks
+func validateRequires(pb *Playbook) error {
+	v := &requiresValidator{seen: make(map[string]bool)}
+	if err := v.tasks(pb.Tasks, "tasks"); err != nil {
+		return err
+	}
+	for i := range pb.Handlers {
+		if err := v.check(&pb.Handlers[i], fmt.Sprintf("handlers[%d]", i), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tasks validates a list of tasks in execution order
+func (v *requiresValidator) tasks(tasks []Task, prefix string) error {
+	for i := 0; i < len(tasks); i++ {
+		task := &tasks[i]
+		field := fmt.Sprintf("%s[%d]", prefix, i)
+
+		if task.ParallelGroup != "" {
+			end := i + 1
+			for end < len(tasks) && tasks[end].ParallelGroup == task.ParallelGroup {
+				end++
+			}
+			if err := v.parallelGroup(tasks[i:end], prefix, i); err != nil {
+				return err
+			}
+			i = end - 1
+			continue
+		}
+
+		if err := v.check(task, field, nil); err != nil {
+			return err
+		}
+		if len(task.Block) > 0 {
+			if err := v.tasks(task.Block, field+".block"); err != nil {
+				return err
+			}
+			if err := v.tasks(task.Rescue, field+".rescue"); err != nil {
+				return err
+			}
+			if err := v.tasks(task.Always, field+".always"); err != nil {
+				return err
+			}
+		}
+		v.add(task.ID)
+	}
+	return nil
+}
+
+// parallelGroup validates a parallel group whose first task is at
+// prefix[offset]. Tasks of the group may require each other, as long as
+// the requirements form no cycle.
+func (v *requiresValidator) parallelGroup(group []Task, prefix string, offset int) error {
+	peers := make(map[string]int)
+	for i, task := range group {
+		if task.ID != "" {
+			peers[task.ID] = i
+		}
+	}
+
+	for i := range group {
+		if err := v.check(&group[i], fmt.Sprintf("%s[%d]", prefix, offset+i), peers); err != nil {
+			return err
+		}
+	}
+
+	// Depth-first search for a cycle among the group's requirements
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, len(group))
+	var visit func(i int) error
+	visit = func(i int) error {
+		state[i] = visiting
+		for _, id := range group[i].Requires {
+			j, ok := peers[id]
+			if !ok {
+				continue
+			}
+			switch state[j] {
+			case visiting:
+				return &ValidationError{
+					Field:   fmt.Sprintf("%s[%d].requires", prefix, offset+i),
+					Message: fmt.Sprintf("requirement cycle in parallel group '%s' through task '%s'", group[i].ParallelGroup, id),
+				}
+			case unvisited:
+				if err := visit(j); err != nil {
+					return err
+				}
+			}
+		}
+		state[i] = done
+		return nil
+	}
+	for i := range group {
+		if state[i] == unvisited {
+			if err := visit(i); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, task := range group {
+		v.add(task.ID)
+	}
+	return nil
+}
+
+// check validates the requires of one task. peers are the IDs of the other
+// tasks in its parallel group, if any.
+func (v *requiresValidator) check(task *Task, field string, peers map[string]int) error {
+	for i, id := range task.Requires {
+		entry := fmt.Sprintf("%s.requires[%d]", field, i)
+		_, isPeer := peers[id]
+		switch {
+		case id == "":
+			return &ValidationError{Field: entry, Message: "required task ID cannot be empty"}
+		case id == task.ID:
+			return &ValidationError{Field: entry, Message: fmt.Sprintf("task '%s' cannot require itself", id)}
+		case v.seen[id]:
+		case isPeer:
+		default:
+			return &ValidationError{
+				Field:   entry,
+				Message: fmt.Sprintf("required task '%s' must be defined earlier in the playbook or in the same parallel group", id),
+			}
+		}
+	}
+	return nil
+}
+
+func (v *requiresValidator) add(id string) {
+	if id != "" {
+		v.seen[id] = true
+	}
+}
@@ -0,0 +1,79 @@
+package playbook
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// runOnceKeyPattern restricts run_once keys to safe file names
+var runOnceKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// ValidRunOnceKey reports whether key can name a run_once marker
+func ValidRunOnceKey(key string) bool {
+	return runOnceKeyPattern.MatchString(key) && key != "." && key != ".."
+}
+
+// RunOnceMarker records a run_once command that completed on this device
+type RunOnceMarker struct {
+	Key         string
+	CompletedAt time.Time
+}
+
+// HasRunOnceMarker reports whether the run_once command with key has
+// already completed
+func HasRunOnceMarker(dir, key string) bool {
+	_, err := os.Stat(filepath.Join(dir, key))
+	return err == nil
+}
+
+// WriteRunOnceMarker records that the run_once command with key completed
+func WriteRunOnceMarker(dir, key string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	data := []byte(time.Now().UTC().Format(time.RFC3339) + "\n")
+	return os.WriteFile(filepath.Join(dir, key), data, 0600)
+}
+
+// ListRunOnceMarkers returns the recorded run_once markers sorted by key
+func ListRunOnceMarkers(dir string) ([]RunOnceMarker, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var markers []RunOnceMarker
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !ValidRunOnceKey(entry.Name()) {
+			continue
+		}
+		marker := RunOnceMarker{Key: entry.Name()}
+		if info, err := entry.Info(); err == nil {
+			marker.CompletedAt = info.ModTime()
+		}
+		markers = append(markers, marker)
+	}
+	sort.Slice(markers, func(i, j int) bool { return markers[i].Key < markers[j].Key })
+	return markers, nil
+}
+
+// ResetRunOnceMarker removes a marker so its command runs again
+func ResetRunOnceMarker(dir, key string) error {
+	if !ValidRunOnceKey(key) {
+		return fmt.Errorf("invalid run_once key '%s'", key)
+	}
+	if err := os.Remove(filepath.Join(dir, key)); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no run_once marker '%s'", key)
+		}
+		return err
+	}
+	return nil
+}
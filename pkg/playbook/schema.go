@@ -0,0 +1,115 @@
+package playbook
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// schemaMigration upgrades a raw playbook document from one schema version
+// to the next, e.g. by renaming fields, before it is decoded into the
+// current in-memory model
+type schemaMigration struct {
+	from    string
+	to      string
+	migrate func(doc *yaml.Node) error
+}
+
+// schemaMigrations upgrade older playbooks step by step to SchemaVersion.
+// When the schema changes, bump SchemaVersion and append a migration from
+// the previous version so playbooks written for it keep running.
+var schemaMigrations = []schemaMigration{}
+
+// SupportedSchemaVersions returns the schema versions this agent can run:
+// SchemaVersion and every version it can migrate from
+func SupportedSchemaVersions() []string {
+	versions := make([]string, 0, len(schemaMigrations)+1)
+	for _, m := range schemaMigrations {
+		versions = append(versions, m.from)
+	}
+	return append(versions, SchemaVersion)
+}
+
+// schemaVersion is a parsed "major.minor" schema version
+type schemaVersion struct {
+	major, minor int
+}
+
+// parseSchemaVersion parses "1" or "1.0" style versions
+func parseSchemaVersion(version string) (schemaVersion, error) {
+	majorStr, minorStr, hasMinor := strings.Cut(strings.TrimSpace(version), ".")
+	major, err := strconv.Atoi(majorStr)
+	if err != nil || major < 0 {
+		return schemaVersion{}, fmt.Errorf("invalid version '%s', expected 'major.minor'", version)
+	}
+	var minor int
+	if hasMinor {
+		minor, err = strconv.Atoi(minorStr)
+		if err != nil || minor < 0 {
+			return schemaVersion{}, fmt.Errorf("invalid version '%s', expected 'major.minor'", version)
+		}
+	}
+	return schemaVersion{major, minor}, nil
+}
+
+func (v schemaVersion) String() string {
+	return fmt.Sprintf("%d.%d", v.major, v.minor)
+}
+
+// migrateSchema upgrades doc, a playbook written for version, to
+// SchemaVersion and returns the version it was read as.
+//
+// Playbooks for a newer minor version of the same major version are
+// accepted as is: minor versions only add optional fields, which this agent
+// ignores, or actions it rejects during validation. Other versions without
+// a migration path are rejected.
+func migrateSchema(doc *yaml.Node, version string) (string, error) {
+	if version == "" {
+		return SchemaVersion, nil
+	}
+
+	v, err := parseSchemaVersion(version)
+	if err != nil {
+		return "", err
+	}
+	current, _ := parseSchemaVersion(SchemaVersion)
+
+	if v.major == current.major && v.minor >= current.minor {
+		return v.String(), nil
+	}
+
+	// Apply the migrations in order, starting from the playbook's version
+	from := v.String()
+	for _, m := range schemaMigrations {
+		if m.from != from {
+			continue
+		}
+		if err := m.migrate(doc); err != nil {
+			return "", fmt.Errorf("failed to migrate playbook from version %s to %s: %w", m.from, m.to, err)
+		}
+		from = m.to
+	}
+	if from != SchemaVersion {
+		return "", fmt.Errorf("version '%s' is not supported: this agent runs schema %d.x playbooks (supported: %s)",
+			version, current.major, strings.Join(SupportedSchemaVersions(), ", "))
+	}
+	return SchemaVersion, nil
+}
+
+// documentVersion returns the top-level 'version' of a playbook document
+func documentVersion(doc *yaml.Node) string {
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		doc = doc.Content[0]
+	}
+	if doc.Kind != yaml.MappingNode {
+		return ""
+	}
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		if doc.Content[i].Value == "version" {
+			return doc.Content[i+1].Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,46 @@
+package playbook
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TagAlways marks a task that runs whatever tags are selected. It is still
+// excluded by a matching skip tag.
+const TagAlways = "always"
+
+// tagSet builds a lookup set from a tag list (nil when empty)
+func tagSet(tags []string) map[string]bool {
+	if len(tags) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		set[tag] = true
+	}
+	return set
+}
+
+// tagSkipReason returns why the execution's tag filters exclude a task, or
+// "" if it should run. Block children have already inherited block tags.
+func (e *Executor) tagSkipReason(task *Task) string {
+	for _, tag := range task.Tags {
+		if e.skipTags[tag] {
+			return fmt.Sprintf("Skipped: tag '%s' is excluded", tag)
+		}
+	}
+
+	if len(e.tags) == 0 {
+		return ""
+	}
+	for _, tag := range task.Tags {
+		if e.tags[tag] || tag == TagAlways {
+			return ""
+		}
+	}
+
+	if len(task.Tags) == 0 {
+		return "Skipped: task has no tags and a tag filter is set"
+	}
+	return fmt.Sprintf("Skipped: tags '%s' don't match the tag filter", strings.Join(task.Tags, "', '"))
+}
@@ -27,6 +27,9 @@ type Playbook struct {
 	Platforms       []string `yaml:"platforms"`                  // windows, linux, darwin, android
 	MinAgentVersion string   `yaml:"min_agent_version,omitempty"` // Minimum agent version required
 
+	// Scope - "control" marks an agent lifecycle playbook (see ActionAgentControl)
+	Scope string `yaml:"scope,omitempty"`
+
 	// Execution hints
 	RequiresReboot bool `yaml:"requires_reboot,omitempty"`
 	RequiresAdmin  bool `yaml:"requires_admin,omitempty"`
@@ -91,6 +94,40 @@ type Task struct {
 	// Conditional execution
 	When string `yaml:"when,omitempty"` // Condition expression
 
+	// Privilege requirement - task must run with root/Administrator rights
+	Become bool `yaml:"become,omitempty"`
+
+	// Tags for grouping and selective execution
+	Tags []string `yaml:"tags,omitempty"`
+
+	// Meta is an executor directive instead of an action, e.g.
+	// "flush_handlers" to run notified handlers at this point
+	Meta string `yaml:"meta,omitempty"`
+
+	// Block groups child tasks that share when/become/tags/ignore_errors.
+	// The block's condition is evaluated once and its attributes are inherited
+	// by every child. A block task has no action of its own.
+	Block []Task `yaml:"block,omitempty"`
+
+	// Rescue runs when a task in the block fails, like a catch clause; a
+	// rescue that completes clears the failure. Always runs after the block
+	// and rescue whether or not they failed. Both require a block.
+	Rescue []Task `yaml:"rescue,omitempty"`
+	Always []Task `yaml:"always,omitempty"`
+
+	// Requires lists task IDs that must complete successfully before this
+	// task runs; otherwise it is skipped. They must be defined earlier, or
+	// in the same parallel group, where the task waits for them.
+	Requires []string `yaml:"requires,omitempty"`
+
+	// Consecutive tasks with the same parallel group run concurrently.
+	// Their results are recorded in playbook order once all have finished.
+	ParallelGroup string `yaml:"parallel_group,omitempty"`
+
+	// Loop runs the task once per item, exposed as {{ item }}. Either a
+	// list, or a string resolving to a YAML list (or one item per line).
+	Loop interface{} `yaml:"loop,omitempty"`
+
 	// The action to perform
 	Action string                 `yaml:"action"` // command, file, registry, sysctl, etc.
 	Params map[string]interface{} `yaml:"params"` // Action-specific parameters
@@ -105,24 +142,59 @@ type Task struct {
 	IgnoreErrors bool `yaml:"ignore_errors,omitempty"`
 	Retries      int  `yaml:"retries,omitempty"`
 	RetryDelay   int  `yaml:"retry_delay,omitempty"` // Seconds
+	Timeout      int  `yaml:"timeout,omitempty"`     // Seconds per attempt, 0 = unlimited
+
+	// Async runs a long command in the background for at most this many
+	// seconds, checking for completion every Poll seconds (default 10) and
+	// emitting progress meanwhile. Replaces timeout for the task.
+	Async int `yaml:"async,omitempty"`
+	Poll  int `yaml:"poll,omitempty"`
 
 	// Handler notification
 	Notify []string `yaml:"notify,omitempty"` // Handler names to trigger
 
 	// Rollback on failure
 	Rollback *Task `yaml:"rollback,omitempty"`
+
+	// RequiresReboot marks the system as needing a reboot when this task
+	// reports a change, e.g. a kernel or driver update
+	RequiresReboot bool `yaml:"requires_reboot,omitempty"`
+
+	// Block section this task was expanded from, set by the executor
+	section string
 }
 
+// Meta directives
+const (
+	MetaFlushHandlers = "flush_handlers" // Run notified handlers now instead of at the end
+)
+
+// Block sections, reported on the results of tasks inside a block
+const (
+	SectionBlock  = "block"
+	SectionRescue = "rescue"
+	SectionAlways = "always"
+)
+
 // TaskResult holds the outcome of a task execution
 type TaskResult struct {
 	// Task identification
 	TaskName string `json:"task_name"`
 	TaskID   string `json:"task_id,omitempty"`
 
+	// Block section the task ran in: block, rescue or always
+	Section string `json:"section,omitempty"`
+
 	// Execution status
 	Status  TaskStatus `json:"status"`
 	Changed bool       `json:"changed"` // Did the task make changes?
 
+	// Set when the change needs a reboot to take effect
+	RebootRequired bool `json:"reboot_required,omitempty"`
+
+	// Why a skipped task didn't run, for grouping skips; Message has the details
+	SkipReason SkipReason `json:"skip_reason,omitempty"`
+
 	// Output from command actions
 	Stdout   string `json:"stdout,omitempty"`
 	Stderr   string `json:"stderr,omitempty"`
@@ -139,6 +211,9 @@ type TaskResult struct {
 	StartTime time.Time `json:"start_time"`
 	EndTime   time.Time `json:"end_time"`
 	Duration  string    `json:"duration"` // String like "1.5s", not time.Duration
+
+	// Stdout parsed as JSON on first use, set up when the result is registered
+	parsedStdout *stdoutJSON
 }
 
 // TaskStatus represents the execution status of a task
@@ -150,8 +225,36 @@ const (
 	TaskStatusCompleted TaskStatus = "completed"
 	TaskStatusFailed    TaskStatus = "failed"
 	TaskStatusSkipped   TaskStatus = "skipped"
+	TaskStatusRejected  TaskStatus = "rejected" // Not run: action not permitted for this execution
 )
 
+// SkipReason categorizes why a task was skipped
+type SkipReason string
+
+const (
+	SkipReasonPlatformFilter   SkipReason = "platform_filter"   // Task or block platform doesn't match
+	SkipReasonConditionFalse   SkipReason = "condition_false"   // Task or block when evaluated to false
+	SkipReasonTagFilter        SkipReason = "tag_filter"        // Excluded by the job's tags/skip tags
+	SkipReasonAlreadySatisfied SkipReason = "already_satisfied" // The handler found nothing to do
+	SkipReasonNoLoopItems      SkipReason = "no_loop_items"     // Loop resolved to an empty list
+	SkipReasonRescueNotNeeded  SkipReason = "rescue_not_needed" // Rescue section of a block that didn't fail
+	SkipReasonRequirementUnmet SkipReason = "requirement_unmet" // A task in requires did not complete
+)
+
+// Progress is an overall progress update emitted after each task finishes.
+// Loop iterations count as separate tasks and skipped tasks count as done.
+type Progress struct {
+	TaskName        string     `json:"task_name"`
+	Status          TaskStatus `json:"status"`
+	TasksDone       int        `json:"tasks_done"`
+	TasksTotal      int        `json:"tasks_total"`
+	ProgressPercent float64    `json:"progress_percent"`
+
+	// How long the current task has been running, for updates emitted
+	// while an async task is still in progress
+	Elapsed string `json:"elapsed,omitempty"`
+}
+
 // ErrorHandler defines how to handle playbook errors
 type ErrorHandler struct {
 	Strategy     string `yaml:"strategy"`      // stop, continue, rollback
@@ -174,6 +277,16 @@ type ExecutionReport struct {
 	// Device identification
 	DeviceID string `json:"device_id"`
 
+	// Test run labeling - DryRun is true when no changes were applied
+	IsTestRun bool `json:"is_test_run,omitempty"`
+	DryRun    bool `json:"dry_run,omitempty"`
+
+	// Agent and system the playbook ran on
+	Environment *ExecutionEnvironment `json:"environment,omitempty"`
+
+	// Operator-defined device labels (environment, team, location)
+	Labels map[string]string `json:"labels,omitempty"`
+
 	// Security verification record - CRITICAL for audit
 	Verification VerificationRecord `json:"verification"`
 
@@ -190,11 +303,37 @@ type ExecutionReport struct {
 	// Detailed results
 	TaskResults []TaskResult `json:"task_results"`
 
+	// OutputTruncated is set when task output exceeded the playbook-wide budget
+	OutputTruncated bool `json:"output_truncated,omitempty"`
+
 	// Error information (if failed)
 	ErrorMessage string `json:"error_message,omitempty"`
+	ErrorCode    string `json:"error_code,omitempty"` // machine-readable reason, see ErrorCode* constants
 
 	// Post-execution
-	RebootRequired bool `json:"reboot_required"`
+	RebootRequired    bool       `json:"reboot_required"`
+	RebootReason      string     `json:"reboot_reason,omitempty"`
+	RebootScheduledAt *time.Time `json:"reboot_scheduled_at,omitempty"`
+
+	// Scratch directory kept after a failure for debugging
+	RetainedWorkdir string `json:"retained_workdir,omitempty"`
+}
+
+// Error codes reported in ExecutionReport.ErrorCode
+const (
+	// ErrorCodeMissingTrustAnchor: the agent has no usable server public key,
+	// so it cannot verify playbooks. The device must be re-enrolled.
+	ErrorCodeMissingTrustAnchor = "missing_trust_anchor"
+)
+
+// ExecutionEnvironment describes the agent and system that ran a playbook
+type ExecutionEnvironment struct {
+	AgentVersion string `json:"agent_version,omitempty"`
+	Platform     string `json:"platform"`
+	Arch         string `json:"arch"`
+	Distro       string `json:"distro,omitempty"` // e.g. "ubuntu 22.04"
+	Elevated     bool   `json:"elevated"`
+	DryRun       bool   `json:"dry_run"`
 }
 
 // VerificationRecord documents the security checks performed
@@ -206,7 +345,8 @@ type VerificationRecord struct {
 	HashVerified   bool   `json:"hash_verified"`
 
 	// Signature verification
-	SignatureVerified bool `json:"signature_verified"`
+	SignatureVerified bool   `json:"signature_verified"`
+	SignatureKeyID    string `json:"signature_key_id,omitempty"` // KeyID of the key that matched
 
 	// Approval status
 	ApprovalStatus   string `json:"approval_status"`
@@ -222,16 +362,34 @@ type VerificationRecord struct {
 
 // Action types supported by the playbook engine
 const (
-	ActionCommand    = "command"    // Execute shell command
-	ActionFile       = "file"       // File operations
-	ActionLineinfile = "lineinfile" // Modify lines in file
-	ActionEnv        = "env"        // Environment variables
-	ActionService    = "service"    // Service management
-	ActionRegistry   = "registry"   // Windows registry (Windows only)
-	ActionSysctl     = "sysctl"     // Kernel parameters (Linux only)
-	ActionDefaults   = "defaults"   // macOS defaults (macOS only)
-	ActionSettings   = "settings"   // Android settings (Android only)
-	ActionPackage    = "package"    // Package management (Android only)
+	ActionCommand           = "command"            // Execute shell command
+	ActionFile              = "file"               // File operations
+	ActionLineinfile        = "lineinfile"         // Modify lines in file
+	ActionEnv               = "env"                // Environment variables
+	ActionService           = "service"            // Service management
+	ActionRegistry          = "registry"           // Windows registry (Windows only)
+	ActionSysctl            = "sysctl"             // Kernel parameters (Linux only)
+	ActionDefaults          = "defaults"           // macOS defaults (macOS only)
+	ActionSettings          = "settings"           // Android settings (Android only)
+	ActionPackage           = "package"            // Package management (apt/dnf/yum, brew, winget/choco)
+	ActionTemplate          = "template"           // Render a text/template file
+	ActionWaitFor           = "wait_for"           // Wait for a port, file or process
+	ActionReplace           = "replace"            // Regex search-and-replace across a file
+	ActionUser              = "user"               // Local user accounts
+	ActionCopy              = "copy"               // Copy files, directories or inline content
+	ActionFetch             = "fetch"              // Upload a file to the server as a job artifact
+	ActionKeyvalFile        = "keyval_file"        // Set or remove entries in key/value config files
+	ActionMaintenanceWindow = "maintenance_window" // Recurring maintenance reboot schedule
+
+	// ActionAgentControl manages the agent itself (update, restart, reenroll).
+	// It is served by an internal handler that cannot be registered through
+	// RegisterHandler and only runs in approved playbooks with ScopeControl.
+	ActionAgentControl = "agent_control"
+)
+
+// Playbook scopes
+const (
+	ScopeControl = "control" // Agent lifecycle playbook - may use agent_control
 )
 
 // Platforms supported
@@ -250,3 +408,41 @@ const (
 	StatusDeprecated = "deprecated"
 	StatusTest       = "test" // For test runs authorized by admin/developer
 )
+
+// Engine features reported to the server in the capabilities report
+const (
+	FeatureDryRun      = "dry_run"    // Simulated execution without changes
+	FeatureBlocks      = "blocks"     // Grouped tasks with shared options
+	FeatureRescue      = "rescue"     // Block rescue/always sections
+	FeatureHandlers    = "handlers"   // Notified handlers run after tasks
+	FeatureRollback    = "rollback"   // Per-task rollback on failure
+	FeatureConditions  = "conditions" // "when" expressions
+	FeatureRegister    = "register"   // Task results stored as variables
+	FeatureBecome      = "become"     // Elevated privilege requirement
+	FeatureTestRuns    = "test_runs"  // Unapproved test playbooks
+	FeatureControlPlay = "control"    // Agent lifecycle playbooks (agent_control)
+	FeatureLoops       = "loops"      // Tasks repeated per loop item
+)
+
+// Features lists the engine features supported by this agent
+var Features = []string{
+	FeatureDryRun,
+	FeatureBlocks,
+	FeatureRescue,
+	FeatureHandlers,
+	FeatureRollback,
+	FeatureConditions,
+	FeatureRegister,
+	FeatureBecome,
+	FeatureTestRuns,
+	FeatureLoops,
+}
+
+// Capabilities describes what an executor can run on this device
+type Capabilities struct {
+	SchemaVersion  string   `json:"schema_version"`
+	SchemaVersions []string `json:"schema_versions,omitempty"` // all versions accepted, incl. migrated ones
+	Platform       string   `json:"platform"`
+	Actions        []string `json:"actions"`
+	Features       []string `json:"features"`
+}
@@ -1,25 +1,52 @@
 package playbook
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync"
 )
 
 // Variable patterns
 var (
-	// {{ variable }} - playbook variables and built-ins
-	varPattern = regexp.MustCompile(`\{\{\s*([a-zA-Z_][a-zA-Z0-9_\.]*)\s*\}\}`)
+	// {{ variable }} - playbook variables and built-ins, optionally
+	// followed by filters: {{ variable | default("x") | upper }}. Names may
+	// index into lists: {{ result.json.items[0].name }}
+	varPattern = regexp.MustCompile(`\{\{\s*([a-zA-Z_](?:[a-zA-Z0-9_\.]|\[\d+\])*)((?:\s*\|\s*[a-z_]+(?:\(\s*(?:"(?:[^"\\]|\\.)*"|'[^']*'|[^()"'\s]*)\s*\))?)*)\s*\}\}`)
 
 	// ${ENV_VAR} - environment variables
 	envPattern = regexp.MustCompile(`\$\{([a-zA-Z_][a-zA-Z0-9_]*)\}`)
 )
 
-// Variables manages variable resolution for playbook execution
+// BuiltinJobWorkdir is the built-in variable holding the per-job scratch directory
+const BuiltinJobWorkdir = "job_workdir"
+
+// BuiltinDefaultShell is the built-in variable naming the shell used by
+// command tasks that don't set one ("" = platform default)
+const BuiltinDefaultShell = "default_shell"
+
+// BuiltinRunOnceDir is the built-in variable holding the directory of
+// run_once markers ("" = run_once unavailable)
+const BuiltinRunOnceDir = "run_once_dir"
+
+// BuiltinJobID is the built-in variable holding the server job ID
+const BuiltinJobID = "job_id"
+
+// BuiltinFailedTask is the built-in variable naming the task whose failure
+// triggered the running rescue section
+const BuiltinFailedTask = "failed_task"
+
+// Variables manages variable resolution for playbook execution.
+// It is safe for concurrent use by parallel tasks.
 type Variables struct {
+	// Shared with loop scopes created by WithItem
+	mu *sync.RWMutex
+
 	// User-defined variables from playbook
 	userVars map[string]string
 
@@ -28,11 +55,18 @@ type Variables struct {
 
 	// Built-in variables (platform, paths, etc.)
 	builtins map[string]string
+
+	// Loop-scoped variables (item, item.<key>, item_index) - read-only
+	locals map[string]string
+
+	// Uploads files for the current job (nil when not running a server job)
+	artifacts ArtifactUploader
 }
 
 // NewVariables creates a new variable context
 func NewVariables() *Variables {
 	v := &Variables{
+		mu:          &sync.RWMutex{},
 		userVars:    make(map[string]string),
 		taskResults: make(map[string]*TaskResult),
 		builtins:    make(map[string]string),
@@ -64,8 +98,99 @@ func (v *Variables) initBuiltins() {
 	v.builtins["path_sep"] = string(filepath.Separator)
 }
 
+// SetBuiltin sets a built-in variable provided by the executor
+func (v *Variables) SetBuiltin(name, value string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.builtins[name] = value
+}
+
+// JobWorkdir returns the per-job scratch directory, or "" if none was created
+func (v *Variables) JobWorkdir() string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.builtins[BuiltinJobWorkdir]
+}
+
+// DefaultShell returns the configured default shell for command tasks,
+// or "" to use the platform default
+func (v *Variables) DefaultShell() string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.builtins[BuiltinDefaultShell]
+}
+
+// RunOnceDir returns the directory of run_once markers, or "" if run_once
+// is unavailable
+func (v *Variables) RunOnceDir() string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.builtins[BuiltinRunOnceDir]
+}
+
+// SetArtifactUploader sets where UploadArtifact sends files
+func (v *Variables) SetArtifactUploader(uploader ArtifactUploader) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.artifacts = uploader
+}
+
+// UploadArtifact sends a file collected by a task to the server as an
+// artifact of the current job and returns its artifact ID
+func (v *Variables) UploadArtifact(name string, data []byte) (string, error) {
+	v.mu.RLock()
+	uploader := v.artifacts
+	jobID := v.builtins[BuiltinJobID]
+	v.mu.RUnlock()
+
+	if uploader == nil || jobID == "" {
+		return "", fmt.Errorf("artifact upload is not available outside a server job")
+	}
+	return uploader.UploadArtifact(jobID, name, data)
+}
+
+// WithItem returns a scope for one loop iteration that resolves
+// {{ item }}, {{ item_index }} and, for map items, {{ item.<key> }}.
+// All other variables and registered results are shared with v.
+func (v *Variables) WithItem(item interface{}, index int) *Variables {
+	locals := map[string]string{
+		"item":       formatValue(item),
+		"item_index": strconv.Itoa(index),
+	}
+	if fields, ok := item.(map[string]interface{}); ok {
+		for key, field := range fields {
+			locals["item."+key] = formatValue(field)
+		}
+	}
+
+	scope := *v
+	scope.locals = locals
+	return &scope
+}
+
+// formatValue converts a YAML value to its variable string form.
+// Lists and maps are rendered as JSON.
+func formatValue(value interface{}) string {
+	switch val := value.(type) {
+	case string:
+		return val
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprint(val)
+		}
+		return string(data)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
 // SetUserVars sets variables from the playbook's variables section
 func (v *Variables) SetUserVars(vars map[string]string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
 	for key, value := range vars {
 		// Resolve any environment variables in the value
 		resolved := v.resolveEnvVars(value)
@@ -75,16 +200,31 @@ func (v *Variables) SetUserVars(vars map[string]string) {
 
 // SetTaskResult stores a task result for later reference
 func (v *Variables) SetTaskResult(name string, result *TaskResult) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if result != nil && result.parsedStdout == nil {
+		result.parsedStdout = &stdoutJSON{}
+	}
 	v.taskResults[name] = result
 }
 
 // Set sets a single variable
 func (v *Variables) Set(name, value string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
 	v.userVars[name] = value
 }
 
 // Get retrieves a variable value
 func (v *Variables) Get(name string) (string, bool) {
+	// Loop variables shadow everything else
+	if val, ok := v.locals[name]; ok {
+		return val, true
+	}
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
 	// Check user vars first
 	if val, ok := v.userVars[name]; ok {
 		return val, true
@@ -96,8 +236,48 @@ func (v *Variables) Get(name string) (string, bool) {
 	return "", false
 }
 
+// Defined reports whether a reference names something that exists: a
+// variable or built-in, an environment variable (env.NAME), a registered
+// task result or one of its properties. Unlike substitution, an empty
+// value still counts as defined.
+func (v *Variables) Defined(name string) bool {
+	if _, ok := v.Get(name); ok {
+		return true
+	}
+	if strings.HasPrefix(name, "env.") {
+		_, ok := os.LookupEnv(strings.TrimPrefix(name, "env."))
+		return ok
+	}
+	if _, ok := v.GetTaskResult(name); ok {
+		return true
+	}
+	_, defined, err := v.lookup(name)
+	return err == nil && defined
+}
+
+// Values returns all variables visible in this scope as a flat map.
+// Loop variables override user variables, which override built-ins.
+func (v *Variables) Values() map[string]string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	values := make(map[string]string, len(v.builtins)+len(v.userVars)+len(v.locals))
+	for key, val := range v.builtins {
+		values[key] = val
+	}
+	for key, val := range v.userVars {
+		values[key] = val
+	}
+	for key, val := range v.locals {
+		values[key] = val
+	}
+	return values
+}
+
 // GetTaskResult retrieves a registered task result
 func (v *Variables) GetTaskResult(name string) (*TaskResult, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
 	result, ok := v.taskResults[name]
 	return result, ok
 }
@@ -109,6 +289,10 @@ func (v *Variables) GetTaskResult(name string) (*TaskResult, bool) {
 //   - {{ env.VAR }} - environment variables via built-in syntax
 //   - ${ENV_VAR} - direct environment variables
 //   - {{ result.stdout }} - task result properties
+//   - {{ result.json.items[0].name }} - fields of a task's JSON stdout;
+//     missing paths resolve to empty
+//   - {{ variable | filter }} - filters applied in order: default(value)
+//     for undefined variables, upper, lower, trim and from_json
 func (v *Variables) Substitute(input string) (string, error) {
 	result := input
 
@@ -120,39 +304,41 @@ func (v *Variables) Substitute(input string) (string, error) {
 	result = varPattern.ReplaceAllStringFunc(result, func(match string) string {
 		// Extract variable name
 		submatch := varPattern.FindStringSubmatch(match)
-		if len(submatch) < 2 {
+		if len(submatch) < 3 {
 			return match
 		}
 		varName := submatch[1]
 
-		// Handle special prefixes
-		if strings.HasPrefix(varName, "env.") {
-			// {{ env.VAR }} - environment variable
-			envName := strings.TrimPrefix(varName, "env.")
-			if val := os.Getenv(envName); val != "" {
-				return val
-			}
-			return match // Keep original if not found
+		filters, err := parseFilters(submatch[2])
+		if err != nil {
+			lastErr = &VariableError{VariableName: varName, Cause: err}
+			return match
 		}
 
-		// Handle task result references
-		if strings.Contains(varName, ".") {
-			parts := strings.SplitN(varName, ".", 2)
-			if result, ok := v.taskResults[parts[0]]; ok {
-				val, err := v.getTaskResultProperty(result, parts[1])
-				if err != nil {
-					lastErr = err
-					return match
-				}
-				return val
-			}
+		val, defined, err := v.lookup(varName)
+		if err != nil {
+			lastErr = err
+			return match
 		}
-
-		// Regular variable lookup
-		if val, ok := v.Get(varName); ok {
+		val, defined, err = applyFilters(val, defined, filters)
+		if err != nil {
+			lastErr = &VariableError{VariableName: varName, Cause: err}
+			return match
+		}
+		if defined {
 			return val
 		}
 
+		// {{ env.VAR }} is kept as is when VAR is unset
+		if strings.HasPrefix(varName, "env.") {
+			return match
+		}
+
+		// Missing JSON paths in a registered result resolve to empty
+		if v.isJSONReference(varName) {
+			return ""
+		}
+
 		// Variable not found - this might be an error
 		lastErr = &VariableError{
 			VariableName: varName,
@@ -164,6 +350,37 @@ func (v *Variables) Substitute(input string) (string, error) {
 	return result, lastErr
 }
 
+// lookup resolves a variable reference without filters, reporting whether
+// it is defined
+func (v *Variables) lookup(varName string) (string, bool, error) {
+	// Handle special prefixes
+	if strings.HasPrefix(varName, "env.") {
+		// {{ env.VAR }} - environment variable
+		val := os.Getenv(strings.TrimPrefix(varName, "env."))
+		return val, val != "", nil
+	}
+
+	// Handle task result references
+	if strings.Contains(varName, ".") {
+		parts := strings.SplitN(varName, ".", 2)
+		if result, ok := v.GetTaskResult(parts[0]); ok {
+			if isJSONProperty(parts[1]) {
+				val, found := jsonProperty(result, parts[1])
+				return val, found, nil
+			}
+			val, err := v.getTaskResultProperty(result, parts[1])
+			if err != nil {
+				return "", false, err
+			}
+			return val, true, nil
+		}
+	}
+
+	// Regular variable lookup
+	val, ok := v.Get(varName)
+	return val, ok, nil
+}
+
 // SubstituteMap substitutes variables in all string values of a map
 func (v *Variables) SubstituteMap(params map[string]interface{}) (map[string]interface{}, error) {
 	result := make(map[string]interface{})
@@ -237,6 +454,17 @@ func (v *Variables) resolveEnvVars(input string) string {
 	})
 }
 
+// isJSONReference reports whether varName addresses the JSON stdout of a
+// registered task result, e.g. "result.json.status"
+func (v *Variables) isJSONReference(varName string) bool {
+	parts := strings.SplitN(varName, ".", 2)
+	if len(parts) != 2 || !isJSONProperty(parts[1]) {
+		return false
+	}
+	_, ok := v.GetTaskResult(parts[0])
+	return ok
+}
+
 // getTaskResultProperty extracts a property from a task result
 func (v *Variables) getTaskResultProperty(result *TaskResult, property string) (string, error) {
 	switch property {
@@ -47,24 +47,64 @@ var (
 
 	// ErrInvalidPublicKey indicates the server public key is invalid
 	ErrInvalidPublicKey = errors.New("SECURITY VIOLATION: invalid server public key")
+
+	// ErrApprovalExpired indicates the playbook was approved too long ago
+	ErrApprovalExpired = errors.New("SECURITY VIOLATION: playbook approval expired - requires re-approval")
 )
 
 // Verifier handles cryptographic verification of playbooks
 type Verifier struct {
-	// serverPublicKey is the Ed25519 public key used to verify signatures
-	// This key is obtained during device enrollment and pinned
-	serverPublicKey ed25519.PublicKey
+	// serverPublicKeys are the Ed25519 public keys accepted for signatures
+	// These keys are obtained during device enrollment and pinned. More than
+	// one is trusted while the server rotates its signing key.
+	serverPublicKeys []ed25519.PublicKey
+
+	// maxApprovalAge rejects approved playbooks approved longer ago than
+	// this (0 = approvals don't expire)
+	maxApprovalAge time.Duration
 }
 
-// NewVerifier creates a new playbook verifier with the given server public key
+// NewVerifier creates a new playbook verifier trusting the given server
+// public keys. A signature from any of them is accepted.
 //
-// SECURITY: The public key should be obtained during enrollment and stored securely.
-// It should NOT be fetched from the network at verification time.
-func NewVerifier(publicKey ed25519.PublicKey) (*Verifier, error) {
-	if len(publicKey) != ed25519.PublicKeySize {
+// SECURITY: The public keys should be obtained during enrollment and stored securely.
+// They should NOT be fetched from the network at verification time.
+func NewVerifier(publicKeys ...ed25519.PublicKey) (*Verifier, error) {
+	if len(publicKeys) == 0 {
 		return nil, ErrInvalidPublicKey
 	}
-	return &Verifier{serverPublicKey: publicKey}, nil
+	for _, key := range publicKeys {
+		if len(key) != ed25519.PublicKeySize {
+			return nil, ErrInvalidPublicKey
+		}
+	}
+	return &Verifier{serverPublicKeys: publicKeys}, nil
+}
+
+// SetMaxApprovalAge makes Verify reject approved playbooks whose ApprovedAt
+// is older than maxAge, or missing. Zero disables the check. Test runs are
+// not affected. ApprovedAt is not covered by the signature; it comes over
+// the same authenticated connection as the rest of the job.
+func (v *Verifier) SetMaxApprovalAge(maxAge time.Duration) {
+	v.maxApprovalAge = maxAge
+}
+
+// KeyID returns a short identifier of a server public key for reports and
+// logs: the first 16 hex characters of its SHA256
+func KeyID(publicKey ed25519.PublicKey) string {
+	sum := sha256.Sum256(publicKey)
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// verifySignature checks signature over hash against every trusted key and
+// returns the key that made it
+func (v *Verifier) verifySignature(hash, signature []byte) (ed25519.PublicKey, bool) {
+	for _, key := range v.serverPublicKeys {
+		if ed25519.Verify(key, hash, signature) {
+			return key, true
+		}
+	}
+	return nil, false
 }
 
 // Verify performs all security checks on a signed playbook
@@ -77,7 +117,8 @@ func NewVerifier(publicKey ed25519.PublicKey) (*Verifier, error) {
 //   2. Calculate SHA256 hash of content
 //   3. Compare calculated hash with expected hash
 //   4. Verify Ed25519 signature of the hash
-//   5. Check approval status is "approved"
+//   5. Check approval status is "approved", and not expired when a
+//      maximum approval age is set
 //
 // ALL checks must pass. Any failure = immediate rejection.
 func (v *Verifier) Verify(sp *SignedPlaybook) (*VerificationRecord, error) {
@@ -128,12 +169,14 @@ func (v *Verifier) Verify(sp *SignedPlaybook) (*VerificationRecord, error) {
 	// STEP 4: Verify Ed25519 signature
 	// =======================================================================
 	// The signature is over the raw hash bytes, not the hex string
-	if !ed25519.Verify(v.serverPublicKey, hashBytes[:], sp.Signature) {
+	key, ok := v.verifySignature(hashBytes[:], sp.Signature)
+	if !ok {
 		record.SignatureVerified = false
 		record.FailureReason = "signature verification failed"
 		return record, ErrInvalidSignature
 	}
 	record.SignatureVerified = true
+	record.SignatureKeyID = KeyID(key)
 
 	// =======================================================================
 	// STEP 5: Check approval status
@@ -146,6 +189,19 @@ func (v *Verifier) Verify(sp *SignedPlaybook) (*VerificationRecord, error) {
 		record.FailureReason = fmt.Sprintf("playbook status is '%s', expected 'approved' or 'test'", sp.Status)
 		return record, ErrNotApproved
 	}
+	if sp.Status == StatusApproved && v.maxApprovalAge > 0 {
+		if sp.ApprovedAt.IsZero() {
+			record.ApprovalVerified = false
+			record.FailureReason = "playbook has no approval time and approvals expire on this device"
+			return record, ErrApprovalExpired
+		}
+		if age := time.Since(sp.ApprovedAt); age > v.maxApprovalAge {
+			record.ApprovalVerified = false
+			record.FailureReason = fmt.Sprintf("playbook was approved at %s, more than %s ago",
+				sp.ApprovedAt.Format(time.RFC3339), v.maxApprovalAge)
+			return record, ErrApprovalExpired
+		}
+	}
 	record.ApprovalVerified = true
 
 	// =======================================================================
@@ -168,3 +224,33 @@ func VerifyHashOnly(content, expectedHash string) (bool, string) {
 	calculated := CalculateHash(content)
 	return calculated == expectedHash, calculated
 }
+
+// VerifyContent checks the hash and Ed25519 signature of server-signed
+// content other than a playbook, such as a remote diagnostic command. The
+// signature is over domain followed by the hex SHA256 hash, e.g.
+// "cloudronix-remote-command:v1:<id>:<hash>", so it can never pass as a
+// playbook signature (over the raw hash bytes) or one for another kind of
+// content. There is no approval step; callers apply their own policy on top.
+func (v *Verifier) VerifyContent(domain, content, expectedHash string, signature []byte) error {
+	if domain == "" {
+		return errors.New("signature domain is required")
+	}
+	if content == "" {
+		return ErrEmptyContent
+	}
+	if expectedHash == "" {
+		return ErrMissingHash
+	}
+	if len(signature) == 0 {
+		return ErrMissingSignature
+	}
+
+	hashBytes := sha256.Sum256([]byte(content))
+	if hex.EncodeToString(hashBytes[:]) != expectedHash {
+		return ErrHashMismatch
+	}
+	if _, ok := v.verifySignature([]byte(domain+expectedHash), signature); !ok {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package playbook
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// version is a parsed semantic version. Core may have any number of
+// numeric components; missing ones compare as zero, so "1.2" == "1.2.0".
+type version struct {
+	core       []int
+	prerelease []string
+}
+
+// parseVersion parses versions such as "1.2.3", "v1.2", "2.0.0-rc.1" and
+// "1.4.0+build.7". Build metadata is ignored.
+func parseVersion(s string) (version, error) {
+	raw := s
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+
+	var v version
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		v.prerelease = strings.Split(s[i+1:], ".")
+		s = s[:i]
+		for _, id := range v.prerelease {
+			if id == "" {
+				return version{}, fmt.Errorf("invalid version '%s': empty pre-release identifier", raw)
+			}
+		}
+	}
+
+	if s == "" {
+		return version{}, fmt.Errorf("invalid version '%s'", raw)
+	}
+	for _, part := range strings.Split(s, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return version{}, fmt.Errorf("invalid version '%s': component '%s' is not a number", raw, part)
+		}
+		v.core = append(v.core, n)
+	}
+	return v, nil
+}
+
+// compareVersions returns -1, 0 or 1 as a is lower than, equal to or
+// higher than b. A pre-release sorts before its release: 1.0.0-rc.1 < 1.0.0.
+func compareVersions(a, b string) (int, error) {
+	va, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := 0; i < len(va.core) || i < len(vb.core); i++ {
+		if c := compareInts(componentAt(va.core, i), componentAt(vb.core, i)); c != 0 {
+			return c, nil
+		}
+	}
+
+	switch {
+	case len(va.prerelease) == 0 && len(vb.prerelease) == 0:
+		return 0, nil
+	case len(va.prerelease) == 0:
+		return 1, nil
+	case len(vb.prerelease) == 0:
+		return -1, nil
+	}
+
+	for i := 0; i < len(va.prerelease) && i < len(vb.prerelease); i++ {
+		if c := comparePrerelease(va.prerelease[i], vb.prerelease[i]); c != 0 {
+			return c, nil
+		}
+	}
+	return compareInts(len(va.prerelease), len(vb.prerelease)), nil
+}
+
+// comparePrerelease orders pre-release identifiers as semver does: numeric
+// identifiers numerically and below alphanumeric ones, which sort lexically
+func comparePrerelease(a, b string) int {
+	na, errA := strconv.Atoi(a)
+	nb, errB := strconv.Atoi(b)
+	switch {
+	case errA == nil && errB == nil:
+		return compareInts(na, nb)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+func componentAt(core []int, i int) int {
+	if i < len(core) {
+		return core[i]
+	}
+	return 0
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
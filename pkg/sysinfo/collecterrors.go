@@ -0,0 +1,69 @@
+package sysinfo
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// CollectionError records a collector that failed, so a field left empty
+// or "unknown" because of an error can be told apart from one that is
+// genuinely disabled or absent
+type CollectionError struct {
+	Field  string `json:"field"`            // e.g. "specs.gpu", "security.firewall"
+	Source string `json:"source,omitempty"` // command or API that failed
+	Error  string `json:"error"`
+}
+
+// collectionErrors gathers the errors of one collection run. Collectors may
+// run concurrently.
+type collectionErrors struct {
+	mu   sync.Mutex
+	list []CollectionError
+}
+
+type collectionErrorsKey struct{}
+
+// withCollectionErrors starts recording collection errors for collectors
+// called with the returned context
+func withCollectionErrors(ctx context.Context) (context.Context, *collectionErrors) {
+	errs := &collectionErrors{}
+	return context.WithValue(ctx, collectionErrorsKey{}, errs), errs
+}
+
+// errors returns the recorded errors, nil if there were none
+func (e *collectionErrors) errors() []CollectionError {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.list
+}
+
+// noteCollectionError records that collecting field from source failed.
+// Errors caused by ctx being cancelled are not recorded: the data is
+// missing because collection was cut short, not because a collector broke.
+func noteCollectionError(ctx context.Context, field, source string, err error) {
+	errs, _ := ctx.Value(collectionErrorsKey{}).(*collectionErrors)
+	if errs == nil || err == nil || ctx.Err() != nil {
+		return
+	}
+
+	msg := err.Error()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if stderr := strings.TrimSpace(string(exitErr.Stderr)); stderr != "" {
+			msg += ": " + firstLine(stderr)
+		}
+	}
+
+	errs.mu.Lock()
+	defer errs.mu.Unlock()
+	errs.list = append(errs.list, CollectionError{Field: field, Source: source, Error: msg})
+}
+
+// firstLine returns s up to its first line break
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return strings.TrimSpace(line)
+}
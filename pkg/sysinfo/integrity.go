@@ -0,0 +1,79 @@
+package sysinfo
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cloudronix/agent/pkg/playbook/actions"
+)
+
+// Limits for file integrity monitoring, to keep each report cycle cheap
+const (
+	MaxWatchedFiles    = 64
+	MaxWatchedFileSize = 50 * 1024 * 1024 // 50 MB
+)
+
+// FileIntegrity is the hash of a watched file. Error is set instead of
+// SHA256 when the file is missing, unreadable or too large.
+type FileIntegrity struct {
+	Path    string     `json:"path"`
+	SHA256  string     `json:"sha256,omitempty"`
+	Size    int64      `json:"size,omitempty"`
+	ModTime *time.Time `json:"mod_time,omitempty"`
+	Error   string     `json:"error,omitempty"`
+}
+
+// collectFileIntegrity hashes the watched files. Files past MaxWatchedFiles
+// are ignored.
+func collectFileIntegrity(ctx context.Context, paths []string) []FileIntegrity {
+	if len(paths) > MaxWatchedFiles {
+		paths = paths[:MaxWatchedFiles]
+	}
+
+	var files []FileIntegrity
+	for _, path := range paths {
+		if ctx.Err() != nil {
+			break
+		}
+		files = append(files, hashWatchedFile(path))
+	}
+	return files
+}
+
+// hashWatchedFile hashes a single watched file
+func hashWatchedFile(path string) FileIntegrity {
+	entry := FileIntegrity{Path: path}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			entry.Error = "not found"
+		} else {
+			entry.Error = err.Error()
+		}
+		return entry
+	}
+	if info.IsDir() {
+		entry.Error = "is a directory"
+		return entry
+	}
+
+	modTime := info.ModTime()
+	entry.Size = info.Size()
+	entry.ModTime = &modTime
+
+	if info.Size() > MaxWatchedFileSize {
+		entry.Error = fmt.Sprintf("file too large to hash (%d bytes)", info.Size())
+		return entry
+	}
+
+	hash, err := actions.FileHash(path)
+	if err != nil {
+		entry.Error = err.Error()
+		return entry
+	}
+	entry.SHA256 = hash
+	return entry
+}
@@ -0,0 +1,82 @@
+package sysinfo
+
+import (
+	"path"
+	"strings"
+	"sync"
+)
+
+// Options tunes collector behavior. The agent sets them once at startup
+// from its configuration via Configure.
+type Options struct {
+	// ForceFullSecurityScoring scores every security module, even those that
+	// don't apply inside a container
+	ForceFullSecurityScoring bool
+
+	// Disk usage percentages at which volumes are flagged warning/critical.
+	// Zero uses the defaults.
+	DiskWarningPercent  float64
+	DiskCriticalPercent float64
+
+	// Files hashed each report cycle for integrity monitoring
+	// (at most MaxWatchedFiles)
+	WatchedFiles []string
+
+	// Glob patterns (e.g. "keepass*", "*vpn*") matched case-insensitively
+	// against process names. Matching top processes are reported under
+	// RedactedProcessName so their resource usage is still accounted for.
+	ProcessDenylist []string
+}
+
+// RedactedProcessName replaces the name of top processes on the denylist
+const RedactedProcessName = "[redacted]"
+
+// Default disk alert thresholds (percent used)
+const (
+	DefaultDiskWarningPercent  = 85
+	DefaultDiskCriticalPercent = 95
+)
+
+// diskThresholds returns the effective warning and critical thresholds
+func (o Options) diskThresholds() (warning, critical float64) {
+	warning, critical = o.DiskWarningPercent, o.DiskCriticalPercent
+	if warning <= 0 {
+		warning = DefaultDiskWarningPercent
+	}
+	if critical <= 0 {
+		critical = DefaultDiskCriticalPercent
+	}
+	return warning, critical
+}
+
+// processDenied reports whether name matches a ProcessDenylist pattern.
+// Malformed patterns only match the name literally.
+func (o Options) processDenied(name string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range o.ProcessDenylist {
+		pattern = strings.ToLower(pattern)
+		if matched, err := path.Match(pattern, name); matched || (err != nil && pattern == name) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	optionsMu sync.RWMutex
+	options   Options
+)
+
+// Configure sets the collector options
+func Configure(opts Options) {
+	optionsMu.Lock()
+	defer optionsMu.Unlock()
+	options = opts
+}
+
+// currentOptions returns the collector options
+func currentOptions() Options {
+	optionsMu.RLock()
+	defer optionsMu.RUnlock()
+	return options
+}
@@ -0,0 +1,8 @@
+package sysinfo
+
+// PendingReboot reports whether the operating system has flagged that a
+// reboot is needed to finish applying changes (package or kernel updates,
+// pending file renames), and a short reason
+func PendingReboot() (bool, string) {
+	return pendingReboot()
+}
@@ -0,0 +1,43 @@
+//go:build linux
+
+package sysinfo
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// needs-restarting can block on the dnf/yum lock; give up rather than stall collection
+const needsRestartingTimeout = 15 * time.Second
+
+// pendingReboot checks the Debian/Ubuntu flag file, then needs-restarting
+// on RHEL-family systems
+func pendingReboot() (bool, string) {
+	if _, err := os.Stat("/var/run/reboot-required"); err == nil {
+		reason := "reboot-required flag is set"
+		if data, err := os.ReadFile("/var/run/reboot-required.pkgs"); err == nil {
+			if pkgs := strings.Fields(string(data)); len(pkgs) > 0 {
+				reason = "updated packages: " + strings.Join(pkgs, ", ")
+			}
+		}
+		return true, reason
+	}
+
+	if path, err := exec.LookPath("needs-restarting"); err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), needsRestartingTimeout)
+		defer cancel()
+
+		// Exit status 1 means a reboot is required
+		err := exec.CommandContext(ctx, path, "-r").Run()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return true, "needs-restarting reports core libraries or services were updated"
+		}
+	}
+
+	return false, ""
+}
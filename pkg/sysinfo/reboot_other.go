@@ -0,0 +1,9 @@
+//go:build !linux && !windows
+
+package sysinfo
+
+// pendingReboot is not detected on this platform; macOS has no equivalent
+// system flag
+func pendingReboot() (bool, string) {
+	return false, ""
+}
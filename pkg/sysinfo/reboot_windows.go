@@ -0,0 +1,38 @@
+//go:build windows
+
+package sysinfo
+
+import (
+	"golang.org/x/sys/windows/registry"
+)
+
+// pendingReboot checks the registry flags set by Windows Update, component
+// servicing and pending file rename operations
+func pendingReboot() (bool, string) {
+	if hklmKeyExists(`SOFTWARE\Microsoft\Windows\CurrentVersion\WindowsUpdate\Auto Update\RebootRequired`) {
+		return true, "Windows Update requires a reboot"
+	}
+	if hklmKeyExists(`SOFTWARE\Microsoft\Windows\CurrentVersion\Component Based Servicing\RebootPending`) {
+		return true, "component servicing requires a reboot"
+	}
+
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, `SYSTEM\CurrentControlSet\Control\Session Manager`, registry.QUERY_VALUE)
+	if err == nil {
+		defer key.Close()
+		if renames, _, err := key.GetStringsValue("PendingFileRenameOperations"); err == nil && len(renames) > 0 {
+			return true, "file rename operations are pending"
+		}
+	}
+
+	return false, ""
+}
+
+// hklmKeyExists reports whether an HKLM registry key exists
+func hklmKeyExists(path string) bool {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, path, registry.QUERY_VALUE)
+	if err != nil {
+		return false
+	}
+	key.Close()
+	return true
+}
@@ -1,7 +1,10 @@
 package sysinfo
 
 import (
+	"context"
+	"fmt"
 	"runtime"
+	"strings"
 )
 
 // SecurityStatus contains the security status of the system
@@ -15,15 +18,35 @@ type SecurityStatus struct {
 	Privacy        PrivacyStatus `json:"privacy"`
 	Score          int           `json:"score"`
 	Platform       string        `json:"platform"`
+
+	// Clock synchronization: "enabled" when synchronized, "partial" when
+	// network time is on but not synchronized, "disabled" when it is off.
+	// Not part of the score, but a drifting clock breaks request signing.
+	TimeSync   ModuleStatus `json:"time_sync"`
+	TimeSource string       `json:"time_source,omitempty"` // configured time server or reference
+
+	// Per-volume disk encryption behind the DiskEncryption summary
+	Volumes []VolumeEncryption `json:"volumes,omitempty"`
+
+	// Checks that failed, leaving their module "unknown"
+	CollectionErrors []CollectionError `json:"collection_errors,omitempty"`
 }
 
 // ModuleStatus represents the status of a security module
 type ModuleStatus struct {
 	Enabled bool   `json:"enabled"`
-	Status  string `json:"status"` // "enabled", "disabled", "partial", "unknown"
+	Status  string `json:"status"` // "enabled", "disabled", "partial", "unknown", "not_applicable"
 	Details string `json:"details,omitempty"`
 }
 
+// VolumeEncryption is the encryption state of a single volume
+type VolumeEncryption struct {
+	Volume    string `json:"volume"` // mount point or drive letter
+	Encrypted bool   `json:"encrypted"`
+	Removable bool   `json:"removable,omitempty"`
+	Details   string `json:"details,omitempty"`
+}
+
 // PrivacyStatus contains privacy-related settings
 type PrivacyStatus struct {
 	TelemetryLevel    string `json:"telemetry_level"`    // "full", "enhanced", "basic", "security"
@@ -33,8 +56,9 @@ type PrivacyStatus struct {
 	ActivityHistory   bool   `json:"activity_history"`
 }
 
-// CollectSecurityStatus gathers security information from the system
-func CollectSecurityStatus() *SecurityStatus {
+// CollectSecurityStatus gathers security information from the system.
+// Cancelling ctx stops any checks still running; their modules stay "unknown".
+func CollectSecurityStatus(ctx context.Context) *SecurityStatus {
 	status := &SecurityStatus{
 		Firewall:       ModuleStatus{Status: "unknown"},
 		Antivirus:      ModuleStatus{Status: "unknown"},
@@ -43,12 +67,20 @@ func CollectSecurityStatus() *SecurityStatus {
 		SecureBoot:     ModuleStatus{Status: "unknown"},
 		UAC:            ModuleStatus{Status: "unknown"},
 		Privacy:        PrivacyStatus{TelemetryLevel: "unknown"},
+		TimeSync:       ModuleStatus{Status: "unknown"},
 		Platform:       runtime.GOOS,
 	}
 
 	// Platform-specific collection is done in security_<platform>.go files
 	// via the collectPlatformSecurity function
-	collectPlatformSecurity(status)
+	ctx, errs := withCollectionErrors(ctx)
+	collectPlatformSecurity(ctx, status)
+	status.CollectionErrors = errs.errors()
+
+	// Host-level controls don't exist inside a container
+	if rt := ContainerRuntime(); rt != "" && !currentOptions().ForceFullSecurityScoring {
+		markContainerNotApplicable(status, rt)
+	}
 
 	// Calculate security score
 	status.Score = calculateSecurityScore(status)
@@ -56,45 +88,92 @@ func CollectSecurityStatus() *SecurityStatus {
 	return status
 }
 
+// markContainerNotApplicable marks modules that are controlled by the container
+// host rather than the container as not applicable
+func markContainerNotApplicable(s *SecurityStatus, engine string) {
+	na := ModuleStatus{
+		Status:  StatusNotApplicable,
+		Details: fmt.Sprintf("Not applicable inside a container (%s)", engine),
+	}
+	s.Firewall = na
+	s.DiskEncryption = na
+	s.Volumes = nil
+	s.SecureBoot = na
+	s.UAC = na
+	s.TimeSync = na
+	s.TimeSource = ""
+}
+
+// summarizeVolumeEncryption derives the disk encryption module status from
+// per-volume results. Only fixed volumes count towards the summary, so an
+// unencrypted USB stick doesn't mark the machine as partially encrypted.
+func summarizeVolumeEncryption(volumes []VolumeEncryption, method string) ModuleStatus {
+	var fixed, encrypted int
+	var unencrypted []string
+	for _, v := range volumes {
+		if v.Removable {
+			continue
+		}
+		fixed++
+		if v.Encrypted {
+			encrypted++
+		} else {
+			unencrypted = append(unencrypted, v.Volume)
+		}
+	}
+
+	switch {
+	case fixed == 0:
+		return ModuleStatus{Enabled: false, Status: "unknown", Details: "No fixed volumes found"}
+	case encrypted == fixed:
+		return ModuleStatus{Enabled: true, Status: "enabled", Details: fmt.Sprintf("%s enabled on all %d fixed volume(s)", method, fixed)}
+	case encrypted == 0:
+		return ModuleStatus{Enabled: false, Status: "disabled", Details: fmt.Sprintf("%s is not enabled on any fixed volume", method)}
+	default:
+		return ModuleStatus{
+			Enabled: false,
+			Status:  "partial",
+			Details: fmt.Sprintf("%s enabled on %d of %d fixed volumes (unencrypted: %s)", method, encrypted, fixed, strings.Join(unencrypted, ", ")),
+		}
+	}
+}
+
+// StatusNotApplicable marks a module that doesn't apply to this system.
+// Such modules are excluded from the security score.
+const StatusNotApplicable = "not_applicable"
+
+// scoreModule adds a module's points to the score unless it is not applicable
+func scoreModule(m ModuleStatus, points int, score, maxScore *int) {
+	if m.Status == StatusNotApplicable {
+		return
+	}
+	*maxScore += points
+	if m.Enabled {
+		*score += points
+	}
+}
+
 func calculateSecurityScore(s *SecurityStatus) int {
 	score := 0
 	maxScore := 0
 
 	// Firewall: 20 points
-	maxScore += 20
-	if s.Firewall.Enabled {
-		score += 20
-	}
+	scoreModule(s.Firewall, 20, &score, &maxScore)
 
 	// Antivirus: 25 points
-	maxScore += 25
-	if s.Antivirus.Enabled {
-		score += 25
-	}
+	scoreModule(s.Antivirus, 25, &score, &maxScore)
 
 	// Disk Encryption: 15 points
-	maxScore += 15
-	if s.DiskEncryption.Enabled {
-		score += 15
-	}
+	scoreModule(s.DiskEncryption, 15, &score, &maxScore)
 
 	// Auto Updates: 15 points
-	maxScore += 15
-	if s.AutoUpdates.Enabled {
-		score += 15
-	}
+	scoreModule(s.AutoUpdates, 15, &score, &maxScore)
 
 	// Secure Boot: 10 points
-	maxScore += 10
-	if s.SecureBoot.Enabled {
-		score += 10
-	}
+	scoreModule(s.SecureBoot, 10, &score, &maxScore)
 
 	// UAC: 10 points
-	maxScore += 10
-	if s.UAC.Enabled {
-		score += 10
-	}
+	scoreModule(s.UAC, 10, &score, &maxScore)
 
 	// Privacy (lower telemetry = better): 5 points
 	maxScore += 5
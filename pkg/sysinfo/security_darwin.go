@@ -3,45 +3,55 @@
 package sysinfo
 
 import (
+	"context"
+	"fmt"
+	"math"
 	"os/exec"
+	"strconv"
 	"strings"
+
+	"github.com/shirou/gopsutil/v3/disk"
 )
 
-func collectPlatformSecurity(status *SecurityStatus) {
+func collectPlatformSecurity(ctx context.Context, status *SecurityStatus) {
 	// Check macOS Application Firewall
-	checkMacFirewall(status)
+	checkMacFirewall(ctx, status)
 
 	// Check XProtect (built-in antivirus)
-	checkXProtect(status)
+	checkXProtect(ctx, status)
 
 	// Check FileVault (disk encryption)
-	checkFileVault(status)
+	checkFileVault(ctx, status)
 
 	// Check Software Update auto-updates
-	checkMacAutoUpdates(status)
+	checkMacAutoUpdates(ctx, status)
 
 	// Check Secure Boot (for T2/Apple Silicon Macs)
-	checkMacSecureBoot(status)
+	checkMacSecureBoot(ctx, status)
 
 	// Check System Integrity Protection (SIP)
-	checkSIP(status)
+	checkSIP(ctx, status)
 
 	// Check Gatekeeper
-	checkGatekeeper(status)
+	checkGatekeeper(ctx, status)
 
 	// Check privacy settings
-	checkMacPrivacy(status)
+	checkMacPrivacy(ctx, status)
+
+	// Check network time
+	checkMacTimeSync(ctx, status)
 }
 
-func checkMacFirewall(status *SecurityStatus) {
+func checkMacFirewall(ctx context.Context, status *SecurityStatus) {
 	// Check Application Firewall status
-	cmd := exec.Command("/usr/libexec/ApplicationFirewall/socketfilterfw", "--getglobalstate")
+	cmd := exec.CommandContext(ctx, "/usr/libexec/ApplicationFirewall/socketfilterfw", "--getglobalstate")
 	output, err := cmd.Output()
 	if err != nil {
 		// Try alternative method
-		cmd = exec.Command("defaults", "read", "/Library/Preferences/com.apple.alf", "globalstate")
+		cmd = exec.CommandContext(ctx, "defaults", "read", "/Library/Preferences/com.apple.alf", "globalstate")
 		output, err = cmd.Output()
 		if err != nil {
+			noteCollectionError(ctx, "security.firewall", "socketfilterfw", err)
 			status.Firewall = ModuleStatus{Enabled: false, Status: "unknown", Details: "Could not determine firewall status"}
 			return
 		}
@@ -62,9 +72,9 @@ func checkMacFirewall(status *SecurityStatus) {
 	}
 }
 
-func checkXProtect(status *SecurityStatus) {
+func checkXProtect(ctx context.Context, status *SecurityStatus) {
 	// XProtect is always enabled on macOS, check if it's up to date
-	cmd := exec.Command("system_profiler", "SPInstallHistoryDataType", "-detailLevel", "mini")
+	cmd := exec.CommandContext(ctx, "system_profiler", "SPInstallHistoryDataType", "-detailLevel", "mini")
 	output, err := cmd.Output()
 	if err == nil {
 		result := string(output)
@@ -75,7 +85,7 @@ func checkXProtect(status *SecurityStatus) {
 	}
 
 	// Check XProtect plist exists
-	cmd = exec.Command("ls", "/Library/Apple/System/Library/CoreServices/XProtect.bundle")
+	cmd = exec.CommandContext(ctx, "ls", "/Library/Apple/System/Library/CoreServices/XProtect.bundle")
 	if err := cmd.Run(); err == nil {
 		status.Antivirus = ModuleStatus{Enabled: true, Status: "enabled", Details: "XProtect is installed"}
 		return
@@ -85,10 +95,11 @@ func checkXProtect(status *SecurityStatus) {
 	status.Antivirus = ModuleStatus{Enabled: true, Status: "enabled", Details: "XProtect (built-in malware protection)"}
 }
 
-func checkFileVault(status *SecurityStatus) {
-	cmd := exec.Command("fdesetup", "status")
+func checkFileVault(ctx context.Context, status *SecurityStatus) {
+	cmd := exec.CommandContext(ctx, "fdesetup", "status")
 	output, err := cmd.Output()
 	if err != nil {
+		noteCollectionError(ctx, "security.disk_encryption", "fdesetup", err)
 		status.DiskEncryption = ModuleStatus{Enabled: false, Status: "unknown", Details: "Could not determine FileVault status"}
 		return
 	}
@@ -102,20 +113,75 @@ func checkFileVault(status *SecurityStatus) {
 		status.DiskEncryption = ModuleStatus{Enabled: true, Status: "partial", Details: "FileVault encryption in progress"}
 	} else {
 		status.DiskEncryption = ModuleStatus{Enabled: false, Status: "unknown", Details: result}
+		return
+	}
+
+	// FileVault only covers the boot volume - check mounted data volumes too
+	dataVolumes := listMacDataVolumes(ctx)
+	if len(dataVolumes) == 0 || status.DiskEncryption.Status == "partial" {
+		return
+	}
+	volumes := append([]VolumeEncryption{{
+		Volume:    "/",
+		Encrypted: status.DiskEncryption.Enabled,
+		Details:   status.DiskEncryption.Details,
+	}}, dataVolumes...)
+	status.Volumes = volumes
+	status.DiskEncryption = summarizeVolumeEncryption(volumes, "Encryption")
+}
+
+// listMacDataVolumes returns the encryption state of volumes mounted under /Volumes
+func listMacDataVolumes(ctx context.Context) []VolumeEncryption {
+	partitions, err := disk.Partitions(false)
+	if err != nil {
+		return nil
 	}
+
+	var volumes []VolumeEncryption
+	for _, part := range partitions {
+		if !strings.HasPrefix(part.Mountpoint, "/Volumes/") {
+			continue
+		}
+
+		output, err := exec.CommandContext(ctx, "diskutil", "info", part.Mountpoint).Output()
+		if err != nil {
+			continue
+		}
+
+		info := parseDiskutilInfo(string(output))
+		volumes = append(volumes, VolumeEncryption{
+			Volume:    part.Mountpoint,
+			Encrypted: info["FileVault"] == "Yes" || info["Encrypted"] == "Yes",
+			Removable: info["Removable Media"] == "Removable" || info["Device Location"] == "External",
+		})
+	}
+	return volumes
 }
 
-func checkMacAutoUpdates(status *SecurityStatus) {
+// parseDiskutilInfo parses "Key: Value" lines from diskutil info
+func parseDiskutilInfo(output string) map[string]string {
+	info := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		info[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return info
+}
+
+func checkMacAutoUpdates(ctx context.Context, status *SecurityStatus) {
 	// Check if automatic updates are enabled
-	cmd := exec.Command("defaults", "read", "/Library/Preferences/com.apple.SoftwareUpdate", "AutomaticCheckEnabled")
+	cmd := exec.CommandContext(ctx, "defaults", "read", "/Library/Preferences/com.apple.SoftwareUpdate", "AutomaticCheckEnabled")
 	output, err := cmd.Output()
 	autoCheck := err == nil && strings.TrimSpace(string(output)) == "1"
 
-	cmd = exec.Command("defaults", "read", "/Library/Preferences/com.apple.SoftwareUpdate", "AutomaticDownload")
+	cmd = exec.CommandContext(ctx, "defaults", "read", "/Library/Preferences/com.apple.SoftwareUpdate", "AutomaticDownload")
 	output, err = cmd.Output()
 	autoDownload := err == nil && strings.TrimSpace(string(output)) == "1"
 
-	cmd = exec.Command("defaults", "read", "/Library/Preferences/com.apple.SoftwareUpdate", "AutomaticallyInstallMacOSUpdates")
+	cmd = exec.CommandContext(ctx, "defaults", "read", "/Library/Preferences/com.apple.SoftwareUpdate", "AutomaticallyInstallMacOSUpdates")
 	output, err = cmd.Output()
 	autoInstall := err == nil && strings.TrimSpace(string(output)) == "1"
 
@@ -128,9 +194,9 @@ func checkMacAutoUpdates(status *SecurityStatus) {
 	}
 }
 
-func checkMacSecureBoot(status *SecurityStatus) {
+func checkMacSecureBoot(ctx context.Context, status *SecurityStatus) {
 	// Check Secure Boot status (requires T2 chip or Apple Silicon)
-	cmd := exec.Command("system_profiler", "SPiBridgeDataType")
+	cmd := exec.CommandContext(ctx, "system_profiler", "SPiBridgeDataType")
 	output, err := cmd.Output()
 	if err == nil && strings.Contains(string(output), "Secure Boot") {
 		result := string(output)
@@ -147,7 +213,7 @@ func checkMacSecureBoot(status *SecurityStatus) {
 	}
 
 	// Check for Apple Silicon
-	cmd = exec.Command("sysctl", "-n", "machdep.cpu.brand_string")
+	cmd = exec.CommandContext(ctx, "sysctl", "-n", "machdep.cpu.brand_string")
 	output, err = cmd.Output()
 	if err == nil && strings.Contains(string(output), "Apple") {
 		// Apple Silicon Macs always have Secure Boot
@@ -159,10 +225,11 @@ func checkMacSecureBoot(status *SecurityStatus) {
 	status.SecureBoot = ModuleStatus{Enabled: false, Status: "not_available", Details: "Mac without T2 chip or Apple Silicon"}
 }
 
-func checkSIP(status *SecurityStatus) {
-	cmd := exec.Command("csrutil", "status")
+func checkSIP(ctx context.Context, status *SecurityStatus) {
+	cmd := exec.CommandContext(ctx, "csrutil", "status")
 	output, err := cmd.Output()
 	if err != nil {
+		noteCollectionError(ctx, "security.uac", "csrutil", err)
 		status.UAC = ModuleStatus{Enabled: false, Status: "unknown", Details: "Could not determine SIP status"}
 		return
 	}
@@ -178,8 +245,8 @@ func checkSIP(status *SecurityStatus) {
 	}
 }
 
-func checkGatekeeper(status *SecurityStatus) {
-	cmd := exec.Command("spctl", "--status")
+func checkGatekeeper(ctx context.Context, status *SecurityStatus) {
+	cmd := exec.CommandContext(ctx, "spctl", "--status")
 	output, err := cmd.Output()
 	if err != nil {
 		return // Gatekeeper check optional, SIP is primary
@@ -194,9 +261,9 @@ func checkGatekeeper(status *SecurityStatus) {
 	}
 }
 
-func checkMacPrivacy(status *SecurityStatus) {
+func checkMacPrivacy(ctx context.Context, status *SecurityStatus) {
 	// Check analytics sharing
-	cmd := exec.Command("defaults", "read", "/Library/Application Support/CrashReporter/DiagnosticMessagesHistory.plist", "AutoSubmit")
+	cmd := exec.CommandContext(ctx, "defaults", "read", "/Library/Application Support/CrashReporter/DiagnosticMessagesHistory.plist", "AutoSubmit")
 	output, _ := cmd.Output()
 	if strings.TrimSpace(string(output)) == "0" {
 		status.Privacy.TelemetryLevel = "security"
@@ -205,12 +272,12 @@ func checkMacPrivacy(status *SecurityStatus) {
 	}
 
 	// Check personalized ads
-	cmd = exec.Command("defaults", "read", "com.apple.AdLib", "allowApplePersonalizedAdvertising")
+	cmd = exec.CommandContext(ctx, "defaults", "read", "com.apple.AdLib", "allowApplePersonalizedAdvertising")
 	output, _ = cmd.Output()
 	status.Privacy.AdvertisingID = strings.TrimSpace(string(output)) == "1"
 
 	// Check Location Services
-	cmd = exec.Command("defaults", "read", "/var/db/locationd/Library/Preferences/ByHost/com.apple.locationd", "LocationServicesEnabled")
+	cmd = exec.CommandContext(ctx, "defaults", "read", "/var/db/locationd/Library/Preferences/ByHost/com.apple.locationd", "LocationServicesEnabled")
 	output, _ = cmd.Output()
 	status.Privacy.LocationServices = strings.TrimSpace(string(output)) == "1"
 
@@ -218,7 +285,52 @@ func checkMacPrivacy(status *SecurityStatus) {
 	status.Privacy.DiagnosticData = status.Privacy.TelemetryLevel != "security"
 
 	// Check Siri history (activity history equivalent)
-	cmd = exec.Command("defaults", "read", "com.apple.assistant.support", "Siri Data Sharing Opt-In Status")
+	cmd = exec.CommandContext(ctx, "defaults", "read", "com.apple.assistant.support", "Siri Data Sharing Opt-In Status")
 	output, _ = cmd.Output()
 	status.Privacy.ActivityHistory = strings.TrimSpace(string(output)) == "2"
 }
+
+func checkMacTimeSync(ctx context.Context, status *SecurityStatus) {
+	output, err := exec.CommandContext(ctx, "systemsetup", "-getusingnetworktime").Output()
+	if err != nil {
+		noteCollectionError(ctx, "security.time_sync", "systemsetup", err)
+		status.TimeSync = ModuleStatus{Enabled: false, Status: "unknown", Details: "Could not determine network time status"}
+		return
+	}
+	if !strings.Contains(strings.ToLower(string(output)), "network time: on") {
+		status.TimeSync = ModuleStatus{Enabled: false, Status: "disabled", Details: "Network time is disabled"}
+		return
+	}
+
+	server := "time.apple.com"
+	if output, err := exec.CommandContext(ctx, "systemsetup", "-getnetworktimeserver").Output(); err == nil {
+		if _, value, ok := strings.Cut(string(output), ":"); ok && strings.TrimSpace(value) != "" {
+			server = strings.TrimSpace(value)
+		}
+	}
+	status.TimeSource = server
+
+	// sntp prints the offset from the server, e.g. "+0.012345 +/- 0.0213 ..."
+	output, err = exec.CommandContext(ctx, "sntp", server).Output()
+	if err != nil {
+		noteCollectionError(ctx, "security.time_sync", "sntp", err)
+		status.TimeSync = ModuleStatus{Enabled: true, Status: "enabled", Details: "Network time is enabled (offset not measured)"}
+		return
+	}
+	fields := strings.Fields(string(output))
+	if len(fields) > 0 {
+		if offset, err := strconv.ParseFloat(fields[0], 64); err == nil {
+			if math.Abs(offset) > macTimeSyncTolerance {
+				status.TimeSync = ModuleStatus{Enabled: false, Status: "partial", Details: fmt.Sprintf("Network time is enabled but the clock is off by %.1fs", offset)}
+				return
+			}
+			status.TimeSync = ModuleStatus{Enabled: true, Status: "enabled", Details: fmt.Sprintf("Clock is synchronized (offset %.3fs)", offset)}
+			return
+		}
+	}
+	status.TimeSync = ModuleStatus{Enabled: true, Status: "enabled", Details: "Network time is enabled"}
+}
+
+// Offset from the time server, in seconds, above which the clock counts as
+// not synchronized
+const macTimeSyncTolerance = 1.0
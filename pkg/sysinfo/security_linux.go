@@ -3,37 +3,42 @@
 package sysinfo
 
 import (
+	"context"
+	"encoding/json"
 	"os"
 	"os/exec"
 	"strings"
 )
 
-func collectPlatformSecurity(status *SecurityStatus) {
+func collectPlatformSecurity(ctx context.Context, status *SecurityStatus) {
 	// Check firewall status (iptables/nftables/ufw/firewalld)
-	checkLinuxFirewall(status)
+	checkLinuxFirewall(ctx, status)
 
 	// Check for antivirus (ClamAV is common on Linux)
-	checkLinuxAntivirus(status)
+	checkLinuxAntivirus(ctx, status)
 
 	// Check disk encryption (LUKS)
-	checkLUKS(status)
+	checkLUKS(ctx, status)
 
 	// Check auto updates
-	checkLinuxAutoUpdates(status)
+	checkLinuxAutoUpdates(ctx, status)
 
 	// Check Secure Boot
-	checkLinuxSecureBoot(status)
+	checkLinuxSecureBoot(ctx, status)
 
 	// Check SELinux/AppArmor (equivalent to UAC)
-	checkMACSystem(status)
+	checkMACSystem(ctx, status)
 
 	// Check privacy settings
-	checkLinuxPrivacy(status)
+	checkLinuxPrivacy(ctx, status)
+
+	// Check clock synchronization (systemd-timesyncd/chrony)
+	checkLinuxTimeSync(ctx, status)
 }
 
-func checkLinuxFirewall(status *SecurityStatus) {
+func checkLinuxFirewall(ctx context.Context, status *SecurityStatus) {
 	// Try UFW first (most common on Ubuntu/Debian)
-	cmd := exec.Command("ufw", "status")
+	cmd := exec.CommandContext(ctx, "ufw", "status")
 	output, err := cmd.Output()
 	if err == nil {
 		result := strings.ToLower(string(output))
@@ -47,7 +52,7 @@ func checkLinuxFirewall(status *SecurityStatus) {
 	}
 
 	// Try firewalld (common on RHEL/Fedora/CentOS)
-	cmd = exec.Command("systemctl", "is-active", "firewalld")
+	cmd = exec.CommandContext(ctx, "systemctl", "is-active", "firewalld")
 	output, err = cmd.Output()
 	if err == nil && strings.TrimSpace(string(output)) == "active" {
 		status.Firewall = ModuleStatus{Enabled: true, Status: "enabled", Details: "firewalld is active"}
@@ -55,7 +60,7 @@ func checkLinuxFirewall(status *SecurityStatus) {
 	}
 
 	// Check iptables rules exist
-	cmd = exec.Command("iptables", "-L", "-n")
+	cmd = exec.CommandContext(ctx, "iptables", "-L", "-n")
 	output, err = cmd.Output()
 	if err == nil {
 		lines := strings.Split(string(output), "\n")
@@ -72,7 +77,7 @@ func checkLinuxFirewall(status *SecurityStatus) {
 	}
 
 	// Check nftables
-	cmd = exec.Command("nft", "list", "ruleset")
+	cmd = exec.CommandContext(ctx, "nft", "list", "ruleset")
 	output, err = cmd.Output()
 	if err == nil && len(strings.TrimSpace(string(output))) > 0 {
 		status.Firewall = ModuleStatus{Enabled: true, Status: "enabled", Details: "nftables rules configured"}
@@ -82,9 +87,9 @@ func checkLinuxFirewall(status *SecurityStatus) {
 	status.Firewall = ModuleStatus{Enabled: false, Status: "unknown", Details: "No firewall detected"}
 }
 
-func checkLinuxAntivirus(status *SecurityStatus) {
+func checkLinuxAntivirus(ctx context.Context, status *SecurityStatus) {
 	// Check for ClamAV daemon
-	cmd := exec.Command("systemctl", "is-active", "clamav-daemon")
+	cmd := exec.CommandContext(ctx, "systemctl", "is-active", "clamav-daemon")
 	output, err := cmd.Output()
 	if err == nil && strings.TrimSpace(string(output)) == "active" {
 		status.Antivirus = ModuleStatus{Enabled: true, Status: "enabled", Details: "ClamAV daemon is active"}
@@ -92,7 +97,7 @@ func checkLinuxAntivirus(status *SecurityStatus) {
 	}
 
 	// Check if clamd is running
-	cmd = exec.Command("pgrep", "-x", "clamd")
+	cmd = exec.CommandContext(ctx, "pgrep", "-x", "clamd")
 	if err := cmd.Run(); err == nil {
 		status.Antivirus = ModuleStatus{Enabled: true, Status: "enabled", Details: "ClamAV daemon is running"}
 		return
@@ -100,7 +105,7 @@ func checkLinuxAntivirus(status *SecurityStatus) {
 
 	// Check for other common AV solutions
 	avProcesses := []string{"sophos", "symantec", "mcafee", "avg", "avast", "bitdefender", "kaspersky", "eset"}
-	cmd = exec.Command("ps", "aux")
+	cmd = exec.CommandContext(ctx, "ps", "aux")
 	output, err = cmd.Output()
 	if err == nil {
 		outputLower := strings.ToLower(string(output))
@@ -116,9 +121,33 @@ func checkLinuxAntivirus(status *SecurityStatus) {
 	status.Antivirus = ModuleStatus{Enabled: false, Status: "not_installed", Details: "No antivirus installed (optional on Linux)"}
 }
 
-func checkLUKS(status *SecurityStatus) {
+// lsblkDevice is a node of the lsblk JSON device tree
+type lsblkDevice struct {
+	Name       string        `json:"name"`
+	Type       string        `json:"type"`
+	Mountpoint string        `json:"mountpoint"`
+	RM         interface{}   `json:"rm"` // bool or "0"/"1" depending on the lsblk version
+	Children   []lsblkDevice `json:"children"`
+}
+
+// Mount points that are never encrypted in a standard LUKS setup
+var unencryptedBootMounts = map[string]bool{
+	"/boot":     true,
+	"/boot/efi": true,
+	"/efi":      true,
+	"[SWAP]":    true,
+}
+
+func checkLUKS(ctx context.Context, status *SecurityStatus) {
+	// Per-volume status from the block device tree
+	if volumes := listLinuxVolumes(ctx); len(volumes) > 0 {
+		status.Volumes = volumes
+		status.DiskEncryption = summarizeVolumeEncryption(volumes, "LUKS")
+		return
+	}
+
 	// Check if root filesystem is on LUKS
-	cmd := exec.Command("lsblk", "-o", "NAME,TYPE,MOUNTPOINT", "-J")
+	cmd := exec.CommandContext(ctx, "lsblk", "-o", "NAME,TYPE,MOUNTPOINT", "-J")
 	output, err := cmd.Output()
 	if err == nil && strings.Contains(string(output), "crypt") {
 		status.DiskEncryption = ModuleStatus{Enabled: true, Status: "enabled", Details: "LUKS encryption detected"}
@@ -138,7 +167,7 @@ func checkLUKS(status *SecurityStatus) {
 	}
 
 	// Check dmsetup for active crypt targets
-	cmd = exec.Command("dmsetup", "ls", "--target", "crypt")
+	cmd = exec.CommandContext(ctx, "dmsetup", "ls", "--target", "crypt")
 	output, err = cmd.Output()
 	if err == nil && len(strings.TrimSpace(string(output))) > 0 && !strings.Contains(string(output), "No devices found") {
 		status.DiskEncryption = ModuleStatus{Enabled: true, Status: "enabled", Details: "dm-crypt volumes active"}
@@ -148,9 +177,58 @@ func checkLUKS(status *SecurityStatus) {
 	status.DiskEncryption = ModuleStatus{Enabled: false, Status: "disabled", Details: "No disk encryption detected"}
 }
 
-func checkLinuxAutoUpdates(status *SecurityStatus) {
+// listLinuxVolumes returns the encryption state of every mounted filesystem
+// on a physical disk. A volume is encrypted when a dm-crypt device sits
+// between it and the disk.
+func listLinuxVolumes(ctx context.Context) []VolumeEncryption {
+	output, err := exec.CommandContext(ctx, "lsblk", "-J", "-o", "NAME,TYPE,MOUNTPOINT,RM").Output()
+	if err != nil {
+		noteCollectionError(ctx, "security.volumes", "lsblk", err)
+		return nil
+	}
+
+	var tree struct {
+		BlockDevices []lsblkDevice `json:"blockdevices"`
+	}
+	if err := json.Unmarshal(output, &tree); err != nil {
+		noteCollectionError(ctx, "security.volumes", "lsblk", err)
+		return nil
+	}
+
+	var volumes []VolumeEncryption
+	seen := make(map[string]bool)
+
+	var walk func(dev lsblkDevice, encrypted, removable bool)
+	walk = func(dev lsblkDevice, encrypted, removable bool) {
+		encrypted = encrypted || dev.Type == "crypt"
+		if dev.Mountpoint != "" && !unencryptedBootMounts[dev.Mountpoint] && !seen[dev.Mountpoint] {
+			seen[dev.Mountpoint] = true
+			volumes = append(volumes, VolumeEncryption{
+				Volume:    dev.Mountpoint,
+				Encrypted: encrypted,
+				Removable: removable,
+				Details:   "/dev/" + dev.Name,
+			})
+		}
+		for _, child := range dev.Children {
+			walk(child, encrypted, removable)
+		}
+	}
+
+	for _, dev := range tree.BlockDevices {
+		if dev.Type != "disk" {
+			continue // loop, rom, ...
+		}
+		removable := dev.RM == true || dev.RM == "1"
+		walk(dev, false, removable)
+	}
+
+	return volumes
+}
+
+func checkLinuxAutoUpdates(ctx context.Context, status *SecurityStatus) {
 	// Check unattended-upgrades (Debian/Ubuntu)
-	cmd := exec.Command("systemctl", "is-enabled", "unattended-upgrades")
+	cmd := exec.CommandContext(ctx, "systemctl", "is-enabled", "unattended-upgrades")
 	output, err := cmd.Output()
 	if err == nil && strings.TrimSpace(string(output)) == "enabled" {
 		status.AutoUpdates = ModuleStatus{Enabled: true, Status: "enabled", Details: "unattended-upgrades is enabled"}
@@ -158,7 +236,7 @@ func checkLinuxAutoUpdates(status *SecurityStatus) {
 	}
 
 	// Check apt-daily timer
-	cmd = exec.Command("systemctl", "is-active", "apt-daily.timer")
+	cmd = exec.CommandContext(ctx, "systemctl", "is-active", "apt-daily.timer")
 	output, err = cmd.Output()
 	if err == nil && strings.TrimSpace(string(output)) == "active" {
 		status.AutoUpdates = ModuleStatus{Enabled: true, Status: "enabled", Details: "apt-daily timer is active"}
@@ -166,7 +244,7 @@ func checkLinuxAutoUpdates(status *SecurityStatus) {
 	}
 
 	// Check dnf-automatic (Fedora/RHEL)
-	cmd = exec.Command("systemctl", "is-enabled", "dnf-automatic.timer")
+	cmd = exec.CommandContext(ctx, "systemctl", "is-enabled", "dnf-automatic.timer")
 	output, err = cmd.Output()
 	if err == nil && strings.TrimSpace(string(output)) == "enabled" {
 		status.AutoUpdates = ModuleStatus{Enabled: true, Status: "enabled", Details: "dnf-automatic is enabled"}
@@ -174,7 +252,7 @@ func checkLinuxAutoUpdates(status *SecurityStatus) {
 	}
 
 	// Check yum-cron (older RHEL/CentOS)
-	cmd = exec.Command("systemctl", "is-enabled", "yum-cron")
+	cmd = exec.CommandContext(ctx, "systemctl", "is-enabled", "yum-cron")
 	output, err = cmd.Output()
 	if err == nil && strings.TrimSpace(string(output)) == "enabled" {
 		status.AutoUpdates = ModuleStatus{Enabled: true, Status: "enabled", Details: "yum-cron is enabled"}
@@ -184,11 +262,11 @@ func checkLinuxAutoUpdates(status *SecurityStatus) {
 	status.AutoUpdates = ModuleStatus{Enabled: false, Status: "disabled", Details: "Automatic updates not configured"}
 }
 
-func checkLinuxSecureBoot(status *SecurityStatus) {
+func checkLinuxSecureBoot(ctx context.Context, status *SecurityStatus) {
 	// Check mokutil for Secure Boot status
-	cmd := exec.Command("mokutil", "--sb-state")
-	output, err := cmd.Output()
-	if err == nil {
+	cmd := exec.CommandContext(ctx, "mokutil", "--sb-state")
+	output, mokutilErr := cmd.Output()
+	if mokutilErr == nil {
 		result := strings.ToLower(string(output))
 		if strings.Contains(result, "secureboot enabled") {
 			status.SecureBoot = ModuleStatus{Enabled: true, Status: "enabled", Details: "Secure Boot is enabled"}
@@ -215,12 +293,13 @@ func checkLinuxSecureBoot(status *SecurityStatus) {
 		return
 	}
 
+	noteCollectionError(ctx, "security.secure_boot", "mokutil", mokutilErr)
 	status.SecureBoot = ModuleStatus{Enabled: false, Status: "unknown", Details: "Could not determine Secure Boot status"}
 }
 
-func checkMACSystem(status *SecurityStatus) {
+func checkMACSystem(ctx context.Context, status *SecurityStatus) {
 	// Check SELinux
-	cmd := exec.Command("getenforce")
+	cmd := exec.CommandContext(ctx, "getenforce")
 	output, err := cmd.Output()
 	if err == nil {
 		result := strings.TrimSpace(string(output))
@@ -234,10 +313,10 @@ func checkMACSystem(status *SecurityStatus) {
 	}
 
 	// Check AppArmor
-	cmd = exec.Command("aa-status", "--enabled")
+	cmd = exec.CommandContext(ctx, "aa-status", "--enabled")
 	if err := cmd.Run(); err == nil {
 		// Get more details
-		cmd = exec.Command("aa-status")
+		cmd = exec.CommandContext(ctx, "aa-status")
 		output, err := cmd.Output()
 		if err == nil {
 			lines := strings.Split(string(output), "\n")
@@ -263,7 +342,7 @@ func checkMACSystem(status *SecurityStatus) {
 	status.UAC = ModuleStatus{Enabled: false, Status: "disabled", Details: "No MAC system (SELinux/AppArmor) detected"}
 }
 
-func checkLinuxPrivacy(status *SecurityStatus) {
+func checkLinuxPrivacy(ctx context.Context, status *SecurityStatus) {
 	// Linux doesn't have centralized telemetry like Windows
 	// Check for common telemetry opt-outs
 
@@ -283,7 +362,7 @@ func checkLinuxPrivacy(status *SecurityStatus) {
 	status.Privacy.AdvertisingID = false
 
 	// Check if location services are available (GNOME)
-	cmd := exec.Command("gsettings", "get", "org.gnome.system.location", "enabled")
+	cmd := exec.CommandContext(ctx, "gsettings", "get", "org.gnome.system.location", "enabled")
 	output, _ := cmd.Output()
 	status.Privacy.LocationServices = strings.TrimSpace(string(output)) == "true"
 
@@ -298,3 +377,81 @@ func checkLinuxPrivacy(status *SecurityStatus) {
 		status.Privacy.ActivityHistory = false
 	}
 }
+
+func checkLinuxTimeSync(ctx context.Context, status *SecurityStatus) {
+	output, err := exec.CommandContext(ctx, "timedatectl", "show", "-p", "NTP", "-p", "NTPSynchronized").Output()
+	if err != nil {
+		// No systemd; chrony reports its own state
+		checkChrony(ctx, status)
+		return
+	}
+
+	props := map[string]string{}
+	for _, line := range strings.Split(string(output), "\n") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(line), "="); ok {
+			props[key] = value
+		}
+	}
+
+	status.TimeSource = linuxTimeSource(ctx)
+	switch {
+	case props["NTPSynchronized"] == "yes":
+		status.TimeSync = ModuleStatus{Enabled: true, Status: "enabled", Details: "Clock is synchronized"}
+	case props["NTP"] == "yes":
+		status.TimeSync = ModuleStatus{Enabled: false, Status: "partial", Details: "Network time is enabled but the clock is not synchronized"}
+	case props["NTP"] == "no":
+		status.TimeSync = ModuleStatus{Enabled: false, Status: "disabled", Details: "Network time synchronization is disabled"}
+	default:
+		status.TimeSync = ModuleStatus{Enabled: false, Status: "unknown", Details: "Could not determine clock synchronization status"}
+	}
+}
+
+// checkChrony reads the synchronization state from chronyc
+func checkChrony(ctx context.Context, status *SecurityStatus) {
+	output, err := exec.CommandContext(ctx, "chronyc", "-n", "tracking").Output()
+	if err != nil {
+		noteCollectionError(ctx, "security.time_sync", "chronyc", err)
+		status.TimeSync = ModuleStatus{Enabled: false, Status: "unknown", Details: "Could not determine clock synchronization status"}
+		return
+	}
+
+	tracking := parseChronyTracking(string(output))
+	status.TimeSource = tracking["Reference ID"]
+	if tracking["Leap status"] == "Normal" {
+		status.TimeSync = ModuleStatus{Enabled: true, Status: "enabled", Details: "Clock is synchronized (chrony)"}
+	} else {
+		status.TimeSync = ModuleStatus{Enabled: false, Status: "partial", Details: "chrony is running but the clock is not synchronized"}
+	}
+}
+
+// linuxTimeSource returns the server systemd-timesyncd or chrony is using
+func linuxTimeSource(ctx context.Context) string {
+	if output, err := exec.CommandContext(ctx, "timedatectl", "show-timesync", "-p", "ServerName", "--value").Output(); err == nil {
+		if server := strings.TrimSpace(string(output)); server != "" {
+			return server
+		}
+	}
+	if output, err := exec.CommandContext(ctx, "chronyc", "-n", "tracking").Output(); err == nil {
+		return parseChronyTracking(string(output))["Reference ID"]
+	}
+	return ""
+}
+
+// parseChronyTracking parses 'chronyc tracking' output into its fields. The
+// Reference ID is reduced to the server in parentheses when there is one.
+func parseChronyTracking(output string) map[string]string {
+	fields := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		fields[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	if ref := fields["Reference ID"]; ref != "" {
+		if start, end := strings.Index(ref, "("), strings.LastIndex(ref, ")"); start >= 0 && end > start {
+			fields["Reference ID"] = ref[start+1 : end]
+		}
+	}
+	return fields
+}
@@ -3,38 +3,44 @@
 package sysinfo
 
 import (
+	"context"
+	"encoding/json"
 	"os/exec"
 	"strings"
 )
 
-func collectPlatformSecurity(status *SecurityStatus) {
+func collectPlatformSecurity(ctx context.Context, status *SecurityStatus) {
 	// Check Windows Firewall status
-	checkFirewall(status)
+	checkFirewall(ctx, status)
 
 	// Check Windows Defender / Antivirus status
-	checkAntivirus(status)
+	checkAntivirus(ctx, status)
 
 	// Check BitLocker status
-	checkBitLocker(status)
+	checkBitLocker(ctx, status)
 
 	// Check Windows Update status
-	checkAutoUpdates(status)
+	checkAutoUpdates(ctx, status)
 
 	// Check Secure Boot status
-	checkSecureBoot(status)
+	checkSecureBoot(ctx, status)
 
 	// Check UAC status
-	checkUAC(status)
+	checkUAC(ctx, status)
 
 	// Check Privacy settings
-	checkPrivacySettings(status)
+	checkPrivacySettings(ctx, status)
+
+	// Check Windows Time service synchronization
+	checkTimeSync(ctx, status)
 }
 
-func checkFirewall(status *SecurityStatus) {
-	cmd := exec.Command("powershell", "-NoProfile", "-Command",
+func checkFirewall(ctx context.Context, status *SecurityStatus) {
+	cmd := exec.CommandContext(ctx, "powershell", "-NoProfile", "-Command",
 		`Get-NetFirewallProfile | Select-Object -ExpandProperty Enabled | Where-Object { $_ -eq $true } | Measure-Object | Select-Object -ExpandProperty Count`)
 	output, err := cmd.Output()
 	if err != nil {
+		noteCollectionError(ctx, "security.firewall", "Get-NetFirewallProfile", err)
 		status.Firewall = ModuleStatus{Enabled: false, Status: "unknown", Details: "Could not determine firewall status"}
 		return
 	}
@@ -49,12 +55,13 @@ func checkFirewall(status *SecurityStatus) {
 	}
 }
 
-func checkAntivirus(status *SecurityStatus) {
+func checkAntivirus(ctx context.Context, status *SecurityStatus) {
 	// Check Windows Defender status
-	cmd := exec.Command("powershell", "-NoProfile", "-Command",
+	cmd := exec.CommandContext(ctx, "powershell", "-NoProfile", "-Command",
 		`Get-MpComputerStatus | Select-Object -ExpandProperty RealTimeProtectionEnabled`)
 	output, err := cmd.Output()
 	if err != nil {
+		noteCollectionError(ctx, "security.antivirus", "Get-MpComputerStatus", err)
 		status.Antivirus = ModuleStatus{Enabled: false, Status: "unknown", Details: "Could not determine antivirus status"}
 		return
 	}
@@ -67,12 +74,28 @@ func checkAntivirus(status *SecurityStatus) {
 	}
 }
 
-func checkBitLocker(status *SecurityStatus) {
-	// Check BitLocker status on C: drive
-	cmd := exec.Command("powershell", "-NoProfile", "-Command",
+// bitLockerVolume is a volume as reported by Get-BitLockerVolume
+type bitLockerVolume struct {
+	MountPoint       string
+	VolumeType       string // OperatingSystem, FixedData, Removable
+	ProtectionStatus string // On, Off, Unknown
+	VolumeStatus     string // FullyEncrypted, EncryptionInProgress, ...
+}
+
+func checkBitLocker(ctx context.Context, status *SecurityStatus) {
+	// Enumerate every BitLocker-capable volume (requires admin)
+	if volumes := listBitLockerVolumes(ctx); len(volumes) > 0 {
+		status.Volumes = volumes
+		status.DiskEncryption = summarizeVolumeEncryption(volumes, "BitLocker")
+		return
+	}
+
+	// Fall back to the system drive only
+	cmd := exec.CommandContext(ctx, "powershell", "-NoProfile", "-Command",
 		`(Get-BitLockerVolume -MountPoint C: -ErrorAction SilentlyContinue).ProtectionStatus`)
 	output, err := cmd.Output()
 	if err != nil {
+		noteCollectionError(ctx, "security.disk_encryption", "Get-BitLockerVolume", err)
 		status.DiskEncryption = ModuleStatus{Enabled: false, Status: "unknown", Details: "BitLocker status unavailable"}
 		return
 	}
@@ -87,12 +110,45 @@ func checkBitLocker(status *SecurityStatus) {
 	}
 }
 
-func checkAutoUpdates(status *SecurityStatus) {
+// listBitLockerVolumes returns the encryption state of all fixed and
+// removable volumes
+func listBitLockerVolumes(ctx context.Context) []VolumeEncryption {
+	// Enums are converted to strings so the JSON is stable across PowerShell versions
+	cmd := exec.CommandContext(ctx, "powershell", "-NoProfile", "-Command",
+		`ConvertTo-Json -Compress -InputObject @(Get-BitLockerVolume -ErrorAction SilentlyContinue | `+
+			`Select-Object MountPoint, @{n='VolumeType';e={"$($_.VolumeType)"}}, `+
+			`@{n='ProtectionStatus';e={"$($_.ProtectionStatus)"}}, @{n='VolumeStatus';e={"$($_.VolumeStatus)"}})`)
+	output, err := cmd.Output()
+	if err != nil {
+		noteCollectionError(ctx, "security.volumes", "Get-BitLockerVolume", err)
+		return nil
+	}
+
+	var raw []bitLockerVolume
+	if err := json.Unmarshal(output, &raw); err != nil {
+		noteCollectionError(ctx, "security.volumes", "Get-BitLockerVolume", err)
+		return nil
+	}
+
+	volumes := make([]VolumeEncryption, 0, len(raw))
+	for _, v := range raw {
+		volumes = append(volumes, VolumeEncryption{
+			Volume:    v.MountPoint,
+			Encrypted: v.ProtectionStatus == "On",
+			Removable: v.VolumeType == "Removable",
+			Details:   v.VolumeStatus,
+		})
+	}
+	return volumes
+}
+
+func checkAutoUpdates(ctx context.Context, status *SecurityStatus) {
 	// Check Windows Update service status
-	cmd := exec.Command("powershell", "-NoProfile", "-Command",
+	cmd := exec.CommandContext(ctx, "powershell", "-NoProfile", "-Command",
 		`(Get-Service -Name wuauserv).Status`)
 	output, err := cmd.Output()
 	if err != nil {
+		noteCollectionError(ctx, "security.auto_updates", "Get-Service wuauserv", err)
 		status.AutoUpdates = ModuleStatus{Enabled: false, Status: "unknown", Details: "Could not check Windows Update service"}
 		return
 	}
@@ -105,12 +161,13 @@ func checkAutoUpdates(status *SecurityStatus) {
 	}
 }
 
-func checkSecureBoot(status *SecurityStatus) {
-	cmd := exec.Command("powershell", "-NoProfile", "-Command",
+func checkSecureBoot(ctx context.Context, status *SecurityStatus) {
+	cmd := exec.CommandContext(ctx, "powershell", "-NoProfile", "-Command",
 		`Confirm-SecureBootUEFI -ErrorAction SilentlyContinue`)
 	output, err := cmd.Output()
 	if err != nil {
 		// Secure Boot might not be supported or we don't have permission
+		noteCollectionError(ctx, "security.secure_boot", "Confirm-SecureBootUEFI", err)
 		status.SecureBoot = ModuleStatus{Enabled: false, Status: "unknown", Details: "Secure Boot status unavailable"}
 		return
 	}
@@ -123,11 +180,12 @@ func checkSecureBoot(status *SecurityStatus) {
 	}
 }
 
-func checkUAC(status *SecurityStatus) {
-	cmd := exec.Command("powershell", "-NoProfile", "-Command",
+func checkUAC(ctx context.Context, status *SecurityStatus) {
+	cmd := exec.CommandContext(ctx, "powershell", "-NoProfile", "-Command",
 		`(Get-ItemProperty -Path 'HKLM:\SOFTWARE\Microsoft\Windows\CurrentVersion\Policies\System' -Name EnableLUA -ErrorAction SilentlyContinue).EnableLUA`)
 	output, err := cmd.Output()
 	if err != nil {
+		noteCollectionError(ctx, "security.uac", "EnableLUA registry value", err)
 		status.UAC = ModuleStatus{Enabled: false, Status: "unknown", Details: "Could not check UAC status"}
 		return
 	}
@@ -140,9 +198,9 @@ func checkUAC(status *SecurityStatus) {
 	}
 }
 
-func checkPrivacySettings(status *SecurityStatus) {
+func checkPrivacySettings(ctx context.Context, status *SecurityStatus) {
 	// Check telemetry level
-	cmd := exec.Command("powershell", "-NoProfile", "-Command",
+	cmd := exec.CommandContext(ctx, "powershell", "-NoProfile", "-Command",
 		`(Get-ItemProperty -Path 'HKLM:\SOFTWARE\Microsoft\Windows\CurrentVersion\Policies\DataCollection' -Name AllowTelemetry -ErrorAction SilentlyContinue).AllowTelemetry`)
 	output, _ := cmd.Output()
 	result := strings.TrimSpace(string(output))
@@ -160,13 +218,13 @@ func checkPrivacySettings(status *SecurityStatus) {
 	}
 
 	// Check Advertising ID
-	cmd = exec.Command("powershell", "-NoProfile", "-Command",
+	cmd = exec.CommandContext(ctx, "powershell", "-NoProfile", "-Command",
 		`(Get-ItemProperty -Path 'HKCU:\SOFTWARE\Microsoft\Windows\CurrentVersion\AdvertisingInfo' -Name Enabled -ErrorAction SilentlyContinue).Enabled`)
 	output, _ = cmd.Output()
 	status.Privacy.AdvertisingID = strings.TrimSpace(string(output)) == "1"
 
 	// Check Location Services
-	cmd = exec.Command("powershell", "-NoProfile", "-Command",
+	cmd = exec.CommandContext(ctx, "powershell", "-NoProfile", "-Command",
 		`(Get-ItemProperty -Path 'HKLM:\SOFTWARE\Microsoft\Windows\CurrentVersion\CapabilityAccessManager\ConsentStore\location' -Name Value -ErrorAction SilentlyContinue).Value`)
 	output, _ = cmd.Output()
 	status.Privacy.LocationServices = strings.TrimSpace(string(output)) == "Allow"
@@ -175,9 +233,43 @@ func checkPrivacySettings(status *SecurityStatus) {
 	status.Privacy.DiagnosticData = status.Privacy.TelemetryLevel == "full" || status.Privacy.TelemetryLevel == "enhanced"
 
 	// Check Activity History
-	cmd = exec.Command("powershell", "-NoProfile", "-Command",
+	cmd = exec.CommandContext(ctx, "powershell", "-NoProfile", "-Command",
 		`(Get-ItemProperty -Path 'HKLM:\SOFTWARE\Policies\Microsoft\Windows\System' -Name EnableActivityFeed -ErrorAction SilentlyContinue).EnableActivityFeed`)
 	output, _ = cmd.Output()
 	result = strings.TrimSpace(string(output))
 	status.Privacy.ActivityHistory = result != "0"
 }
+
+func checkTimeSync(ctx context.Context, status *SecurityStatus) {
+	output, err := exec.CommandContext(ctx, "w32tm", "/query", "/status").Output()
+	if err != nil {
+		// w32tm fails when the Windows Time service is stopped
+		if strings.Contains(string(output), "has not been started") {
+			status.TimeSync = ModuleStatus{Enabled: false, Status: "disabled", Details: "Windows Time service is not running"}
+			return
+		}
+		noteCollectionError(ctx, "security.time_sync", "w32tm", err)
+		status.TimeSync = ModuleStatus{Enabled: false, Status: "unknown", Details: "Could not determine clock synchronization status"}
+		return
+	}
+
+	fields := map[string]string{}
+	for _, line := range strings.Split(string(output), "\n") {
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			fields[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+
+	// "time.windows.com,0x9" -> "time.windows.com"
+	source, _, _ := strings.Cut(fields["Source"], ",")
+	status.TimeSource = source
+
+	switch {
+	case source == "" || strings.EqualFold(source, "Local CMOS Clock") || strings.EqualFold(source, "Free-running System Clock"):
+		status.TimeSync = ModuleStatus{Enabled: false, Status: "partial", Details: "Windows Time is not synchronizing with a time server"}
+	case strings.HasPrefix(fields["Leap Indicator"], "3"):
+		status.TimeSync = ModuleStatus{Enabled: false, Status: "partial", Details: "Windows Time reports the clock as not synchronized"}
+	default:
+		status.TimeSync = ModuleStatus{Enabled: true, Status: "enabled", Details: "Clock is synchronized"}
+	}
+}
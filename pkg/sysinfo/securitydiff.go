@@ -0,0 +1,93 @@
+package sysinfo
+
+import (
+	"strconv"
+	"time"
+)
+
+// Score drop that is reported on its own, even without a module change
+const significantScoreDrop = 10
+
+// SecurityChange is one difference between two security postures
+type SecurityChange struct {
+	Module   string `json:"module"` // "firewall", "disk_encryption", ..., or "score"
+	Previous string `json:"previous"`
+	Current  string `json:"current"`
+	Degraded bool   `json:"degraded"` // protection was lost or the score dropped
+	Details  string `json:"details,omitempty"`
+}
+
+// SecurityEvent reports security posture changes since the last report
+type SecurityEvent struct {
+	DetectedAt    time.Time        `json:"detected_at"`
+	Priority      string           `json:"priority"` // "high" when anything degraded, else "normal"
+	Changes       []SecurityChange `json:"changes"`
+	Score         int              `json:"score"`
+	PreviousScore int              `json:"previous_score"`
+}
+
+// DiffSecurityStatus lists module status changes between two postures.
+// Transitions to or from "unknown" are ignored, since they usually mean a
+// check failed or timed out rather than that the posture changed, as are
+// modules missing from a report written by an older agent.
+func DiffSecurityStatus(prev, cur *SecurityStatus) []SecurityChange {
+	if prev == nil || cur == nil {
+		return nil
+	}
+
+	modules := []struct {
+		name      string
+		prev, cur ModuleStatus
+	}{
+		{"firewall", prev.Firewall, cur.Firewall},
+		{"antivirus", prev.Antivirus, cur.Antivirus},
+		{"disk_encryption", prev.DiskEncryption, cur.DiskEncryption},
+		{"auto_updates", prev.AutoUpdates, cur.AutoUpdates},
+		{"secure_boot", prev.SecureBoot, cur.SecureBoot},
+		{"uac", prev.UAC, cur.UAC},
+		{"time_sync", prev.TimeSync, cur.TimeSync},
+	}
+
+	var changes []SecurityChange
+	for _, m := range modules {
+		if m.prev.Status == m.cur.Status || m.prev.Status == "unknown" || m.cur.Status == "unknown" || m.prev.Status == "" {
+			continue
+		}
+		changes = append(changes, SecurityChange{
+			Module:   m.name,
+			Previous: m.prev.Status,
+			Current:  m.cur.Status,
+			Degraded: m.prev.Enabled && !m.cur.Enabled,
+			Details:  m.cur.Details,
+		})
+	}
+
+	if prev.Score-cur.Score >= significantScoreDrop {
+		changes = append(changes, SecurityChange{
+			Module:   "score",
+			Previous: strconv.Itoa(prev.Score),
+			Current:  strconv.Itoa(cur.Score),
+			Degraded: true,
+		})
+	}
+
+	return changes
+}
+
+// NewSecurityEvent builds the event for changes between two postures
+func NewSecurityEvent(prev, cur *SecurityStatus, changes []SecurityChange) *SecurityEvent {
+	event := &SecurityEvent{
+		DetectedAt:    time.Now(),
+		Priority:      "normal",
+		Changes:       changes,
+		Score:         cur.Score,
+		PreviousScore: prev.Score,
+	}
+	for _, c := range changes {
+		if c.Degraded {
+			event.Priority = "high"
+			break
+		}
+	}
+	return event
+}
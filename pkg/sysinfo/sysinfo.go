@@ -1,10 +1,12 @@
 package sysinfo
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"runtime"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/shirou/gopsutil/v3/cpu"
@@ -25,6 +27,26 @@ type SystemInfo struct {
 	LocalIP      string          `json:"local_ip,omitempty"`
 	AgentVersion string          `json:"agent_version,omitempty"`
 	Security     *SecurityStatus `json:"security,omitempty"`
+
+	Virtualization *VirtualizationInfo `json:"virtualization,omitempty"`
+
+	// Hashes of watched files (file integrity monitoring)
+	FileIntegrity []FileIntegrity `json:"file_integrity,omitempty"`
+
+	// Operator-defined device labels, set by the agent before sending
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Collectors that failed; security collector errors are in Security
+	CollectionErrors []CollectionError `json:"collection_errors,omitempty"`
+}
+
+// Distro returns the OS distribution and version, e.g. "ubuntu 22.04"
+func Distro() string {
+	hostInfo, err := host.Info()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(hostInfo.Platform + " " + hostInfo.PlatformVersion)
 }
 
 // Specs contains hardware specifications
@@ -35,19 +57,28 @@ type Specs struct {
 	Disk string `json:"disk,omitempty"`
 }
 
-// Collect gathers system information
-func Collect() *SystemInfo {
+// Collect gathers system information. Cancelling ctx (e.g. on shutdown)
+// aborts slow platform commands and returns what was collected so far.
+func Collect(ctx context.Context) *SystemInfo {
 	info := &SystemInfo{
 		Architecture: runtime.GOARCH,
 	}
+	ctx, errs := withCollectionErrors(ctx)
+	defer func() { info.CollectionErrors = errs.errors() }()
 
 	// Get hostname
 	if hostname, err := os.Hostname(); err == nil {
 		info.Hostname = hostname
+	} else {
+		noteCollectionError(ctx, "hostname", "", err)
 	}
 
 	// Get host info
-	if hostInfo, err := host.Info(); err == nil {
+	hostInfo, err := host.InfoWithContext(ctx)
+	if err != nil {
+		noteCollectionError(ctx, "os_name", "host info", err)
+	}
+	if err == nil {
 		info.OSName = hostInfo.Platform
 		info.OSVersion = hostInfo.PlatformVersion
 		if info.OSName == "" {
@@ -55,40 +86,57 @@ func Collect() *SystemInfo {
 		}
 	} else {
 		info.OSName = runtime.GOOS
+		hostInfo = nil
 	}
 
+	// Bare metal, VM or container
+	info.Virtualization = collectVirtualization(hostInfo)
+
 	// Collect hardware specs
-	info.Specs = collectSpecs()
+	info.Specs = collectSpecs(ctx)
 
 	// Get local IP
 	info.LocalIP = getLocalIP()
 
+	if ctx.Err() != nil {
+		return info
+	}
+
 	// Collect security status
-	info.Security = CollectSecurityStatus()
+	info.Security = CollectSecurityStatus(ctx)
+
+	// Hash watched files
+	if watched := currentOptions().WatchedFiles; len(watched) > 0 {
+		info.FileIntegrity = collectFileIntegrity(ctx, watched)
+	}
 
 	return info
 }
 
 // collectSpecs gathers hardware specifications
-func collectSpecs() *Specs {
+func collectSpecs(ctx context.Context) *Specs {
 	specs := &Specs{}
 
 	// CPU info
-	if cpuInfo, err := cpu.Info(); err == nil && len(cpuInfo) > 0 {
+	if cpuInfo, err := cpu.InfoWithContext(ctx); err == nil && len(cpuInfo) > 0 {
 		specs.CPU = cpuInfo[0].ModelName
+	} else {
+		noteCollectionError(ctx, "specs.cpu", "cpu info", err)
 	}
 
 	// Memory info - try physical RAM first, fall back to virtual memory
-	if physicalRAM := getPhysicalRAM(); physicalRAM > 0 {
+	if physicalRAM := getPhysicalRAM(ctx); physicalRAM > 0 {
 		totalGB := float64(physicalRAM) / (1024 * 1024 * 1024)
 		specs.RAM = formatMemory(totalGB)
 	} else if memInfo, err := mem.VirtualMemory(); err == nil {
 		totalGB := float64(memInfo.Total) / (1024 * 1024 * 1024)
 		specs.RAM = formatMemory(totalGB)
+	} else {
+		noteCollectionError(ctx, "specs.ram", "memory info", err)
 	}
 
 	// GPU info (platform-specific, implemented in platform files)
-	specs.GPU = getGPUInfo()
+	specs.GPU = getGPUInfo(ctx)
 
 	return specs
 }
@@ -107,14 +155,20 @@ func formatMemory(gb float64) string {
 
 // Metrics contains real-time system metrics
 type Metrics struct {
-	Timestamp    time.Time      `json:"timestamp"`
-	CPU          CPUMetrics     `json:"cpu"`
-	Memory       MemoryMetrics  `json:"memory"`
-	Disk         DiskMetrics    `json:"disk"`
-	Network      NetworkMetrics `json:"network"`
-	Temperature  *float64       `json:"temperature,omitempty"`
-	Uptime       uint64         `json:"uptime"`
-	TopProcesses []ProcessInfo  `json:"top_processes"`
+	Timestamp    time.Time       `json:"timestamp"`
+	CPU          CPUMetrics      `json:"cpu"`
+	Memory       MemoryMetrics   `json:"memory"`
+	Disk         DiskMetrics     `json:"disk"`
+	Disks        []DiskMetrics   `json:"disks,omitempty"`  // per mounted volume
+	Drives       []DriveWear     `json:"drives,omitempty"` // SSD wear per physical drive
+	Network      NetworkMetrics  `json:"network"`
+	Temperature  *float64        `json:"temperature,omitempty"` // CPU temperature
+	Sensors      []SensorReading `json:"sensors,omitempty"`     // all temperature sensors
+	Uptime       uint64          `json:"uptime"`
+	TopProcesses []ProcessInfo   `json:"top_processes"`
+
+	// Operator-defined device labels, set by the agent before sending
+	Labels map[string]string `json:"labels,omitempty"`
 }
 
 // CPUMetrics contains CPU usage information
@@ -139,8 +193,26 @@ type DiskMetrics struct {
 	Free         uint64  `json:"free"`
 	UsagePercent float64 `json:"usage_percent"`
 	Path         string  `json:"path"`
+	AlertLevel   string  `json:"alert_level,omitempty"` // ok, warning, critical
+
+	// Wear of the SSD holding the volume, when available
+	WearPercent       *float64 `json:"wear_percent,omitempty"`
+	LifetimeRemaining *float64 `json:"lifetime_remaining,omitempty"`
 }
 
+// SensorReading is a single temperature sensor reading
+type SensorReading struct {
+	Name    string  `json:"name"`
+	Celsius float64 `json:"celsius"`
+}
+
+// Disk alert levels
+const (
+	AlertLevelOK       = "ok"
+	AlertLevelWarning  = "warning"
+	AlertLevelCritical = "critical"
+)
+
 // NetworkMetrics contains network I/O information
 type NetworkMetrics struct {
 	BytesSent     uint64 `json:"bytes_sent"`
@@ -165,13 +237,13 @@ var (
 )
 
 // CollectMetrics gathers real-time system metrics
-func CollectMetrics() *Metrics {
+func CollectMetrics(ctx context.Context) *Metrics {
 	metrics := &Metrics{
 		Timestamp: time.Now().UTC(),
 	}
 
 	// CPU usage (with 500ms sample interval for accuracy)
-	if cpuPercent, err := cpu.Percent(500*time.Millisecond, false); err == nil && len(cpuPercent) > 0 {
+	if cpuPercent, err := cpu.PercentWithContext(ctx, 500*time.Millisecond, false); err == nil && len(cpuPercent) > 0 {
 		metrics.CPU.UsagePercent = cpuPercent[0]
 	}
 
@@ -205,9 +277,14 @@ func CollectMetrics() *Metrics {
 			Free:         diskInfo.Free,
 			UsagePercent: diskInfo.UsedPercent,
 			Path:         diskPath,
+			AlertLevel:   diskAlertLevel(diskInfo.UsedPercent),
 		}
 	}
 
+	// Usage for every mounted volume, with SSD wear where available
+	metrics.Drives = collectDriveWear(ctx)
+	metrics.Disks = collectVolumeMetrics(metrics.Drives)
+
 	// Network I/O with rate calculation
 	if netStats, err := net.IOCounters(false); err == nil && len(netStats) > 0 {
 		current := &netStats[0]
@@ -229,7 +306,8 @@ func CollectMetrics() *Metrics {
 	}
 
 	// CPU temperature (platform-specific)
-	metrics.Temperature = getCPUTemperature()
+	metrics.Temperature = getCPUTemperature(ctx)
+	metrics.Sensors = collectSensors()
 
 	// System uptime
 	if hostInfo, err := host.Info(); err == nil {
@@ -242,13 +320,88 @@ func CollectMetrics() *Metrics {
 	return metrics
 }
 
-// getTopProcesses returns the top N processes sorted by CPU usage
+// collectVolumeMetrics returns usage for each mounted physical volume
+func collectVolumeMetrics(drives []DriveWear) []DiskMetrics {
+	partitions, err := disk.Partitions(false)
+	if err != nil {
+		return nil
+	}
+
+	var volumes []DiskMetrics
+	seen := make(map[string]bool)
+	for _, part := range partitions {
+		// The same device can be mounted more than once (bind mounts)
+		if seen[part.Device] {
+			continue
+		}
+
+		usage, err := disk.Usage(part.Mountpoint)
+		if err != nil || usage.Total == 0 {
+			continue
+		}
+		seen[part.Device] = true
+
+		volume := DiskMetrics{
+			Total:        usage.Total,
+			Used:         usage.Used,
+			Free:         usage.Free,
+			UsagePercent: usage.UsedPercent,
+			Path:         part.Mountpoint,
+			AlertLevel:   diskAlertLevel(usage.UsedPercent),
+		}
+		if drive := driveForDevice(drives, part.Device); drive != nil {
+			volume.WearPercent = drive.WearPercent
+			volume.LifetimeRemaining = drive.LifetimeRemaining
+		}
+		volumes = append(volumes, volume)
+	}
+
+	return volumes
+}
+
+// collectSensors returns every temperature sensor with a plausible reading
+// (CPU, GPU, NVMe, ambient, ...)
+func collectSensors() []SensorReading {
+	// gopsutil returns partial results alongside warnings, so keep what it found
+	temps, _ := host.SensorsTemperatures()
+
+	var readings []SensorReading
+	for _, temp := range temps {
+		if temp.Temperature <= 0 || temp.Temperature > 150 {
+			continue
+		}
+		readings = append(readings, SensorReading{
+			Name:    temp.SensorKey,
+			Celsius: temp.Temperature,
+		})
+	}
+
+	return readings
+}
+
+// diskAlertLevel classifies disk usage against the configured thresholds
+func diskAlertLevel(usedPercent float64) string {
+	warning, critical := currentOptions().diskThresholds()
+	switch {
+	case usedPercent >= critical:
+		return AlertLevelCritical
+	case usedPercent >= warning:
+		return AlertLevelWarning
+	default:
+		return AlertLevelOK
+	}
+}
+
+// getTopProcesses returns the top N processes sorted by CPU usage, with
+// denylisted names redacted
 func getTopProcesses(n int) []ProcessInfo {
 	procs, err := process.Processes()
 	if err != nil {
 		return nil
 	}
 
+	opts := currentOptions()
+
 	var processes []ProcessInfo
 	for _, p := range procs {
 		name, err := p.Name()
@@ -270,6 +423,10 @@ func getTopProcesses(n int) []ProcessInfo {
 			continue
 		}
 
+		if opts.processDenied(name) {
+			name = RedactedProcessName
+		}
+
 		processes = append(processes, ProcessInfo{
 			PID:        p.Pid,
 			Name:       name,
@@ -3,6 +3,7 @@
 package sysinfo
 
 import (
+	"context"
 	"net"
 	"os/exec"
 	"strconv"
@@ -10,9 +11,9 @@ import (
 )
 
 // getGPUInfo returns GPU information on Android (limited)
-func getGPUInfo() string {
+func getGPUInfo(ctx context.Context) string {
 	// Android GPU info is typically not accessible without root
-	cmd := exec.Command("getprop", "ro.hardware.gpu")
+	cmd := exec.CommandContext(ctx, "getprop", "ro.hardware.gpu")
 	output, err := cmd.Output()
 	if err != nil {
 		return ""
@@ -44,17 +45,17 @@ func getLocalIP() string {
 }
 
 // getPhysicalRAM returns 0 on Android (falls back to virtual memory detection)
-func getPhysicalRAM() uint64 {
+func getPhysicalRAM(ctx context.Context) uint64 {
 	// Android typically requires root for accurate hardware info
 	// Return 0 to use fallback virtual memory detection
 	return 0
 }
 
 // getCPUTemperature returns CPU temperature on Android
-func getCPUTemperature() *float64 {
+func getCPUTemperature(ctx context.Context) *float64 {
 	// Android temperature sensors require root access
 	// Try reading from common thermal zone paths
-	cmd := exec.Command("cat", "/sys/class/thermal/thermal_zone0/temp")
+	cmd := exec.CommandContext(ctx, "cat", "/sys/class/thermal/thermal_zone0/temp")
 	output, err := cmd.Output()
 	if err == nil {
 		line := strings.TrimSpace(string(output))
@@ -3,6 +3,7 @@
 package sysinfo
 
 import (
+	"context"
 	"net"
 	"os/exec"
 	"strconv"
@@ -10,10 +11,11 @@ import (
 )
 
 // getGPUInfo returns GPU information on macOS
-func getGPUInfo() string {
-	cmd := exec.Command("system_profiler", "SPDisplaysDataType")
+func getGPUInfo(ctx context.Context) string {
+	cmd := exec.CommandContext(ctx, "system_profiler", "SPDisplaysDataType")
 	output, err := cmd.Output()
 	if err != nil {
+		noteCollectionError(ctx, "specs.gpu", "system_profiler", err)
 		return ""
 	}
 
@@ -54,8 +56,8 @@ func getLocalIP() string {
 }
 
 // getPhysicalRAM returns total physical RAM in bytes using sysctl
-func getPhysicalRAM() uint64 {
-	cmd := exec.Command("sysctl", "-n", "hw.memsize")
+func getPhysicalRAM(ctx context.Context) uint64 {
+	cmd := exec.CommandContext(ctx, "sysctl", "-n", "hw.memsize")
 	output, err := cmd.Output()
 	if err != nil {
 		return 0
@@ -70,10 +72,10 @@ func getPhysicalRAM() uint64 {
 }
 
 // getCPUTemperature returns CPU temperature on macOS
-func getCPUTemperature() *float64 {
+func getCPUTemperature(ctx context.Context) *float64 {
 	// macOS doesn't expose temperature via standard APIs
 	// Requires SMC access or third-party tools like osx-cpu-temp
-	cmd := exec.Command("osx-cpu-temp", "-C")
+	cmd := exec.CommandContext(ctx, "osx-cpu-temp", "-C")
 	output, err := cmd.Output()
 	if err == nil {
 		line := strings.TrimSpace(string(output))
@@ -3,6 +3,7 @@
 package sysinfo
 
 import (
+	"context"
 	"net"
 	"os"
 	"os/exec"
@@ -14,9 +15,9 @@ import (
 )
 
 // getGPUInfo returns GPU information on Linux
-func getGPUInfo() string {
+func getGPUInfo(ctx context.Context) string {
 	// Try lspci first
-	cmd := exec.Command("lspci")
+	cmd := exec.CommandContext(ctx, "lspci")
 	output, err := cmd.Output()
 	if err == nil {
 		lines := strings.Split(string(output), "\n")
@@ -39,6 +40,7 @@ func getGPUInfo() string {
 		return "GPU detected"
 	}
 
+	noteCollectionError(ctx, "specs.gpu", "lspci", err)
 	return ""
 }
 
@@ -66,7 +68,7 @@ func getLocalIP() string {
 }
 
 // getPhysicalRAM returns total physical RAM in bytes from /proc/meminfo
-func getPhysicalRAM() uint64 {
+func getPhysicalRAM(ctx context.Context) uint64 {
 	data, err := os.ReadFile("/proc/meminfo")
 	if err != nil {
 		return 0
@@ -89,9 +91,9 @@ func getPhysicalRAM() uint64 {
 }
 
 // getCPUTemperature returns CPU temperature on Linux
-func getCPUTemperature() *float64 {
+func getCPUTemperature(ctx context.Context) *float64 {
 	// Try gopsutil sensors first
-	temps, err := host.SensorsTemperatures()
+	temps, err := host.SensorsTemperaturesWithContext(ctx)
 	if err == nil {
 		for _, temp := range temps {
 			sensorKey := strings.ToLower(temp.SensorKey)
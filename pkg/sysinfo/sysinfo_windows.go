@@ -3,6 +3,7 @@
 package sysinfo
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"os/exec"
@@ -13,12 +14,13 @@ import (
 )
 
 // getGPUInfo returns GPU information on Windows
-func getGPUInfo() string {
+func getGPUInfo(ctx context.Context) string {
 	// Use PowerShell to get GPU info (more reliable than WMIC)
-	cmd := exec.Command("powershell", "-NoProfile", "-Command",
+	cmd := exec.CommandContext(ctx, "powershell", "-NoProfile", "-Command",
 		"(Get-CimInstance -ClassName Win32_VideoController).Name")
 	output, err := cmd.Output()
 	if err != nil {
+		noteCollectionError(ctx, "specs.gpu", "Win32_VideoController", err)
 		return ""
 	}
 
@@ -34,9 +36,9 @@ func getGPUInfo() string {
 }
 
 // getPhysicalRAM returns total physical RAM in bytes using PowerShell
-func getPhysicalRAM() uint64 {
+func getPhysicalRAM(ctx context.Context) uint64 {
 	// Use PowerShell to get total physical memory from memory chips
-	cmd := exec.Command("powershell", "-NoProfile", "-Command",
+	cmd := exec.CommandContext(ctx, "powershell", "-NoProfile", "-Command",
 		"(Get-CimInstance -ClassName Win32_PhysicalMemory | Measure-Object -Property Capacity -Sum).Sum")
 	output, err := cmd.Output()
 	if err != nil {
@@ -77,14 +79,14 @@ func getLocalIP() string {
 var tempLoggedOnce bool
 
 // getCPUTemperature returns CPU temperature on Windows
-func getCPUTemperature() *float64 {
+func getCPUTemperature(ctx context.Context) *float64 {
 	shouldLog := !tempLoggedOnce
 	if shouldLog {
 		tempLoggedOnce = true
 	}
 
 	// Try gopsutil sensors first
-	temps, err := host.SensorsTemperatures()
+	temps, err := host.SensorsTemperaturesWithContext(ctx)
 	if err == nil && len(temps) > 0 {
 		if shouldLog {
 			fmt.Printf("[Temp] gopsutil found %d sensors\n", len(temps))
@@ -119,7 +121,7 @@ func getCPUTemperature() *float64 {
 	if shouldLog {
 		fmt.Println("[Temp] Trying WMI MSAcpi_ThermalZoneTemperature...")
 	}
-	cmd := exec.Command("powershell", "-NoProfile", "-Command",
+	cmd := exec.CommandContext(ctx, "powershell", "-NoProfile", "-Command",
 		`Get-CimInstance -Namespace "root/WMI" -ClassName MSAcpi_ThermalZoneTemperature -ErrorAction SilentlyContinue | Select-Object -First 1 -ExpandProperty CurrentTemperature`)
 	output, err := cmd.Output()
 	if err == nil {
@@ -145,7 +147,7 @@ func getCPUTemperature() *float64 {
 	if shouldLog {
 		fmt.Println("[Temp] Trying OpenHardwareMonitor WMI...")
 	}
-	cmd = exec.Command("powershell", "-NoProfile", "-Command",
+	cmd = exec.CommandContext(ctx, "powershell", "-NoProfile", "-Command",
 		`Get-CimInstance -Namespace "root/OpenHardwareMonitor" -ClassName Sensor -ErrorAction SilentlyContinue | Where-Object { $_.SensorType -eq 'Temperature' -and $_.Name -like '*CPU*' } | Select-Object -First 1 -ExpandProperty Value`)
 	output, err = cmd.Output()
 	if err == nil {
@@ -167,7 +169,7 @@ func getCPUTemperature() *float64 {
 	if shouldLog {
 		fmt.Println("[Temp] Trying LibreHardwareMonitor WMI...")
 	}
-	cmd = exec.Command("powershell", "-NoProfile", "-Command",
+	cmd = exec.CommandContext(ctx, "powershell", "-NoProfile", "-Command",
 		`Get-CimInstance -Namespace "root/LibreHardwareMonitor" -ClassName Sensor -ErrorAction SilentlyContinue | Where-Object { $_.SensorType -eq 'Temperature' -and $_.Name -like '*CPU*' } | Select-Object -First 1 -ExpandProperty Value`)
 	output, err = cmd.Output()
 	if err == nil {
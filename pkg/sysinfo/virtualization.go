@@ -0,0 +1,61 @@
+package sysinfo
+
+import (
+	"sync"
+
+	"github.com/shirou/gopsutil/v3/host"
+)
+
+// Virtualization types
+const (
+	VirtualizationPhysical  = "physical"
+	VirtualizationVM        = "vm"
+	VirtualizationContainer = "container"
+)
+
+// VirtualizationInfo describes whether the device is bare metal, a VM or a container
+type VirtualizationInfo struct {
+	Type             string `json:"type"`                        // physical, vm, container
+	System           string `json:"system,omitempty"`            // hypervisor, e.g. "kvm", "vmware", "hyperv"
+	Role             string `json:"role,omitempty"`              // "guest" or "host"
+	ContainerRuntime string `json:"container_runtime,omitempty"` // e.g. "docker", "podman", "lxc"
+}
+
+var (
+	containerOnce    sync.Once
+	containerRuntime string
+)
+
+// ContainerRuntime returns the detected container runtime, or "" when not in a container.
+// Detection runs once per process.
+func ContainerRuntime() string {
+	containerOnce.Do(func() {
+		containerRuntime = detectContainer()
+	})
+	return containerRuntime
+}
+
+// IsContainer reports whether the agent runs inside a container
+func IsContainer() bool {
+	return ContainerRuntime() != ""
+}
+
+// collectVirtualization classifies the environment from host info and container detection
+func collectVirtualization(hostInfo *host.InfoStat) *VirtualizationInfo {
+	v := &VirtualizationInfo{Type: VirtualizationPhysical}
+
+	if hostInfo != nil {
+		v.System = hostInfo.VirtualizationSystem
+		v.Role = hostInfo.VirtualizationRole
+		if v.Role == "guest" {
+			v.Type = VirtualizationVM
+		}
+	}
+
+	if rt := ContainerRuntime(); rt != "" {
+		v.Type = VirtualizationContainer
+		v.ContainerRuntime = rt
+	}
+
+	return v
+}
@@ -0,0 +1,49 @@
+//go:build linux
+
+package sysinfo
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// detectContainer returns the container runtime if the agent runs inside a container
+func detectContainer() string {
+	if _, err := os.Stat("/.dockerenv"); err == nil {
+		return "docker"
+	}
+	if _, err := os.Stat("/run/.containerenv"); err == nil {
+		return "podman"
+	}
+
+	// Set by systemd-nspawn, LXC and others
+	if c := os.Getenv("container"); c != "" {
+		return c
+	}
+
+	// cgroup paths of PID 1 name the container manager
+	if data, err := os.ReadFile("/proc/1/cgroup"); err == nil {
+		content := string(data)
+		switch {
+		case strings.Contains(content, "kubepods"):
+			return "kubernetes"
+		case strings.Contains(content, "docker"):
+			return "docker"
+		case strings.Contains(content, "containerd"):
+			return "containerd"
+		case strings.Contains(content, "lxc"):
+			return "lxc"
+		case strings.Contains(content, "libpod"):
+			return "podman"
+		}
+	}
+
+	if output, err := exec.Command("systemd-detect-virt", "--container").Output(); err == nil {
+		if virt := strings.TrimSpace(string(output)); virt != "" && virt != "none" {
+			return virt
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package sysinfo
+
+// detectContainer returns the container runtime if the agent runs inside a container.
+// Containers are only detected on Linux.
+func detectContainer() string {
+	return ""
+}
@@ -0,0 +1,200 @@
+package sysinfo
+
+import (
+	"context"
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DriveWear is the SSD wear reported by a drive's SMART data. Both values
+// are percentages; either may be absent when the drive doesn't report it.
+type DriveWear struct {
+	Device            string   `json:"device"`
+	Model             string   `json:"model,omitempty"`
+	WearPercent       *float64 `json:"wear_percent,omitempty"`       // rated endurance used
+	LifetimeRemaining *float64 `json:"lifetime_remaining,omitempty"` // rated endurance left
+}
+
+// Wear changes slowly and querying SMART data is comparatively expensive,
+// so results are cached between metrics cycles
+const (
+	driveWearRefresh = time.Hour
+	smartCmdTimeout  = 10 * time.Second
+)
+
+var (
+	driveWearMu      sync.Mutex
+	driveWearCache   []DriveWear
+	driveWearUpdated time.Time
+)
+
+// collectDriveWear returns wear for all drives that report it, using
+// smartctl or, on Linux, nvme-cli. Returns nil when neither tool is
+// available or the agent lacks permission to query the drives.
+func collectDriveWear(ctx context.Context) []DriveWear {
+	driveWearMu.Lock()
+	defer driveWearMu.Unlock()
+
+	if !driveWearUpdated.IsZero() && time.Since(driveWearUpdated) < driveWearRefresh {
+		return driveWearCache
+	}
+
+	var drives []DriveWear
+	if _, err := exec.LookPath("smartctl"); err == nil {
+		drives = smartctlDriveWear(ctx)
+	} else if _, err := exec.LookPath("nvme"); err == nil {
+		drives = nvmeDriveWear(ctx)
+	}
+
+	if ctx.Err() != nil {
+		return driveWearCache // interrupted - keep the previous results
+	}
+	driveWearCache = drives
+	driveWearUpdated = time.Now()
+	return drives
+}
+
+// smartJSON runs a command expected to print JSON. smartctl uses its exit
+// code as a status bitmask, so output is parsed regardless of the exit code.
+func smartJSON(ctx context.Context, v interface{}, name string, args ...string) bool {
+	ctx, cancel := context.WithTimeout(ctx, smartCmdTimeout)
+	defer cancel()
+
+	out, _ := exec.CommandContext(ctx, name, args...).Output()
+	return len(out) > 0 && json.Unmarshal(out, v) == nil
+}
+
+// smartctlDriveWear reads wear from every drive smartctl can scan
+func smartctlDriveWear(ctx context.Context) []DriveWear {
+	var scan struct {
+		Devices []struct {
+			Name string `json:"name"`
+			Type string `json:"type"`
+		} `json:"devices"`
+	}
+	if !smartJSON(ctx, &scan, "smartctl", "--scan", "-j") {
+		return nil
+	}
+
+	var drives []DriveWear
+	for _, dev := range scan.Devices {
+		var info struct {
+			ModelName  string `json:"model_name"`
+			NVMeHealth *struct {
+				PercentageUsed *float64 `json:"percentage_used"`
+			} `json:"nvme_smart_health_information_log"`
+			ATAAttributes *struct {
+				Table []struct {
+					ID    int     `json:"id"`
+					Name  string  `json:"name"`
+					Value float64 `json:"value"`
+				} `json:"table"`
+			} `json:"ata_smart_attributes"`
+		}
+		if !smartJSON(ctx, &info, "smartctl", "-j", "-i", "-A", "-d", dev.Type, dev.Name) {
+			continue
+		}
+
+		drive := DriveWear{Device: dev.Name, Model: info.ModelName}
+		switch {
+		case info.NVMeHealth != nil && info.NVMeHealth.PercentageUsed != nil:
+			drive.setWear(*info.NVMeHealth.PercentageUsed)
+		case info.ATAAttributes != nil:
+			// SATA SSDs report remaining life as the normalized value of a
+			// vendor-specific attribute
+			for _, attr := range info.ATAAttributes.Table {
+				if ataLifetimeAttributes[attr.ID] == attr.Name {
+					drive.setWear(100 - attr.Value)
+					break
+				}
+			}
+		}
+
+		if drive.WearPercent != nil {
+			drives = append(drives, drive)
+		}
+	}
+	return drives
+}
+
+// ataLifetimeAttributes maps SMART attribute IDs to the names of the SSD
+// remaining-life attributes whose normalized value is percent remaining
+var ataLifetimeAttributes = map[int]string{
+	169: "Remaining_Lifetime_Perc",
+	177: "Wear_Leveling_Count",
+	202: "Percent_Lifetime_Remain",
+	231: "SSD_Life_Left",
+	233: "Media_Wearout_Indicator",
+}
+
+// nvmeDriveWear reads wear from NVMe drives with nvme-cli (Linux)
+func nvmeDriveWear(ctx context.Context) []DriveWear {
+	var list struct {
+		Devices []struct {
+			DevicePath  string `json:"DevicePath"`
+			ModelNumber string `json:"ModelNumber"`
+		} `json:"Devices"`
+	}
+	if !smartJSON(ctx, &list, "nvme", "list", "-o", "json") {
+		return nil
+	}
+
+	var drives []DriveWear
+	for _, dev := range list.Devices {
+		// The field name differs between nvme-cli versions
+		var log struct {
+			PercentUsed    *float64 `json:"percent_used"`
+			PercentageUsed *float64 `json:"percentage_used"`
+		}
+		if !smartJSON(ctx, &log, "nvme", "smart-log", "-o", "json", dev.DevicePath) {
+			continue
+		}
+
+		drive := DriveWear{Device: dev.DevicePath, Model: strings.TrimSpace(dev.ModelNumber)}
+		if log.PercentUsed != nil {
+			drive.setWear(*log.PercentUsed)
+		} else if log.PercentageUsed != nil {
+			drive.setWear(*log.PercentageUsed)
+		} else {
+			continue
+		}
+		drives = append(drives, drive)
+	}
+	return drives
+}
+
+// setWear records the used endurance percentage. NVMe allows values above
+// 100 once the rated endurance is exceeded; remaining life stops at zero.
+func (d *DriveWear) setWear(used float64) {
+	remaining := 100 - used
+	if remaining < 0 {
+		remaining = 0
+	}
+	d.WearPercent = &used
+	d.LifetimeRemaining = &remaining
+}
+
+// driveForDevice finds the drive holding a volume's device, e.g. drive
+// /dev/nvme0 for /dev/nvme0n1p2 or /dev/sda for /dev/sda1
+func driveForDevice(drives []DriveWear, device string) *DriveWear {
+	for i := range drives {
+		name := drives[i].Device
+		if !strings.HasPrefix(device, name) {
+			continue
+		}
+		rest := device[len(name):]
+		// /dev/nvme1 must not match /dev/nvme10n1
+		if rest != "" && isDigit(name[len(name)-1]) && isDigit(rest[0]) {
+			continue
+		}
+		return &drives[i]
+	}
+	return nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}